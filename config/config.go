@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -59,6 +61,17 @@ func LoadConfig(path string) IConfig {
 				return f
 			}(),
 			gcpBucket: envMap["APP_GCP_BUCKET"],
+			shippingFee: func() float64 {
+				// ไม่ได้กำหนดไว้ = ส่งฟรี
+				if envMap["APP_SHIPPING_FEE"] == "" {
+					return 0
+				}
+				f, err := strconv.ParseFloat(envMap["APP_SHIPPING_FEE"], 64)
+				if err != nil {
+					log.Fatalf("load shipping fee failed: %v", err)
+				}
+				return f
+			}(),
 		},
 		db: &db{
 			host: envMap["DB_HOST"],
@@ -86,6 +99,32 @@ func LoadConfig(path string) IConfig {
 			adminKey:  envMap["JWT_ADMIN_KEY"],
 			secretKey: envMap["JWT_SECRET_KEY"],
 			apiKey:    envMap["JWT_API_KEY"],
+			privateKey: func() []byte {
+				// ไม่ได้กำหนดไว้ = sign ด้วย HS256 แบบเดิม
+				if envMap["JWT_PRIVATE_KEY_FILE"] == "" {
+					return nil
+				}
+				b, err := os.ReadFile(envMap["JWT_PRIVATE_KEY_FILE"])
+				if err != nil {
+					log.Fatalf("load jwt private key failed: %v", err)
+				}
+				return b
+			}(),
+			publicKeys: func() [][]byte {
+				// public key ของ private key ตัวเก่า ยังใช้ verify token ที่ออกไปแล้วได้จนกว่าจะหมดอายุ
+				keys := make([][]byte, 0)
+				for _, path := range strings.Split(envMap["JWT_PUBLIC_KEY_FILES"], ",") {
+					if path = strings.TrimSpace(path); path == "" {
+						continue
+					}
+					b, err := os.ReadFile(path)
+					if err != nil {
+						log.Fatalf("load jwt public key failed: %v", err)
+					}
+					keys = append(keys, b)
+				}
+				return keys
+			}(),
 			accessExpiresAt: func() int {
 				t, err := strconv.Atoi(envMap["JWT_ACCESS_EXPIRES"])
 				if err != nil {
@@ -101,6 +140,103 @@ func LoadConfig(path string) IConfig {
 				return t
 			}(),
 		},
+		storage: &storage{
+			driver: func() string {
+				// ไม่ได้กำหนดไว้ = ใช้ google cloud storage แบบเดิม
+				if envMap["STORAGE_DRIVER"] == "" {
+					return "gcs"
+				}
+				return envMap["STORAGE_DRIVER"]
+			}(),
+			localPath: func() string {
+				if envMap["STORAGE_LOCAL_PATH"] == "" {
+					return "./storage"
+				}
+				return envMap["STORAGE_LOCAL_PATH"]
+			}(),
+			localUrl:    envMap["STORAGE_LOCAL_URL"],
+			s3Endpoint:  envMap["S3_ENDPOINT"],
+			s3Region:    envMap["S3_REGION"],
+			s3Bucket:    envMap["S3_BUCKET"],
+			s3AccessKey: envMap["S3_ACCESS_KEY"],
+			s3SecretKey: envMap["S3_SECRET_KEY"],
+			s3PublicUrl: envMap["S3_PUBLIC_URL"],
+			s3PathStyle: envMap["S3_PATH_STYLE"] == "true",
+			signingKey: func() string {
+				// local storage เซ็น url เอง ต้องมี key ของตัวเอง (ไม่ใช้ร่วมกับ jwt)
+				if envMap["STORAGE_DRIVER"] == "local" && envMap["STORAGE_SIGNING_KEY"] == "" {
+					log.Fatalf("load storage signing key failed: STORAGE_SIGNING_KEY is required for local storage")
+				}
+				return envMap["STORAGE_SIGNING_KEY"]
+			}(),
+		},
+		payment: &payment{
+			promptPayId:            envMap["PROMPTPAY_ID"],
+			promptPayWebhookSecret: envMap["PROMPTPAY_WEBHOOK_SECRET"],
+			cardGatewayUrl:         envMap["CARD_GATEWAY_URL"],
+			cardSecretKey:          envMap["CARD_GATEWAY_SECRET_KEY"],
+			cardWebhookSecret:      envMap["CARD_GATEWAY_WEBHOOK_SECRET"],
+			returnUrl:              envMap["PAYMENT_RETURN_URL"],
+		},
+		mail: &mail{
+			driver: func() string {
+				// ไม่ได้กำหนดไว้ = เขียน email เป็นไฟล์ (dev)
+				if envMap["MAIL_DRIVER"] == "" {
+					return "file"
+				}
+				return envMap["MAIL_DRIVER"]
+			}(),
+			smtpHost: envMap["SMTP_HOST"],
+			smtpPort: func() int {
+				if envMap["SMTP_PORT"] == "" {
+					return 587
+				}
+				p, err := strconv.Atoi(envMap["SMTP_PORT"])
+				if err != nil {
+					log.Fatalf("load smtp port failed: %v", err)
+				}
+				return p
+			}(),
+			smtpUsername: envMap["SMTP_USERNAME"],
+			smtpPassword: envMap["SMTP_PASSWORD"],
+			from:         envMap["MAIL_FROM"],
+			filePath: func() string {
+				if envMap["MAIL_FILE_PATH"] == "" {
+					return "./mails"
+				}
+				return envMap["MAIL_FILE_PATH"]
+			}(),
+			linkUrl: envMap["MAIL_LINK_URL"],
+		},
+		auth: &auth{
+			adminTotpRequired: envMap["AUTH_ADMIN_TOTP_REQUIRED"] == "true",
+			// ปิดไว้เป็นค่าเริ่มต้น api key แบบ jwt ที่หลุดไป revoke ไม่ได้
+			// ย้าย client : สร้าง key ใหม่ที่ POST /appinfo/apikeys -> เปลี่ยน X-Api-Key ของ client
+			// -> เอา AUTH_LEGACY_API_KEY=true ออก key แบบ jwt ทุกอันจะใช้ไม่ได้ทันที
+			legacyApiKey: envMap["AUTH_LEGACY_API_KEY"] == "true",
+			lockoutDriver: func() string {
+				if envMap["AUTH_LOCKOUT_DRIVER"] == "" {
+					return "postgres"
+				}
+				return envMap["AUTH_LOCKOUT_DRIVER"]
+			}(),
+			lockoutThreshold:   envInt(envMap, "AUTH_LOCKOUT_THRESHOLD", 5),
+			lockoutIpThreshold: envInt(envMap, "AUTH_LOCKOUT_IP_THRESHOLD", 20),
+			lockoutDuration:    envInt(envMap, "AUTH_LOCKOUT_DURATION", 30),
+			lockoutMaxDuration: envInt(envMap, "AUTH_LOCKOUT_MAX_DURATION", 3600),
+		},
+		oauth: &oauth{
+			redirectUrl:          envMap["OAUTH_REDIRECT_URL"],
+			googleClientId:       envMap["GOOGLE_CLIENT_ID"],
+			googleClientSecret:   envMap["GOOGLE_CLIENT_SECRET"],
+			lineClientId:         envMap["LINE_CHANNEL_ID"],
+			lineClientSecret:     envMap["LINE_CHANNEL_SECRET"],
+			facebookClientId:     envMap["FACEBOOK_APP_ID"],
+			facebookClientSecret: envMap["FACEBOOK_APP_SECRET"],
+			oidcIssuer:           envMap["OIDC_ISSUER"],
+			oidcClientId:         envMap["OIDC_CLIENT_ID"],
+			oidcClientSecret:     envMap["OIDC_CLIENT_SECRET"],
+		},
 	}
 }
 
@@ -108,12 +244,22 @@ type IConfig interface {
 	App() IAppConfig
 	Db() IDbConfig
 	Jwt() IJwtConfig
+	Storage() IStorageConfig
+	Payment() IPaymentConfig
+	Mail() IMailConfig
+	Auth() IAuthConfig
+	Oauth() IOauthConfig
 }
 
 type config struct {
-	app *app
-	db  *db
-	jwt *jwt
+	app     *app
+	db      *db
+	jwt     *jwt
+	storage *storage
+	payment *payment
+	mail    *mail
+	auth    *auth
+	oauth   *oauth
 }
 
 type IAppConfig interface {
@@ -125,6 +271,7 @@ type IAppConfig interface {
 	BodyLimit() int
 	FileLimit() int
 	GcpBucket() string
+	ShippingFee() float64
 }
 
 type app struct {
@@ -137,6 +284,7 @@ type app struct {
 	bodyLimit    int
 	fileLimit    int
 	gcpBucket    string
+	shippingFee  float64
 }
 
 func (c *config) App() IAppConfig {
@@ -153,6 +301,7 @@ func (a *app) WriteTimeout() time.Duration { return a.writeTimeout }
 func (a *app) BodyLimit() int              { return a.bodyLimit }
 func (a *app) FileLimit() int              { return a.fileLimit }
 func (a *app) GcpBucket() string           { return a.gcpBucket }
+func (a *app) ShippingFee() float64        { return a.shippingFee }
 
 type IDbConfig interface {
 	Url() string
@@ -184,6 +333,8 @@ type IJwtConfig interface {
 	SecretKey() []byte // เวลาเอาไปใช้งานใช้เป็น type byte
 	AdminKey() []byte
 	ApiKey() []byte
+	PrivateKey() []byte   // PEM ของ key ที่ใช้ sign (RSA หรือ Ed25519)
+	PublicKeys() [][]byte // PEM ของ key ที่ใช้ verify อย่างเดียว (ช่วง rotate key)
 	AccessExpireAt() int
 	RefreshExpireAt() int
 	SetJwtAccessExpire(t int)
@@ -194,6 +345,8 @@ type jwt struct {
 	adminKey         string
 	secretKey        string
 	apiKey           string
+	privateKey       []byte
+	publicKeys       [][]byte
 	accessExpiresAt  int
 	refreshExpiresAt int
 }
@@ -205,7 +358,204 @@ func (c *config) Jwt() IJwtConfig {
 func (j *jwt) SecretKey() []byte         { return []byte(j.secretKey) }
 func (j *jwt) AdminKey() []byte          { return []byte(j.adminKey) }
 func (j *jwt) ApiKey() []byte            { return []byte(j.apiKey) }
+func (j *jwt) PrivateKey() []byte        { return j.privateKey }
+func (j *jwt) PublicKeys() [][]byte      { return j.publicKeys }
 func (j *jwt) AccessExpireAt() int       { return j.accessExpiresAt }
 func (j *jwt) RefreshExpireAt() int      { return j.refreshExpiresAt }
 func (j *jwt) SetJwtAccessExpire(t int)  { j.accessExpiresAt = t }
 func (j *jwt) SetJwtRefreshExpire(t int) { j.refreshExpiresAt = t }
+
+type IStorageConfig interface {
+	Driver() string // gcs, local, s3
+	LocalPath() string
+	LocalUrl() string
+	S3Endpoint() string
+	S3Region() string
+	S3Bucket() string
+	S3AccessKey() string
+	S3SecretKey() string
+	S3PublicUrl() string
+	S3PathStyle() bool
+	SigningKey() []byte
+}
+
+type storage struct {
+	driver      string
+	localPath   string
+	localUrl    string // url ของ files module eg. http://localhost:3000/v1/files
+	s3Endpoint  string // eg. https://s3.ap-southeast-1.amazonaws.com, http://localhost:9000 (minio)
+	s3Region    string
+	s3Bucket    string
+	s3AccessKey string
+	s3SecretKey string
+	s3PublicUrl string // url ที่ใช้เข้าถึงไฟล์ใน bucket ถ้าไม่กำหนดจะใช้ endpoint
+	s3PathStyle bool   // minio ใช้ path style : endpoint/bucket/key
+	signingKey  string // ใช้เซ็น url ของ local storage
+}
+
+func (c *config) Storage() IStorageConfig {
+	return c.storage
+}
+
+func (s *storage) Driver() string      { return s.driver }
+func (s *storage) LocalPath() string   { return s.localPath }
+func (s *storage) LocalUrl() string    { return s.localUrl }
+func (s *storage) S3Endpoint() string  { return s.s3Endpoint }
+func (s *storage) S3Region() string    { return s.s3Region }
+func (s *storage) S3Bucket() string    { return s.s3Bucket }
+func (s *storage) S3AccessKey() string { return s.s3AccessKey }
+func (s *storage) S3SecretKey() string { return s.s3SecretKey }
+func (s *storage) S3PublicUrl() string { return s.s3PublicUrl }
+func (s *storage) S3PathStyle() bool   { return s.s3PathStyle }
+func (s *storage) SigningKey() []byte  { return []byte(s.signingKey) }
+
+type IPaymentConfig interface {
+	PromptPayId() string
+	PromptPayWebhookSecret() []byte
+	CardGatewayUrl() string
+	CardSecretKey() string
+	CardWebhookSecret() []byte
+	ReturnUrl() string
+}
+
+// payment : provider ที่ไม่ได้กำหนดค่าไว้จะไม่เปิดให้ใช้งาน
+type payment struct {
+	promptPayId            string // เบอร์โทร, เลขบัตรประชาชน/เลขผู้เสียภาษี หรือ e-wallet id
+	promptPayWebhookSecret string
+	cardGatewayUrl         string // eg. https://api.gateway.example
+	cardSecretKey          string
+	cardWebhookSecret      string
+	returnUrl              string // หน้าที่ gateway redirect กลับมาหลังจ่ายด้วยบัตร
+}
+
+func (c *config) Payment() IPaymentConfig {
+	return c.payment
+}
+
+func (p *payment) PromptPayId() string            { return p.promptPayId }
+func (p *payment) PromptPayWebhookSecret() []byte { return []byte(p.promptPayWebhookSecret) }
+func (p *payment) CardGatewayUrl() string         { return p.cardGatewayUrl }
+func (p *payment) CardSecretKey() string          { return p.cardSecretKey }
+func (p *payment) CardWebhookSecret() []byte      { return []byte(p.cardWebhookSecret) }
+func (p *payment) ReturnUrl() string              { return p.returnUrl }
+
+type IMailConfig interface {
+	Driver() string // smtp, file, memory
+	SmtpHost() string
+	SmtpPort() int
+	SmtpUsername() string
+	SmtpPassword() string
+	From() string
+	FilePath() string
+	LinkUrl() string
+}
+
+type mail struct {
+	driver       string
+	smtpHost     string
+	smtpPort     int
+	smtpUsername string // ว่าง = ไม่ต้อง auth
+	smtpPassword string
+	from         string // eg. K-Commerce <no-reply@example.com>
+	filePath     string // ที่เก็บไฟล์ .eml ของ file driver
+	linkUrl      string // url ของหน้าเว็บที่รับ token จาก email eg. https://shop.example.com
+}
+
+func (c *config) Mail() IMailConfig {
+	return c.mail
+}
+
+func (m *mail) Driver() string       { return m.driver }
+func (m *mail) SmtpHost() string     { return m.smtpHost }
+func (m *mail) SmtpPort() int        { return m.smtpPort }
+func (m *mail) SmtpUsername() string { return m.smtpUsername }
+func (m *mail) SmtpPassword() string { return m.smtpPassword }
+func (m *mail) From() string         { return m.from }
+func (m *mail) FilePath() string     { return m.filePath }
+func (m *mail) LinkUrl() string      { return m.linkUrl }
+
+type IAuthConfig interface {
+	AdminTotpRequired() bool
+	LegacyApiKey() bool
+	LockoutDriver() string
+	LockoutThreshold() int
+	LockoutIpThreshold() int
+	LockoutDuration() int
+	LockoutMaxDuration() int
+}
+
+type auth struct {
+	adminTotpRequired  bool   // admin และ staff (role ที่มี permission :any) ต้องใช้ TOTP ตอน sign in คนที่ยังไม่ได้ตั้งค่าต้องตั้งค่าก่อนได้ passport
+	legacyApiKey       bool   // deprecated : เปิดชั่วคราวระหว่างย้าย client ไป POST /appinfo/apikeys รับ api key แบบ jwt และ GET /appinfo/apikey
+	lockoutDriver      string // postgres, memory (instance เดียว)
+	lockoutThreshold   int    // sign in ผิดได้กี่ครั้งต่อบัญชีก่อนถูก lock
+	lockoutIpThreshold int    // sign in ผิดได้กี่ครั้งต่อ ip ก่อนถูก lock
+	lockoutDuration    int    // lock ครั้งแรก (วินาที) ผิดต่อเพิ่มเป็น 2 เท่า
+	lockoutMaxDuration int    // lock นานสุด (วินาที) และเป็นช่วงที่นับจำนวนครั้งที่ผิด
+}
+
+func (c *config) Auth() IAuthConfig {
+	return c.auth
+}
+
+func (a *auth) AdminTotpRequired() bool { return a.adminTotpRequired }
+func (a *auth) LegacyApiKey() bool      { return a.legacyApiKey }
+func (a *auth) LockoutDriver() string   { return a.lockoutDriver }
+func (a *auth) LockoutThreshold() int   { return a.lockoutThreshold }
+func (a *auth) LockoutIpThreshold() int { return a.lockoutIpThreshold }
+func (a *auth) LockoutDuration() int    { return a.lockoutDuration }
+func (a *auth) LockoutMaxDuration() int { return a.lockoutMaxDuration }
+
+type IOauthConfig interface {
+	RedirectUrl() string
+	GoogleClientId() string
+	GoogleClientSecret() string
+	LineClientId() string
+	LineClientSecret() string
+	FacebookClientId() string
+	FacebookClientSecret() string
+	OidcIssuer() string
+	OidcClientId() string
+	OidcClientSecret() string
+}
+
+// oauth : provider ที่ไม่ได้กำหนด client id ไว้จะไม่เปิดให้ใช้งาน
+type oauth struct {
+	redirectUrl          string // หน้า callback ของ frontend redirect uri ของแต่ละ provider คือ <redirectUrl>/<provider>
+	googleClientId       string
+	googleClientSecret   string
+	lineClientId         string // LINE Login channel id
+	lineClientSecret     string
+	facebookClientId     string // app id
+	facebookClientSecret string
+	oidcIssuer           string // OpenID provider อื่น (eg. mock server ตอน dev)
+	oidcClientId         string
+	oidcClientSecret     string
+}
+
+func (c *config) Oauth() IOauthConfig {
+	return c.oauth
+}
+
+func (o *oauth) RedirectUrl() string          { return o.redirectUrl }
+func (o *oauth) GoogleClientId() string       { return o.googleClientId }
+func (o *oauth) GoogleClientSecret() string   { return o.googleClientSecret }
+func (o *oauth) LineClientId() string         { return o.lineClientId }
+func (o *oauth) LineClientSecret() string     { return o.lineClientSecret }
+func (o *oauth) FacebookClientId() string     { return o.facebookClientId }
+func (o *oauth) FacebookClientSecret() string { return o.facebookClientSecret }
+func (o *oauth) OidcIssuer() string           { return o.oidcIssuer }
+func (o *oauth) OidcClientId() string         { return o.oidcClientId }
+func (o *oauth) OidcClientSecret() string     { return o.oidcClientSecret }
+
+// envInt : ค่าตัวเลขที่ไม่ได้กำหนดไว้ใช้ค่า default
+func envInt(envMap map[string]string, key string, defaultValue int) int {
+	if envMap[key] == "" {
+		return defaultValue
+	}
+	v, err := strconv.Atoi(envMap[key])
+	if err != nil {
+		log.Fatalf("load %s failed: %v", strings.ToLower(key), err)
+	}
+	return v
+}
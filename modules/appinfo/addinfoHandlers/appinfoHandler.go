@@ -8,6 +8,7 @@ import (
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/appinfo"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/appinfo/appinfoUsecases"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares/middlewaresHandlers"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
 	"github.com/gofiber/fiber/v2"
 )
@@ -19,27 +20,91 @@ const (
 	findCategoryErr   appinfoHandlerErrCode = "appinfo-002"
 	addCategoryErr    appinfoHandlerErrCode = "appinfo-003"
 	removeCategoryErr appinfoHandlerErrCode = "appinfo-004"
+	findApiKeyErr     appinfoHandlerErrCode = "appinfo-005"
+	findOneApiKeyErr  appinfoHandlerErrCode = "appinfo-006"
+	updateApiKeyErr   appinfoHandlerErrCode = "appinfo-007"
+	revokeApiKeyErr   appinfoHandlerErrCode = "appinfo-008"
 )
 
 type IAppinfoHandler interface {
 	GenerateApiKey(c *fiber.Ctx) error
+	GenerateLegacyApiKey(c *fiber.Ctx) error
 	FindCategory(c *fiber.Ctx) error
 	AddCategory(c *fiber.Ctx) error
 	RemoveCategory(c *fiber.Ctx) error
+	FindApiKey(c *fiber.Ctx) error
+	FindOneApiKey(c *fiber.Ctx) error
+	UpdateApiKey(c *fiber.Ctx) error
+	RevokeApiKey(c *fiber.Ctx) error
 }
 
 type appinfoHandler struct {
 	cfg            config.IConfig
 	appinfoUsecase appinfoUsecases.IAppinfoUsecase
+	middleware     middlewaresHandlers.IMiddlewaresHandler
 }
 
-func AppinfoHandler(cfg config.IConfig, appinfoUsecase appinfoUsecases.IAppinfoUsecase) IAppinfoHandler {
+func AppinfoHandler(cfg config.IConfig, appinfoUsecase appinfoUsecases.IAppinfoUsecase, middleware middlewaresHandlers.IMiddlewaresHandler) IAppinfoHandler {
 	return &appinfoHandler{
 		cfg:            cfg,
-		appinfoUsecase: appinfoUsecase}
+		appinfoUsecase: appinfoUsecase,
+		middleware:     middleware,
+	}
 }
 
+// GenerateApiKey : สร้าง key ใน api_keys ตัว key จริงอยู่ใน response นี้ครั้งเดียว
 func (h *appinfoHandler) GenerateApiKey(c *fiber.Ctx) error {
+	req := new(appinfo.ApiKeyReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(generateApiKeyErr),
+			err.Error(),
+		).Res()
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(generateApiKeyErr),
+			"name is required",
+		).Res()
+	}
+	scopes, err := appinfo.CheckScopes(req.Scopes)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(generateApiKeyErr),
+			err.Error(),
+		).Res()
+	}
+	req.Scopes = scopes
+	if req.OwnerId = strings.TrimSpace(req.OwnerId); req.OwnerId == "" {
+		req.OwnerId = c.Locals("userId").(string)
+	}
+
+	apiKey, err := h.appinfoUsecase.InsertApiKey(req)
+	if err != nil {
+		switch err.Error() {
+		case "owner not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(generateApiKeyErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(generateApiKeyErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, apiKey).Res()
+}
+
+// GenerateLegacyApiKey : deprecated api key แบบ jwt revoke ไม่ได้ ใช้ได้เฉพาะตอนเปิด AUTH_LEGACY_API_KEY
+func (h *appinfoHandler) GenerateLegacyApiKey(c *fiber.Ctx) error {
 	apiKey, err := authentication.NewAuthentication(
 		authentication.ApiKey,
 		h.cfg.Jwt(),
@@ -52,6 +117,7 @@ func (h *appinfoHandler) GenerateApiKey(c *fiber.Ctx) error {
 			err.Error(),
 		).Res()
 	}
+	c.Set("Deprecation", "true")
 	return entities.NewResponse(c).Success(
 		fiber.StatusOK,
 		&struct {
@@ -103,6 +169,13 @@ func (h *appinfoHandler) AddCategory(c *fiber.Ctx) error {
 	}
 
 	if err := h.appinfoUsecase.InsertCategory(req); err != nil {
+		if err.Error() == "parent category not found" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(addCategoryErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(addCategoryErr),
@@ -147,3 +220,116 @@ func (h *appinfoHandler) RemoveCategory(c *fiber.Ctx) error {
 		},
 	).Res()
 }
+
+func (h *appinfoHandler) FindApiKey(c *fiber.Ctx) error {
+	apiKeys, err := h.appinfoUsecase.FindApiKey()
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findApiKeyErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, apiKeys).Res()
+}
+
+func (h *appinfoHandler) FindOneApiKey(c *fiber.Ctx) error {
+	apiKeyId := strings.Trim(c.Params("api_key_id"), " ")
+
+	apiKey, err := h.appinfoUsecase.FindOneApiKey(apiKeyId)
+	if err != nil {
+		switch err.Error() {
+		case "api key not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findOneApiKeyErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(findOneApiKeyErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, apiKey).Res()
+}
+
+func (h *appinfoHandler) UpdateApiKey(c *fiber.Ctx) error {
+	req := new(appinfo.ApiKeyUpdateReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateApiKeyErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("api_key_id"), " ")
+
+	if req.Name != nil {
+		if *req.Name = strings.TrimSpace(*req.Name); *req.Name == "" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateApiKeyErr),
+				"name is required",
+			).Res()
+		}
+	}
+	if req.Scopes != nil {
+		scopes, err := appinfo.CheckScopes(*req.Scopes)
+		if err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateApiKeyErr),
+				err.Error(),
+			).Res()
+		}
+		req.Scopes = &scopes
+	}
+
+	apiKey, err := h.appinfoUsecase.UpdateApiKey(req)
+	h.middleware.ForgetApiKey(req.Id)
+	if err != nil {
+		switch err.Error() {
+		case "api key not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateApiKeyErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateApiKeyErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, apiKey).Res()
+}
+
+// RevokeApiKey : ล้าง cache ของ middleware ทันที instance อื่นจะใช้ key ไม่ได้ภายใน 1 นาที
+func (h *appinfoHandler) RevokeApiKey(c *fiber.Ctx) error {
+	apiKeyId := strings.Trim(c.Params("api_key_id"), " ")
+
+	apiKey, err := h.appinfoUsecase.RevokeApiKey(apiKeyId)
+	h.middleware.ForgetApiKey(apiKeyId)
+	if err != nil {
+		switch err.Error() {
+		case "api key not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(revokeApiKeyErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(revokeApiKeyErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, apiKey).Res()
+}
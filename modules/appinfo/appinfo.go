@@ -1,10 +1,111 @@
 package appinfo
 
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
+)
+
 type CategoryFilter struct {
 	Title string `query:"title"` // รองรับ query params
+	Id    int    `query:"id"`    // หา subtree ที่มี category นี้เป็น root
+	Tree  bool   `query:"tree"`
 }
 
 type Category struct {
-	Id    int    `db:"id" json:"id"`
-	Title string `db:"title" json:"title"`
+	Id       int         `db:"id" json:"id"`
+	Title    string      `db:"title" json:"title"`
+	ParentId *int        `db:"parent_id" json:"parent_id"`
+	Children []*Category `db:"-" json:"children,omitempty"`
+}
+
+// BuildCategoryTree : category ที่ไม่มี parent อยู่ใน list จะเป็น root ของ tree
+func BuildCategoryTree(categories []*Category) []*Category {
+	categoryMap := make(map[int]*Category)
+	for _, category := range categories {
+		category.Children = make([]*Category, 0)
+		categoryMap[category.Id] = category
+	}
+
+	roots := make([]*Category, 0)
+	for _, category := range categories {
+		if category.ParentId != nil {
+			if parent, ok := categoryMap[*category.ParentId]; ok {
+				parent.Children = append(parent.Children, category)
+				continue
+			}
+		}
+		roots = append(roots, category)
+	}
+	return roots
+}
+
+type ApiKey struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	OwnerId    *string  `json:"owner_id"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	Key        string   `json:"key,omitempty"` // มีเฉพาะตอนสร้าง
+	LastUsedAt *string  `json:"last_used_at"`
+	RevokedAt  *string  `json:"revoked_at"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+type ApiKeyReq struct {
+	Name    string   `json:"name"`
+	OwnerId string   `json:"owner_id"` // ว่าง = admin ที่สร้าง
+	Scopes  []string `json:"scopes"`
+}
+
+// ApiKeyUpdateReq : field ที่เป็น nil จะไม่ถูก update
+type ApiKeyUpdateReq struct {
+	Id     string    `json:"-"`
+	Name   *string   `json:"name"`
+	Scopes *[]string `json:"scopes"`
+}
+
+const apiKeyPrefix = "kc_"
+
+// NewApiKey : random 32 bytes ไม่ใช่ jwt จึง revoke ได้ด้วยการลบ hash ออกจาก database
+// prefix คือส่วนต้นของ key ที่เก็บไว้ให้ดูว่าเป็น key ไหนโดยไม่ต้องเก็บ key จริง
+func NewApiKey() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate api key failed: %v", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:len(apiKeyPrefix)+8], nil
+}
+
+// CheckScopes : ตัด scope ซ้ำและตรวจว่ามีอยู่จริง ต้องมีอย่างน้อย 1 scope
+func CheckScopes(scopes []string) ([]string, error) {
+	result := make([]string, 0, len(scopes))
+	seen := make(map[string]bool)
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if seen[scope] {
+			continue
+		}
+		valid := false
+		for _, s := range middlewares.Scopes {
+			if s == scope {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("scope %s is invalid", scope)
+		}
+		seen[scope] = true
+		result = append(result, scope)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("scopes are required")
+	}
+	return result, nil
 }
@@ -2,10 +2,13 @@ package appinfoRepositories
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/appinfo"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -13,6 +16,11 @@ type IAppinfoRepository interface {
 	FindCategory(req *appinfo.CategoryFilter) ([]*appinfo.Category, error)
 	InsertCategory(req []*appinfo.Category) error
 	DeleteCategory(categoryId int) error
+	FindApiKey() ([]*appinfo.ApiKey, error)
+	FindOneApiKey(apiKeyId string) (*appinfo.ApiKey, error)
+	InsertApiKey(req *appinfo.ApiKeyReq, prefix, key string) (string, error)
+	UpdateApiKey(req *appinfo.ApiKeyUpdateReq) error
+	RevokeApiKey(apiKeyId string) error
 }
 
 type appinfoRepository struct {
@@ -29,16 +37,46 @@ func (r *appinfoRepository) FindCategory(req *appinfo.CategoryFilter) ([]*appinf
 	query := `
 	SELECT
 		"id",
-		"title"
+		"title",
+		"parent_id"
 	FROM "categories"`
 
 	filterValues := make([]any, 0)
-	if req.Title != "" {
-		query += `WHERE (LOWER("title") LIKE $1)`
+	if req.Id > 0 {
+		// ดึง category และ descendants ทั้งหมด
+		query = `
+	WITH RECURSIVE "ct" AS (
+		SELECT
+			"id",
+			"title",
+			"parent_id"
+		FROM "categories"
+		WHERE "id" = $1
+		UNION ALL
+		SELECT
+			"c"."id",
+			"c"."title",
+			"c"."parent_id"
+		FROM "categories" "c"
+		JOIN "ct"
+		ON "c"."parent_id" = "ct"."id"
+	)
+	SELECT
+		"id",
+		"title",
+		"parent_id"
+	FROM "ct"`
 
+		filterValues = append(filterValues, req.Id)
+	}
+	if req.Title != "" {
 		filterValues = append(filterValues, "%"+strings.ToLower(req.Title)+"%")
+
+		query += fmt.Sprintf(`
+	WHERE (LOWER("title") LIKE $%d)`, len(filterValues))
 	}
-	query += ";"
+	query += `
+	ORDER BY "id" ASC;`
 
 	category := make([]*appinfo.Category, 0)
 	if err := r.db.Select(&category, query, filterValues...); err != nil {
@@ -52,7 +90,8 @@ func (r *appinfoRepository) InsertCategory(req []*appinfo.Category) error {
 	query := `
 	INSERT INTO "categories"
 	(
-		"title"
+		"title",
+		"parent_id"
 	)
 	VALUES`
 
@@ -63,14 +102,14 @@ func (r *appinfoRepository) InsertCategory(req []*appinfo.Category) error {
 
 	valueStack := make([]any, 0)
 	for i, cat := range req {
-		valueStack = append(valueStack, cat.Title)
+		valueStack = append(valueStack, cat.Title, cat.ParentId)
 
 		if i != len(req)-1 {
 			query += fmt.Sprintf(`
-		($%d),`, i+1)
+		($%d, $%d),`, i*2+1, i*2+2)
 		} else {
 			query += fmt.Sprintf(`
-		($%d)`, i+1)
+		($%d, $%d)`, i*2+1, i*2+2)
 		}
 	}
 
@@ -80,6 +119,9 @@ func (r *appinfoRepository) InsertCategory(req []*appinfo.Category) error {
 	rows, err := tx.QueryxContext(ctx, query, valueStack...)
 	if err != nil {
 		tx.Rollback()
+		if strings.Contains(err.Error(), "categories_parent_id_fkey") {
+			return fmt.Errorf("parent category not found")
+		}
 		return fmt.Errorf("insert categories failed: %v", err)
 	}
 
@@ -91,6 +133,14 @@ func (r *appinfoRepository) InsertCategory(req []*appinfo.Category) error {
 		}
 		i++
 	}
+	// error จาก foreign key ของ parent_id อาจมาตอนอ่าน rows
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		if strings.Contains(err.Error(), "categories_parent_id_fkey") {
+			return fmt.Errorf("parent category not found")
+		}
+		return fmt.Errorf("insert categories failed: %v", err)
+	}
 
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
@@ -109,3 +159,139 @@ func (r *appinfoRepository) DeleteCategory(categoryId int) error {
 	}
 	return nil
 }
+
+const apiKeyColumns = `
+			"k"."id",
+			"k"."name",
+			"k"."owner_id",
+			"k"."prefix",
+			"k"."scopes",
+			"k"."last_used_at",
+			"k"."revoked_at",
+			"k"."created_at",
+			"k"."updated_at"`
+
+func (r *appinfoRepository) FindApiKey() ([]*appinfo.ApiKey, error) {
+	query := fmt.Sprintf(`
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT%s
+		FROM "api_keys" "k"
+		ORDER BY "k"."created_at" DESC
+	) AS "t";`, apiKeyColumns)
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query); err != nil {
+		return nil, fmt.Errorf("get api keys failed: %v", err)
+	}
+
+	apiKeys := make([]*appinfo.ApiKey, 0)
+	if err := json.Unmarshal(raw, &apiKeys); err != nil {
+		return nil, fmt.Errorf("unmarshal api keys failed: %v", err)
+	}
+	return apiKeys, nil
+}
+
+func (r *appinfoRepository) FindOneApiKey(apiKeyId string) (*appinfo.ApiKey, error) {
+	query := fmt.Sprintf(`
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT%s
+		FROM "api_keys" "k"
+		WHERE "k"."id" = $1
+	) AS "t";`, apiKeyColumns)
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, apiKeyId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("api key not found")
+		}
+		return nil, fmt.Errorf("get api key failed: %v", err)
+	}
+
+	apiKey := new(appinfo.ApiKey)
+	if err := json.Unmarshal(raw, apiKey); err != nil {
+		return nil, fmt.Errorf("unmarshal api key failed: %v", err)
+	}
+	return apiKey, nil
+}
+
+// InsertApiKey : เก็บเฉพาะ hash ของ key
+func (r *appinfoRepository) InsertApiKey(req *appinfo.ApiKeyReq, prefix, key string) (string, error) {
+	query := `
+	INSERT INTO "api_keys" (
+		"name",
+		"owner_id",
+		"prefix",
+		"key_hash",
+		"scopes"
+	)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING "id";`
+
+	var apiKeyId string
+	if err := r.db.QueryRowxContext(
+		context.Background(),
+		query,
+		req.Name,
+		req.OwnerId,
+		prefix,
+		authentication.HashToken(key),
+		req.Scopes,
+	).Scan(&apiKeyId); err != nil {
+		if strings.Contains(err.Error(), "api_keys_owner_id_fkey") {
+			return "", fmt.Errorf("owner not found")
+		}
+		return "", fmt.Errorf("insert api key failed: %v", err)
+	}
+	return apiKeyId, nil
+}
+
+func (r *appinfoRepository) UpdateApiKey(req *appinfo.ApiKeyUpdateReq) error {
+	sets := make([]string, 0)
+	values := make([]any, 0)
+	set := func(column string, value any) {
+		values = append(values, value)
+		sets = append(sets, fmt.Sprintf(`"%s" = $%d`, column, len(values)))
+	}
+	if req.Name != nil {
+		set("name", *req.Name)
+	}
+	if req.Scopes != nil {
+		set("scopes", *req.Scopes)
+	}
+
+	query := `
+	UPDATE "api_keys" SET
+		` + strings.Join(append(sets, `"updated_at" = now()`), `,
+		`) + fmt.Sprintf(`
+	WHERE "id" = $%d;`, len(values)+1)
+
+	result, err := r.db.ExecContext(context.Background(), query, append(values, req.Id)...)
+	if err != nil {
+		return fmt.Errorf("update api key failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("api key not found")
+	}
+	return nil
+}
+
+// RevokeApiKey : เก็บ row ไว้ดูย้อนหลัง revoke ซ้ำไม่เปลี่ยนเวลาเดิม
+func (r *appinfoRepository) RevokeApiKey(apiKeyId string) error {
+	query := `
+	UPDATE "api_keys" SET
+		"revoked_at" = COALESCE("revoked_at", now())
+	WHERE "id" = $1;`
+
+	result, err := r.db.ExecContext(context.Background(), query, apiKeyId)
+	if err != nil {
+		return fmt.Errorf("revoke api key failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("api key not found")
+	}
+	return nil
+}
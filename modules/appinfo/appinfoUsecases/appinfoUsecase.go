@@ -9,6 +9,11 @@ type IAppinfoUsecase interface {
 	FindCategory(req *appinfo.CategoryFilter) ([]*appinfo.Category, error)
 	InsertCategory(req []*appinfo.Category) error
 	DeleteCategory(categoryId int) error
+	FindApiKey() ([]*appinfo.ApiKey, error)
+	FindOneApiKey(apiKeyId string) (*appinfo.ApiKey, error)
+	InsertApiKey(req *appinfo.ApiKeyReq) (*appinfo.ApiKey, error)
+	UpdateApiKey(req *appinfo.ApiKeyUpdateReq) (*appinfo.ApiKey, error)
+	RevokeApiKey(apiKeyId string) (*appinfo.ApiKey, error)
 }
 
 type appinfoUsecase struct {
@@ -26,6 +31,9 @@ func (u *appinfoUsecase) FindCategory(req *appinfo.CategoryFilter) ([]*appinfo.C
 	if err != nil {
 		return nil, err
 	}
+	if req.Tree || req.Id > 0 {
+		return appinfo.BuildCategoryTree(category), nil
+	}
 	return category, nil
 }
 
@@ -42,3 +50,44 @@ func (u *appinfoUsecase) DeleteCategory(categoryId int) error {
 	}
 	return nil
 }
+
+func (u *appinfoUsecase) FindApiKey() ([]*appinfo.ApiKey, error) {
+	return u.appinfoRepository.FindApiKey()
+}
+
+func (u *appinfoUsecase) FindOneApiKey(apiKeyId string) (*appinfo.ApiKey, error) {
+	return u.appinfoRepository.FindOneApiKey(apiKeyId)
+}
+
+// InsertApiKey : key จริงส่งกลับไปครั้งเดียว หายแล้วต้องสร้างใหม่
+func (u *appinfoUsecase) InsertApiKey(req *appinfo.ApiKeyReq) (*appinfo.ApiKey, error) {
+	key, prefix, err := appinfo.NewApiKey()
+	if err != nil {
+		return nil, err
+	}
+	apiKeyId, err := u.appinfoRepository.InsertApiKey(req, prefix, key)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := u.appinfoRepository.FindOneApiKey(apiKeyId)
+	if err != nil {
+		return nil, err
+	}
+	apiKey.Key = key
+	return apiKey, nil
+}
+
+func (u *appinfoUsecase) UpdateApiKey(req *appinfo.ApiKeyUpdateReq) (*appinfo.ApiKey, error) {
+	if err := u.appinfoRepository.UpdateApiKey(req); err != nil {
+		return nil, err
+	}
+	return u.appinfoRepository.FindOneApiKey(req.Id)
+}
+
+func (u *appinfoUsecase) RevokeApiKey(apiKeyId string) (*appinfo.ApiKey, error) {
+	if err := u.appinfoRepository.RevokeApiKey(apiKeyId); err != nil {
+		return nil, err
+	}
+	return u.appinfoRepository.FindOneApiKey(apiKeyId)
+}
@@ -0,0 +1,46 @@
+package carts
+
+import (
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products"
+)
+
+type Cart struct {
+	UserId   string      `json:"user_id"`
+	Items    []*CartItem `json:"items"`
+	Subtotal float64     `json:"subtotal"`
+}
+
+type CartItem struct {
+	Id           string            `json:"id"`
+	Qty          int               `json:"qty"`
+	Price        float64           `json:"price"` // ราคาตอนเพิ่มลง cart
+	PriceChanged bool              `json:"price_changed"`
+	VariantId    string            `json:"variant_id"`
+	Product      *products.Product `json:"product"` // ข้อมูล product ปัจจุบัน
+	Variant      *products.Variant `json:"variant"` // ข้อมูล variant ปัจจุบัน (ถ้ามี)
+	CreatedAt    string            `json:"created_at"`
+	UpdatedAt    string            `json:"updated_at"`
+}
+
+type CartItemReq struct {
+	UserId    string `json:"-"`
+	ProductId string `json:"product_id"`
+	VariantId string `json:"variant_id" query:"variant_id"`
+	Qty       int    `json:"qty"`
+}
+
+type CheckoutReq struct {
+	UserId      string  `json:"-"`
+	ShippingFee float64 `json:"-"`
+	Address     string  `json:"address"`
+	Contact     string  `json:"contact"`
+	CouponCode  string  `json:"coupon_code"`
+}
+
+// CurrentPrice : ราคาปัจจุบันของ item ตาม product หรือ variant
+func (i *CartItem) CurrentPrice() float64 {
+	if i.Variant != nil {
+		return i.Variant.EffectivePrice(i.Product.Price)
+	}
+	return i.Product.Price
+}
@@ -0,0 +1,216 @@
+package cartsHandlers
+
+import (
+	"strings"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts/cartsUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/gofiber/fiber/v2"
+)
+
+type cartsHandlersErrCode string
+
+const (
+	findCartErr       cartsHandlersErrCode = "carts-001"
+	addCartItemErr    cartsHandlersErrCode = "carts-002"
+	updateCartItemErr cartsHandlersErrCode = "carts-003"
+	removeCartItemErr cartsHandlersErrCode = "carts-004"
+	checkoutErr       cartsHandlersErrCode = "carts-005"
+)
+
+type ICartsHandler interface {
+	FindCart(c *fiber.Ctx) error
+	AddCartItem(c *fiber.Ctx) error
+	UpdateCartItem(c *fiber.Ctx) error
+	RemoveCartItem(c *fiber.Ctx) error
+	Checkout(c *fiber.Ctx) error
+}
+
+type cartsHandler struct {
+	cfg          config.IConfig
+	cartsUsecase cartsUsecases.ICartsUsecase
+}
+
+func CartsHandler(cfg config.IConfig, cartsUsecase cartsUsecases.ICartsUsecase) ICartsHandler {
+	return &cartsHandler{
+		cfg:          cfg,
+		cartsUsecase: cartsUsecase,
+	}
+}
+
+func (h *cartsHandler) FindCart(c *fiber.Ctx) error {
+	userId := c.Locals("userId").(string)
+
+	cart, err := h.cartsUsecase.FindCart(userId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findCartErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, cart).Res()
+}
+
+func (h *cartsHandler) AddCartItem(c *fiber.Ctx) error {
+	req := new(carts.CartItemReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addCartItemErr),
+			err.Error(),
+		).Res()
+	}
+	if req.ProductId == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addCartItemErr),
+			"product id is required",
+		).Res()
+	}
+	if req.Qty <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addCartItemErr),
+			"qty must more than 0",
+		).Res()
+	}
+	req.UserId = c.Locals("userId").(string)
+
+	cart, err := h.cartsUsecase.AddCartItem(req)
+	if err != nil {
+		switch err.Error() {
+		case "product not found", "variant not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(addCartItemErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(addCartItemErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, cart).Res()
+}
+
+func (h *cartsHandler) UpdateCartItem(c *fiber.Ctx) error {
+	req := new(carts.CartItemReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateCartItemErr),
+			err.Error(),
+		).Res()
+	}
+	if req.Qty <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateCartItemErr),
+			"qty must more than 0",
+		).Res()
+	}
+	req.UserId = c.Locals("userId").(string)
+	req.ProductId = strings.Trim(c.Params("product_id"), " ")
+
+	cart, err := h.cartsUsecase.UpdateCartItem(req)
+	if err != nil {
+		switch err.Error() {
+		case "cart item not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateCartItemErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateCartItemErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, cart).Res()
+}
+
+func (h *cartsHandler) RemoveCartItem(c *fiber.Ctx) error {
+	req := new(carts.CartItemReq)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(removeCartItemErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = c.Locals("userId").(string)
+	req.ProductId = strings.Trim(c.Params("product_id"), " ")
+
+	cart, err := h.cartsUsecase.RemoveCartItem(req)
+	if err != nil {
+		switch err.Error() {
+		case "cart item not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(removeCartItemErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(removeCartItemErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, cart).Res()
+}
+
+func (h *cartsHandler) Checkout(c *fiber.Ctx) error {
+	req := new(carts.CheckoutReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(checkoutErr),
+			err.Error(),
+		).Res()
+	}
+	if req.Address == "" || req.Contact == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(checkoutErr),
+			"address and contact are required",
+		).Res()
+	}
+	req.UserId = c.Locals("userId").(string)
+	req.ShippingFee = h.cfg.App().ShippingFee()
+	req.CouponCode = strings.ToUpper(strings.TrimSpace(req.CouponCode))
+
+	order, err := h.cartsUsecase.Checkout(req)
+	if err != nil {
+		if err.Error() == "cart is empty" ||
+			err.Error() == "cart prices have changed" ||
+			err.Error() == "cart has changed" ||
+			strings.HasPrefix(err.Error(), "coupon ") ||
+			err.Error() == "order does not reach coupon minimum spend" ||
+			strings.HasSuffix(err.Error(), "is out of stock") ||
+			strings.HasSuffix(err.Error(), "requires variant") ||
+			(strings.HasPrefix(err.Error(), "variant ") && strings.HasSuffix(err.Error(), "not found")) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(checkoutErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(checkoutErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
+}
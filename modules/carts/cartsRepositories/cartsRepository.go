@@ -0,0 +1,212 @@
+package cartsRepositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts"
+	"github.com/jmoiron/sqlx"
+)
+
+type ICartsRepository interface {
+	FindCartItem(userId string) ([]*carts.CartItem, error)
+	InsertCartItem(req *carts.CartItemReq) error
+	UpdateCartItem(req *carts.CartItemReq) error
+	DeleteCartItem(req *carts.CartItemReq) error
+	UpdateCartItemPrice(userId string) error
+}
+
+type cartsRepository struct {
+	db *sqlx.DB
+}
+
+func CartsRepository(db *sqlx.DB) ICartsRepository {
+	return &cartsRepository{
+		db: db,
+	}
+}
+
+func (r *cartsRepository) FindCartItem(userId string) ([]*carts.CartItem, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT
+			"ci"."id",
+			"ci"."qty",
+			"ci"."price",
+			COALESCE("ci"."variant_id", '') AS "variant_id",
+			(
+				SELECT
+					to_jsonb("pt")
+				FROM (
+					SELECT
+						"p"."id",
+						"p"."title",
+						"p"."description",
+						"p"."price",
+						"p"."stock",
+						"p"."created_at",
+						"p"."updated_at",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("it")), '[]'::json)
+							FROM (
+								SELECT
+									"i"."id",
+									"i"."filename",
+									"i"."url",
+									"i"."renditions"
+								FROM "images" "i"
+								WHERE "i"."product_id" = "p"."id"
+								AND "i"."variant_id" IS NULL
+							) AS "it"
+						) AS "images"
+					FROM "products" "p"
+					WHERE "p"."id" = "ci"."product_id"
+				) AS "pt"
+			) AS "product",
+			(
+				SELECT
+					to_jsonb("vt")
+				FROM (
+					SELECT
+						"v"."id",
+						"v"."product_id",
+						"v"."sku",
+						"v"."options",
+						"v"."price",
+						"v"."stock",
+						"v"."created_at",
+						"v"."updated_at"
+					FROM "product_variants" "v"
+					WHERE "v"."id" = "ci"."variant_id"
+				) AS "vt"
+			) AS "variant",
+			"ci"."created_at",
+			"ci"."updated_at"
+		FROM "carts_items" "ci"
+		WHERE "ci"."user_id" = $1
+		ORDER BY "ci"."created_at" ASC
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, userId); err != nil {
+		return nil, fmt.Errorf("get cart failed: %v", err)
+	}
+
+	items := make([]*carts.CartItem, 0)
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("unmarshal cart failed: %v", err)
+	}
+	return items, nil
+}
+
+func (r *cartsRepository) InsertCartItem(req *carts.CartItemReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// เพิ่ม product ซ้ำจะรวม qty เข้ากับ item เดิม และใช้ราคาปัจจุบัน
+	query := `
+	INSERT INTO "carts_items" (
+		"user_id",
+		"product_id",
+		"variant_id",
+		"qty",
+		"price"
+	)
+	SELECT $1, "p"."id", "v"."id", $4, COALESCE("v"."price", "p"."price")
+	FROM "products" "p"
+	LEFT JOIN "product_variants" "v"
+	ON "v"."product_id" = "p"."id"
+	AND "v"."id" = NULLIF($3, '')
+	WHERE "p"."id" = $2
+	AND (NULLIF($3, '') IS NULL OR "v"."id" IS NOT NULL)
+	ON CONFLICT ("user_id", "product_id", COALESCE("variant_id", '')) DO UPDATE SET
+		"qty" = "carts_items"."qty" + EXCLUDED."qty",
+		"price" = EXCLUDED."price"
+	RETURNING "id";`
+
+	var id string
+	if err := r.db.QueryRowContext(ctx, query, req.UserId, req.ProductId, req.VariantId, req.Qty).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			if req.VariantId != "" {
+				return fmt.Errorf("variant not found")
+			}
+			return fmt.Errorf("product not found")
+		}
+		return fmt.Errorf("insert cart item failed: %v", err)
+	}
+	return nil
+}
+
+func (r *cartsRepository) UpdateCartItem(req *carts.CartItemReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	UPDATE "carts_items" SET
+		"qty" = $1
+	WHERE "user_id" = $2
+	AND "product_id" = $3
+	AND COALESCE("variant_id", '') = $4;`
+
+	result, err := r.db.ExecContext(ctx, query, req.Qty, req.UserId, req.ProductId, req.VariantId)
+	if err != nil {
+		return fmt.Errorf("update cart item failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("cart item not found")
+	}
+	return nil
+}
+
+func (r *cartsRepository) DeleteCartItem(req *carts.CartItemReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	DELETE FROM "carts_items"
+	WHERE "user_id" = $1
+	AND "product_id" = $2
+	AND COALESCE("variant_id", '') = $3;`
+
+	result, err := r.db.ExecContext(ctx, query, req.UserId, req.ProductId, req.VariantId)
+	if err != nil {
+		return fmt.Errorf("delete cart item failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("cart item not found")
+	}
+	return nil
+}
+
+func (r *cartsRepository) UpdateCartItemPrice(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	UPDATE "carts_items" "ci" SET
+		"price" = "cp"."price"
+	FROM (
+		SELECT
+			"c"."id",
+			COALESCE("v"."price", "p"."price") AS "price"
+		FROM "carts_items" "c"
+		JOIN "products" "p"
+		ON "p"."id" = "c"."product_id"
+		LEFT JOIN "product_variants" "v"
+		ON "v"."id" = "c"."variant_id"
+		WHERE "c"."user_id" = $1
+	) AS "cp"
+	WHERE "cp"."id" = "ci"."id"
+	AND "ci"."price" <> "cp"."price";`
+
+	if _, err := r.db.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("update cart price failed: %v", err)
+	}
+	return nil
+}
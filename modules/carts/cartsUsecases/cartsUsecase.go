@@ -0,0 +1,130 @@
+package cartsUsecases
+
+import (
+	"fmt"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts/cartsRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsRepositories"
+)
+
+type ICartsUsecase interface {
+	FindCart(userId string) (*carts.Cart, error)
+	AddCartItem(req *carts.CartItemReq) (*carts.Cart, error)
+	UpdateCartItem(req *carts.CartItemReq) (*carts.Cart, error)
+	RemoveCartItem(req *carts.CartItemReq) (*carts.Cart, error)
+	Checkout(req *carts.CheckoutReq) (*orders.Order, error)
+}
+
+type cartsUsecase struct {
+	cartsRepository    cartsRepositories.ICartsRepository
+	ordersRepository   ordersRepositories.IOrdersRepository
+	productsRepository productsRepositories.IProductsRepository
+}
+
+func CartsUsecase(cartsRepository cartsRepositories.ICartsRepository, ordersRepository ordersRepositories.IOrdersRepository, productsRepository productsRepositories.IProductsRepository) ICartsUsecase {
+	return &cartsUsecase{
+		cartsRepository:    cartsRepository,
+		ordersRepository:   ordersRepository,
+		productsRepository: productsRepository,
+	}
+}
+
+func (u *cartsUsecase) FindCart(userId string) (*carts.Cart, error) {
+	items, err := u.cartsRepository.FindCartItem(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	cart := &carts.Cart{
+		UserId: userId,
+		Items:  items,
+	}
+	for _, item := range items {
+		// ราคาใน cart คิดจากราคา product ปัจจุบันเสมอ
+		item.PriceChanged = item.Price != item.CurrentPrice()
+		cart.Subtotal += item.CurrentPrice() * float64(item.Qty)
+	}
+	return cart, nil
+}
+
+func (u *cartsUsecase) AddCartItem(req *carts.CartItemReq) (*carts.Cart, error) {
+	if err := u.cartsRepository.InsertCartItem(req); err != nil {
+		return nil, err
+	}
+	return u.FindCart(req.UserId)
+}
+
+func (u *cartsUsecase) UpdateCartItem(req *carts.CartItemReq) (*carts.Cart, error) {
+	if err := u.cartsRepository.UpdateCartItem(req); err != nil {
+		return nil, err
+	}
+	return u.FindCart(req.UserId)
+}
+
+func (u *cartsUsecase) RemoveCartItem(req *carts.CartItemReq) (*carts.Cart, error) {
+	if err := u.cartsRepository.DeleteCartItem(req); err != nil {
+		return nil, err
+	}
+	return u.FindCart(req.UserId)
+}
+
+func (u *cartsUsecase) Checkout(req *carts.CheckoutReq) (*orders.Order, error) {
+	cart, err := u.FindCart(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, fmt.Errorf("cart is empty")
+	}
+
+	// revalidate : ถ้าราคาเปลี่ยนตั้งแต่เพิ่มลง cart ให้ user ตรวจสอบ cart ใหม่ก่อน checkout
+	for _, item := range cart.Items {
+		if item.PriceChanged {
+			if err := u.cartsRepository.UpdateCartItemPrice(req.UserId); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("cart prices have changed")
+		}
+	}
+
+	order := &orders.Order{
+		UserId:      req.UserId,
+		Address:     req.Address,
+		Contact:     req.Contact,
+		Status:      "waiting",
+		CouponCode:  req.CouponCode,
+		ShippingFee: req.ShippingFee,
+		Products:    make([]*orders.ProductsOrder, 0),
+		CartItemIds: make([]string, 0),
+	}
+	for _, item := range cart.Items {
+		prod, err := u.productsRepository.FindOneProduct(item.Product.Id)
+		if err != nil {
+			return nil, err
+		}
+		line := &orders.ProductsOrder{
+			Qty:       item.Qty,
+			VariantId: item.VariantId,
+		}
+		if err := line.SetSnapshot(prod); err != nil {
+			return nil, err
+		}
+		order.Products = append(order.Products, line)
+		order.CartItemIds = append(order.CartItemIds, item.Id)
+	}
+
+	// cart ถูกล้างใน transaction เดียวกับ order
+	orderId, err := u.ordersRepository.InsertOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
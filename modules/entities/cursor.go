@@ -0,0 +1,66 @@
+package entities
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Cursor : ตำแหน่งของ record สำหรับแบ่งหน้าแบบ keyset ส่งให้ client เป็น string ที่อ่านไม่ออก
+type Cursor struct {
+	Id       string `json:"id"`
+	Value    string `json:"v"` // ค่าของ column ที่ใช้ sort ของ record นั้น
+	OrderBy  string `json:"o"`
+	Sort     string `json:"s"`
+	Backward bool   `json:"b,omitempty"` // true = ดึงหน้าก่อนหน้า
+}
+
+type PageCursor struct {
+	Next string
+	Prev string
+}
+
+func (c *Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(cursor string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("cursor is invalid")
+	}
+
+	c := new(Cursor)
+	if err := json.Unmarshal(raw, c); err != nil || c.Id == "" {
+		return nil, fmt.Errorf("cursor is invalid")
+	}
+	return c, nil
+}
+
+// NewPageCursor : สร้าง next/prev cursor จาก record แรกและสุดท้ายของหน้า
+// hasMore คือยังมี record ต่อไปในทิศทางที่ดึงมา (ดึงเกิน limit มา 1 record)
+func NewPageCursor(req *Cursor, hasMore bool, first, last *Cursor) *PageCursor {
+	page := new(PageCursor)
+	if first == nil || last == nil {
+		return page
+	}
+	first.Backward = true
+	last.Backward = false
+
+	if req != nil && req.Backward {
+		if hasMore {
+			page.Prev = first.Encode()
+		}
+		page.Next = last.Encode()
+		return page
+	}
+
+	if hasMore {
+		page.Next = last.Encode()
+	}
+	if req != nil {
+		page.Prev = first.Encode()
+	}
+	return page
+}
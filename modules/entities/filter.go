@@ -1,13 +1,53 @@
 package entities
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
 type PaginationReq struct {
-	Page      int `query:"page"`
-	Limit     int `query:"limit"`
-	TotalPage int `query:"total_page" json:"total_page"`
-	TotalItem int `query:"total_item" json:"total_item"`
+	Page      int     `query:"page"`
+	Limit     int     `query:"limit"`
+	TotalPage int     `query:"total_page" json:"total_page"`
+	TotalItem int     `query:"total_item" json:"total_item"`
+	Cursor    string  `query:"cursor"`     // ส่ง cursor (ว่างได้ = หน้าแรก) จะแบ่งหน้าแบบ keyset แทน page
+	SkipCount bool    `query:"skip_count"` // ไม่นับจำนวนทั้งหมด total_item, total_page จะเป็น 0
+	UseCursor bool    `query:"-"`
+	Position  *Cursor `query:"-"` // cursor ที่ decode แล้ว nil = หน้าแรก
+}
+
+// ParseCursor : เปิดการแบ่งหน้าแบบ cursor เมื่อมี query cursor
+func (p *PaginationReq) ParseCursor(c *fiber.Ctx, orderBy, sort string) error {
+	if !c.Context().QueryArgs().Has("cursor") {
+		return nil
+	}
+	p.UseCursor = true
+	if p.Cursor == "" {
+		return nil
+	}
+
+	cursor, err := DecodeCursor(p.Cursor)
+	if err != nil {
+		return err
+	}
+	// cursor ใช้ได้กับการเรียงลำดับแบบเดียวกับตอนที่สร้างเท่านั้น
+	if cursor.OrderBy != orderBy || cursor.Sort != sort {
+		return fmt.Errorf("cursor is invalid")
+	}
+	p.Position = cursor
+	return nil
 }
 
 type SortReq struct {
 	OrderBy string `query:"order_by"`
 	Sort    string `query:"sort"`
 }
+
+// SortRelevance : เรียงตามความเกี่ยวข้องกับคำค้นหา ใช้ได้ทั้ง sort=relevance และ order_by=relevance
+const SortRelevance = "relevance"
+
+func (s *SortReq) IsRelevance() bool {
+	return strings.EqualFold(s.Sort, SortRelevance) || strings.EqualFold(s.OrderBy, SortRelevance)
+}
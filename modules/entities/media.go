@@ -1,7 +1,42 @@
 package entities
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
 type Image struct {
-	Id       string `db:"id" json:"id"`
-	FileName string `db:"filename" json:"filename"`
-	Url      string `db:"url" json:"url"`
+	Id         string     `db:"id" json:"id"`
+	FileName   string     `db:"filename" json:"filename"`
+	Url        string     `db:"url" json:"url"`
+	Renditions Renditions `db:"renditions" json:"renditions"`
+}
+
+// Renditions : url ของรูปแต่ละขนาด key คือชื่อขนาด eg. thumbnail, medium, large
+type Renditions map[string]string
+
+// Value : เก็บลง column jsonb ไม่มี renditions = {}
+func (r Renditions) Value() (driver.Value, error) {
+	if r == nil {
+		return "{}", nil
+	}
+	raw, err := json.Marshal(map[string]string(r))
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (r *Renditions) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*r = make(Renditions)
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	}
+	return fmt.Errorf("scan renditions failed: unsupported type %T", src)
 }
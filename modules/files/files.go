@@ -1,6 +1,16 @@
 package files
 
-import "mime/multipart"
+import (
+	"fmt"
+	"mime/multipart"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/imaging"
+)
 
 type FileReq struct {
 	File        *multipart.FileHeader `form:"file"`
@@ -10,10 +20,86 @@ type FileReq struct {
 }
 
 type FileRes struct {
-	FileName string `json:"filename"`
-	Url      string `json:"url"`
+	FileName    string              `json:"filename"`
+	Url         string              `json:"url"` // ไฟล์ private ไม่มี url ใช้ destination ขอ signed url แทน
+	Destination string              `json:"destination"`
+	Renditions  entities.Renditions `json:"renditions"`
 }
 
 type DeleteFileReq struct {
 	Destination string `json:"destination"`
 }
+
+// PresignReq : ขอ url สำหรับ upload ไฟล์ private ตรงเข้า storage โดยไม่ผ่าน server
+type PresignReq struct {
+	Extension   string `json:"extension"`
+	ContentType string `json:"content_type"`
+	Directory   string `json:"directory"` // admin เท่านั้นที่กำหนดได้ user ทั่วไปจะเป็น slips/<user_id>
+	Destination string `json:"-"`
+}
+
+type SignedUrlRes struct {
+	Url         string            `json:"url"`
+	Method      string            `json:"method"`
+	Fields      map[string]string `json:"fields,omitempty"` // upload : ส่งเป็น multipart/form-data พร้อม field file ไว้ท้ายสุด
+	Destination string            `json:"destination"`
+	ExpiresAt   string            `json:"expires_at"`
+}
+
+// SignedUploadReq : form ที่ upload ตรงเข้า local storage ตาม PresignPost
+type SignedUploadReq struct {
+	Destination string `form:"-"`
+	ContentType string `form:"Content-Type"`
+	MaxSize     string `form:"max_size"`
+	Expires     string `form:"expires"`
+	Signature   string `form:"signature"`
+	Data        []byte `form:"-"`
+}
+
+// SignedUrlExpires : อายุของ signed url ทั้ง download และ upload
+const SignedUrlExpires = time.Minute * 15
+
+// PublicDirectories : directory ที่ไฟล์อ่านได้แบบ public ไฟล์อื่นเป็น private ทั้งหมด
+var PublicDirectories = []string{
+	"images/products",
+}
+
+func IsPublicDestination(destination string) bool {
+	destination = strings.TrimPrefix(path.Clean("/"+destination), "/")
+	for _, dir := range PublicDirectories {
+		if strings.HasPrefix(destination, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SlipDirectory : ที่เก็บสลิปโอนเงินของ user แต่ละคน (private)
+// สลิปที่ upload ก่อนเปลี่ยนเป็น private ยังเป็น public ตาม url เดิม (ไม่ได้ย้าย) จนกว่าจะ upload สลิปใหม่แทน
+func SlipDirectory(userId string) string {
+	return fmt.Sprintf("slips/%s", userId)
+}
+
+// IsSlipOf : destination ต้องอยู่ใน directory สลิปของ user เท่านั้น กันการอ้างไฟล์ของคนอื่น
+func IsSlipOf(userId, destination string) bool {
+	return strings.HasPrefix(path.Clean("/"+destination), "/"+SlipDirectory(userId)+"/")
+}
+
+// ImageDeleteReq : ลบรูปพร้อม renditions ทั้งหมดที่อยู่ใน directory เดียวกัน
+func ImageDeleteReq(directory string, image *entities.Image) []*DeleteFileReq {
+	req := []*DeleteFileReq{
+		{Destination: fmt.Sprintf("%s/%s", directory, image.FileName)},
+	}
+
+	names := make([]string, 0, len(image.Renditions))
+	for name := range image.Renditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		req = append(req, &DeleteFileReq{
+			Destination: fmt.Sprintf("%s/%s", directory, imaging.RenditionFileName(image.FileName, name)),
+		})
+	}
+	return req
+}
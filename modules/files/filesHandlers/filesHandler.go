@@ -2,7 +2,9 @@ package filesHandlers
 
 import (
 	"fmt"
+	"io"
 	"math"
+	"mime"
 	"path/filepath"
 	"strings"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/utils"
 	"github.com/gofiber/fiber/v2"
 )
@@ -17,13 +20,18 @@ import (
 type filesHandlersErrCode string
 
 const (
-	uploadErr filesHandlersErrCode = "files-001"
-	deleteErr filesHandlersErrCode = "files-002"
+	uploadErr     filesHandlersErrCode = "files-001"
+	deleteErr     filesHandlersErrCode = "files-002"
+	presignErr    filesHandlersErrCode = "files-003"
+	signedFileErr filesHandlersErrCode = "files-004"
 )
 
 type IFilesHandler interface {
 	UploadFiles(c *fiber.Ctx) error
 	DeleteFile(c *fiber.Ctx) error
+	PresignUpload(c *fiber.Ctx) error
+	FindSignedFile(c *fiber.Ctx) error
+	UploadSignedFile(c *fiber.Ctx) error
 }
 
 type filesHandler struct {
@@ -86,13 +94,22 @@ func (h *filesHandler) UploadFiles(c *fiber.Ctx) error {
 		})
 	}
 
-	res, err := h.filesUsecase.UploadToGCP(req)
+	res, err := h.filesUsecase.UploadFiles(req)
 	if err != nil {
-		return entities.NewResponse(c).Error(
-			fiber.ErrInternalServerError.Code,
-			string(uploadErr),
-			err.Error(),
-		).Res()
+		switch err.Error() {
+		case "file is not a valid image", "file content does not match extension", "image is too large":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(uploadErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(uploadErr),
+				err.Error(),
+			).Res()
+		}
 	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, res).Res()
 }
@@ -107,7 +124,7 @@ func (h *filesHandler) DeleteFile(c *fiber.Ctx) error {
 		).Res()
 	}
 
-	if err := h.filesUsecase.DeleteFileOnGCP(req); err != nil {
+	if err := h.filesUsecase.DeleteFiles(req); err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(deleteErr),
@@ -116,3 +133,143 @@ func (h *filesHandler) DeleteFile(c *fiber.Ctx) error {
 	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
 }
+
+func (h *filesHandler) PresignUpload(c *fiber.Ctx) error {
+	req := new(files.PresignReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(presignErr),
+			err.Error(),
+		).Res()
+	}
+
+	// สลิปรับได้ทั้งรูปและ pdf
+	extMap := map[string]string{
+		"png":  "png",
+		"jpg":  "jpg",
+		"jpeg": "jpeg",
+		"pdf":  "pdf",
+	}
+	ext := strings.ToLower(strings.TrimPrefix(req.Extension, "."))
+	if extMap[ext] == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(presignErr),
+			"extension is not acceptable",
+		).Res()
+	}
+	// content type ต้องตรงกับนามสกุล ไม่ใช้ค่าที่ client ส่งมา
+	req.ContentType = mime.TypeByExtension("." + ext)
+
+	directory := files.SlipDirectory(c.Locals("userId").(string))
+	if c.Locals("userPermissions").(middlewares.Permissions).Has(middlewares.PermFilesWrite) && req.Directory != "" {
+		directory = strings.Trim(req.Directory, "/")
+	}
+	req.Destination = directory + "/" + utils.RandFileName(ext)
+
+	res, err := h.filesUsecase.PresignUpload(req)
+	if err != nil {
+		switch err.Error() {
+		case "destination must be private":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(presignErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(presignErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, res).Res()
+}
+
+func (h *filesHandler) FindSignedFile(c *fiber.Ctx) error {
+	filePath, err := h.filesUsecase.FindSignedFile(c.Params("*"), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		return signedFileErrRes(c, err)
+	}
+	if err := c.SendFile(filePath); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrNotFound.Code,
+			string(signedFileErr),
+			"file not found",
+		).Res()
+	}
+	return nil
+}
+
+func (h *filesHandler) UploadSignedFile(c *fiber.Ctx) error {
+	req := new(files.SignedUploadReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(signedFileErr),
+			err.Error(),
+		).Res()
+	}
+	req.Destination = c.Params("*")
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(signedFileErr),
+			"file is required",
+		).Res()
+	}
+	if file.Size > int64(h.cfg.App().FileLimit()) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(signedFileErr),
+			fmt.Sprintf("file size must less than %d MiB", int(math.Ceil(float64(h.cfg.App().FileLimit())/math.Pow(1024, 2)))),
+		).Res()
+	}
+	container, err := file.Open()
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(signedFileErr),
+			err.Error(),
+		).Res()
+	}
+	defer container.Close()
+	if req.Data, err = io.ReadAll(container); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(signedFileErr),
+			err.Error(),
+		).Res()
+	}
+
+	if err := h.filesUsecase.UploadSignedFile(req); err != nil {
+		return signedFileErrRes(c, err)
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func signedFileErrRes(c *fiber.Ctx, err error) error {
+	switch err.Error() {
+	case "signature is invalid", "signed url has expired":
+		return entities.NewResponse(c).Error(
+			fiber.ErrForbidden.Code,
+			string(signedFileErr),
+			err.Error(),
+		).Res()
+	case "destination is invalid", "signed url is not supported", "file size is invalid":
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(signedFileErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Error(
+		fiber.ErrInternalServerError.Code,
+		string(signedFileErr),
+		err.Error(),
+	).Res()
+}
@@ -0,0 +1,141 @@
+package filesStorages
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+type gcsStorage struct {
+	bucket string
+	mutex  sync.Mutex
+	client *storage.Client
+}
+
+// GCSStorage : google cloud storage ใช้ credentials จาก environment (GOOGLE_APPLICATION_CREDENTIALS)
+func GCSStorage(bucket string) IStorage {
+	return &gcsStorage{
+		bucket: bucket,
+	}
+}
+
+// getClient : สร้าง client ตอนใช้งานครั้งแรก เพื่อให้ start server ได้โดยไม่ต้องมี credentials
+func (s *gcsStorage) getClient() (*storage.Client, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+	// client ใช้ต่อทั้ง app จึงไม่ผูกกับ ctx ของ request
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("storage.NewClient: %w", err)
+	}
+	s.client = client
+	return client, nil
+}
+
+func (s *gcsStorage) Upload(ctx context.Context, destination, contentType string, data []byte, public bool) (string, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return "", err
+	}
+
+	// Upload an object with storage.Writer.
+	wc := client.Bucket(s.bucket).Object(destination).NewWriter(ctx)
+	wc.ContentType = contentType
+
+	if _, err = io.Copy(wc, bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("io.Copy: %w", err)
+	}
+
+	// Data can continue to be added to the file until the writer is closed.
+	if err := wc.Close(); err != nil {
+		return "", fmt.Errorf("Writer.Close: %w", err)
+	}
+
+	if !public {
+		return "", nil
+	}
+
+	// MakePublic gives all users read access to an object.
+	acl := client.Bucket(s.bucket).Object(destination).ACL()
+	if err := acl.Set(ctx, storage.AllUsers, storage.RoleReader); err != nil {
+		return "", fmt.Errorf("ACLHandle.Set: %w", err)
+	}
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", s.bucket, destination), nil
+}
+
+func (s *gcsStorage) Delete(ctx context.Context, destination string) error {
+	client, err := s.getClient()
+	if err != nil {
+		return err
+	}
+
+	o := client.Bucket(s.bucket).Object(destination)
+
+	attrs, err := o.Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("object.Attrs: %w", err)
+	}
+	o = o.If(storage.Conditions{GenerationMatch: attrs.Generation})
+
+	if err := o.Delete(ctx); err != nil {
+		return fmt.Errorf("Object(%q).Delete: %w", destination, err)
+	}
+	return nil
+}
+
+// SignedUrl : ต้องใช้ service account ที่มี private key หรือสิทธิ์ iam.serviceAccounts.signBlob
+func (s *gcsStorage) SignedUrl(ctx context.Context, method, destination, contentType string, expires time.Duration) (string, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return "", err
+	}
+
+	opts := &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  method,
+		Expires: time.Now().Add(expires),
+	}
+	if method == http.MethodPut {
+		opts.ContentType = contentType
+	}
+
+	url, err := client.Bucket(s.bucket).SignedURL(destination, opts)
+	if err != nil {
+		return "", fmt.Errorf("Bucket(%q).SignedURL: %w", s.bucket, err)
+	}
+	return url, nil
+}
+
+func (s *gcsStorage) PresignPost(ctx context.Context, destination, contentType string, maxSize int64, expires time.Duration) (*PresignedPost, error) {
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := client.Bucket(s.bucket).GenerateSignedPostPolicyV4(destination, &storage.PostPolicyV4Options{
+		Expires: time.Now().Add(expires),
+		Fields: &storage.PolicyV4Fields{
+			ContentType: contentType,
+		},
+		Conditions: []storage.PostPolicyV4Condition{
+			storage.ConditionContentLengthRange(1, uint64(maxSize)),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Bucket(%q).GenerateSignedPostPolicyV4: %w", s.bucket, err)
+	}
+	return &PresignedPost{
+		Url:    policy.URL,
+		Fields: policy.Fields,
+	}, nil
+}
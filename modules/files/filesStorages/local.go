@@ -0,0 +1,194 @@
+package filesStorages
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ILocalStorage : local storage ต้องให้ server เสิร์ฟ signed url เอง
+type ILocalStorage interface {
+	IStorage
+	PublicPath() string
+	VerifySignedUrl(method, destination, expires, signature string) (string, error)
+	VerifyPresignedPost(destination, contentType, maxSize, expires, signature string, size int64) error
+}
+
+type localStorage struct {
+	root       string
+	baseUrl    string
+	signingKey []byte
+}
+
+// LocalStorage : เก็บไฟล์ใน disk ของ server ใช้ตอน dev และ test
+// ไฟล์ public อยู่ใน root/public (เสิร์ฟที่ baseUrl/static) ไฟล์ private อยู่ใน root/private
+func LocalStorage(root, baseUrl string, signingKey []byte) ILocalStorage {
+	return &localStorage{
+		root:       root,
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		signingKey: signingKey,
+	}
+}
+
+func (s *localStorage) PublicPath() string {
+	return filepath.Join(s.root, "public")
+}
+
+// cleanDestination : กัน destination ที่ออกนอก root eg. ../../etc/passwd
+func cleanDestination(destination string) (string, error) {
+	clean := strings.TrimPrefix(path.Clean("/"+destination), "/")
+	if clean == "" {
+		return "", fmt.Errorf("destination is invalid")
+	}
+	return clean, nil
+}
+
+func (s *localStorage) filePath(destination string, public bool) (string, error) {
+	clean, err := cleanDestination(destination)
+	if err != nil {
+		return "", err
+	}
+
+	dir := "private"
+	if public {
+		dir = "public"
+	}
+	return filepath.Join(s.root, dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *localStorage) Upload(ctx context.Context, destination, contentType string, data []byte, public bool) (string, error) {
+	filePath, err := s.filePath(destination, public)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", fmt.Errorf("create directory failed: %v", err)
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return "", fmt.Errorf("write file failed: %v", err)
+	}
+
+	if !public {
+		return "", nil
+	}
+	clean, _ := cleanDestination(destination)
+	return fmt.Sprintf("%s/static/%s", s.baseUrl, clean), nil
+}
+
+func (s *localStorage) Delete(ctx context.Context, destination string) error {
+	// ไม่รู้ว่าเป็นไฟล์ public หรือ private จึงลบทั้งสองที่
+	var found bool
+	for _, public := range []bool{true, false} {
+		filePath, err := s.filePath(destination, public)
+		if err != nil {
+			return err
+		}
+
+		if err := os.Remove(filePath); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("delete file failed: %v", err)
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("file not found")
+	}
+	return nil
+}
+
+// sign : parts คือ method, destination, expires และเงื่อนไขอื่นที่ต้องเซ็นไว้ด้วย
+func (s *localStorage) sign(parts ...string) string {
+	h := hmac.New(sha256.New, s.signingKey)
+	h.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *localStorage) SignedUrl(ctx context.Context, method, destination, contentType string, expires time.Duration) (string, error) {
+	clean, err := cleanDestination(destination)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expiresAt)
+	query.Set("signature", s.sign(method, clean, expiresAt))
+
+	return fmt.Sprintf("%s/signed/%s?%s", s.baseUrl, escapePath(clean), query.Encode()), nil
+}
+
+// VerifySignedUrl : ตรวจ signature และเวลาหมดอายุ แล้วคืน path ของไฟล์ private
+func (s *localStorage) VerifySignedUrl(method, destination, expires, signature string) (string, error) {
+	clean, err := cleanDestination(destination)
+	if err != nil {
+		return "", err
+	}
+
+	expectSignature := s.sign(method, clean, expires)
+	if !hmac.Equal([]byte(expectSignature), []byte(signature)) {
+		return "", fmt.Errorf("signature is invalid")
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return "", fmt.Errorf("signed url has expired")
+	}
+	if method != http.MethodGet && method != http.MethodPut {
+		return "", fmt.Errorf("signature is invalid")
+	}
+	return s.filePath(clean, false)
+}
+
+// PresignPost : form ส่งกลับมาที่ server (POST baseUrl/signed/destination) content type และขนาดไฟล์ถูกเซ็นไว้ใน signature
+func (s *localStorage) PresignPost(ctx context.Context, destination, contentType string, maxSize int64, expires time.Duration) (*PresignedPost, error) {
+	clean, err := cleanDestination(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	size := strconv.FormatInt(maxSize, 10)
+	return &PresignedPost{
+		Url: fmt.Sprintf("%s/signed/%s", s.baseUrl, escapePath(clean)),
+		Fields: map[string]string{
+			"Content-Type": contentType,
+			"max_size":     size,
+			"expires":      expiresAt,
+			"signature":    s.sign(http.MethodPost, clean, expiresAt, contentType, size),
+		},
+	}, nil
+}
+
+// VerifyPresignedPost : ตรวจ signature เวลาหมดอายุ และขนาดไฟล์ที่ upload ผ่าน form
+func (s *localStorage) VerifyPresignedPost(destination, contentType, maxSize, expires, signature string, size int64) error {
+	clean, err := cleanDestination(destination)
+	if err != nil {
+		return err
+	}
+
+	expectSignature := s.sign(http.MethodPost, clean, expires, contentType, maxSize)
+	if !hmac.Equal([]byte(expectSignature), []byte(signature)) {
+		return fmt.Errorf("signature is invalid")
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return fmt.Errorf("signed url has expired")
+	}
+	limit, err := strconv.ParseInt(maxSize, 10, 64)
+	if err != nil || size < 1 || size > limit {
+		return fmt.Errorf("file size is invalid")
+	}
+	return nil
+}
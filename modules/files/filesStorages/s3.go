@@ -0,0 +1,339 @@
+package filesStorages
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PublicUrl string
+	PathStyle bool
+}
+
+type s3Storage struct {
+	opts   *S3Options
+	client *http.Client
+}
+
+// S3Storage : storage ที่ใช้ S3 API ได้ eg. AWS S3, MinIO, Cloudflare R2
+// ไฟล์ public ใช้ ACL public-read ถ้า storage ไม่รองรับ ACL (eg. MinIO) ต้องตั้ง bucket policy ให้ path ของไฟล์ public เอง
+func S3Storage(opts *S3Options) IStorage {
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	opts.Endpoint = strings.TrimSuffix(opts.Endpoint, "/")
+	opts.PublicUrl = strings.TrimSuffix(opts.PublicUrl, "/")
+
+	return &s3Storage{
+		opts: opts,
+		client: &http.Client{
+			Timeout: time.Second * 60,
+		},
+	}
+}
+
+// bucketUrl : path style = endpoint/bucket, virtual hosted style = bucket.endpoint
+func (s *s3Storage) bucketUrl() (string, error) {
+	endpoint, err := url.Parse(s.opts.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return "", fmt.Errorf("s3 endpoint is invalid")
+	}
+
+	if s.opts.PathStyle {
+		return fmt.Sprintf("%s://%s/%s", endpoint.Scheme, endpoint.Host, s.opts.Bucket), nil
+	}
+	return fmt.Sprintf("%s://%s.%s", endpoint.Scheme, s.opts.Bucket, endpoint.Host), nil
+}
+
+func (s *s3Storage) objectUrl(destination string) (string, error) {
+	bucketUrl, err := s.bucketUrl()
+	if err != nil {
+		return "", err
+	}
+	return bucketUrl + "/" + escapePath(strings.TrimPrefix(destination, "/")), nil
+}
+
+func (s *s3Storage) do(ctx context.Context, method, destination, contentType string, data []byte, public bool) error {
+	objectUrl, err := s.objectUrl(destination)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, objectUrl, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("new s3 request failed: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if public {
+		req.Header.Set("X-Amz-Acl", "public-read")
+	}
+	SignV4(req, data, s.opts.AccessKey, s.opts.SecretKey, s.opts.Region, time.Now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 request failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("s3 %s %s failed: %s %s", method, destination, res.Status, string(body))
+	}
+	return nil
+}
+
+func (s *s3Storage) Upload(ctx context.Context, destination, contentType string, data []byte, public bool) (string, error) {
+	if err := s.do(ctx, http.MethodPut, destination, contentType, data, public); err != nil {
+		return "", err
+	}
+	if !public {
+		return "", nil
+	}
+
+	if s.opts.PublicUrl != "" {
+		return fmt.Sprintf("%s/%s", s.opts.PublicUrl, escapePath(strings.TrimPrefix(destination, "/"))), nil
+	}
+	return s.objectUrl(destination)
+}
+
+func (s *s3Storage) Delete(ctx context.Context, destination string) error {
+	return s.do(ctx, http.MethodDelete, destination, "", nil, false)
+}
+
+func (s *s3Storage) SignedUrl(ctx context.Context, method, destination, contentType string, expires time.Duration) (string, error) {
+	objectUrl, err := s.objectUrl(destination)
+	if err != nil {
+		return "", err
+	}
+	return PresignV4(method, objectUrl, contentType, s.opts.AccessKey, s.opts.SecretKey, s.opts.Region, time.Now(), expires)
+}
+
+func (s *s3Storage) PresignPost(ctx context.Context, destination, contentType string, maxSize int64, expires time.Duration) (*PresignedPost, error) {
+	bucketUrl, err := s.bucketUrl()
+	if err != nil {
+		return nil, err
+	}
+	return PresignPostV4(
+		bucketUrl,
+		s.opts.Bucket,
+		strings.TrimPrefix(destination, "/"),
+		contentType,
+		maxSize,
+		s.opts.AccessKey,
+		s.opts.SecretKey,
+		s.opts.Region,
+		time.Now(),
+		expires,
+	)
+}
+
+// SignV4 : เซ็น request ด้วย AWS Signature Version 4 (service s3)
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func SignV4(req *http.Request, payload []byte, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// canonical headers : ชื่อตัวเล็ก เรียงตามตัวอักษร รวม host
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSha256(signingKey(secretKey, date, region), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
+
+// PresignV4 : สร้าง url ที่เซ็นไว้ใน query string ให้ client เรียก storage ได้ตรงๆ จนกว่าจะหมดอายุ
+// ถ้ามี contentType client ต้องส่ง header Content-Type ตรงกับที่เซ็นไว้
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+func PresignV4(method, rawUrl, contentType, accessKey, secretKey, region string, now time.Time, expires time.Duration) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", fmt.Errorf("presign url failed: %v", err)
+	}
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", accessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", fmt.Sprintf("%d", int(expires.Seconds())))
+	canonicalHeaders, signedHeaders := "host:"+u.Host+"\n", "host"
+	if contentType != "" {
+		canonicalHeaders, signedHeaders = "content-type:"+contentType+"\n"+canonicalHeaders, "content-type;host"
+	}
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		canonicalQuery(query),
+		canonicalHeaders,
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSha256(signingKey(secretKey, date, region), stringToSign))
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+// PresignPostV4 : สร้าง POST policy ให้ client upload ผ่าน form ได้ตรงๆ
+// storage จะตรวจ key, Content-Type และขนาดไฟล์ (content-length-range) ตาม policy ที่เซ็นไว้
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
+func PresignPostV4(bucketUrl, bucket, key, contentType string, maxSize int64, accessKey, secretKey, region string, now time.Time, expires time.Duration) (*PresignedPost, error) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	credential := fmt.Sprintf("%s/%s/%s/s3/aws4_request", accessKey, date, region)
+
+	policy, err := json.Marshal(map[string]any{
+		"expiration": now.Add(expires).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": []any{
+			map[string]string{"bucket": bucket},
+			map[string]string{"key": key},
+			map[string]string{"Content-Type": contentType},
+			[]any{"content-length-range", 1, maxSize},
+			map[string]string{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+			map[string]string{"x-amz-credential": credential},
+			map[string]string{"x-amz-date": amzDate},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal post policy failed: %v", err)
+	}
+	encodedPolicy := base64.StdEncoding.EncodeToString(policy)
+
+	return &PresignedPost{
+		Url: bucketUrl,
+		Fields: map[string]string{
+			"key":              key,
+			"Content-Type":     contentType,
+			"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+			"x-amz-credential": credential,
+			"x-amz-date":       amzDate,
+			"policy":           encodedPolicy,
+			"x-amz-signature":  hex.EncodeToString(hmacSha256(signingKey(secretKey, date, region), encodedPolicy)),
+		},
+	}, nil
+}
+
+func signingKey(secretKey, date, region string) []byte {
+	key := hmacSha256([]byte("AWS4"+secretKey), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, "s3")
+	return hmacSha256(key, "aws4_request")
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0)
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// escape : URI encode ตาม spec ของ AWS (เว้นเฉพาะ A-Z a-z 0-9 - _ . ~)
+func escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func escapePath(value string) string {
+	segments := strings.Split(value, "/")
+	for i := range segments {
+		segments[i] = escape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,58 @@
+package filesStorages
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+)
+
+// IStorage : ที่เก็บไฟล์ destination คือ path ของไฟล์ใน storage eg. images/products/xxx.png
+type IStorage interface {
+	// Upload : ไฟล์ private จะไม่มี url ต้องขอ SignedUrl ทุกครั้งที่จะอ่าน
+	Upload(ctx context.Context, destination, contentType string, data []byte, public bool) (string, error)
+	Delete(ctx context.Context, destination string) error
+	// SignedUrl : url ชั่วคราวของไฟล์ private method GET = download, PUT = upload ตรงเข้า storage
+	SignedUrl(ctx context.Context, method, destination, contentType string, expires time.Duration) (string, error)
+	// PresignPost : form สำหรับ upload ไฟล์ private ตรงเข้า storage โดยจำกัด content type และขนาดไฟล์
+	PresignPost(ctx context.Context, destination, contentType string, maxSize int64, expires time.Duration) (*PresignedPost, error)
+}
+
+// PresignedPost : client ส่ง POST multipart/form-data ไปที่ Url พร้อม Fields ทั้งหมด และ field file ไว้ท้ายสุด
+type PresignedPost struct {
+	Url    string
+	Fields map[string]string
+}
+
+const (
+	DriverGCS   = "gcs"
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+func NewStorage(cfg config.IConfig) IStorage {
+	switch cfg.Storage().Driver() {
+	case DriverLocal:
+		url := cfg.Storage().LocalUrl()
+		if url == "" {
+			url = fmt.Sprintf("http://%s/v1/files", cfg.App().Url())
+		}
+		return LocalStorage(cfg.Storage().LocalPath(), url, cfg.Storage().SigningKey())
+	case DriverS3:
+		return S3Storage(&S3Options{
+			Endpoint:  cfg.Storage().S3Endpoint(),
+			Region:    cfg.Storage().S3Region(),
+			Bucket:    cfg.Storage().S3Bucket(),
+			AccessKey: cfg.Storage().S3AccessKey(),
+			SecretKey: cfg.Storage().S3SecretKey(),
+			PublicUrl: cfg.Storage().S3PublicUrl(),
+			PathStyle: cfg.Storage().S3PathStyle(),
+		})
+	case DriverGCS:
+		return GCSStorage(cfg.App().GcpBucket())
+	}
+	log.Fatalf("storage driver %q is not supported", cfg.Storage().Driver())
+	return nil
+}
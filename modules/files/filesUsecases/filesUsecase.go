@@ -1,49 +1,41 @@
 package filesUsecases
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesStorages"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/imaging"
 )
 
 type IFilesUsecase interface {
-	UploadToGCP(req []*files.FileReq) ([]*files.FileRes, error)
-	DeleteFileOnGCP(req []*files.DeleteFileReq) error
+	UploadFiles(req []*files.FileReq) ([]*files.FileRes, error)
+	DeleteFiles(req []*files.DeleteFileReq) error
+	SignedUrl(destination string) (*files.SignedUrlRes, error)
+	PresignUpload(req *files.PresignReq) (*files.SignedUrlRes, error)
+	FindSignedFile(destination, expires, signature string) (string, error)
+	UploadSignedFile(req *files.SignedUploadReq) error
 }
 
 type filesUsecase struct {
-	cfg config.IConfig
+	cfg     config.IConfig
+	storage filesStorages.IStorage
 }
 
 func FilesUsecase(cfg config.IConfig) IFilesUsecase {
 	return &filesUsecase{
-		cfg: cfg,
+		cfg:     cfg,
+		storage: filesStorages.NewStorage(cfg),
 	}
 }
 
-type filesPub struct {
-	bucket      string
-	destination string
-	file        *files.FileRes
-}
-
-// MakePublic gives all users read access to an object.
-func (f *filesPub) makePublic(ctx context.Context, client *storage.Client) error {
-	acl := client.Bucket(f.bucket).Object(f.destination).ACL()
-	if err := acl.Set(ctx, storage.AllUsers, storage.RoleReader); err != nil {
-		return fmt.Errorf("ACLHandle.Set: %w", err)
-	}
-	fmt.Printf("Blob %v is now publicly accessible.\n", f.destination)
-	return nil
-}
-
-func (u *filesUsecase) uploadWorkers(ctx context.Context, client *storage.Client, jobs <-chan *files.FileReq, results chan<- *files.FileRes, errs chan<- error) {
+func (u *filesUsecase) uploadWorkers(ctx context.Context, jobs <-chan *files.FileReq, results chan<- *files.FileRes, errs chan<- error) {
 	for job := range jobs {
 		container, err := job.File.Open()
 		if err != nil {
@@ -52,58 +44,56 @@ func (u *filesUsecase) uploadWorkers(ctx context.Context, client *storage.Client
 		}
 
 		b, err := io.ReadAll(container)
+		container.Close()
 		if err != nil {
 			errs <- err
 			return
 		}
 
-		buf := bytes.NewBuffer(b)
-
-		// Upload an object with storage.Writer.
-		wc := client.Bucket(u.cfg.App().GcpBucket()).Object(job.Destination).NewWriter(ctx)
-
-		if _, err = io.Copy(wc, buf); err != nil {
-			errs <- fmt.Errorf("io.Copy: %w", err)
+		// ตรวจสอบ format จาก magic bytes, ตัด EXIF และสร้างรูปหลายขนาด
+		img, err := imaging.Process(b, job.Extenstion)
+		if err != nil {
+			errs <- err
 			return
 		}
 
-		// Data can continue to be added to the file until the writer is closed.
-		if err := wc.Close(); err != nil {
-			errs <- fmt.Errorf("Writer.Close: %w", err)
+		public := files.IsPublicDestination(job.Destination)
+		url, err := u.storage.Upload(ctx, job.Destination, img.ContentType, img.Original, public)
+		if err != nil {
+			errs <- err
 			return
 		}
-		fmt.Printf("%v uploaded to %v.\n", job.FileName, job.Destination)
 
-		newFile := &filesPub{
-			file: &files.FileRes{
-				FileName: job.FileName,
-				Url:      fmt.Sprintf("https://storage.googleapis.com/%s/%s", u.cfg.App().GcpBucket(), job.Destination),
-			},
-			bucket:      u.cfg.App().GcpBucket(),
-			destination: job.Destination,
-		}
-
-		if err := newFile.makePublic(ctx, client); err != nil {
-			errs <- err
-			return
+		// ไฟล์ private eg. สลิป ไม่ต้องมีหลายขนาด
+		renditions := make(entities.Renditions)
+		for _, r := range imaging.Renditions {
+			if !public {
+				break
+			}
+			destination := imaging.RenditionFileName(job.Destination, r.Name)
+			renditionUrl, err := u.storage.Upload(ctx, destination, img.ContentType, img.Renditions[r.Name], public)
+			if err != nil {
+				errs <- err
+				return
+			}
+			renditions[r.Name] = renditionUrl
 		}
+		fmt.Printf("%v uploaded to %v.\n", job.FileName, job.Destination)
 
 		errs <- nil
-		results <- newFile.file
+		results <- &files.FileRes{
+			FileName:    job.FileName,
+			Url:         url,
+			Destination: job.Destination,
+			Renditions:  renditions,
+		}
 	}
 }
 
-func (u *filesUsecase) UploadToGCP(req []*files.FileReq) ([]*files.FileRes, error) {
+func (u *filesUsecase) UploadFiles(req []*files.FileReq) ([]*files.FileRes, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
 	defer cancel()
 
-	// Contnect to cloud storage
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("storage.NewClient: %w", err)
-	}
-	defer client.Close()
-
 	// Defind cahnnel for pool worker
 	jobsCh := make(chan *files.FileReq, len(req))
 	resultsCh := make(chan *files.FileRes, len(req))
@@ -118,7 +108,7 @@ func (u *filesUsecase) UploadToGCP(req []*files.FileReq) ([]*files.FileRes, erro
 
 	numsWorker := 5
 	for i := 0; i < numsWorker; i++ {
-		go u.uploadWorkers(ctx, client, jobsCh, resultsCh, errsCh)
+		go u.uploadWorkers(ctx, jobsCh, resultsCh, errsCh)
 	}
 
 	for a := 0; a < len(req); a++ {
@@ -133,19 +123,10 @@ func (u *filesUsecase) UploadToGCP(req []*files.FileReq) ([]*files.FileRes, erro
 	return res, nil
 }
 
-func (u *filesUsecase) deleteFileWorkers(ctx context.Context, client *storage.Client, jobs <-chan *files.DeleteFileReq, errs chan<- error) {
+func (u *filesUsecase) deleteFileWorkers(ctx context.Context, jobs <-chan *files.DeleteFileReq, errs chan<- error) {
 	for job := range jobs {
-		o := client.Bucket(u.cfg.App().GcpBucket()).Object(job.Destination)
-
-		attrs, err := o.Attrs(ctx)
-		if err != nil {
-			errs <- fmt.Errorf("object.Attrs: %w", err)
-			return
-		}
-		o = o.If(storage.Conditions{GenerationMatch: attrs.Generation})
-
-		if err := o.Delete(ctx); err != nil {
-			errs <- fmt.Errorf("Object(%q).Delete: %w", job.Destination, err)
+		if err := u.storage.Delete(ctx, job.Destination); err != nil {
+			errs <- err
 			return
 		}
 		fmt.Printf("Blob %v deleted.\n", job.Destination)
@@ -153,16 +134,10 @@ func (u *filesUsecase) deleteFileWorkers(ctx context.Context, client *storage.Cl
 	}
 }
 
-func (u *filesUsecase) DeleteFileOnGCP(req []*files.DeleteFileReq) error {
+func (u *filesUsecase) DeleteFiles(req []*files.DeleteFileReq) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
 	defer cancel()
 
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		return fmt.Errorf("storage.NewClient: %w", err)
-	}
-	defer client.Close()
-
 	jobsCh := make(chan *files.DeleteFileReq, len(req))
 	errsCh := make(chan error, len(req))
 
@@ -173,7 +148,7 @@ func (u *filesUsecase) DeleteFileOnGCP(req []*files.DeleteFileReq) error {
 
 	numsWorker := 5
 	for i := 0; i < numsWorker; i++ {
-		go u.deleteFileWorkers(ctx, client, jobsCh, errsCh)
+		go u.deleteFileWorkers(ctx, jobsCh, errsCh)
 	}
 
 	for a := 0; a < len(req); a++ {
@@ -184,3 +159,69 @@ func (u *filesUsecase) DeleteFileOnGCP(req []*files.DeleteFileReq) error {
 	}
 	return nil
 }
+
+func (u *filesUsecase) SignedUrl(destination string) (*files.SignedUrlRes, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	url, err := u.storage.SignedUrl(ctx, http.MethodGet, destination, "", files.SignedUrlExpires)
+	if err != nil {
+		return nil, err
+	}
+	return &files.SignedUrlRes{
+		Url:         url,
+		Method:      http.MethodGet,
+		Destination: destination,
+		ExpiresAt:   time.Now().Add(files.SignedUrlExpires).Format(time.RFC3339),
+	}, nil
+}
+
+func (u *filesUsecase) PresignUpload(req *files.PresignReq) (*files.SignedUrlRes, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	// upload ตรงจะไม่ผ่าน imaging จึงให้ upload ได้เฉพาะไฟล์ private
+	if files.IsPublicDestination(req.Destination) {
+		return nil, fmt.Errorf("destination must be private")
+	}
+
+	// storage ตรวจขนาดไฟล์เองตาม policy เพราะไฟล์ไม่ผ่าน server
+	post, err := u.storage.PresignPost(ctx, req.Destination, req.ContentType, int64(u.cfg.App().FileLimit()), files.SignedUrlExpires)
+	if err != nil {
+		return nil, err
+	}
+	return &files.SignedUrlRes{
+		Url:         post.Url,
+		Method:      http.MethodPost,
+		Fields:      post.Fields,
+		Destination: req.Destination,
+		ExpiresAt:   time.Now().Add(files.SignedUrlExpires).Format(time.RFC3339),
+	}, nil
+}
+
+// FindSignedFile : local storage เท่านั้น คืน path ของไฟล์ให้ handler ส่งกลับไป
+func (u *filesUsecase) FindSignedFile(destination, expires, signature string) (string, error) {
+	storage, ok := u.storage.(filesStorages.ILocalStorage)
+	if !ok {
+		return "", fmt.Errorf("signed url is not supported")
+	}
+	return storage.VerifySignedUrl(http.MethodGet, destination, expires, signature)
+}
+
+// UploadSignedFile : local storage เท่านั้น รับไฟล์ที่ upload ตรงผ่าน form ของ PresignPost
+func (u *filesUsecase) UploadSignedFile(req *files.SignedUploadReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	storage, ok := u.storage.(filesStorages.ILocalStorage)
+	if !ok {
+		return fmt.Errorf("signed url is not supported")
+	}
+	if err := storage.VerifyPresignedPost(req.Destination, req.ContentType, req.MaxSize, req.Expires, req.Signature, int64(len(req.Data))); err != nil {
+		return err
+	}
+	if _, err := storage.Upload(ctx, req.Destination, req.ContentType, req.Data, false); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,7 +1,100 @@
 // Middlewares : ตัวกลางระหว่าง user กับ api
 package middlewares
 
-type Role struct {
-	Id    int    `db:"id"`
-	Title string `db:"title"`
+import "strings"
+
+// permission ของ role แต่ละ route ระบุ permission ที่ต้องการใน Authorize
+// :any = ทำกับข้อมูลของ user อื่นได้ (ไม่มี = เฉพาะของตัวเอง)
+// permission ใหม่ต้องเพิ่มใน table permissions ด้วย (migration)
+const (
+	PermUsersReadAny    = "users:read:any"
+	PermUsersWriteAny   = "users:write:any"
+	PermAdminsWrite     = "admins:write"
+	PermRolesRead       = "roles:read"
+	PermRolesWrite      = "roles:write"
+	PermApiKeysRead     = "apikeys:read"
+	PermApiKeysWrite    = "apikeys:write"
+	PermCategoriesWrite = "categories:write"
+	PermProductsWrite   = "products:write"
+	PermStockRead       = "stock:read"
+	PermStockWrite      = "stock:write"
+	PermFilesWrite      = "files:write"
+	PermOrdersReadAny   = "orders:read:any"
+	PermOrdersWriteAny  = "orders:write:any"
+	PermOrdersFulfil    = "orders:fulfil" // เปลี่ยน status ตามขั้นตอนจัดส่ง (warehouse)
+	PermPaymentsReadAny = "payments:read:any"
+	PermPromotionsRead  = "promotions:read"
+	PermPromotionsWrite = "promotions:write"
+	PermReportsExport   = "reports:export"
+)
+
+// Permissions : permission ของ role ของ user ที่ JwtAuth set ไว้ใน Locals("userPermissions")
+type Permissions []string
+
+// Has : ต้องมีทุก permission ที่ระบุ
+func (p Permissions) Has(permissions ...string) bool {
+	for _, permission := range permissions {
+		found := false
+		for _, v := range p {
+			if v == permission {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAny : มี permission ใดก็ได้ที่ระบุ
+func (p Permissions) HasAny(permissions ...string) bool {
+	for _, permission := range permissions {
+		if p.Has(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaff : มี permission :any อย่างน้อยหนึ่งตัว = ทำกับข้อมูลของ user อื่นได้ (admin และ staff role)
+func (p Permissions) IsStaff() bool {
+	for _, v := range p {
+		if strings.HasSuffix(v, ":any") {
+			return true
+		}
+	}
+	return false
+}
+
+// scope ของ api key แต่ละ route ระบุ scope ที่ต้องการใน ApiKeyAuth
+const (
+	ScopeUsers   = "users"   // signup, signin, refresh, signout
+	ScopeCatalog = "catalog" // ดู products และ categories
+)
+
+var Scopes = []string{ScopeUsers, ScopeCatalog}
+
+type ApiKey struct {
+	Id     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// HasScopes : key ต้องมีทุก scope ที่ route ต้องการ
+func (k *ApiKey) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		found := false
+		for _, s := range k.Scopes {
+			if s == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
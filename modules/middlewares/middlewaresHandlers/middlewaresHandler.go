@@ -5,9 +5,9 @@ import (
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares/middlewaresUsecases"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
-	"github.com/Montheankul-K/E-Commerce-Application-Backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -21,6 +21,7 @@ const (
 	paramsCheckErr middlewaresHandlersErrCode = "middleware-003"
 	authorizeErr   middlewaresHandlersErrCode = "middleware-004"
 	apiKeyErr      middlewaresHandlersErrCode = "middleware-005"
+	verifiedErr    middlewaresHandlersErrCode = "middleware-006"
 )
 
 type IMiddlewaresHandler interface {
@@ -28,9 +29,11 @@ type IMiddlewaresHandler interface {
 	RouterCheck() fiber.Handler // path check
 	Logger() fiber.Handler
 	JwtAuth() fiber.Handler
-	ParamsCheck() fiber.Handler
-	Authorize(expectRoleId ...int) fiber.Handler
-	ApiKeyAuth() fiber.Handler
+	ParamsCheck(permissions ...string) fiber.Handler
+	Authorize(permissions ...string) fiber.Handler
+	ApiKeyAuth(scopes ...string) fiber.Handler
+	ForgetApiKey(apiKeyId string)
+	VerifiedAuth() fiber.Handler
 }
 
 type middlewaresHandler struct {
@@ -96,18 +99,31 @@ func (h *middlewaresHandler) JwtAuth() fiber.Handler {
 			).Res()
 		}
 
+		// อ่าน permission จาก database (cache) ไม่ใช้ใน token เพราะ token ที่ออกก่อนแก้ role ยังใช้ได้อยู่
+		permissions, err := h.middlewaresUsecase.FindRolePermissions(claims.RoleId)
+		if err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(JwtAuthErr),
+				err.Error(),
+			).Res()
+		}
+
 		// set payload (fiber cache) เพื่อเอาไปใช้งานต่อ
-		// set user id, role id
+		// set user id, role id, session id, permissions
 		c.Locals("userId", claims.Id)
 		c.Locals("userRoleId", claims.RoleId)
+		c.Locals("sessionId", claims.SessionId)
+		c.Locals("userPermissions", permissions)
 		return c.Next() // เรียกใช้ handler ตัวถัดไปเมื่อทำงานเสร็จ
 	}
 }
 
-func (h *middlewaresHandler) ParamsCheck() fiber.Handler {
+// ParamsCheck : user_id ต้องเป็นของตัวเอง ยกเว้นมี permission ใดก็ได้ที่ระบุ (ไม่ระบุ = เฉพาะของตัวเอง)
+func (h *middlewaresHandler) ParamsCheck(permissions ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userId := c.Locals("userId") // get value from cache
-		if c.Locals("userRoleId").(int) == 2 {
+		if userPermissions, ok := c.Locals("userPermissions").(middlewares.Permissions); ok && userPermissions.HasAny(permissions...) {
 			return c.Next()
 		}
 		if c.Params("user_id") != userId {
@@ -121,57 +137,98 @@ func (h *middlewaresHandler) ParamsCheck() fiber.Handler {
 	}
 }
 
-func (h *middlewaresHandler) Authorize(expectRoleId ...int) fiber.Handler { // รับ params เข้ามาได้หลายตัว โดยจะเป็น array
+// Authorize : ใช้ต่อจาก JwtAuth role ของ user ต้องมีทุก permission ที่ระบุ
+func (h *middlewaresHandler) Authorize(permissions ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userRoleId, ok := c.Locals("userRoleId").(int) // convert to int
+		userPermissions, ok := c.Locals("userPermissions").(middlewares.Permissions)
 		if !ok {
 			return entities.NewResponse(c).Error(
 				fiber.ErrUnauthorized.Code,
 				string(authorizeErr),
-				"user_id is not int type",
+				"permissions are not found",
 			).Res()
 		}
 
-		roles, err := h.middlewaresUsecase.FindRole()
-		if err != nil {
+		if !userPermissions.Has(permissions...) {
 			return entities.NewResponse(c).Error(
-				fiber.ErrInternalServerError.Code,
+				fiber.ErrUnauthorized.Code,
 				string(authorizeErr),
-				err.Error(),
+				"no permission to access",
 			).Res()
 		}
+		return c.Next()
+	}
+}
 
-		sum := 0
-		for _, v := range expectRoleId {
-			sum += v
+// ApiKeyAuth : key ต้องมีอยู่ใน api_keys ยังไม่ถูก revoke และมีทุก scope ที่ route ต้องการ
+func (h *middlewaresHandler) ApiKeyAuth(scopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("X-Api-Key") // header X-Api-Key (field มาตรฐาน)
+		if key == "" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrUnauthorized.Code,
+				string(apiKeyErr),
+				"api key is invalid or required",
+			).Res()
 		}
 
-		// convert to binary
-		expectedValueBinary := utils.BinaryConverter(sum, len(roles))
-		userValueBinary := utils.BinaryConverter(userRoleId, len(roles))
-
-		// bitwise operator
-		for i := range userValueBinary {
-			if userValueBinary[i]&expectedValueBinary[i] == 1 {
-				return c.Next()
+		apiKey, err := h.middlewaresUsecase.FindApiKey(key)
+		if err != nil {
+			if err.Error() != "api key not found" {
+				return entities.NewResponse(c).Error(
+					fiber.ErrInternalServerError.Code,
+					string(apiKeyErr),
+					err.Error(),
+				).Res()
+			}
+			if apiKey = h.legacyApiKey(key); apiKey == nil {
+				return entities.NewResponse(c).Error(
+					fiber.ErrUnauthorized.Code,
+					string(apiKeyErr),
+					"api key is invalid or required",
+				).Res()
 			}
 		}
-		return entities.NewResponse(c).Error(
-			fiber.ErrUnauthorized.Code,
-			string(authorizeErr),
-			"no permission to access",
-		).Res()
+		if !apiKey.HasScopes(scopes...) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrForbidden.Code,
+				string(apiKeyErr),
+				"api key has no permission to access",
+			).Res()
+		}
+
+		c.Locals("apiKeyId", apiKey.Id)
+		return c.Next()
 	}
 }
 
-func (h *middlewaresHandler) ApiKeyAuth() fiber.Handler {
+// legacyApiKey : deprecated api key แบบ jwt (ก่อนมี api_keys) ใช้ได้ทุก scope เหมือนเดิม revoke ไม่ได้
+func (h *middlewaresHandler) legacyApiKey(key string) *middlewares.ApiKey {
+	if !h.cfg.Auth().LegacyApiKey() {
+		return nil
+	}
+	if _, err := authentication.ParseApiKey(h.cfg.Jwt(), key); err != nil {
+		return nil
+	}
+	return &middlewares.ApiKey{
+		Name:   "legacy",
+		Scopes: middlewares.Scopes,
+	}
+}
+
+func (h *middlewaresHandler) ForgetApiKey(apiKeyId string) {
+	h.middlewaresUsecase.ForgetApiKey(apiKeyId)
+}
+
+// VerifiedAuth : ใช้ต่อจาก JwtAuth อ่านจาก database เพราะ token ที่ออกก่อนยืนยัน email ยังใช้ได้อยู่
+func (h *middlewaresHandler) VerifiedAuth() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		key := c.Get("X-Api-Key") // header X-Api-Key (field มาตรฐาน)
-		if _, err := authentication.ParseApiKey(h.cfg.Jwt(), key); err != nil {
+		userId, _ := c.Locals("userId").(string)
+		if !h.middlewaresUsecase.FindEmailVerified(userId) {
 			return entities.NewResponse(c).Error(
-				fiber.ErrUnauthorized.Code,
-				string(apiKeyErr),
-				"api key is invalid or required",
+				fiber.ErrForbidden.Code,
+				string(verifiedErr),
+				"email is not verified",
 			).Res()
 		}
 		return c.Next()
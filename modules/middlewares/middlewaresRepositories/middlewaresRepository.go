@@ -1,15 +1,20 @@
 package middlewaresRepositories
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
 	"github.com/jmoiron/sqlx"
 )
 
 type IMiddlewaresRepository interface {
 	FindAccessToken(userId, accessToken string) bool
-	FindRole() ([]*middlewares.Role, error)
+	FindRolePermissions(roleId int) ([]string, error)
+	FindApiKey(key string) (*middlewares.ApiKey, error)
+	FindEmailVerified(userId string) bool
 }
 
 type middlewaresRepository struct {
@@ -31,23 +36,65 @@ func (r *middlewaresRepository) FindAccessToken(userId, accessToken string) bool
 	AND "access_token" = $2;`
 
 	var check bool
-	if err := r.db.Get(&check, query, userId, accessToken); err != nil {
+	if err := r.db.Get(&check, query, userId, authentication.HashToken(accessToken)); err != nil {
 		return false
 	}
-	return true
+	return check
 }
 
-func (r *middlewaresRepository) FindRole() ([]*middlewares.Role, error) {
+func (r *middlewaresRepository) FindRolePermissions(roleId int) ([]string, error) {
 	query := `
-	SELECT 
-		"id",
-		"title"
-	FROM "roles"
-	ORDER BY "id" DESC;`
-
-	roles := make([]*middlewares.Role, 0)
-	if err := r.db.Select(&roles, query); err != nil { // select ใช้กับหลาย row
-		return nil, fmt.Errorf("roles are empty")
+	SELECT
+		"permission"
+	FROM "roles_permissions"
+	WHERE "role_id" = $1
+	ORDER BY "permission";`
+
+	permissions := make([]string, 0)
+	if err := r.db.Select(&permissions, query, roleId); err != nil {
+		return nil, fmt.Errorf("get permissions failed: %v", err)
+	}
+	return permissions, nil
+}
+
+// FindApiKey : key ที่ยังไม่ถูก revoke พร้อมบันทึก last_used_at (ถูกเรียกเฉพาะตอน cache หมดอายุ)
+func (r *middlewaresRepository) FindApiKey(key string) (*middlewares.ApiKey, error) {
+	query := `
+	UPDATE "api_keys" SET
+		"last_used_at" = now()
+	WHERE "key_hash" = $1
+	AND "revoked_at" IS NULL
+	RETURNING json_build_object(
+		'id', "id",
+		'name', "name",
+		'scopes', "scopes"
+	);`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, authentication.HashToken(key)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("api key not found")
+		}
+		return nil, fmt.Errorf("get api key failed: %v", err)
+	}
+
+	apiKey := new(middlewares.ApiKey)
+	if err := json.Unmarshal(raw, apiKey); err != nil {
+		return nil, fmt.Errorf("unmarshal api key failed: %v", err)
+	}
+	return apiKey, nil
+}
+
+func (r *middlewaresRepository) FindEmailVerified(userId string) bool {
+	query := `
+	SELECT
+		("email_verified_at" IS NOT NULL)
+	FROM "users"
+	WHERE "id" = $1;`
+
+	var verified bool
+	if err := r.db.Get(&verified, query, userId); err != nil {
+		return false
 	}
-	return roles, nil
+	return verified
 }
@@ -1,17 +1,42 @@
 package middlewaresUsecases
 
 import (
+	"sync"
+	"time"
+
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares/middlewaresRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
 )
 
 type IMiddlewaresUsecase interface {
 	FindAccessToken(userId, accessToken string) bool
-	FindRole() ([]*middlewares.Role, error)
+	FindRolePermissions(roleId int) (middlewares.Permissions, error)
+	FindApiKey(key string) (*middlewares.ApiKey, error)
+	ForgetApiKey(apiKeyId string)
+	FindEmailVerified(userId string) bool
+}
+
+// apiKeyCacheTtl : key ที่ถูก revoke ยังใช้ได้ไม่เกินเวลานี้ใน instance อื่น (instance ที่ revoke ล้าง cache ทันที)
+const apiKeyCacheTtl = time.Minute
+
+type apiKeyCacheItem struct {
+	apiKey    *middlewares.ApiKey
+	expiresAt time.Time
+}
+
+// permissionsCacheTtl : การแก้ permission ของ role มีผลกับทุก instance ไม่เกินเวลานี้
+const permissionsCacheTtl = time.Minute
+
+type permissionsCacheItem struct {
+	permissions middlewares.Permissions
+	expiresAt   time.Time
 }
 
 type middlewaresUsecase struct {
 	middlewaresRepository middlewaresRepositories.IMiddlewaresRepository
+	apiKeys               sync.Map // sha-256 ของ key -> *apiKeyCacheItem
+	permissions           sync.Map // role id -> *permissionsCacheItem
 }
 
 func MiddlewaresUsecase(middlewaresRepository middlewaresRepositories.IMiddlewaresRepository) IMiddlewaresUsecase {
@@ -24,10 +49,59 @@ func (u *middlewaresUsecase) FindAccessToken(userId, accessToken string) bool {
 	return u.middlewaresRepository.FindAccessToken(userId, accessToken)
 }
 
-func (u *middlewaresUsecase) FindRole() ([]*middlewares.Role, error) {
-	roles, err := u.middlewaresRepository.FindRole()
+// FindRolePermissions : ถูกเรียกทุก request ที่ผ่าน JwtAuth จึง cache ตาม role id (จำนวน role มีไม่มาก)
+func (u *middlewaresUsecase) FindRolePermissions(roleId int) (middlewares.Permissions, error) {
+	now := time.Now()
+	if item, ok := u.permissions.Load(roleId); ok {
+		if item := item.(*permissionsCacheItem); now.Before(item.expiresAt) {
+			return item.permissions, nil
+		}
+		u.permissions.Delete(roleId)
+	}
+
+	permissions, err := u.middlewaresRepository.FindRolePermissions(roleId)
+	if err != nil {
+		return nil, err
+	}
+	u.permissions.Store(roleId, &permissionsCacheItem{
+		permissions: permissions,
+		expiresAt:   now.Add(permissionsCacheTtl),
+	})
+	return permissions, nil
+}
+
+// FindApiKey : cache เฉพาะ key ที่ถูกต้อง key มั่วจึงไม่ทำให้ cache โตได้เรื่อยๆ
+func (u *middlewaresUsecase) FindApiKey(key string) (*middlewares.ApiKey, error) {
+	now := time.Now()
+	hash := authentication.HashToken(key)
+	if item, ok := u.apiKeys.Load(hash); ok {
+		if item := item.(*apiKeyCacheItem); now.Before(item.expiresAt) {
+			return item.apiKey, nil
+		}
+		u.apiKeys.Delete(hash)
+	}
+
+	apiKey, err := u.middlewaresRepository.FindApiKey(key)
 	if err != nil {
 		return nil, err
 	}
-	return roles, nil
+	u.apiKeys.Store(hash, &apiKeyCacheItem{
+		apiKey:    apiKey,
+		expiresAt: now.Add(apiKeyCacheTtl),
+	})
+	return apiKey, nil
+}
+
+// ForgetApiKey : ล้าง cache ของ key ที่ถูก revoke หรือแก้ scope ให้มีผลทันที
+func (u *middlewaresUsecase) ForgetApiKey(apiKeyId string) {
+	u.apiKeys.Range(func(hash, item any) bool {
+		if item.(*apiKeyCacheItem).apiKey.Id == apiKeyId {
+			u.apiKeys.Delete(hash)
+		}
+		return true
+	})
+}
+
+func (u *middlewaresUsecase) FindEmailVerified(userId string) bool {
+	return u.middlewaresRepository.FindEmailVerified(userId)
 }
@@ -1,6 +1,6 @@
 package monitor
 
 type Monitor struct {
-	Name    string `json: "name"`
-	Version string `json: "version"`
+	Name    string
+	Version string
 }
@@ -1,6 +1,12 @@
 package orders
 
 import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products"
 )
@@ -22,20 +28,203 @@ type Order struct {
 	Address      string           `db:"address" json:"address"`
 	Contact      string           `db:"contact" json:"contact"`
 	Status       string           `db:"status" json:"status"`
-	TotalPaid    float64          `db:"total_pain" json:"total_paid"`
+	Subtotal     float64          `db:"subtotal" json:"subtotal"`
+	CouponCode   string           `db:"coupon_code" json:"coupon_code"`
+	PromotionId  string           `db:"promotion_id" json:"promotion_id"`
+	Discount     float64          `db:"discount" json:"discount"`
+	ShippingFee  float64          `db:"shipping_fee" json:"shipping_fee"`
+	TotalPaid    float64          `db:"total_paid" json:"total_paid"`
 	CreatedAt    string           `db:"created_at" json:"created_at"`
 	UpdatedAt    string           `db:"updated_at" json:"updated_at"`
+	CartItemIds  []string         `db:"-" json:"-"` // checkout : cart item ที่ถูกลบใน transaction เดียวกับ order
 }
 
 type TransferSlip struct {
-	Id        string `json:"id"`
-	FileName  string `json:"filename"`
-	Url       string `json:"url"`
-	CreatedAt string `json:"created_at"`
+	Id          string `json:"id"`
+	FileName    string `json:"filename"`
+	Url         string `json:"url"`         // สลิปเก่าที่เป็น public เท่านั้น
+	Destination string `json:"destination"` // path ใน storage ของสลิป private ต้องขอ signed url เพื่อดู
+	CreatedAt   string `json:"created_at"`
+}
+
+type OrderStatusHistory struct {
+	Id         string `json:"id"`
+	OrderId    string `json:"order_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	ChangedBy  string `json:"changed_by"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// OrderReportFilter : ช่วงวันที่ต้องระบุเสมอ (YYYY-MM-DD)
+type OrderReportFilter struct {
+	Status    string `query:"status"`
+	StartDate string `query:"start_date"`
+	EndDate   string `query:"end_date"`
+}
+
+// OrderReport : ยอดของ order สำหรับ export (ไม่มีข้อมูลที่อยู่และการติดต่อของลูกค้า)
+type OrderReport struct {
+	Id          string  `db:"id"`
+	UserId      string  `db:"user_id"`
+	Status      string  `db:"status"`
+	CouponCode  string  `db:"coupon_code"`
+	Subtotal    float64 `db:"subtotal"`
+	Discount    float64 `db:"discount"`
+	ShippingFee float64 `db:"shipping_fee"`
+	TotalPaid   float64 `db:"total_paid"`
+	CreatedAt   string  `db:"created_at"`
+}
+
+// WriteOrderReport : เขียน report เป็น csv บรรทัดแรกเป็นชื่อ column
+func WriteOrderReport(w io.Writer, reports []*OrderReport) error {
+	price := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{
+		"id",
+		"user_id",
+		"status",
+		"coupon_code",
+		"subtotal",
+		"discount",
+		"shipping_fee",
+		"total_paid",
+		"created_at",
+	}); err != nil {
+		return fmt.Errorf("write order report failed: %v", err)
+	}
+	for _, r := range reports {
+		if err := writer.Write([]string{
+			r.Id,
+			r.UserId,
+			r.Status,
+			r.CouponCode,
+			price(r.Subtotal),
+			price(r.Discount),
+			price(r.ShippingFee),
+			price(r.TotalPaid),
+			r.CreatedAt,
+		}); err != nil {
+			return fmt.Errorf("write order report failed: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("write order report failed: %v", err)
+	}
+	return nil
+}
+
+// OrderActor : user ที่ทำการเปลี่ยน status ของ order
+type OrderActor struct {
+	UserId       string
+	IsAdmin      bool
+	IsFulfilment bool // warehouse : เปลี่ยน status ตามขั้นตอนจัดส่งของ order ใดก็ได้
 }
 
 type ProductsOrder struct {
-	Id      string            `db:"id" json:"id"`
-	Qty     int               `db:"qty" json:"qty"`
-	Product *products.Product `db:"product" json:"product"`
+	Id        string            `db:"id" json:"id"`
+	Qty       int               `db:"qty" json:"qty"`
+	VariantId string            `db:"variant_id" json:"variant_id"`
+	Product   *products.Product `db:"product" json:"product"`
+}
+
+// CalculateTotal คำนวณยอดจากราคา product ใน order (ต้องเป็นราคาจาก database เท่านั้น)
+func (o *Order) CalculateTotal() {
+	subtotal := 0.0
+	for _, p := range o.Products {
+		subtotal += p.Product.Price * float64(p.Qty)
+	}
+	o.Subtotal = roundPrice(subtotal)
+
+	if o.Discount > o.Subtotal {
+		o.Discount = o.Subtotal
+	}
+	o.TotalPaid = roundPrice(o.Subtotal - o.Discount + o.ShippingFee)
+}
+
+// ItemKey : key ของสินค้าใน order ที่ใช้รวมจำนวนและ lock stock (variant id หรือ product id)
+func (p *ProductsOrder) ItemKey() string {
+	if p.VariantId != "" {
+		return p.VariantId
+	}
+	return p.Product.Id
+}
+
+// SetSnapshot เก็บข้อมูล product (และ variant ที่เลือก) ณ ตอนสั่งซื้อ
+// product ที่มี variant ต้องสั่งซื้อด้วย variant id
+func (p *ProductsOrder) SetSnapshot(prod *products.Product) error {
+	if p.VariantId != "" {
+		variant := prod.FindVariant(p.VariantId)
+		if variant == nil {
+			return fmt.Errorf("variant %s not found", p.VariantId)
+		}
+		prod.Variant = variant
+	} else if len(prod.Variants) > 0 {
+		return fmt.Errorf("product %s requires variant", prod.Id)
+	}
+	prod.Variants = make([]*products.Variant, 0)
+
+	p.Product = prod
+	return nil
+}
+
+func roundPrice(price float64) float64 {
+	return math.Round(price*100) / 100
+}
+
+// statusTransitions : status ถัดไปที่ admin เปลี่ยนได้ (key = status ปัจจุบัน)
+// waiting -> paid เกิดจาก webhook ของ payment gateway เท่านั้น ส่วนการโอนผ่านสลิป admin เปลี่ยนเป็น shipping ได้เลย
+var statusTransitions = map[string][]string{
+	"waiting":   {"shipping", "canceled"},
+	"paid":      {"shipping", "canceled"},
+	"shipping":  {"completed"},
+	"completed": {},
+	"canceled":  {},
+}
+
+// customerTransitions : customer ยกเลิก order ของตัวเองได้ก่อน shipping เท่านั้น
+var customerTransitions = map[string][]string{
+	"waiting": {"canceled"},
+}
+
+// fulfilmentTransitions : warehouse จัดส่งได้เฉพาะ order ที่จ่ายแล้ว (การตรวจสลิปยังเป็นของ admin)
+var fulfilmentTransitions = map[string][]string{
+	"paid":     {"shipping"},
+	"shipping": {"completed"},
+}
+
+func IsStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+func hasTransition(transitions map[string][]string, from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func CanTransition(from, to string, isAdmin bool) bool {
+	if isAdmin {
+		return hasTransition(statusTransitions, from, to)
+	}
+	return hasTransition(customerTransitions, from, to)
+}
+
+// CanTransition : status ที่ actor เปลี่ยนได้ (isOwner = order เป็นของ actor)
+func (a *OrderActor) CanTransition(from, to string, isOwner bool) bool {
+	if a.IsAdmin {
+		return CanTransition(from, to, true)
+	}
+	if a.IsFulfilment && hasTransition(fulfilmentTransitions, from, to) {
+		return true
+	}
+	return isOwner && CanTransition(from, to, false)
 }
@@ -1,11 +1,15 @@
 package ordersHandlers
 
 import (
+	"bytes"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersUsecases"
 	"github.com/gofiber/fiber/v2"
@@ -17,15 +21,27 @@ type IOrdersHandler interface {
 	FindOrder(c *fiber.Ctx) error
 	InsertOrder(c *fiber.Ctx) error
 	UpdateOrder(c *fiber.Ctx) error
+	FindOrderStatusHistory(c *fiber.Ctx) error
+	FindTransferSlip(c *fiber.Ctx) error
+	ExportOrder(c *fiber.Ctx) error
 }
 
 type ordersHandlersErrCode string
 
 const (
-	findOneOrderErr ordersHandlersErrCode = "orders-001"
-	findOrderErr    ordersHandlersErrCode = "orders-002"
-	insertOrderErr  ordersHandlersErrCode = "orders-003"
-	updateOrderErr  ordersHandlersErrCode = "orders-004"
+	findOneOrderErr      ordersHandlersErrCode = "orders-001"
+	findOrderErr         ordersHandlersErrCode = "orders-002"
+	insertOrderErr       ordersHandlersErrCode = "orders-003"
+	updateOrderErr       ordersHandlersErrCode = "orders-004"
+	updateOrderStatusErr ordersHandlersErrCode = "orders-005"
+	findOrderHistoryErr  ordersHandlersErrCode = "orders-006"
+	couponNotFoundErr    ordersHandlersErrCode = "orders-007"
+	couponExpiredErr     ordersHandlersErrCode = "orders-008"
+	couponMinSpendErr    ordersHandlersErrCode = "orders-009"
+	couponUsageLimitErr  ordersHandlersErrCode = "orders-010"
+	couponNotApplyErr    ordersHandlersErrCode = "orders-011"
+	findTransferSlipErr  ordersHandlersErrCode = "orders-012"
+	exportOrderErr       ordersHandlersErrCode = "orders-013"
 )
 
 type ordersHandler struct {
@@ -41,16 +57,25 @@ func OrdersHandler(cfg config.IConfig, ordersUsecase ordersUsecases.IOrdersUseca
 }
 
 func (h *ordersHandler) FindOneOrder(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
 	orderId := strings.Trim(c.Params("order_id"), " ")
 
 	order, err := h.ordersUsecase.FindOneOrder(orderId)
 	if err != nil {
-		entities.NewResponse(c).Error(
+		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(findOneOrderErr),
 			err.Error(),
 		).Res()
 	}
+	// ParamsCheck check แค่ user_id ของ path ส่วน order ต้องเป็นของ user คนนั้นด้วย
+	if order.UserId != userId && !c.Locals("userPermissions").(middlewares.Permissions).Has(middlewares.PermOrdersReadAny) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrNotFound.Code,
+			string(findOneOrderErr),
+			"order not found",
+		).Res()
+	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, order).Res()
 }
 
@@ -76,10 +101,10 @@ func (h *ordersHandler) FindOrder(c *fiber.Ctx) error {
 		req.Limit = 5
 	}
 
-	// sort
+	// sort : column จริงจะถูก map ใน builder
 	orderByMap := map[string]string{
-		"id":         `"o"."id"`,
-		"created_at": `"o"."created_at"`,
+		"id":         "id",
+		"created_at": "created_at",
 	}
 	if orderByMap[req.OrderBy] == "" {
 		req.OrderBy = orderByMap["id"]
@@ -94,6 +119,14 @@ func (h *ordersHandler) FindOrder(c *fiber.Ctx) error {
 		req.Sort = sortMap["DESC"]
 	}
 
+	if err := req.ParseCursor(c, req.OrderBy, req.Sort); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findOrderErr),
+			err.Error(),
+		).Res()
+	}
+
 	// date YYYY-MM-DD
 	// 2006 : year, 01 : month, 02 : day ในภาษา go
 	if req.StartDate != "" {
@@ -144,16 +177,40 @@ func (h *ordersHandler) InsertOrder(c *fiber.Ctx) error {
 			"products are empty",
 		).Res()
 	}
-	if c.Locals("userRoleId").(int) != 2 {
-		// ถ้าเป็น admin จะไม่ได้มีการบันทึก userId ทำให้ insert ไม่ได้
+	if !c.Locals("userPermissions").(middlewares.Permissions).Has(middlewares.PermOrdersWriteAny) {
+		// ถ้าไม่มีสิทธิ์สร้าง order ให้ user อื่น ใช้ userId ของตัวเองเสมอ
 		req.UserId = userId
 	}
 
+	// สลิปแนบได้ตอน update เท่านั้น ซึ่ง check directory ของเจ้าของ order แล้ว
+	req.TransferSlip = nil
 	req.Status = "waiting"
+	req.CouponCode = strings.ToUpper(strings.TrimSpace(req.CouponCode))
+	req.PromotionId = ""
+	req.Subtotal = 0
+	req.Discount = 0
+	req.ShippingFee = h.cfg.App().ShippingFee()
 	req.TotalPaid = 0
 
 	order, err := h.ordersUsecase.InsertOrder(req)
 	if err != nil {
+		if code, ok := couponErrCode(err); ok {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(code),
+				err.Error(),
+			).Res()
+		}
+		if err.Error() == "product qty is invalid" ||
+			strings.HasSuffix(err.Error(), "is out of stock") ||
+			strings.HasSuffix(err.Error(), "requires variant") ||
+			(strings.HasPrefix(err.Error(), "variant ") && strings.HasSuffix(err.Error(), "not found")) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertOrderErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(insertOrderErr),
@@ -175,20 +232,32 @@ func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
 	}
 	req.Id = orderId
 
-	statusMap := map[string]string{
-		"waiting":   "waiting",
-		"shipping":  "shjjipping",
-		"completed": "completed",
-		"canceled":  "canceled",
+	// status ที่เปลี่ยนได้ตาม role ถูก check อีกครั้งกับ status ปัจจุบันของ order ตอน update
+	req.Status = strings.ToLower(strings.Trim(req.Status, " "))
+	if req.Status != "" && !orders.IsStatus(req.Status) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateOrderErr),
+			"status is invalid",
+		).Res()
 	}
-	if c.Locals("userRoleId").(int) == 2 {
-		// ให้ admin update ได้ทุก status
-		req.Status = statusMap[strings.ToLower(req.Status)]
-	} else if strings.ToLower(req.Status) == statusMap["canceled"] {
-		req.Status = statusMap["canceled"]
+	permissions := c.Locals("userPermissions").(middlewares.Permissions)
+	actor := &orders.OrderActor{
+		UserId:       c.Locals("userId").(string),
+		IsAdmin:      permissions.Has(middlewares.PermOrdersWriteAny),
+		IsFulfilment: permissions.Has(middlewares.PermOrdersFulfil),
 	}
 
 	if req.TransferSlip != nil {
+		// สลิปต้องอยู่ใน directory ของเจ้าของ order เท่านั้น กันการอ้างไฟล์ของคนอื่น
+		userId := strings.Trim(c.Params("user_id"), " ")
+		if req.TransferSlip.Destination != "" && !files.IsSlipOf(userId, req.TransferSlip.Destination) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateOrderErr),
+				"transfer slip is invalid",
+			).Res()
+		}
 		if req.TransferSlip.Id == "" {
 			req.TransferSlip.Id = uuid.NewString()
 		}
@@ -208,8 +277,28 @@ func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
 		}
 	}
 
-	order, err := h.ordersUsecase.UpdateOrder(req)
+	order, err := h.ordersUsecase.UpdateOrder(req, actor)
 	if err != nil {
+		switch err.Error() {
+		case "order not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateOrderErr),
+				err.Error(),
+			).Res()
+		case "order status transition is not allowed":
+			return entities.NewResponse(c).Error(
+				fiber.ErrConflict.Code,
+				string(updateOrderStatusErr),
+				err.Error(),
+			).Res()
+		case "transfer slip cannot be changed":
+			return entities.NewResponse(c).Error(
+				fiber.ErrForbidden.Code,
+				string(updateOrderErr),
+				err.Error(),
+			).Res()
+		}
 		return entities.NewResponse(c).Error(
 			fiber.ErrInternalServerError.Code,
 			string(updateOrderErr),
@@ -218,3 +307,138 @@ func (h *ordersHandler) UpdateOrder(c *fiber.Ctx) error {
 	}
 	return entities.NewResponse(c).Success(fiber.StatusCreated, order).Res()
 }
+
+func (h *ordersHandler) FindOrderStatusHistory(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	history, err := h.ordersUsecase.FindOrderStatusHistory(userId, orderId)
+	if err != nil {
+		switch err.Error() {
+		case "order not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findOrderHistoryErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(findOrderHistoryErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, history).Res()
+}
+
+func (h *ordersHandler) FindTransferSlip(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	orderId := strings.Trim(c.Params("order_id"), " ")
+
+	slip, err := h.ordersUsecase.FindTransferSlip(userId, orderId)
+	if err != nil {
+		switch err.Error() {
+		case "order not found", "transfer slip not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findTransferSlipErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(findTransferSlipErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, slip).Res()
+}
+
+// couponErrCode : error จากการใช้ coupon แยก code ให้ client แสดงเหตุผลที่ใช้ coupon ไม่ได้
+func couponErrCode(err error) (ordersHandlersErrCode, bool) {
+	switch err.Error() {
+	case "coupon not found":
+		return couponNotFoundErr, true
+	case "coupon is expired or inactive":
+		return couponExpiredErr, true
+	case "order does not reach coupon minimum spend":
+		return couponMinSpendErr, true
+	case "coupon usage limit reached", "coupon usage limit per user reached":
+		return couponUsageLimitErr, true
+	case "coupon is not applicable to products":
+		return couponNotApplyErr, true
+	}
+	return "", false
+}
+
+// orderReportMaxDays : ช่วงวันที่ยาวสุดของ report ต่อครั้ง (query ไม่ได้แบ่งหน้า)
+const orderReportMaxDays = 366
+
+// ExportOrder : report ยอดของ order ในช่วงวันที่เป็นไฟล์ csv
+func (h *ordersHandler) ExportOrder(c *fiber.Ctx) error {
+	req := new(orders.OrderReportFilter)
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportOrderErr),
+			err.Error(),
+		).Res()
+	}
+
+	req.Status = strings.ToLower(strings.Trim(req.Status, " "))
+	if req.Status != "" && !orders.IsStatus(req.Status) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportOrderErr),
+			"status is invalid",
+		).Res()
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportOrderErr),
+			"start date is invalid",
+		).Res()
+	}
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil || end.Before(start) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportOrderErr),
+			"end date is invalid",
+		).Res()
+	}
+	if end.Sub(start) >= orderReportMaxDays*24*time.Hour {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(exportOrderErr),
+			fmt.Sprintf("date range must not exceed %d days", orderReportMaxDays),
+		).Res()
+	}
+	req.StartDate = start.Format("2006-01-02")
+	req.EndDate = end.Format("2006-01-02")
+
+	reports, err := h.ordersUsecase.FindOrderReport(req)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(exportOrderErr),
+			err.Error(),
+		).Res()
+	}
+
+	buf := new(bytes.Buffer)
+	if err := orders.WriteOrderReport(buf, reports); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(exportOrderErr),
+			err.Error(),
+		).Res()
+	}
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="orders_%s_%s.csv"`, req.StartDate, req.EndDate))
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
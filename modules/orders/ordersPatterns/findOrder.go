@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders"
 	"github.com/jmoiron/sqlx"
 )
@@ -18,6 +19,7 @@ type IFindOrderBuilder interface {
 	buildWhereSearch()
 	buildWhereStatus()
 	buildWhereDate()
+	buildWhereCursor()
 	buildSort()
 	buildPaginate()
 	closeQuery()
@@ -28,6 +30,19 @@ type IFindOrderBuilder interface {
 	serLastIndex(n int)
 	getDb() *sqlx.DB
 	reset()
+	getReq() *orders.OrderFilter
+}
+
+// column ต้องมาจาก map เท่านั้น เพราะ ORDER BY ใช้ placeholder แทนชื่อ column ไม่ได้
+var orderOrderByMap = map[string]string{
+	"id":         `"o"."id"`,
+	"created_at": `"o"."created_at"`,
+}
+
+// type ของค่าใน cursor เมื่อเทียบกับ column
+var orderCursorCastMap = map[string]string{
+	"id":         "varchar",
+	"created_at": "timestamp",
 }
 
 type findOrderBuilder struct {
@@ -71,6 +86,7 @@ func (b *findOrderBuilder) initQuery() {
 					SELECT 
 						"spo"."id",
 						"spo"."qty",
+						COALESCE("spo"."variant_id", '') AS "variant_id",
 						"spo"."product"
 					FROM "products_orders" "spo"
 					WHERE "spo"."order_id" = "o"."id"
@@ -78,12 +94,12 @@ func (b *findOrderBuilder) initQuery() {
 			) AS "products",
 			"o"."address",
 			"o"."contact",
-			(
-				SELECT
-					SUM(COALESCE(("po"."product"->>prict')::FLOAT*("po"."qty")::FLOAT,0)
-				FROM "products_orders" "po"
-				WHERE "po"."order_id" = "o"."id"
-			) AS "total_paid"
+			COALESCE("o"."coupon_code", '') AS "coupon_code",
+			COALESCE("o"."promotion_id", '') AS "promotion_id",
+			"o"."subtotal",
+			"o"."discount",
+			"o"."shipping_fee",
+			"o"."total_paid",
 			"o"."created_at",
 			"o"."updated_at"
 		FROM "orders" "o"
@@ -167,16 +183,68 @@ func (b *findOrderBuilder) buildWhereDate() {
 	}
 }
 
-func (b *findOrderBuilder) buildSort() {
-	b.values = append(b.values, b.req.OrderBy)
+func (b *findOrderBuilder) buildWhereCursor() {
+	if !b.req.UseCursor || b.req.Position == nil {
+		return
+	}
 
-	b.query += fmt.Sprintf(`
-		ORDER BY $%d %s`, b.lastIndex+1, b.req.Sort)
+	operator := ">"
+	if b.direction() == "DESC" {
+		operator = "<"
+	}
+	b.values = append(b.values, b.req.Position.Value, b.req.Position.Id)
+
+	query := fmt.Sprintf(`
+		AND (%s, "o"."id") %s ($%d::%s, $%d)`,
+		b.orderBy(),
+		operator,
+		b.lastIndex+1,
+		orderCursorCastMap[b.req.OrderBy],
+		b.lastIndex+2,
+	)
+	temp := b.getQuery()
+	temp += query
+	b.setQuery(temp)
 
 	b.lastIndex = len(b.values)
 }
 
+func (b *findOrderBuilder) buildSort() {
+	b.query += fmt.Sprintf(`
+		ORDER BY %s %s, "o"."id" %s`, b.orderBy(), b.direction(), b.direction())
+}
+
+func (b *findOrderBuilder) orderBy() string {
+	if orderBy := orderOrderByMap[b.req.OrderBy]; orderBy != "" {
+		return orderBy
+	}
+	return orderOrderByMap["id"]
+}
+
+// direction : ทิศทางการเรียงใน query ตอนดึงหน้าก่อนหน้าจะกลับทิศแล้วค่อยกลับผลลัพธ์ทีหลัง
+func (b *findOrderBuilder) direction() string {
+	desc := b.req.Sort == "DESC"
+	if b.req.Position != nil && b.req.Position.Backward {
+		desc = !desc
+	}
+	if desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
 func (b *findOrderBuilder) buildPaginate() {
+	if b.req.UseCursor {
+		// ดึงเกินมา 1 record เพื่อดูว่ายังมีหน้าถัดไปไหม
+		b.values = append(b.values, b.req.Limit+1)
+
+		b.query += fmt.Sprintf(`
+		LIMIT $%d`, b.lastIndex+1)
+
+		b.lastIndex = len(b.values)
+		return
+	}
+
 	b.values = append(
 		b.values,
 		(b.req.Page-1)*b.req.Limit,
@@ -218,13 +286,17 @@ func (b *findOrderBuilder) getDb() *sqlx.DB {
 	return b.db
 }
 
+func (b *findOrderBuilder) getReq() *orders.OrderFilter {
+	return b.req
+}
+
 func (b *findOrderBuilder) reset() {
 	b.query = ""
 	b.values = make([]any, 0)
 	b.lastIndex = 0
 }
 
-func (en *findOrderEngineer) FindOrder() []*orders.Order {
+func (en *findOrderEngineer) FindOrder() ([]*orders.Order, *entities.PageCursor) {
 	_, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
 
@@ -232,6 +304,7 @@ func (en *findOrderEngineer) FindOrder() []*orders.Order {
 	en.builder.buildWhereSearch()
 	en.builder.buildWhereStatus()
 	en.builder.buildWhereDate()
+	en.builder.buildWhereCursor()
 	en.builder.buildSort()
 	en.builder.buildPaginate()
 	en.builder.closeQuery()
@@ -239,7 +312,8 @@ func (en *findOrderEngineer) FindOrder() []*orders.Order {
 	raw := make([]byte, 0)
 	if err := en.builder.getDb().Get(&raw, en.builder.getQuery(), en.builder.getValues()...); err != nil {
 		log.Printf("get orders failde: %v\n", err)
-		return make([]*orders.Order, 0)
+		en.builder.reset()
+		return make([]*orders.Order, 0), new(entities.PageCursor)
 	}
 
 	ordersData := make([]*orders.Order, 0)
@@ -248,7 +322,44 @@ func (en *findOrderEngineer) FindOrder() []*orders.Order {
 	}
 
 	en.builder.reset()
-	return ordersData
+	return en.cursorPage(ordersData)
+}
+
+// cursorPage : ตัด record ที่ดึงเกินมาและสร้าง next/prev cursor
+func (en *findOrderEngineer) cursorPage(ordersData []*orders.Order) ([]*orders.Order, *entities.PageCursor) {
+	req := en.builder.getReq()
+	if !req.UseCursor || len(ordersData) == 0 {
+		return ordersData, new(entities.PageCursor)
+	}
+
+	hasMore := len(ordersData) > req.Limit
+	if hasMore {
+		ordersData = ordersData[:req.Limit]
+	}
+	if req.Position != nil && req.Position.Backward {
+		for i, j := 0, len(ordersData)-1; i < j; i, j = i+1, j-1 {
+			ordersData[i], ordersData[j] = ordersData[j], ordersData[i]
+		}
+	}
+
+	cursorOf := func(order *orders.Order) *entities.Cursor {
+		value := order.Id
+		if req.OrderBy == "created_at" {
+			value = order.CreatedAt
+		}
+		return &entities.Cursor{
+			Id:      order.Id,
+			Value:   value,
+			OrderBy: req.OrderBy,
+			Sort:    req.Sort,
+		}
+	}
+	return ordersData, entities.NewPageCursor(
+		req.Position,
+		hasMore,
+		cursorOf(ordersData[0]),
+		cursorOf(ordersData[len(ordersData)-1]),
+	)
 }
 
 func (en *findOrderEngineer) CountOrder() int {
@@ -2,31 +2,53 @@ package ordersPatterns
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions"
 	"github.com/jmoiron/sqlx"
 )
 
 type IInsertOrderBuilder interface {
 	initTransaction() error
+	lockProducts() error
+	calculateTotal()
+	applyPromotion() error
 	insertOrder() error
 	insertProductOrder() error
+	insertStatusHistory() error
+	insertPromotionUsage() error
+	reserveStock() error
+	clearCart() error
 	getOrderId() string
 	commit() error
 }
 
 type insertOrderBuilder struct {
-	req *orders.Order
-	db  *sqlx.DB
-	tx  *sqlx.Tx
+	req            *orders.Order
+	db             *sqlx.DB
+	tx             *sqlx.Tx
+	productIds     []string       // เรียงตาม id เพื่อให้ lock row ลำดับเดียวกันทุก transaction (กัน deadlock)
+	variantIds     []string       // เรียงตาม id เช่นเดียวกับ productIds
+	qty            map[string]int // จำนวนรวมต่อ product หรือ variant (key จาก ItemKey)
+	prices         map[string]float64
+	variantProduct map[string]string // variant id -> product id
 }
 
 func InsertOrderBuilder(db *sqlx.DB, req *orders.Order) IInsertOrderBuilder {
 	return &insertOrderBuilder{
-		db:  db,
-		req: req,
+		db:             db,
+		req:            req,
+		productIds:     make([]string, 0),
+		variantIds:     make([]string, 0),
+		qty:            make(map[string]int),
+		prices:         make(map[string]float64),
+		variantProduct: make(map[string]string),
 	}
 }
 
@@ -43,6 +65,296 @@ func (b *insertOrderBuilder) initTransaction() error {
 	return nil
 }
 
+func (b *insertOrderBuilder) lockProducts() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	seen := make(map[string]bool)
+	for _, p := range b.req.Products {
+		if !seen[p.Product.Id] {
+			seen[p.Product.Id] = true
+			b.productIds = append(b.productIds, p.Product.Id)
+		}
+		if p.VariantId != "" {
+			if _, ok := b.variantProduct[p.VariantId]; !ok {
+				b.variantIds = append(b.variantIds, p.VariantId)
+			}
+			b.variantProduct[p.VariantId] = p.Product.Id
+		}
+		b.qty[p.ItemKey()] += p.Qty
+	}
+	sort.Strings(b.productIds)
+	sort.Strings(b.variantIds)
+
+	query := `
+	SELECT
+		"id",
+		"price",
+		"stock",
+		"id" AS "product_id"
+	FROM "products"
+	WHERE "id" IN (` + placeholders(len(b.productIds)) + `)
+	ORDER BY "id"
+	FOR UPDATE;`
+	// FOR UPDATE : lock row จนกว่า transaction จะจบ order อื่นที่ซื้อ product เดียวกันต้องรอ
+
+	productPrices, productStock, _, err := b.lockRows(ctx, query, b.productIds)
+	if err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("lock products failed: %v", err)
+	}
+
+	variantPrices := make(map[string]*float64)
+	variantStock := make(map[string]int)
+	variantOwner := make(map[string]string)
+	if len(b.variantIds) > 0 {
+		query = `
+	SELECT
+		"id",
+		"price",
+		"stock",
+		"product_id"
+	FROM "product_variants"
+	WHERE "id" IN (` + placeholders(len(b.variantIds)) + `)
+	ORDER BY "id"
+	FOR UPDATE;`
+
+		prices, stock, owner, err := b.lockRows(ctx, query, b.variantIds)
+		if err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("lock variants failed: %v", err)
+		}
+		variantPrices, variantStock, variantOwner = prices, stock, owner
+	}
+
+	for _, id := range b.productIds {
+		if _, ok := productStock[id]; !ok {
+			b.tx.Rollback()
+			return fmt.Errorf("product %s not found", id)
+		}
+		if qty, ok := b.qty[id]; ok {
+			if productStock[id] < qty {
+				b.tx.Rollback()
+				return fmt.Errorf("product %s is out of stock", id)
+			}
+			b.prices[id] = *productPrices[id]
+		}
+	}
+	for _, id := range b.variantIds {
+		if variantOwner[id] != b.variantProduct[id] {
+			b.tx.Rollback()
+			return fmt.Errorf("variant %s not found", id)
+		}
+		if variantStock[id] < b.qty[id] {
+			b.tx.Rollback()
+			return fmt.Errorf("variant %s is out of stock", id)
+		}
+
+		// variant ที่ไม่ได้กำหนดราคาใช้ราคาของ product
+		if variantPrices[id] != nil {
+			b.prices[id] = *variantPrices[id]
+		} else {
+			b.prices[id] = *productPrices[b.variantProduct[id]]
+		}
+	}
+	return nil
+}
+
+// lockRows : อ่าน price, stock และ product_id ของ row ที่ถูก lock
+func (b *insertOrderBuilder) lockRows(ctx context.Context, query string, ids []string) (map[string]*float64, map[string]int, map[string]string, error) {
+	values := make([]any, 0)
+	for _, id := range ids {
+		values = append(values, id)
+	}
+
+	rows, err := b.tx.QueryxContext(ctx, query, values...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rows.Close()
+
+	prices := make(map[string]*float64)
+	stock := make(map[string]int)
+	owner := make(map[string]string)
+	for rows.Next() {
+		var (
+			id        string
+			price     *float64
+			qty       int
+			productId string
+		)
+		if err := rows.Scan(&id, &price, &qty, &productId); err != nil {
+			return nil, nil, nil, err
+		}
+		prices[id] = price
+		stock[id] = qty
+		owner[id] = productId
+	}
+	return prices, stock, owner, rows.Err()
+}
+
+func (b *insertOrderBuilder) calculateTotal() {
+	// ใช้ราคาที่ lock ไว้ใน transaction เดียวกันเท่านั้น ไม่เชื่อราคาจาก client
+	for i := range b.req.Products {
+		b.req.Products[i].Product.Price = b.prices[b.req.Products[i].ItemKey()]
+	}
+	b.req.CalculateTotal()
+}
+
+func (b *insertOrderBuilder) applyPromotion() error {
+	if b.req.CouponCode == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// lock promotion กันการใช้ coupon พร้อมกันจนเกิน usage limit
+	query := `
+	SELECT
+		"id",
+		(
+			("starts_at" IS NULL OR "starts_at" <= now()) AND
+			("ends_at" IS NULL OR "ends_at" >= now())
+		) AS "in_period"
+	FROM "promotions"
+	WHERE "code" = $1
+	FOR UPDATE;`
+
+	var (
+		promotionId string
+		inPeriod    bool
+	)
+	if err := b.tx.QueryRowContext(ctx, query, b.req.CouponCode).Scan(&promotionId, &inPeriod); err != nil {
+		b.tx.Rollback()
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("coupon not found")
+		}
+		return fmt.Errorf("get promotion failed: %v", err)
+	}
+
+	query = `
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT
+			"pm"."id",
+			"pm"."code",
+			"pm"."type",
+			"pm"."value",
+			"pm"."min_spend",
+			"pm"."max_discount",
+			"pm"."usage_limit",
+			"pm"."usage_limit_per_user",
+			"pm"."used_count",
+			"pm"."is_active",
+			(
+				SELECT
+					COALESCE(array_agg("pmc"."category_id"), '{}')
+				FROM "promotions_categories" "pmc"
+				WHERE "pmc"."promotion_id" = "pm"."id"
+			) AS "category_ids",
+			(
+				SELECT
+					COALESCE(array_agg("pmp"."product_id"), '{}')
+				FROM "promotions_products" "pmp"
+				WHERE "pmp"."promotion_id" = "pm"."id"
+			) AS "product_ids"
+		FROM "promotions" "pm"
+		WHERE "pm"."id" = $1
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := b.tx.GetContext(ctx, &raw, query, promotionId); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get promotion failed: %v", err)
+	}
+	promotion := new(promotions.Promotion)
+	if err := json.Unmarshal(raw, promotion); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("unmarshal promotion failed: %v", err)
+	}
+
+	query = `
+	SELECT
+		COUNT(*) AS "count"
+	FROM "promotions_usages"
+	WHERE "promotion_id" = $1
+	AND "user_id" = $2;`
+
+	var userUsedCount int
+	if err := b.tx.GetContext(ctx, &userUsedCount, query, promotionId, b.req.UserId); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("count promotion usages failed: %v", err)
+	}
+
+	// category ของแต่ละ product รวม parent ทุกชั้น ใช้ตรวจ scope ของ promotion
+	// promotion ที่ผูกกับ category จึงใช้กับ product ใน sub category ได้ด้วย
+	query = `
+	WITH RECURSIVE "pc" AS (
+		SELECT
+			"product_id",
+			"category_id"
+		FROM "products_categories"
+		WHERE "product_id" IN (` + placeholders(len(b.productIds)) + `)
+		UNION
+		SELECT
+			"pc"."product_id",
+			"c"."parent_id"
+		FROM "pc"
+		JOIN "categories" "c"
+		ON "c"."id" = "pc"."category_id"
+		WHERE "c"."parent_id" IS NOT NULL
+	)
+	SELECT
+		"product_id",
+		"category_id"
+	FROM "pc";`
+
+	values := make([]any, 0)
+	for _, id := range b.productIds {
+		values = append(values, id)
+	}
+
+	type productCategory struct {
+		ProductId  string `db:"product_id"`
+		CategoryId int    `db:"category_id"`
+	}
+	categories := make([]*productCategory, 0)
+	if err := b.tx.SelectContext(ctx, &categories, query, values...); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get products_categories failed: %v", err)
+	}
+
+	items := make([]*promotions.PromotionItem, 0)
+	for _, p := range b.req.Products {
+		item := &promotions.PromotionItem{
+			ProductId:   p.Product.Id,
+			CategoryIds: make([]int, 0),
+			Price:       p.Product.Price,
+			Qty:         p.Qty,
+		}
+		for _, c := range categories {
+			if c.ProductId == p.Product.Id {
+				item.CategoryIds = append(item.CategoryIds, c.CategoryId)
+			}
+		}
+		items = append(items, item)
+	}
+
+	discount, err := promotion.Discount(items, inPeriod, userUsedCount)
+	if err != nil {
+		b.tx.Rollback()
+		return err
+	}
+
+	b.req.PromotionId = promotion.Id
+	b.req.CouponCode = promotion.Code
+	b.req.Discount = discount
+	b.req.CalculateTotal()
+	return nil
+}
+
 func (b *insertOrderBuilder) insertOrder() error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
@@ -53,10 +365,16 @@ func (b *insertOrderBuilder) insertOrder() error {
 		"contact",
 		"address",
 		"transfer_slip",
-		"status"
+		"status",
+		"promotion_id",
+		"coupon_code",
+		"subtotal",
+		"discount",
+		"shipping_fee",
+		"total_paid"
 	)
 	VALUES
-	($1, $2, $3, $4, $5)
+	($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11)
 		RETURNING "id";`
 
 	if err := b.tx.QueryRowContext(
@@ -64,8 +382,15 @@ func (b *insertOrderBuilder) insertOrder() error {
 		query,
 		b.req.UserId,
 		b.req.Contact,
+		b.req.Address,
 		b.req.TransferSlip,
 		b.req.Status,
+		b.req.PromotionId,
+		b.req.CouponCode,
+		b.req.Subtotal,
+		b.req.Discount,
+		b.req.ShippingFee,
+		b.req.TotalPaid,
 	).Scan(&b.req.Id); err != nil {
 		b.tx.Rollback()
 		return fmt.Errorf("insert order failed: %v", err)
@@ -81,6 +406,7 @@ func (b *insertOrderBuilder) insertProductOrder() error {
 	INSERT INTO "products_orders" (
 		"order_id",
 		"qty",
+		"variant_id",
 		"product"
 	)
 	VALUES`
@@ -92,25 +418,160 @@ func (b *insertOrderBuilder) insertProductOrder() error {
 			values,
 			b.req.Id,
 			b.req.Products[i].Qty,
+			b.req.Products[i].VariantId,
 			b.req.Products[i].Product,
 		)
 
 		if i != len(b.req.Products)-1 {
 			query += fmt.Sprintf(`
-			($%d, $%d, $%d),`, lastIndex+1, lastIndex+2, lastIndex+3)
+			($%d, $%d, NULLIF($%d, ''), $%d),`, lastIndex+1, lastIndex+2, lastIndex+3, lastIndex+4)
 		} else {
 			query += fmt.Sprintf(`
-			($%d, $%d, $%d);`, lastIndex+1, lastIndex+2, lastIndex+3)
+			($%d, $%d, NULLIF($%d, ''), $%d);`, lastIndex+1, lastIndex+2, lastIndex+3, lastIndex+4)
 		}
-		lastIndex += 3
+		lastIndex += 4
 	}
 
 	if _, err := b.tx.ExecContext(ctx, query, values...); err != nil {
+		b.tx.Rollback()
 		return fmt.Errorf("insert products_orders failed: %v", err)
 	}
 	return nil
 }
 
+func (b *insertOrderBuilder) insertStatusHistory() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "order_status_history" (
+		"order_id",
+		"to_status",
+		"changed_by"
+	)
+	VALUES ($1, $2, NULLIF($3, ''));`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.Id, b.req.Status, b.req.UserId); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert order status history failed: %v", err)
+	}
+	return nil
+}
+
+func (b *insertOrderBuilder) insertPromotionUsage() error {
+	if b.req.PromotionId == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "promotions_usages" (
+		"promotion_id",
+		"user_id",
+		"order_id",
+		"discount"
+	)
+	VALUES ($1, NULLIF($2, ''), $3, $4);`
+
+	if _, err := b.tx.ExecContext(
+		ctx,
+		query,
+		b.req.PromotionId,
+		b.req.UserId,
+		b.req.Id,
+		b.req.Discount,
+	); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert promotion usage failed: %v", err)
+	}
+
+	query = `
+	UPDATE "promotions" SET
+		"used_count" = "used_count" + 1
+	WHERE "id" = $1;`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.PromotionId); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update promotion used count failed: %v", err)
+	}
+	return nil
+}
+
+func (b *insertOrderBuilder) reserveStock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	productQuery := `
+	UPDATE "products" SET
+		"stock" = "stock" - $1
+	WHERE "id" = $2
+	RETURNING "stock";`
+
+	variantQuery := `
+	UPDATE "product_variants" SET
+		"stock" = "stock" - $1
+	WHERE "id" = $2
+	RETURNING "stock";`
+
+	movementQuery := `
+	INSERT INTO "stock_movements" (
+		"product_id",
+		"variant_id",
+		"order_id",
+		"user_id",
+		"type",
+		"qty",
+		"balance"
+	)
+	VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), 'order', $5, $6);`
+
+	reserve := func(query, productId, variantId string, qty int) error {
+		var balance int
+		id := productId
+		if variantId != "" {
+			id = variantId
+		}
+		if err := b.tx.QueryRowContext(ctx, query, qty, id).Scan(&balance); err != nil {
+			return fmt.Errorf("reserve stock failed: %v", err)
+		}
+
+		if _, err := b.tx.ExecContext(
+			ctx,
+			movementQuery,
+			productId,
+			variantId,
+			b.req.Id,
+			b.req.UserId,
+			-qty,
+			balance,
+		); err != nil {
+			return fmt.Errorf("insert stock movement failed: %v", err)
+		}
+		return nil
+	}
+
+	for _, id := range b.productIds {
+		// product ที่ถูกสั่งผ่าน variant เท่านั้นจะไม่ถูกตัด stock ของ product
+		qty, ok := b.qty[id]
+		if !ok {
+			continue
+		}
+		if err := reserve(productQuery, id, "", qty); err != nil {
+			b.tx.Rollback()
+			return err
+		}
+	}
+	for _, id := range b.variantIds {
+		if err := reserve(variantQuery, b.variantProduct[id], id, b.qty[id]); err != nil {
+			b.tx.Rollback()
+			return err
+		}
+	}
+	return nil
+}
+
 func (b *insertOrderBuilder) commit() error {
 	if err := b.tx.Commit(); err != nil {
 		return err
@@ -118,6 +579,33 @@ func (b *insertOrderBuilder) commit() error {
 	return nil
 }
 
+// clearCart : ลบ cart item ที่ checkout ใน transaction เดียวกับ order
+// ลบได้ไม่ครบแปลว่า cart ถูกแก้หรือถูก checkout ไปแล้วระหว่างนั้น จึงยกเลิกทั้ง order กันการ checkout cart เดิมซ้ำ
+func (b *insertOrderBuilder) clearCart() error {
+	if len(b.req.CartItemIds) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	DELETE FROM "carts_items"
+	WHERE "user_id" = $1
+	AND "id" = ANY($2);`
+
+	result, err := b.tx.ExecContext(ctx, query, b.req.UserId, b.req.CartItemIds)
+	if err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("clear cart failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows != int64(len(b.req.CartItemIds)) {
+		b.tx.Rollback()
+		return fmt.Errorf("cart has changed")
+	}
+	return nil
+}
+
 type insertOrderEngineer struct {
 	builder IInsertOrderBuilder
 }
@@ -130,14 +618,41 @@ func (en *insertOrderEngineer) InsertOrder() (string, error) {
 	if err := en.builder.initTransaction(); err != nil {
 		return "", err
 	}
+	if err := en.builder.lockProducts(); err != nil {
+		return "", err
+	}
+	en.builder.calculateTotal()
+	if err := en.builder.applyPromotion(); err != nil {
+		return "", err
+	}
 	if err := en.builder.insertOrder(); err != nil {
 		return "", err
 	}
 	if err := en.builder.insertProductOrder(); err != nil {
 		return "", err
 	}
+	if err := en.builder.insertStatusHistory(); err != nil {
+		return "", err
+	}
+	if err := en.builder.insertPromotionUsage(); err != nil {
+		return "", err
+	}
+	if err := en.builder.reserveStock(); err != nil {
+		return "", err
+	}
+	if err := en.builder.clearCart(); err != nil {
+		return "", err
+	}
 	if err := en.builder.commit(); err != nil {
 		return "", err
 	}
 	return en.builder.getOrderId(), nil
 }
+
+func placeholders(n int) string {
+	values := make([]string, 0)
+	for i := 1; i <= n; i++ {
+		values = append(values, fmt.Sprintf("$%d", i))
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,338 @@
+package ordersPatterns
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders"
+	"github.com/jmoiron/sqlx"
+)
+
+type IUpdateOrderBuilder interface {
+	initTransaction() error
+	findOldStatus() error
+	validateTransition() error
+	updateOrder() error
+	insertStatusHistory() error
+	restoreStock() error
+	releasePromotion() error
+	commit() error
+}
+
+type updateOrderBuilder struct {
+	req       *orders.Order
+	actor     *orders.OrderActor
+	db        *sqlx.DB
+	tx        *sqlx.Tx
+	oldStatus string
+	ownerId   string
+}
+
+func UpdateOrderBuilder(db *sqlx.DB, req *orders.Order, actor *orders.OrderActor) IUpdateOrderBuilder {
+	return &updateOrderBuilder{
+		db:    db,
+		req:   req,
+		actor: actor,
+	}
+}
+
+func (b *updateOrderBuilder) initTransaction() error {
+	tx, err := b.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	b.tx = tx
+	return nil
+}
+
+func (b *updateOrderBuilder) findOldStatus() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// lock order ไว้ กันการ cancel ซ้อนกันแล้วคืน stock 2 รอบ
+	query := `
+	SELECT
+		"status",
+		"user_id"
+	FROM "orders"
+	WHERE "id" = $1
+	FOR UPDATE;`
+
+	if err := b.tx.QueryRowContext(ctx, query, b.req.Id).Scan(&b.oldStatus, &b.ownerId); err != nil {
+		b.tx.Rollback()
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order not found")
+		}
+		return fmt.Errorf("get order failed: %v", err)
+	}
+	return nil
+}
+
+// validateTransition : สลิปแก้ได้เฉพาะเจ้าของ order และ admin
+func (b *updateOrderBuilder) validateTransition() error {
+	isOwner := b.ownerId == b.actor.UserId
+	if b.req.TransferSlip != nil && !isOwner && !b.actor.IsAdmin {
+		b.tx.Rollback()
+		return fmt.Errorf("transfer slip cannot be changed")
+	}
+	if b.req.Status == "" {
+		return nil
+	}
+	if !b.actor.CanTransition(b.oldStatus, b.req.Status, isOwner) {
+		b.tx.Rollback()
+		return fmt.Errorf("order status transition is not allowed")
+	}
+	return nil
+}
+
+func (b *updateOrderBuilder) updateOrder() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	UPDATE "orders" SET`
+
+	queryWhereStack := make([]string, 0)
+	values := make([]any, 0)
+	lastIndex := 1
+
+	if b.req.Status != "" {
+		values = append(values, b.req.Status)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"status" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if b.req.TransferSlip != nil {
+		values = append(values, b.req.TransferSlip)
+
+		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
+		"transfer_slip" = $%d?`, lastIndex))
+
+		lastIndex++
+	}
+
+	if len(queryWhereStack) == 0 {
+		return nil
+	}
+
+	values = append(values, b.req.Id)
+
+	queryClose := fmt.Sprintf(`
+	WHERE "id" = $%d;`, lastIndex)
+
+	for i := range queryWhereStack {
+		if i != len(queryWhereStack)-1 {
+			query += strings.Replace(queryWhereStack[i], "?", ",", 1)
+		} else {
+			query += strings.Replace(queryWhereStack[i], "?", "", 1)
+		}
+	}
+	query += queryClose
+
+	if _, err := b.tx.ExecContext(ctx, query, values...); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update order failed: %v", err)
+	}
+	return nil
+}
+
+func (b *updateOrderBuilder) insertStatusHistory() error {
+	if b.req.Status == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "order_status_history" (
+		"order_id",
+		"from_status",
+		"to_status",
+		"changed_by"
+	)
+	VALUES ($1, $2, $3, NULLIF($4, ''));`
+
+	if _, err := b.tx.ExecContext(
+		ctx,
+		query,
+		b.req.Id,
+		b.oldStatus,
+		b.req.Status,
+		b.actor.UserId,
+	); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert order status history failed: %v", err)
+	}
+	return nil
+}
+
+func (b *updateOrderBuilder) restoreStock() error {
+	// คืน stock เฉพาะตอนที่ order เปลี่ยนเป็น canceled ครั้งแรก
+	if b.req.Status != "canceled" || b.oldStatus == "canceled" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// group ตามลำดับ column เพราะชื่อ "variant_id" จะอ้างถึง column ของ products_orders แทน alias
+	query := `
+	SELECT
+		("po"."product"->>'id') AS "product_id",
+		COALESCE("po"."variant_id", "po"."product"->'variant'->>'id', '') AS "variant_id",
+		SUM("po"."qty") AS "qty"
+	FROM "products_orders" "po"
+	WHERE "po"."order_id" = $1
+	GROUP BY 1, 2
+	ORDER BY 1, 2;`
+
+	type productQty struct {
+		ProductId string `db:"product_id"`
+		VariantId string `db:"variant_id"`
+		Qty       int    `db:"qty"`
+	}
+	items := make([]*productQty, 0)
+	if err := b.tx.SelectContext(ctx, &items, query, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get products_orders failed: %v", err)
+	}
+
+	productQuery := `
+	UPDATE "products" SET
+		"stock" = "stock" + $1
+	WHERE "id" = $2
+	RETURNING "stock";`
+
+	variantQuery := `
+	UPDATE "product_variants" SET
+		"stock" = "stock" + $1
+	WHERE "id" = $2
+	RETURNING "stock";`
+
+	movementQuery := `
+	INSERT INTO "stock_movements" (
+		"product_id",
+		"variant_id",
+		"order_id",
+		"user_id",
+		"type",
+		"qty",
+		"balance"
+	)
+	VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), 'cancel', $5, $6);`
+
+	for _, item := range items {
+		query, id := productQuery, item.ProductId
+		if item.VariantId != "" {
+			query, id = variantQuery, item.VariantId
+		}
+
+		var balance int
+		if err := b.tx.QueryRowContext(ctx, query, item.Qty, id).Scan(&balance); err != nil {
+			if err == sql.ErrNoRows {
+				// product หรือ variant ถูกลบไปแล้ว ไม่ต้องคืน stock
+				continue
+			}
+			b.tx.Rollback()
+			return fmt.Errorf("restore stock failed: %v", err)
+		}
+
+		if _, err := b.tx.ExecContext(
+			ctx,
+			movementQuery,
+			item.ProductId,
+			item.VariantId,
+			b.req.Id,
+			b.actor.UserId,
+			item.Qty,
+			balance,
+		); err != nil {
+			b.tx.Rollback()
+			return fmt.Errorf("insert stock movement failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// releasePromotion : order ที่ถูก cancel คืนสิทธิ์การใช้ coupon
+func (b *updateOrderBuilder) releasePromotion() error {
+	if b.req.Status != "canceled" || b.oldStatus == "canceled" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	WITH "usages" AS (
+		DELETE FROM "promotions_usages"
+		WHERE "order_id" = $1
+		RETURNING "promotion_id"
+	)
+	UPDATE "promotions" "pm" SET
+		"used_count" = GREATEST("pm"."used_count" - "u"."count", 0)
+	FROM (
+		SELECT
+			"promotion_id",
+			COUNT(*) AS "count"
+		FROM "usages"
+		GROUP BY "promotion_id"
+	) AS "u"
+	WHERE "pm"."id" = "u"."promotion_id";`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("release promotion failed: %v", err)
+	}
+	return nil
+}
+
+func (b *updateOrderBuilder) commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type updateOrderEngineer struct {
+	builder IUpdateOrderBuilder
+}
+
+func UpdateOrderEngineer(b IUpdateOrderBuilder) *updateOrderEngineer {
+	return &updateOrderEngineer{builder: b}
+}
+
+func (en *updateOrderEngineer) UpdateOrder() error {
+	if err := en.builder.initTransaction(); err != nil {
+		return err
+	}
+	if err := en.builder.findOldStatus(); err != nil {
+		return err
+	}
+	if err := en.builder.validateTransition(); err != nil {
+		return err
+	}
+	if err := en.builder.updateOrder(); err != nil {
+		return err
+	}
+	if err := en.builder.insertStatusHistory(); err != nil {
+		return err
+	}
+	if err := en.builder.restoreStock(); err != nil {
+		return err
+	}
+	if err := en.builder.releasePromotion(); err != nil {
+		return err
+	}
+	if err := en.builder.commit(); err != nil {
+		return err
+	}
+	return nil
+}
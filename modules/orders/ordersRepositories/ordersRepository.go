@@ -1,11 +1,10 @@
 package ordersRepositories
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersPatterns"
 	"github.com/jmoiron/sqlx"
@@ -13,9 +12,11 @@ import (
 
 type IOrdersRepository interface {
 	FindOneOrder(orderId string) (*orders.Order, error)
-	FindOrder(req *orders.OrderFilter) ([]*orders.Order, int)
+	FindOrder(req *orders.OrderFilter) ([]*orders.Order, int, *entities.PageCursor)
 	InsertOrder(req *orders.Order) (string, error)
-	UpdateOrder(req *orders.Order) error
+	UpdateOrder(req *orders.Order, actor *orders.OrderActor) error
+	FindOrderStatusHistory(userId, orderId string) ([]*orders.OrderStatusHistory, error)
+	FindOrderReport(req *orders.OrderReportFilter) ([]*orders.OrderReport, error)
 }
 
 type ordersRepository struct {
@@ -44,6 +45,7 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 					SELECT 
 						"spo"."id",
 						"spo"."qty",
+						COALESCE("spo"."variant_id", '') AS "variant_id",
 						"spo"."product"
 					FROM "products_orders" "spo"
 					WHERE "spo"."order_id" = "o"."id"
@@ -51,14 +53,12 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 			) AS "products",
 			"o"."address",
 			"o"."contact",
-			(
-				SELECT
-					-- ใช้ ->> ในการเข้าถึง value ใน json
-					SUM(COALESCE(("po"."product"->>prict')::FLOAT*("po"."qty")::FLOAT,0)
-					-- ::type ทำการ convert type
-				FROM "products_orders" "po"
-				WHERE "po"."order_id" = "o"."id"
-			) AS "total_paid"
+			COALESCE("o"."coupon_code", '') AS "coupon_code",
+			COALESCE("o"."promotion_id", '') AS "promotion_id",
+			"o"."subtotal",
+			"o"."discount",
+			"o"."shipping_fee",
+			"o"."total_paid",
 			"o"."created_at",
 			"o"."updated_at"
 		FROM "orders" "o"
@@ -79,10 +79,15 @@ func (r *ordersRepository) FindOneOrder(orderId string) (*orders.Order, error) {
 	return orderData, nil
 }
 
-func (r *ordersRepository) FindOrder(req *orders.OrderFilter) ([]*orders.Order, int) {
+func (r *ordersRepository) FindOrder(req *orders.OrderFilter) ([]*orders.Order, int, *entities.PageCursor) {
 	builder := ordersPatterns.FindOrderBuilder(r.db, req)
 	engineer := ordersPatterns.FindOrderEngineer(builder)
-	return engineer.FindOrder(), engineer.CountOrder()
+
+	ordersData, cursor := engineer.FindOrder()
+	if req.SkipCount {
+		return ordersData, 0, cursor
+	}
+	return ordersData, engineer.CountOrder(), cursor
 }
 
 func (r *ordersRepository) InsertOrder(req *orders.Order) (string, error) {
@@ -94,48 +99,71 @@ func (r *ordersRepository) InsertOrder(req *orders.Order) (string, error) {
 	return orderId, nil
 }
 
-func (r *ordersRepository) UpdateOrder(req *orders.Order) error {
-	query := `
-	UPDATE "orders" SET`
-
-	queryWhereStack := make([]string, 0)
-	values := make([]any, 0)
-	lastIndex := 1
-
-	if req.Status != "" {
-		values = append(values, req.Status)
-
-		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
-		"status" = $%d?`, lastIndex))
-
-		lastIndex++
+func (r *ordersRepository) UpdateOrder(req *orders.Order, actor *orders.OrderActor) error {
+	builder := ordersPatterns.UpdateOrderBuilder(r.db, req, actor)
+	if err := ordersPatterns.UpdateOrderEngineer(builder).UpdateOrder(); err != nil {
+		return err
 	}
+	return nil
+}
 
-	if req.TransferSlip != nil {
-		values = append(values, req.TransferSlip)
-
-		queryWhereStack = append(queryWhereStack, fmt.Sprintf(`
-		"transfer_slip" = $%d?`, lastIndex))
+func (r *ordersRepository) FindOrderStatusHistory(userId, orderId string) ([]*orders.OrderStatusHistory, error) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT
+			"h"."id",
+			"h"."order_id",
+			COALESCE("h"."from_status"::TEXT, '') AS "from_status",
+			"h"."to_status",
+			COALESCE("h"."changed_by", '') AS "changed_by",
+			"h"."created_at"
+		FROM "order_status_history" "h"
+		JOIN "orders" "o"
+		ON "o"."id" = "h"."order_id"
+		WHERE "h"."order_id" = $1
+		AND "o"."user_id" = $2
+		ORDER BY "h"."created_at" ASC
+	) AS "t";`
 
-		lastIndex++
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, orderId, userId); err != nil {
+		return nil, fmt.Errorf("get order status history failed: %v", err)
 	}
 
-	values = append(values, req.Id)
-
-	queryClose := fmt.Sprintf(`
-	WHERE "id" = $%d;`, lastIndex)
-
-	for i := range queryWhereStack {
-		if i != len(queryWhereStack)-1 {
-			query += strings.Replace(queryWhereStack[i], "?", ",", 1)
-		} else {
-			query += strings.Replace(queryWhereStack[i], "?", "", 1)
-		}
+	history := make([]*orders.OrderStatusHistory, 0)
+	if err := json.Unmarshal(raw, &history); err != nil {
+		return nil, fmt.Errorf("unmarshal order status history failed: %v", err)
+	}
+	if len(history) == 0 {
+		// order ทุกอันมี history อย่างน้อย 1 record ตอนสร้าง
+		return nil, fmt.Errorf("order not found")
 	}
-	query += queryClose
+	return history, nil
+}
 
-	if _, err := r.db.ExecContext(context.Background(), query, values...); err != nil {
-		return fmt.Errorf("update order failed: %v", err)
+func (r *ordersRepository) FindOrderReport(req *orders.OrderReportFilter) ([]*orders.OrderReport, error) {
+	query := `
+	SELECT
+		"o"."id",
+		"o"."user_id",
+		"o"."status",
+		COALESCE("o"."coupon_code", '') AS "coupon_code",
+		"o"."subtotal",
+		"o"."discount",
+		"o"."shipping_fee",
+		"o"."total_paid",
+		to_char("o"."created_at", 'YYYY-MM-DD HH24:MI:SS') AS "created_at"
+	FROM "orders" "o"
+	WHERE "o"."created_at" >= DATE($1)
+	AND "o"."created_at" < ($2)::DATE + 1
+	AND ($3::TEXT = '' OR "o"."status"::TEXT = $3)
+	ORDER BY "o"."created_at", "o"."id";`
+
+	reports := make([]*orders.OrderReport, 0)
+	if err := r.db.Select(&reports, query, req.StartDate, req.EndDate, req.Status); err != nil {
+		return nil, fmt.Errorf("get order report failed: %v", err)
 	}
-	return nil
+	return reports, nil
 }
@@ -3,8 +3,11 @@ package ordersUsecases
 import (
 	"fmt"
 	"math"
+	"net/http"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesUsecases"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersRepositories"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsRepositories"
@@ -14,18 +17,23 @@ type IOrdersUsecase interface {
 	FindOneOrder(orderId string) (*orders.Order, error)
 	FindOrder(req *orders.OrderFilter) *entities.PaginateRes
 	InsertOrder(req *orders.Order) (*orders.Order, error)
-	UpdateOrder(req *orders.Order) (*orders.Order, error)
+	UpdateOrder(req *orders.Order, actor *orders.OrderActor) (*orders.Order, error)
+	FindOrderStatusHistory(userId, orderId string) ([]*orders.OrderStatusHistory, error)
+	FindTransferSlip(userId, orderId string) (*files.SignedUrlRes, error)
+	FindOrderReport(req *orders.OrderReportFilter) ([]*orders.OrderReport, error)
 }
 
 type ordersUsecase struct {
 	ordersRepository   ordersRepositories.IOrdersRepository
 	productsRepository productsRepositories.IProductsRepository
+	filesUsecase       filesUsecases.IFilesUsecase
 }
 
-func OrdersUsecase(ordersRepository ordersRepositories.IOrdersRepository, productsRepository productsRepositories.IProductsRepository) IOrdersUsecase {
+func OrdersUsecase(ordersRepository ordersRepositories.IOrdersRepository, productsRepository productsRepositories.IProductsRepository, filesUsecase filesUsecases.IFilesUsecase) IOrdersUsecase {
 	return &ordersUsecase{
 		ordersRepository:   ordersRepository,
 		productsRepository: productsRepository,
+		filesUsecase:       filesUsecase,
 	}
 }
 
@@ -38,13 +46,15 @@ func (u *ordersUsecase) FindOneOrder(orderId string) (*orders.Order, error) {
 }
 
 func (u *ordersUsecase) FindOrder(req *orders.OrderFilter) *entities.PaginateRes {
-	orders, count := u.ordersRepository.FindOrder(req)
+	orders, count, cursor := u.ordersRepository.FindOrder(req)
 	return &entities.PaginateRes{
-		Data:      orders,
-		Page:      req.Page,
-		Limit:     req.Limit,
-		TotalItem: count,
-		TotalPage: int(math.Ceil(float64(count) / float64(req.Limit))),
+		Data:       orders,
+		Page:       req.Page,
+		Limit:      req.Limit,
+		TotalItem:  count,
+		TotalPage:  int(math.Ceil(float64(count) / float64(req.Limit))),
+		NextCursor: cursor.Next,
+		PrevCursor: cursor.Prev,
 	}
 }
 
@@ -54,15 +64,19 @@ func (u *ordersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
 		if req.Products[i].Product == nil {
 			return nil, fmt.Errorf("product is nil")
 		}
+		if req.Products[i].Qty <= 0 {
+			return nil, fmt.Errorf("product qty is invalid")
+		}
 
 		prod, err := u.productsRepository.FindOneProduct(req.Products[i].Product.Id)
 		if err != nil {
 			return nil, err
 		}
 
-		// set price
-		req.TotalPaid += req.Products[i].Product.Price * float64(req.Products[i].Qty)
-		req.Products[i].Product = prod
+		// snapshot ของ product ส่วนราคาและยอดรวมจะถูกคำนวณใหม่ใน transaction ตอน insert
+		if err := req.Products[i].SetSnapshot(prod); err != nil {
+			return nil, err
+		}
 	}
 
 	orderId, err := u.ordersRepository.InsertOrder(req)
@@ -77,8 +91,8 @@ func (u *ordersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
 	return order, nil
 }
 
-func (u *ordersUsecase) UpdateOrder(req *orders.Order) (*orders.Order, error) {
-	if err := u.ordersRepository.UpdateOrder(req); err != nil {
+func (u *ordersUsecase) UpdateOrder(req *orders.Order, actor *orders.OrderActor) (*orders.Order, error) {
+	if err := u.ordersRepository.UpdateOrder(req, actor); err != nil {
 		return nil, err
 	}
 
@@ -88,3 +102,42 @@ func (u *ordersUsecase) UpdateOrder(req *orders.Order) (*orders.Order, error) {
 	}
 	return order, nil
 }
+
+func (u *ordersUsecase) FindOrderStatusHistory(userId, orderId string) ([]*orders.OrderStatusHistory, error) {
+	history, err := u.ordersRepository.FindOrderStatusHistory(userId, orderId)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// FindTransferSlip : สร้าง signed url อายุสั้นของสลิปทุกครั้งที่ขอดู
+func (u *ordersUsecase) FindTransferSlip(userId, orderId string) (*files.SignedUrlRes, error) {
+	order, err := u.ordersRepository.FindOneOrder(orderId)
+	if err != nil || order.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+	if order.TransferSlip == nil {
+		return nil, fmt.Errorf("transfer slip not found")
+	}
+
+	// สลิปเก่าก่อนเปลี่ยนเป็น private ไม่ได้ถูกย้าย ยังเปิดได้จาก url public เดิม
+	if order.TransferSlip.Destination == "" {
+		if order.TransferSlip.Url == "" {
+			return nil, fmt.Errorf("transfer slip not found")
+		}
+		return &files.SignedUrlRes{
+			Url:    order.TransferSlip.Url,
+			Method: http.MethodGet,
+		}, nil
+	}
+	// order ที่อ้างสลิปนอก directory ของเจ้าของไม่ได้ sign ให้
+	if !files.IsSlipOf(order.UserId, order.TransferSlip.Destination) {
+		return nil, fmt.Errorf("transfer slip not found")
+	}
+	return u.filesUsecase.SignedUrl(order.TransferSlip.Destination)
+}
+
+func (u *ordersUsecase) FindOrderReport(req *orders.OrderReportFilter) ([]*orders.OrderReport, error) {
+	return u.ordersRepository.FindOrderReport(req)
+}
@@ -0,0 +1,73 @@
+package payments
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	ProviderPromptPay = "promptpay"
+	ProviderCard      = "card"
+
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+
+	Currency = "THB"
+)
+
+// Payment : payment intent ของ order หนึ่ง order มีได้หลาย intent (eg. เปลี่ยนวิธีจ่าย) แต่ succeeded ได้แค่อันเดียว
+type Payment struct {
+	Id          string  `db:"id" json:"id"`
+	OrderId     string  `db:"order_id" json:"order_id"`
+	UserId      string  `db:"user_id" json:"user_id"`
+	Provider    string  `db:"provider" json:"provider"`
+	Status      string  `db:"status" json:"status"`
+	Amount      float64 `db:"amount" json:"amount"`
+	Currency    string  `db:"currency" json:"currency"`
+	ProviderRef string  `db:"provider_ref" json:"provider_ref"`
+	QrPayload   string  `db:"qr_payload" json:"qr_payload,omitempty"`     // promptpay : ข้อความที่นำไปสร้างรูป QR
+	RedirectUrl string  `db:"redirect_url" json:"redirect_url,omitempty"` // card : หน้าจ่ายเงินของ gateway
+	PaidAt      string  `db:"paid_at" json:"paid_at,omitempty"`
+	CreatedAt   string  `db:"created_at" json:"created_at"`
+	UpdatedAt   string  `db:"updated_at" json:"updated_at"`
+}
+
+type PaymentReq struct {
+	OrderId  string `json:"order_id" form:"order_id"`
+	Provider string `json:"provider" form:"provider"`
+}
+
+// Intent : ข้อมูลที่ provider สร้างให้ตอนเริ่มจ่ายเงิน
+type Intent struct {
+	ProviderRef string
+	QrPayload   string
+	RedirectUrl string
+}
+
+// WebhookEvent : event จาก provider ที่แปลงเป็นรูปแบบกลางแล้ว
+type WebhookEvent struct {
+	Id          string // event id ของ provider ใช้กันการประมวลผลซ้ำ
+	Provider    string
+	Type        string
+	PaymentId   string
+	ProviderRef string
+	Status      string // succeeded, failed
+	Amount      float64
+	Payload     []byte
+}
+
+// ToSatang : จำนวนเงินในหน่วยสตางค์ gateway ส่วนใหญ่รับเป็นจำนวนเต็ม
+func ToSatang(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func FromSatang(amount int64) float64 {
+	return float64(amount) / 100
+}
+
+// FormatAmount : ทศนิยม 2 ตำแหน่ง eg. 100 -> 100.00
+func FormatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f", FromSatang(ToSatang(amount)))
+}
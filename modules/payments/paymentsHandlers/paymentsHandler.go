@@ -0,0 +1,152 @@
+package paymentsHandlers
+
+import (
+	"strings"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments/paymentsUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type paymentsHandlersErrCode string
+
+const (
+	insertPaymentErr  paymentsHandlersErrCode = "payments-001"
+	findOnePaymentErr paymentsHandlersErrCode = "payments-002"
+	webhookErr        paymentsHandlersErrCode = "payments-003"
+)
+
+type IPaymentsHandler interface {
+	InsertPayment(c *fiber.Ctx) error
+	FindOnePayment(c *fiber.Ctx) error
+	Webhook(c *fiber.Ctx) error
+}
+
+type paymentsHandler struct {
+	cfg             config.IConfig
+	paymentsUsecase paymentsUsecases.IPaymentsUsecase
+}
+
+func PaymentsHandler(cfg config.IConfig, paymentsUsecase paymentsUsecases.IPaymentsUsecase) IPaymentsHandler {
+	return &paymentsHandler{
+		cfg:             cfg,
+		paymentsUsecase: paymentsUsecase,
+	}
+}
+
+func (h *paymentsHandler) InsertPayment(c *fiber.Ctx) error {
+	req := new(payments.PaymentReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertPaymentErr),
+			err.Error(),
+		).Res()
+	}
+	req.OrderId = strings.TrimSpace(req.OrderId)
+	req.Provider = strings.ToLower(strings.TrimSpace(req.Provider))
+	if req.OrderId == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertPaymentErr),
+			"order id is required",
+		).Res()
+	}
+
+	payment, err := h.paymentsUsecase.InsertPayment(c.Locals("userId").(string), req)
+	if err != nil {
+		switch err.Error() {
+		case "provider is not supported":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertPaymentErr),
+				err.Error(),
+			).Res()
+		case "order not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(insertPaymentErr),
+				err.Error(),
+			).Res()
+		case "order is not waiting for payment", "order has already been paid", "order has nothing to pay":
+			return entities.NewResponse(c).Error(
+				fiber.ErrConflict.Code,
+				string(insertPaymentErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(insertPaymentErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, payment).Res()
+}
+
+func (h *paymentsHandler) FindOnePayment(c *fiber.Ctx) error {
+	paymentId := strings.Trim(c.Params("payment_id"), " ")
+
+	payment, err := h.paymentsUsecase.FindOnePayment(
+		paymentId,
+		c.Locals("userId").(string),
+		c.Locals("userPermissions").(middlewares.Permissions).Has(middlewares.PermPaymentsReadAny),
+	)
+	if err != nil {
+		switch err.Error() {
+		case "payment not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findOnePaymentErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(findOnePaymentErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, payment).Res()
+}
+
+// Webhook : provider เรียกเข้ามาโดยไม่มี jwt ใช้ HMAC ใน header X-Signature ยืนยันแทน
+// ตอบ 2xx เมื่อบันทึกแล้ว (รวมถึง event ซ้ำ) provider จะ retry เมื่อได้ status อื่น
+func (h *paymentsHandler) Webhook(c *fiber.Ctx) error {
+	provider := strings.ToLower(strings.Trim(c.Params("provider"), " "))
+
+	if err := h.paymentsUsecase.ProcessWebhook(provider, c.Get("X-Signature"), c.Body()); err != nil {
+		switch err.Error() {
+		case "provider is not supported", "payment not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(webhookErr),
+				err.Error(),
+			).Res()
+		case "signature is invalid", "signature has expired":
+			return entities.NewResponse(c).Error(
+				fiber.ErrUnauthorized.Code,
+				string(webhookErr),
+				err.Error(),
+			).Res()
+		case "webhook payload is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(webhookErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(webhookErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
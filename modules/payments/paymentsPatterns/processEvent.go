@@ -0,0 +1,270 @@
+package paymentsPatterns
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments"
+	"github.com/jmoiron/sqlx"
+)
+
+type IProcessEventBuilder interface {
+	initTransaction() error
+	insertEvent() error
+	findPayment() error
+	updatePayment() error
+	cancelOtherPayments() error
+	updateOrder() error
+	commit() error
+}
+
+type processEventBuilder struct {
+	db      *sqlx.DB
+	tx      *sqlx.Tx
+	event   *payments.WebhookEvent
+	payment *payments.Payment
+	// skip : event ซ้ำหรือ payment จบไปแล้ว บันทึก event ไว้แต่ไม่เปลี่ยนสถานะอะไร
+	skip bool
+}
+
+func ProcessEventBuilder(db *sqlx.DB, event *payments.WebhookEvent) IProcessEventBuilder {
+	return &processEventBuilder{
+		db:      db,
+		event:   event,
+		payment: new(payments.Payment),
+	}
+}
+
+func (b *processEventBuilder) initTransaction() error {
+	tx, err := b.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	b.tx = tx
+	return nil
+}
+
+func (b *processEventBuilder) insertEvent() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// provider ส่ง event เดิมซ้ำได้ (retry) unique (provider, event_id) ทำให้ประมวลผลแค่ครั้งเดียว
+	query := `
+	INSERT INTO "payment_events" (
+		"provider",
+		"event_id",
+		"type",
+		"payment_id",
+		"payload"
+	)
+	VALUES (
+		$1,
+		$2,
+		$3,
+		(SELECT "id" FROM "payments" WHERE "id" = $4 AND "provider" = $1),
+		$5
+	)
+	ON CONFLICT ("provider", "event_id") DO NOTHING
+	RETURNING "id";`
+
+	var id string
+	if err := b.tx.QueryRowContext(
+		ctx,
+		query,
+		b.event.Provider,
+		b.event.Id,
+		b.event.Type,
+		b.event.PaymentId,
+		string(b.event.Payload),
+	).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			b.skip = true
+			return nil
+		}
+		b.tx.Rollback()
+		return fmt.Errorf("insert payment event failed: %v", err)
+	}
+	return nil
+}
+
+func (b *processEventBuilder) findPayment() error {
+	if b.skip {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// lock payment ไว้ กัน event succeeded กับ failed ของ payment เดียวกันเข้ามาพร้อมกัน
+	query := `
+	SELECT
+		"id",
+		"order_id",
+		"status",
+		"amount",
+		COALESCE("provider_ref", '') AS "provider_ref"
+	FROM "payments"
+	WHERE "id" = $1
+	AND "provider" = $2
+	FOR UPDATE;`
+
+	if err := b.tx.GetContext(ctx, b.payment, query, b.event.PaymentId, b.event.Provider); err != nil {
+		b.tx.Rollback()
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("payment not found")
+		}
+		return fmt.Errorf("get payment failed: %v", err)
+	}
+	return nil
+}
+
+func (b *processEventBuilder) updatePayment() error {
+	if b.skip {
+		return nil
+	}
+	if b.payment.Status != payments.StatusPending {
+		b.skip = true
+		return nil
+	}
+	if b.payment.ProviderRef != "" && b.payment.ProviderRef != b.event.ProviderRef {
+		log.Printf("payment %s: provider ref %s does not match event %s", b.payment.Id, b.event.ProviderRef, b.event.Id)
+		b.skip = true
+		return nil
+	}
+	if b.event.Status == payments.StatusSucceeded &&
+		payments.ToSatang(b.event.Amount) != payments.ToSatang(b.payment.Amount) {
+		// ยอดไม่ตรงกับ order ต้องให้ admin ตรวจสอบเอง
+		log.Printf("payment %s: paid amount %.2f does not match %.2f", b.payment.Id, b.event.Amount, b.payment.Amount)
+		b.skip = true
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	UPDATE "payments" SET
+		"status" = $1,
+		"provider_ref" = $2,
+		"paid_at" = CASE WHEN $1 = 'succeeded' THEN now() ELSE "paid_at" END
+	WHERE "id" = $3;`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.event.Status, b.event.ProviderRef, b.payment.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update payment failed: %v", err)
+	}
+	b.payment.Status = b.event.Status
+	return nil
+}
+
+func (b *processEventBuilder) cancelOtherPayments() error {
+	if b.skip || b.payment.Status != payments.StatusSucceeded {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// order จ่ายแล้ว intent อื่นที่ยังค้างอยู่ใช้ไม่ได้อีก
+	query := `
+	UPDATE "payments" SET
+		"status" = 'canceled'
+	WHERE "order_id" = $1
+	AND "id" <> $2
+	AND "status" = 'pending';`
+
+	if _, err := b.tx.ExecContext(ctx, query, b.payment.OrderId, b.payment.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("cancel payments failed: %v", err)
+	}
+	return nil
+}
+
+func (b *processEventBuilder) updateOrder() error {
+	if b.skip || b.payment.Status != payments.StatusSucceeded {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	var status string
+	if err := b.tx.QueryRowContext(ctx, `
+	SELECT
+		"status"
+	FROM "orders"
+	WHERE "id" = $1
+	FOR UPDATE;`, b.payment.OrderId).Scan(&status); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("get order failed: %v", err)
+	}
+	if status != "waiting" {
+		// eg. order ถูกยกเลิกก่อนเงินเข้า ต้องคืนเงินเอง
+		log.Printf("payment %s succeeded but order %s is %s", b.payment.Id, b.payment.OrderId, status)
+		return nil
+	}
+
+	if _, err := b.tx.ExecContext(ctx, `
+	UPDATE "orders" SET
+		"status" = 'paid'
+	WHERE "id" = $1;`, b.payment.OrderId); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("update order failed: %v", err)
+	}
+
+	// changed_by = NULL : เปลี่ยนโดยระบบ
+	if _, err := b.tx.ExecContext(ctx, `
+	INSERT INTO "order_status_history" (
+		"order_id",
+		"from_status",
+		"to_status"
+	)
+	VALUES ($1, $2, 'paid');`, b.payment.OrderId, status); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("insert order status history failed: %v", err)
+	}
+	return nil
+}
+
+func (b *processEventBuilder) commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type processEventEngineer struct {
+	builder IProcessEventBuilder
+}
+
+func ProcessEventEngineer(b IProcessEventBuilder) *processEventEngineer {
+	return &processEventEngineer{builder: b}
+}
+
+func (en *processEventEngineer) ProcessEvent() error {
+	if err := en.builder.initTransaction(); err != nil {
+		return err
+	}
+	if err := en.builder.insertEvent(); err != nil {
+		return err
+	}
+	if err := en.builder.findPayment(); err != nil {
+		return err
+	}
+	if err := en.builder.updatePayment(); err != nil {
+		return err
+	}
+	if err := en.builder.cancelOtherPayments(); err != nil {
+		return err
+	}
+	if err := en.builder.updateOrder(); err != nil {
+		return err
+	}
+	if err := en.builder.commit(); err != nil {
+		return err
+	}
+	return nil
+}
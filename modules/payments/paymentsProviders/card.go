@@ -0,0 +1,145 @@
+package paymentsProviders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments"
+)
+
+type CardOptions struct {
+	BaseUrl       string
+	SecretKey     string
+	WebhookSecret []byte
+	ReturnUrl     string
+}
+
+type cardProvider struct {
+	opts   *CardOptions
+	client *http.Client
+}
+
+// CardProvider : gateway บัตรเครดิตแบบ hosted payment page ข้อมูลบัตรไม่ผ่าน server ของเรา
+// ลูกค้าจ่ายที่ redirect url แล้ว gateway แจ้งผลผ่าน webhook
+func CardProvider(opts *CardOptions) IProvider {
+	opts.BaseUrl = strings.TrimSuffix(opts.BaseUrl, "/")
+
+	return &cardProvider{
+		opts: opts,
+		client: &http.Client{
+			Timeout: time.Second * 30,
+		},
+	}
+}
+
+func (p *cardProvider) Name() string { return payments.ProviderCard }
+
+type cardChargeReq struct {
+	Amount    int64             `json:"amount"` // สตางค์
+	Currency  string            `json:"currency"`
+	ReturnUri string            `json:"return_uri"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+type cardCharge struct {
+	Id           string            `json:"id"`
+	Status       string            `json:"status"`
+	Amount       int64             `json:"amount"`
+	Currency     string            `json:"currency"`
+	AuthorizeUri string            `json:"authorize_uri"`
+	Metadata     map[string]string `json:"metadata"`
+}
+
+func (p *cardProvider) CreateIntent(ctx context.Context, payment *payments.Payment) (*payments.Intent, error) {
+	body, err := json.Marshal(&cardChargeReq{
+		Amount:    payments.ToSatang(payment.Amount),
+		Currency:  strings.ToLower(payment.Currency),
+		ReturnUri: p.opts.ReturnUrl,
+		Metadata: map[string]string{
+			"payment_id": payment.Id,
+			"order_id":   payment.OrderId,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal charge failed: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.BaseUrl+"/charges", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("new charge request failed: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.opts.SecretKey)
+	// retry ด้วย payment เดิมจะได้ charge เดิม ไม่ตัดเงินซ้ำ
+	req.Header.Set("Idempotency-Key", payment.Id)
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("create charge failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("create charge failed: %s %s", res.Status, string(msg))
+	}
+
+	charge := new(cardCharge)
+	if err := json.NewDecoder(res.Body).Decode(charge); err != nil {
+		return nil, fmt.Errorf("decode charge failed: %v", err)
+	}
+	if charge.Id == "" || charge.AuthorizeUri == "" {
+		return nil, fmt.Errorf("create charge failed: response is invalid")
+	}
+	return &payments.Intent{
+		ProviderRef: charge.Id,
+		RedirectUrl: charge.AuthorizeUri,
+	}, nil
+}
+
+// cardEvent : eg. {"id": "evnt_1", "type": "charge.succeeded", "data": {"id": "chrg_1", "amount": 10050, ...}}
+type cardEvent struct {
+	Id   string     `json:"id"`
+	Type string     `json:"type"`
+	Data cardCharge `json:"data"`
+}
+
+func (p *cardProvider) ParseWebhook(signature string, body []byte) (*payments.WebhookEvent, error) {
+	if err := VerifyWebhook(p.opts.WebhookSecret, signature, body, time.Now()); err != nil {
+		return nil, err
+	}
+
+	event := new(cardEvent)
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, fmt.Errorf("webhook payload is invalid")
+	}
+	if event.Id == "" || event.Data.Id == "" || event.Data.Metadata["payment_id"] == "" {
+		return nil, fmt.Errorf("webhook payload is invalid")
+	}
+
+	status := ""
+	switch event.Type {
+	case "charge.succeeded":
+		status = payments.StatusSucceeded
+	case "charge.failed", "charge.expired":
+		status = payments.StatusFailed
+	default:
+		return nil, nil
+	}
+	return &payments.WebhookEvent{
+		Id:          event.Id,
+		Provider:    p.Name(),
+		Type:        event.Type,
+		PaymentId:   event.Data.Metadata["payment_id"],
+		ProviderRef: event.Data.Id,
+		Status:      status,
+		Amount:      payments.FromSatang(event.Data.Amount),
+		Payload:     body,
+	}, nil
+}
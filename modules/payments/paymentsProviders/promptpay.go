@@ -0,0 +1,154 @@
+package paymentsProviders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments"
+)
+
+// promptPayAid : application id ของ PromptPay (merchant presented QR)
+const promptPayAid = "A000000677010111"
+
+type promptPayProvider struct {
+	target        string
+	webhookSecret []byte
+}
+
+// PromptPayProvider : สร้าง QR ให้ลูกค้าสแกนจ่าย ผลการจ่ายเงินมาจาก webhook ของธนาคาร/ผู้ให้บริการที่รับเงิน
+func PromptPayProvider(target string, webhookSecret []byte) IProvider {
+	return &promptPayProvider{
+		target:        target,
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (p *promptPayProvider) Name() string { return payments.ProviderPromptPay }
+
+func (p *promptPayProvider) CreateIntent(ctx context.Context, payment *payments.Payment) (*payments.Intent, error) {
+	payload, err := PromptPayPayload(p.target, payment.Amount)
+	if err != nil {
+		return nil, err
+	}
+	// QR ของ PromptPay ไม่มี reference ของเรา ธนาคารจะอ้างถึง payment id ที่ลงทะเบียนไว้ตอนส่ง webhook
+	return &payments.Intent{
+		ProviderRef: payment.Id,
+		QrPayload:   payload,
+	}, nil
+}
+
+// promptPayEvent : eg. {"id": "evt_1", "type": "payment.succeeded", "data": {"reference": "<payment id>", "transaction_id": "...", "amount": 100.5}}
+type promptPayEvent struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Reference     string  `json:"reference"`
+		TransactionId string  `json:"transaction_id"`
+		Amount        float64 `json:"amount"`
+	} `json:"data"`
+}
+
+func (p *promptPayProvider) ParseWebhook(signature string, body []byte) (*payments.WebhookEvent, error) {
+	if err := VerifyWebhook(p.webhookSecret, signature, body, time.Now()); err != nil {
+		return nil, err
+	}
+
+	event := new(promptPayEvent)
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, fmt.Errorf("webhook payload is invalid")
+	}
+	if event.Id == "" || event.Data.Reference == "" {
+		return nil, fmt.Errorf("webhook payload is invalid")
+	}
+
+	status := ""
+	switch event.Type {
+	case "payment.succeeded":
+		status = payments.StatusSucceeded
+	case "payment.failed":
+		status = payments.StatusFailed
+	default:
+		return nil, nil
+	}
+	return &payments.WebhookEvent{
+		Id:          event.Id,
+		Provider:    p.Name(),
+		Type:        event.Type,
+		PaymentId:   event.Data.Reference,
+		ProviderRef: event.Data.Reference,
+		Status:      status,
+		Amount:      event.Data.Amount,
+		Payload:     body,
+	}, nil
+}
+
+// PromptPayPayload : ข้อความ EMVCo QR ของ PromptPay
+// target : เบอร์โทร 10 หลัก, เลขบัตรประชาชน/เลขผู้เสียภาษี 13 หลัก หรือ e-wallet id 15 หลัก
+// amount = 0 คือ QR ที่ให้ผู้จ่ายกรอกจำนวนเงินเอง
+func PromptPayPayload(target string, amount float64) (string, error) {
+	target = strings.NewReplacer("-", "", " ", "").Replace(target)
+	for _, r := range target {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("promptpay id is invalid")
+		}
+	}
+
+	var account string
+	switch len(target) {
+	case 10:
+		// เบอร์โทรแปลงเป็นรูปแบบสากล 0066 + เบอร์ที่ตัด 0 ตัวแรกออก ยาว 13 หลัก
+		account = emvField("01", "0066"+target[1:])
+	case 13:
+		account = emvField("02", target)
+	case 15:
+		account = emvField("03", target)
+	default:
+		return "", fmt.Errorf("promptpay id is invalid")
+	}
+	if amount < 0 {
+		return "", fmt.Errorf("amount is invalid")
+	}
+
+	// 11 = QR ใช้ซ้ำได้, 12 = QR สำหรับจ่ายครั้งเดียว (มีจำนวนเงิน)
+	initiation := "11"
+	if amount > 0 {
+		initiation = "12"
+	}
+
+	var b strings.Builder
+	b.WriteString(emvField("00", "01"))
+	b.WriteString(emvField("01", initiation))
+	b.WriteString(emvField("29", emvField("00", promptPayAid)+account))
+	b.WriteString(emvField("53", "764")) // THB
+	if amount > 0 {
+		b.WriteString(emvField("54", payments.FormatAmount(amount)))
+	}
+	b.WriteString(emvField("58", "TH"))
+	// crc คำนวณรวม id และความยาวของตัวมันเอง (6304)
+	b.WriteString("6304")
+	return b.String() + fmt.Sprintf("%04X", CRC16(b.String())), nil
+}
+
+// emvField : id 2 หลัก + ความยาว 2 หลัก + ข้อมูล
+func emvField(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// CRC16 : CRC-16/CCITT-FALSE (poly 0x1021, init 0xFFFF) ตาม spec ของ EMVCo
+func CRC16(data string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
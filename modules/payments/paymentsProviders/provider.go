@@ -0,0 +1,37 @@
+package paymentsProviders
+
+import (
+	"context"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments"
+)
+
+// IProvider : payment gateway หนึ่งเจ้า
+type IProvider interface {
+	Name() string
+	// CreateIntent : เริ่มการจ่ายเงินของ payment ที่สร้างไว้แล้ว (payment.Id ใช้เป็น reference ฝั่ง provider)
+	CreateIntent(ctx context.Context, payment *payments.Payment) (*payments.Intent, error)
+	// ParseWebhook : ตรวจ signature แล้วแปลง body เป็น event คืน nil ถ้าเป็น event ที่ไม่ต้องทำอะไร
+	ParseWebhook(signature string, body []byte) (*payments.WebhookEvent, error)
+}
+
+// NewProviders : เปิดเฉพาะ provider ที่กำหนดค่าไว้ใน config
+func NewProviders(cfg config.IConfig) map[string]IProvider {
+	providers := make(map[string]IProvider)
+	if cfg.Payment().PromptPayId() != "" {
+		providers[payments.ProviderPromptPay] = PromptPayProvider(
+			cfg.Payment().PromptPayId(),
+			cfg.Payment().PromptPayWebhookSecret(),
+		)
+	}
+	if cfg.Payment().CardGatewayUrl() != "" {
+		providers[payments.ProviderCard] = CardProvider(&CardOptions{
+			BaseUrl:       cfg.Payment().CardGatewayUrl(),
+			SecretKey:     cfg.Payment().CardSecretKey(),
+			WebhookSecret: cfg.Payment().CardWebhookSecret(),
+			ReturnUrl:     cfg.Payment().ReturnUrl(),
+		})
+	}
+	return providers
+}
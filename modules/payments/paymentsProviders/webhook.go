@@ -0,0 +1,74 @@
+package paymentsProviders
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookTolerance : อายุของ signature กันการนำ request เก่ามายิงซ้ำ (replay)
+const WebhookTolerance = time.Minute * 5
+
+// SignWebhook : header X-Signature รูปแบบ t=<unix>,v1=<hex hmac-sha256(secret, "<unix>.<body>")>
+func SignWebhook(secret []byte, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, webhookSignature(secret, t, body))
+}
+
+// VerifyWebhook : secret ว่าง = ไม่รับ webhook เลย
+func VerifyWebhook(secret []byte, header string, body []byte, now time.Time) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("signature is invalid")
+	}
+
+	var timestamp string
+	signatures := make([]string, 0)
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			// ช่วงเปลี่ยน secret provider อาจส่งมาหลาย signature
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("signature is invalid")
+	}
+
+	expect := webhookSignature(secret, timestamp, body)
+	valid := false
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(expect), []byte(signature)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("signature is invalid")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("signature is invalid")
+	}
+	if diff := now.Sub(time.Unix(unix, 0)); diff > WebhookTolerance || diff < -WebhookTolerance {
+		return fmt.Errorf("signature has expired")
+	}
+	return nil
+}
+
+func webhookSignature(secret []byte, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp + "."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
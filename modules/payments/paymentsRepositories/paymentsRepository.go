@@ -0,0 +1,137 @@
+package paymentsRepositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments/paymentsPatterns"
+	"github.com/jmoiron/sqlx"
+)
+
+type IPaymentsRepository interface {
+	FindOnePayment(paymentId string) (*payments.Payment, error)
+	FindOrderPayments(orderId string) ([]*payments.Payment, error)
+	InsertPayment(req *payments.Payment) (*payments.Payment, error)
+	UpdatePaymentIntent(paymentId string, intent *payments.Intent) error
+	UpdatePaymentStatus(paymentId, status string) error
+	ProcessEvent(event *payments.WebhookEvent) error
+}
+
+type paymentsRepository struct {
+	db *sqlx.DB
+}
+
+func PaymentsRepository(db *sqlx.DB) IPaymentsRepository {
+	return &paymentsRepository{db: db}
+}
+
+const paymentColumns = `
+		"id",
+		"order_id",
+		"user_id",
+		"provider",
+		"status",
+		"amount",
+		"currency",
+		COALESCE("provider_ref", '') AS "provider_ref",
+		COALESCE("qr_payload", '') AS "qr_payload",
+		COALESCE("redirect_url", '') AS "redirect_url",
+		COALESCE(to_char("paid_at", 'YYYY-MM-DD"T"HH24:MI:SS'), '') AS "paid_at",
+		to_char("created_at", 'YYYY-MM-DD"T"HH24:MI:SS') AS "created_at",
+		to_char("updated_at", 'YYYY-MM-DD"T"HH24:MI:SS') AS "updated_at"`
+
+func (r *paymentsRepository) FindOnePayment(paymentId string) (*payments.Payment, error) {
+	query := fmt.Sprintf(`
+	SELECT%s
+	FROM "payments"
+	WHERE "id" = $1;`, paymentColumns)
+
+	payment := new(payments.Payment)
+	if err := r.db.Get(payment, query, paymentId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("payment not found")
+		}
+		return nil, fmt.Errorf("get payment failed: %v", err)
+	}
+	return payment, nil
+}
+
+func (r *paymentsRepository) FindOrderPayments(orderId string) ([]*payments.Payment, error) {
+	query := fmt.Sprintf(`
+	SELECT%s
+	FROM "payments"
+	WHERE "order_id" = $1
+	ORDER BY "created_at" DESC;`, paymentColumns)
+
+	paymentsData := make([]*payments.Payment, 0)
+	if err := r.db.Select(&paymentsData, query, orderId); err != nil {
+		return nil, fmt.Errorf("get payments failed: %v", err)
+	}
+	return paymentsData, nil
+}
+
+func (r *paymentsRepository) InsertPayment(req *payments.Payment) (*payments.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `
+	INSERT INTO "payments" (
+		"order_id",
+		"user_id",
+		"provider",
+		"amount",
+		"currency"
+	)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING "id";`
+
+	var paymentId string
+	if err := r.db.QueryRowxContext(
+		ctx,
+		query,
+		req.OrderId,
+		req.UserId,
+		req.Provider,
+		req.Amount,
+		req.Currency,
+	).Scan(&paymentId); err != nil {
+		return nil, fmt.Errorf("insert payment failed: %v", err)
+	}
+	return r.FindOnePayment(paymentId)
+}
+
+func (r *paymentsRepository) UpdatePaymentIntent(paymentId string, intent *payments.Intent) error {
+	query := `
+	UPDATE "payments" SET
+		"provider_ref" = NULLIF($1, ''),
+		"qr_payload" = NULLIF($2, ''),
+		"redirect_url" = NULLIF($3, '')
+	WHERE "id" = $4;`
+
+	if _, err := r.db.Exec(query, intent.ProviderRef, intent.QrPayload, intent.RedirectUrl, paymentId); err != nil {
+		return fmt.Errorf("update payment failed: %v", err)
+	}
+	return nil
+}
+
+// UpdatePaymentStatus : เปลี่ยนได้เฉพาะ payment ที่ยัง pending อยู่ ผลจาก webhook ต้องผ่าน ProcessEvent
+func (r *paymentsRepository) UpdatePaymentStatus(paymentId, status string) error {
+	query := `
+	UPDATE "payments" SET
+		"status" = $1
+	WHERE "id" = $2
+	AND "status" = 'pending';`
+
+	if _, err := r.db.Exec(query, status, paymentId); err != nil {
+		return fmt.Errorf("update payment failed: %v", err)
+	}
+	return nil
+}
+
+func (r *paymentsRepository) ProcessEvent(event *payments.WebhookEvent) error {
+	builder := paymentsPatterns.ProcessEventBuilder(r.db, event)
+	return paymentsPatterns.ProcessEventEngineer(builder).ProcessEvent()
+}
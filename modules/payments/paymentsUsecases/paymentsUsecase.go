@@ -0,0 +1,117 @@
+package paymentsUsecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments/paymentsProviders"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments/paymentsRepositories"
+)
+
+type IPaymentsUsecase interface {
+	InsertPayment(userId string, req *payments.PaymentReq) (*payments.Payment, error)
+	FindOnePayment(paymentId, userId string, isAdmin bool) (*payments.Payment, error)
+	ProcessWebhook(provider, signature string, body []byte) error
+}
+
+type paymentsUsecase struct {
+	paymentsRepository paymentsRepositories.IPaymentsRepository
+	ordersRepository   ordersRepositories.IOrdersRepository
+	providers          map[string]paymentsProviders.IProvider
+}
+
+func PaymentsUsecase(paymentsRepository paymentsRepositories.IPaymentsRepository, ordersRepository ordersRepositories.IOrdersRepository, providers map[string]paymentsProviders.IProvider) IPaymentsUsecase {
+	return &paymentsUsecase{
+		paymentsRepository: paymentsRepository,
+		ordersRepository:   ordersRepository,
+		providers:          providers,
+	}
+}
+
+func (u *paymentsUsecase) InsertPayment(userId string, req *payments.PaymentReq) (*payments.Payment, error) {
+	provider, ok := u.providers[req.Provider]
+	if !ok {
+		return nil, fmt.Errorf("provider is not supported")
+	}
+
+	order, err := u.ordersRepository.FindOneOrder(req.OrderId)
+	if err != nil || order.UserId != userId {
+		return nil, fmt.Errorf("order not found")
+	}
+	if order.Status != "waiting" {
+		return nil, fmt.Errorf("order is not waiting for payment")
+	}
+	if order.TotalPaid <= 0 {
+		return nil, fmt.Errorf("order has nothing to pay")
+	}
+
+	orderPayments, err := u.paymentsRepository.FindOrderPayments(order.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range orderPayments {
+		if p.Status == payments.StatusSucceeded {
+			return nil, fmt.Errorf("order has already been paid")
+		}
+		// กดจ่ายซ้ำด้วยวิธีเดิม ใช้ intent เดิม
+		if p.Status == payments.StatusPending && p.Provider == req.Provider &&
+			payments.ToSatang(p.Amount) == payments.ToSatang(order.TotalPaid) {
+			return p, nil
+		}
+	}
+
+	payment, err := u.paymentsRepository.InsertPayment(&payments.Payment{
+		OrderId:  order.Id,
+		UserId:   userId,
+		Provider: req.Provider,
+		Amount:   order.TotalPaid,
+		Currency: payments.Currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	intent, err := provider.CreateIntent(ctx, payment)
+	if err != nil {
+		u.paymentsRepository.UpdatePaymentStatus(payment.Id, payments.StatusFailed)
+		return nil, err
+	}
+	if err := u.paymentsRepository.UpdatePaymentIntent(payment.Id, intent); err != nil {
+		return nil, err
+	}
+	return u.paymentsRepository.FindOnePayment(payment.Id)
+}
+
+func (u *paymentsUsecase) FindOnePayment(paymentId, userId string, isAdmin bool) (*payments.Payment, error) {
+	payment, err := u.paymentsRepository.FindOnePayment(paymentId)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin && payment.UserId != userId {
+		return nil, fmt.Errorf("payment not found")
+	}
+	return payment, nil
+}
+
+func (u *paymentsUsecase) ProcessWebhook(provider, signature string, body []byte) error {
+	p, ok := u.providers[provider]
+	if !ok {
+		return fmt.Errorf("provider is not supported")
+	}
+
+	event, err := p.ParseWebhook(signature, body)
+	if err != nil {
+		return err
+	}
+	// event ที่ไม่เกี่ยวกับผลการจ่ายเงิน
+	if event == nil {
+		return nil
+	}
+	return u.paymentsRepository.ProcessEvent(event)
+}
@@ -1,24 +1,151 @@
 package products
 
 import (
+	"strings"
+	"unicode"
+
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/appinfo"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
 )
 
 type Product struct {
-	Id          string            `json:"id"`
-	Title       string            `json:"title"`
-	Description string            `json:"description"`
-	Category    *appinfo.Category `json:"category"`
-	CreatedAt   string            `json:"created_at"`
-	UpdatedAt   string            `json:"updated_at"`
-	Price       float64           `json:"price"`
-	Images      []*entities.Image `json:"images"`
+	Id          string              `json:"id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Categories  []*appinfo.Category `json:"categories"`
+	CreatedAt   string              `json:"created_at"`
+	UpdatedAt   string              `json:"updated_at"`
+	Price       float64             `json:"price"`
+	Stock       int                 `json:"stock"`
+	Images      []*entities.Image   `json:"images"`
+	Variants    []*Variant          `json:"variants"`
+	Variant     *Variant            `json:"variant,omitempty"` // variant ที่ถูกสั่งซื้อ (snapshot ใน order)
+}
+
+// Variant : SKU ของ product แยกตาม option eg. size, color
+type Variant struct {
+	Id        string            `json:"id"`
+	ProductId string            `json:"product_id"`
+	Sku       string            `json:"sku"`
+	Options   map[string]string `json:"options"`
+	Price     *float64          `json:"price"` // nil = ใช้ราคาของ product
+	Stock     int               `json:"stock"`
+	Images    []*entities.Image `json:"images"`
+	CreatedAt string            `json:"created_at"`
+	UpdatedAt string            `json:"updated_at"`
+}
+
+// EffectivePrice : ราคาที่ใช้ขายของ variant
+func (v *Variant) EffectivePrice(productPrice float64) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return productPrice
+}
+
+// FindVariant : หา variant ของ product จาก id
+func (p *Product) FindVariant(variantId string) *Variant {
+	for _, v := range p.Variants {
+		if v.Id == variantId {
+			return v
+		}
+	}
+	return nil
 }
 
 type ProductFilter struct {
-	Id     string `query:"id"`
-	Search string `query:"search"`
+	Id            string  `query:"id"`
+	Search        string  `query:"search"`
+	SearchMode    string  `query:"search_mode"` // fulltext (default), like
+	CategoryId    []int   `query:"category_id"` // ส่งซ้ำได้หลายค่า รวม category ลูกทั้งหมด
+	MinPrice      float64 `query:"min_price"`
+	MaxPrice      float64 `query:"max_price"`
+	CreatedAfter  string  `query:"created_after"`  // YYYY-MM-DD
+	CreatedBefore string  `query:"created_before"` // YYYY-MM-DD
+	InStock       bool    `query:"in_stock"`       // มี stock ที่ product หรือ variant ใด variant หนึ่ง
+	Facets        bool    `query:"facets"`         // ไม่ส่งมา = นับ facet เฉพาะการแบ่งหน้าแบบ page ที่นับจำนวนทั้งหมด
 	*entities.PaginationReq
 	*entities.SortReq
 }
+
+// DefaultFacets : cursor และ skip_count ใช้กับการเลื่อนหน้าต่อเนื่อง จึงไม่ต้องนับ facet ซ้ำทุกหน้า
+func (f *ProductFilter) DefaultFacets() bool {
+	return !f.UseCursor && !f.SkipCount
+}
+
+type ProductFacets struct {
+	Categories []*CategoryFacet `json:"categories"`
+	Prices     []*PriceFacet    `json:"prices"`
+}
+
+type CategoryFacet struct {
+	Id    int    `db:"id" json:"id"`
+	Title string `db:"title" json:"title"`
+	Count int    `db:"count" json:"count"`
+}
+
+type PriceFacet struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"` // nil = ไม่จำกัด
+	Count int      `json:"count"`
+}
+
+// PriceBuckets : ขอบล่างของแต่ละช่วงราคาใน facets
+var PriceBuckets = []float64{0, 100, 500, 1000, 5000}
+
+// NewPriceFacets สร้างช่วงราคาตาม PriceBuckets โดย counts[i] คือจำนวน product ในช่วงที่ i
+func NewPriceFacets(counts map[int]int) []*PriceFacet {
+	facets := make([]*PriceFacet, 0)
+	for i, min := range PriceBuckets {
+		facet := &PriceFacet{
+			Min:   min,
+			Count: counts[i],
+		}
+		if i+1 < len(PriceBuckets) {
+			max := PriceBuckets[i+1]
+			facet.Max = &max
+		}
+		facets = append(facets, facet)
+	}
+	return facets
+}
+
+type StockAdjustReq struct {
+	ProductId string `json:"-"`
+	VariantId string `json:"-"`
+	UserId    string `json:"-"`
+	Qty       int    `json:"qty"` // + เพิ่ม stock, - ลด stock
+	Note      string `json:"note"`
+}
+
+type StockMovement struct {
+	Id        string `json:"id"`
+	ProductId string `json:"product_id"`
+	VariantId string `json:"variant_id"`
+	OrderId   string `json:"order_id"`
+	UserId    string `json:"user_id"`
+	Type      string `json:"type"`
+	Qty       int    `json:"qty"`
+	Balance   int    `json:"balance"`
+	Note      string `json:"note"`
+	CreatedAt string `json:"created_at"`
+}
+
+type StockMovementFilter struct {
+	ProductId string `query:"-"`
+	*entities.PaginationReq
+}
+
+// BuildTsQuery แปลงคำค้นหาเป็น tsquery แบบ prefix ของทุกคำ eg. "blue shi" -> "blue:* & shi:*"
+// ตัดอักขระพิเศษของ tsquery ออกทั้งหมด คืนค่าว่างถ้าไม่มีคำที่ใช้ค้นหาได้
+func BuildTsQuery(search string) string {
+	words := strings.FieldsFunc(strings.ToLower(search), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r)
+	})
+
+	terms := make([]string, 0)
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
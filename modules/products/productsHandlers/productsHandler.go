@@ -1,8 +1,8 @@
 package productsHandlers
 
 import (
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/appinfo"
@@ -22,6 +22,11 @@ const (
 	insertProductErr  productsHandlersErrCode = "products-003"
 	updateProductErr  productsHandlersErrCode = "products-004"
 	deleteProductErr  productsHandlersErrCode = "products-005"
+	adjustStockErr    productsHandlersErrCode = "products-006"
+	findStockErr      productsHandlersErrCode = "products-007"
+	insertVariantErr  productsHandlersErrCode = "products-008"
+	updateVariantErr  productsHandlersErrCode = "products-009"
+	deleteVariantErr  productsHandlersErrCode = "products-010"
 )
 
 type IProductsHandler interface {
@@ -30,6 +35,11 @@ type IProductsHandler interface {
 	AddProduct(c *fiber.Ctx) error
 	UpdateProduct(c *fiber.Ctx) error
 	DeleteProduct(c *fiber.Ctx) error
+	AdjustStock(c *fiber.Ctx) error
+	FindStockMovement(c *fiber.Ctx) error
+	AddVariant(c *fiber.Ctx) error
+	UpdateVariant(c *fiber.Ctx) error
+	DeleteVariant(c *fiber.Ctx) error
 }
 
 type productsHandler struct {
@@ -82,19 +92,77 @@ func (h *productsHandler) FindProduct(c *fiber.Ctx) error {
 	}
 	if req.OrderBy == "" {
 		req.OrderBy = "title"
+		if req.Search != "" && !c.Context().QueryArgs().Has("cursor") {
+			// ค้นหาแล้วเรียงตามความเกี่ยวข้องเป็นค่าเริ่มต้น (ยกเว้นแบ่งหน้าแบบ cursor)
+			req.OrderBy = entities.SortRelevance
+		}
 	}
-	if req.Sort == "" {
+	req.Sort = strings.ToUpper(req.Sort)
+	if req.Sort != "DESC" && !req.IsRelevance() {
 		req.Sort = "ASC"
 	}
 
+	if err := req.ParseCursor(c, req.OrderBy, req.Sort); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findProductErr),
+			err.Error(),
+		).Res()
+	}
+	if req.UseCursor && req.IsRelevance() {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findProductErr),
+			"cursor does not support relevance sort",
+		).Res()
+	}
+	if !c.Context().QueryArgs().Has("facets") {
+		req.Facets = req.DefaultFacets()
+	}
+
+	if req.MinPrice < 0 || req.MaxPrice < 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findProductErr),
+			"price is invalid",
+		).Res()
+	}
+	if req.MaxPrice > 0 && req.MinPrice > req.MaxPrice {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findProductErr),
+			"min price must be less than max price",
+		).Res()
+	}
+
+	// date YYYY-MM-DD
+	if req.CreatedAfter != "" {
+		if _, err := time.Parse("2006-01-02", req.CreatedAfter); err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(findProductErr),
+				"created after is invalid",
+			).Res()
+		}
+	}
+	if req.CreatedBefore != "" {
+		if _, err := time.Parse("2006-01-02", req.CreatedBefore); err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(findProductErr),
+				"created before is invalid",
+			).Res()
+		}
+	}
+
 	products := h.productsUsecase.FindProduct(req)
 	return entities.NewResponse(c).Success(fiber.StatusOK, products).Res()
 }
 
 func (h *productsHandler) AddProduct(c *fiber.Ctx) error {
 	req := &products.Product{
-		Category: &appinfo.Category{},
-		Images:   make([]*entities.Image, 0),
+		Categories: make([]*appinfo.Category, 0),
+		Images:     make([]*entities.Image, 0),
 	}
 	if err := c.BodyParser(req); err != nil {
 		return entities.NewResponse(c).Error(
@@ -103,13 +171,22 @@ func (h *productsHandler) AddProduct(c *fiber.Ctx) error {
 			err.Error(),
 		).Res()
 	}
-	if req.Category.Id <= 0 {
+	if len(req.Categories) == 0 {
 		return entities.NewResponse(c).Error(
 			fiber.ErrBadRequest.Code,
 			string(insertProductErr),
-			"category id is invalid",
+			"categories are required",
 		).Res()
 	}
+	for _, category := range req.Categories {
+		if category == nil || category.Id <= 0 {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertProductErr),
+				"category id is invalid",
+			).Res()
+		}
+	}
 
 	product, err := h.productsUsecase.AddProduct(req)
 	if err != nil {
@@ -126,8 +203,8 @@ func (h *productsHandler) UpdateProduct(c *fiber.Ctx) error {
 	productId := strings.Trim(c.Params("product_id"), " ")
 
 	req := &products.Product{
-		Images:   make([]*entities.Image, 0),
-		Category: &appinfo.Category{},
+		Images:     make([]*entities.Image, 0),
+		Categories: make([]*appinfo.Category, 0),
 	}
 	if err := c.BodyParser(req); err != nil {
 		return entities.NewResponse(c).Error(
@@ -137,6 +214,15 @@ func (h *productsHandler) UpdateProduct(c *fiber.Ctx) error {
 		).Res()
 	}
 	req.Id = productId
+	for _, category := range req.Categories {
+		if category == nil || category.Id <= 0 {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateProductErr),
+				"category id is invalid",
+			).Res()
+		}
+	}
 
 	product, err := h.productsUsecase.UpdateProduct(req)
 	if err != nil {
@@ -163,12 +249,15 @@ func (h *productsHandler) DeleteProduct(c *fiber.Ctx) error {
 
 	deleteFileReq := make([]*files.DeleteFileReq, 0)
 	for _, p := range product.Images {
-		deleteFileReq = append(deleteFileReq, &files.DeleteFileReq{
-			Destination: fmt.Sprintf("images/products/%s", p.FileName),
-		})
+		deleteFileReq = append(deleteFileReq, files.ImageDeleteReq("images/products", p)...)
+	}
+	for _, v := range product.Variants {
+		for _, p := range v.Images {
+			deleteFileReq = append(deleteFileReq, files.ImageDeleteReq("images/products", p)...)
+		}
 	}
 	if len(deleteFileReq) > 0 {
-		if err := h.filesUsecase.DeleteFileOnGCP(deleteFileReq); err != nil {
+		if err := h.filesUsecase.DeleteFiles(deleteFileReq); err != nil {
 			return entities.NewResponse(c).Error(
 				fiber.ErrInternalServerError.Code,
 				string(deleteProductErr),
@@ -186,3 +275,227 @@ func (h *productsHandler) DeleteProduct(c *fiber.Ctx) error {
 	}
 	return entities.NewResponse(c).Success(fiber.StatusNoContent, nil).Res()
 }
+
+func (h *productsHandler) AdjustStock(c *fiber.Ctx) error {
+	req := new(products.StockAdjustReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(adjustStockErr),
+			err.Error(),
+		).Res()
+	}
+	if req.Qty == 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(adjustStockErr),
+			"qty must not be 0",
+		).Res()
+	}
+	req.ProductId = strings.Trim(c.Params("product_id"), " ")
+	req.VariantId = strings.Trim(c.Params("variant_id"), " ")
+	req.UserId = c.Locals("userId").(string)
+
+	movement, err := h.productsUsecase.AdjustStock(req)
+	if err != nil {
+		switch err.Error() {
+		case "product not found", "variant not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(adjustStockErr),
+				err.Error(),
+			).Res()
+		case "stock is insufficient":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(adjustStockErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(adjustStockErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, movement).Res()
+}
+
+func (h *productsHandler) FindStockMovement(c *fiber.Ctx) error {
+	req := &products.StockMovementFilter{
+		PaginationReq: &entities.PaginationReq{},
+	}
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findStockErr),
+			err.Error(),
+		).Res()
+	}
+	req.ProductId = strings.Trim(c.Params("product_id"), " ")
+
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.Limit < 5 {
+		req.Limit = 5
+	}
+
+	movements := h.productsUsecase.FindStockMovement(req)
+	return entities.NewResponse(c).Success(fiber.StatusOK, movements).Res()
+}
+
+func (h *productsHandler) AddVariant(c *fiber.Ctx) error {
+	req := &products.Variant{
+		Options: make(map[string]string),
+		Images:  make([]*entities.Image, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertVariantErr),
+			err.Error(),
+		).Res()
+	}
+	req.ProductId = strings.Trim(c.Params("product_id"), " ")
+	req.Sku = strings.TrimSpace(req.Sku)
+
+	if req.Sku == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertVariantErr),
+			"sku is required",
+		).Res()
+	}
+	if req.Options == nil {
+		req.Options = make(map[string]string)
+	}
+	if req.Price != nil && *req.Price < 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertVariantErr),
+			"price is invalid",
+		).Res()
+	}
+
+	variant, err := h.productsUsecase.AddVariant(req)
+	if err != nil {
+		switch err.Error() {
+		case "product not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(insertVariantErr),
+				err.Error(),
+			).Res()
+		case "sku has been used":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertVariantErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(insertVariantErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, variant).Res()
+}
+
+func (h *productsHandler) UpdateVariant(c *fiber.Ctx) error {
+	req := &products.Variant{
+		Images: make([]*entities.Image, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateVariantErr),
+			err.Error(),
+		).Res()
+	}
+	req.ProductId = strings.Trim(c.Params("product_id"), " ")
+	req.Id = strings.Trim(c.Params("variant_id"), " ")
+	req.Sku = strings.TrimSpace(req.Sku)
+
+	if req.Price != nil && *req.Price < 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateVariantErr),
+			"price is invalid",
+		).Res()
+	}
+
+	variant, err := h.productsUsecase.UpdateVariant(req)
+	if err != nil {
+		switch err.Error() {
+		case "variant not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateVariantErr),
+				err.Error(),
+			).Res()
+		case "sku has been used":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateVariantErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateVariantErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, variant).Res()
+}
+
+func (h *productsHandler) DeleteVariant(c *fiber.Ctx) error {
+	productId := strings.Trim(c.Params("product_id"), " ")
+	variantId := strings.Trim(c.Params("variant_id"), " ")
+
+	variant, err := h.productsUsecase.FindOneVariant(productId, variantId)
+	if err != nil {
+		switch err.Error() {
+		case "variant not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(deleteVariantErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deleteVariantErr),
+				err.Error(),
+			).Res()
+		}
+	}
+
+	deleteFileReq := make([]*files.DeleteFileReq, 0)
+	for _, p := range variant.Images {
+		deleteFileReq = append(deleteFileReq, files.ImageDeleteReq("images/products", p)...)
+	}
+	if len(deleteFileReq) > 0 {
+		if err := h.filesUsecase.DeleteFiles(deleteFileReq); err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deleteVariantErr),
+				err.Error(),
+			).Res()
+		}
+	}
+
+	if err := h.productsUsecase.DeleteVariant(productId, variantId); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(deleteVariantErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusNoContent, nil).Res()
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/utils"
 	"github.com/jmoiron/sqlx"
@@ -18,13 +19,19 @@ type IFindProductBuilder interface {
 	openJsonQuery()
 	initQuery()
 	countQuery()
+	categoryFacetQuery()
+	priceFacetQuery()
 	whereQuery()
+	cursorQuery()
 	sort()
 	paginate()
 	closeJsonQuery()
 	resetQuery()
 	Result() []*products.Product
 	Count() int
+	PageCursor() *entities.PageCursor
+	CategoryFacets() []*products.CategoryFacet
+	PriceFacets() []*products.PriceFacet
 	PrintQuery()
 }
 
@@ -34,12 +41,28 @@ type findProductBuilder struct {
 	query          string
 	lastStackIndex int
 	values         []any
+	pageCursor     *entities.PageCursor
+}
+
+// column ต้องมาจาก map เท่านั้น เพราะ ORDER BY ใช้ placeholder แทนชื่อ column ไม่ได้
+var productOrderByMap = map[string]string{
+	"id":    `"p"."id"`,
+	"title": `"p"."title"`,
+	"price": `"p"."price"`,
+}
+
+// type ของค่าใน cursor เมื่อเทียบกับ column
+var productCursorCastMap = map[string]string{
+	"id":    "varchar",
+	"title": "varchar",
+	"price": "float8",
 }
 
 func FindProductBuilder(db *sqlx.DB, req *products.ProductFilter) IFindProductBuilder {
 	return &findProductBuilder{
-		db:  db,
-		req: req,
+		db:         db,
+		req:        req,
+		pageCursor: new(entities.PageCursor),
 	}
 }
 
@@ -57,19 +80,22 @@ func (b *findProductBuilder) initQuery() {
 			"p"."title",
 			"p"."description",
 			"p"."price",
+			"p"."stock",
 			(
 				SELECT
-					to_jsonb("ct") 
+					COALESCE(array_to_json(array_agg("ct")), '[]'::json)
 				FROM (
 					SELECT
 						"c"."id",
-						"c"."title"
+						"c"."title",
+						"c"."parent_id"
 					FROM "categories" "c"
-					LEFT JOIN "products_categories" "pc"
+					JOIN "products_categories" "pc"
 					ON "pc"."category_id" = "c"."id"
 					WHERE "pc"."product_id" = "p"."id"
+					ORDER BY "c"."id" ASC
 				) AS "ct"
-			) AS "category",
+			) AS "categories",
 			"p"."created_at",
 			"p"."updated_at",
 			(
@@ -79,10 +105,44 @@ func (b *findProductBuilder) initQuery() {
 					SELECT 
 						"i"."id",
 						"i"."filename",
-						"i"."url"
+						"i"."url",
+						"i"."renditions"
 					FROM "images" "i"
+					WHERE "i"."product_id" = "p"."id"
+					AND "i"."variant_id" IS NULL
 				) AS "it"
-			) AS "images"
+			) AS "images",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("vt")), '[]'::json)
+				FROM (
+					SELECT
+						"v"."id",
+						"v"."product_id",
+						"v"."sku",
+						"v"."options",
+						"v"."price",
+						"v"."stock",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("vit")), '[]'::json)
+							FROM (
+								SELECT
+									"vi"."id",
+									"vi"."filename",
+									"vi"."url",
+									"vi"."renditions"
+								FROM "images" "vi"
+								WHERE "vi"."variant_id" = "v"."id"
+							) AS "vit"
+						) AS "images",
+						"v"."created_at",
+						"v"."updated_at"
+					FROM "product_variants" "v"
+					WHERE "v"."product_id" = "p"."id"
+					ORDER BY "v"."created_at" ASC
+				) AS "vt"
+			) AS "variants"
 		FROM "products" "p"
 		WHERE 1 = 1`
 }
@@ -95,9 +155,34 @@ func (b *findProductBuilder) countQuery() {
 	WHERE 1 = 1`
 }
 
+func (b *findProductBuilder) categoryFacetQuery() {
+	b.query += `
+	SELECT
+		"fc"."id",
+		"fc"."title",
+		COUNT(DISTINCT "p"."id") AS "count"
+	FROM "products" "p"
+	JOIN "products_categories" "fpc"
+	ON "fpc"."product_id" = "p"."id"
+	JOIN "categories" "fc"
+	ON "fc"."id" = "fpc"."category_id"
+	WHERE 1 = 1`
+}
+
+func (b *findProductBuilder) priceFacetQuery() {
+	b.query += `
+	SELECT
+		(width_bucket("p"."price", $1::float8[]) - 1) AS "bucket",
+		COUNT(*) AS "count"
+	FROM "products" "p"
+	WHERE 1 = 1`
+	b.values = append(b.values, products.PriceBuckets)
+}
+
 func (b *findProductBuilder) whereQuery() {
 	var queryWhere string
 	queryWhereStack := make([]string, 0)
+	index := len(b.values) // values ที่มีอยู่ก่อน where eg. price buckets ของ facet
 
 	// Id check
 	if b.req.Id != "" {
@@ -108,7 +193,13 @@ func (b *findProductBuilder) whereQuery() {
 	}
 
 	// Search check
-	if b.req.Search != "" {
+	if b.isFullText() {
+		b.values = append(b.values, products.BuildTsQuery(b.req.Search))
+
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."search_vector" @@ to_tsquery('simple', ?)`)
+	} else if b.req.Search != "" {
+		// search_mode=like : ค้นหาบางส่วนของคำ (ไม่ใช้ index)
 		b.values = append(b.values,
 			"%"+strings.ToLower(b.req.Search)+"%",
 			"%"+strings.ToLower(b.req.Search)+"%",
@@ -118,14 +209,78 @@ func (b *findProductBuilder) whereQuery() {
 		AND (LOWER("p"."title") LIKE ? OR LOWER("p"."description") LIKE ?)`)
 	}
 
+	// Category check : รวม category ลูกทุกระดับ
+	if len(b.req.CategoryId) > 0 {
+		b.values = append(b.values, b.req.CategoryId)
+
+		queryWhereStack = append(queryWhereStack, `
+		AND EXISTS (
+			SELECT 1
+			FROM "products_categories" "pc"
+			WHERE "pc"."product_id" = "p"."id"
+			AND "pc"."category_id" IN (
+				WITH RECURSIVE "ct" AS (
+					SELECT "id" FROM "categories" WHERE "id" = ANY(?::int[])
+					UNION ALL
+					SELECT "c"."id" FROM "categories" "c"
+					JOIN "ct" ON "c"."parent_id" = "ct"."id"
+				)
+				SELECT "id" FROM "ct"
+			)
+		)`)
+	}
+
+	// Price range check
+	if b.req.MinPrice > 0 {
+		b.values = append(b.values, b.req.MinPrice)
+
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."price" >= ?`)
+	}
+	if b.req.MaxPrice > 0 {
+		b.values = append(b.values, b.req.MaxPrice)
+
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."price" <= ?`)
+	}
+
+	// Created date check
+	if b.req.CreatedAfter != "" {
+		b.values = append(b.values, b.req.CreatedAfter)
+
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."created_at" >= ?::date`)
+	}
+	if b.req.CreatedBefore != "" {
+		b.values = append(b.values, b.req.CreatedBefore)
+
+		// รวมทั้งวันของ created_before
+		queryWhereStack = append(queryWhereStack, `
+		AND "p"."created_at" < ?::date + 1`)
+	}
+
+	// In stock check
+	if b.req.InStock {
+		queryWhereStack = append(queryWhereStack, `
+		AND (
+			"p"."stock" > 0 OR
+			EXISTS (
+				SELECT 1
+				FROM "product_variants" "sv"
+				WHERE "sv"."product_id" = "p"."id"
+				AND "sv"."stock" > 0
+			)
+		)`)
+	}
+
+	// แทน ? ด้วย $n ตามลำดับของ values
 	for i := range queryWhereStack {
-		if i != len(queryWhereStack)-1 {
-			queryWhere += strings.Replace(queryWhereStack[i], "?", "$"+strconv.Itoa(i+1), 1)
+		for strings.Contains(queryWhereStack[i], "?") {
+			index++
 			// Itoa() convert integer to string
-		} else {
-			queryWhere += strings.Replace(queryWhereStack[i], "?", "$"+strconv.Itoa(i+1), 1)
-			queryWhere = strings.Replace(queryWhere, "?", "$"+strconv.Itoa(i+2), 1)
+			queryWhereStack[i] = strings.Replace(queryWhereStack[i], "?", "$"+strconv.Itoa(index), 1)
 		}
+		queryWhere += queryWhereStack[i]
 	}
 	// last stack record
 	b.lastStackIndex = len(b.values)
@@ -134,35 +289,82 @@ func (b *findProductBuilder) whereQuery() {
 	b.query += queryWhere
 }
 
+func (b *findProductBuilder) isFullText() bool {
+	return b.req.SearchMode != "like" && products.BuildTsQuery(b.req.Search) != ""
+}
+
 func (b *findProductBuilder) sort() {
-	odderByMap := map[string]string{
-		"id":    "\"p\".\"id\"",
-		"title": "\"p\".\"title\"",
-		"price": "\"p\".\"price\"",
+	// relevance ใช้ได้เฉพาะตอนค้นหาแบบ full-text
+	if b.req.IsRelevance() && b.isFullText() {
+		b.values = append(b.values, products.BuildTsQuery(b.req.Search))
+		b.query += fmt.Sprintf(`
+		ORDER BY ts_rank("p"."search_vector", to_tsquery('simple', $%d)) DESC, "p"."id" ASC`, b.lastStackIndex+1)
+		b.lastStackIndex = len(b.values)
+		return
 	}
-	if odderByMap[b.req.OrderBy] == "" {
-		b.req.OrderBy = odderByMap["title"]
-	} else {
-		b.req.OrderBy = odderByMap[b.req.OrderBy]
+
+	b.query += fmt.Sprintf(`
+		ORDER BY %s %s, "p"."id" %s`, b.orderBy(), b.direction(), b.direction())
+}
+
+func (b *findProductBuilder) orderBy() string {
+	if orderBy := productOrderByMap[b.req.OrderBy]; orderBy != "" {
+		return orderBy
+	}
+	return productOrderByMap["title"]
+}
+
+// direction : ทิศทางการเรียงใน query ตอนดึงหน้าก่อนหน้าจะกลับทิศแล้วค่อยกลับผลลัพธ์ทีหลัง
+func (b *findProductBuilder) direction() string {
+	desc := strings.ToUpper(b.req.Sort) == "DESC"
+	if b.isBackward() {
+		desc = !desc
+	}
+	if desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+func (b *findProductBuilder) isBackward() bool {
+	return b.req.Position != nil && b.req.Position.Backward
+}
+
+func (b *findProductBuilder) cursorQuery() {
+	if !b.req.UseCursor || b.req.Position == nil {
+		return
 	}
 
-	sortMap := map[string]string{
-		"DESC": "DESC",
-		"ASC":  "ASC",
+	operator := ">"
+	if b.direction() == "DESC" {
+		operator = "<"
 	}
-	if sortMap[b.req.Sort] == "" {
-		b.req.Sort = sortMap["ASC"]
-	} else {
-		b.req.Sort = sortMap[strings.ToUpper(b.req.Sort)]
+	cast := productCursorCastMap[b.req.OrderBy]
+	if cast == "" {
+		cast = productCursorCastMap["title"]
 	}
 
-	b.values = append(b.values, b.req.OrderBy)
+	b.values = append(b.values, b.req.Position.Value, b.req.Position.Id)
 	b.query += fmt.Sprintf(`
-		ORDER BY $%d %s`, b.lastStackIndex+1, b.req.Sort)
+		AND (%s, "p"."id") %s ($%d::%s, $%d)`,
+		b.orderBy(),
+		operator,
+		b.lastStackIndex+1,
+		cast,
+		b.lastStackIndex+2,
+	)
 	b.lastStackIndex = len(b.values)
 }
 
 func (b *findProductBuilder) paginate() {
+	if b.req.UseCursor {
+		// ดึงเกินมา 1 record เพื่อดูว่ายังมีหน้าถัดไปไหม
+		b.values = append(b.values, b.req.Limit+1)
+		b.query += fmt.Sprintf(`	LIMIT $%d`, b.lastStackIndex+1)
+		b.lastStackIndex = len(b.values)
+		return
+	}
+
 	// offset = (page - 1) * limit
 	b.values = append(b.values, (b.req.Page-1)*b.req.Limit, b.req.Limit)
 	b.query += fmt.Sprintf(`	OFFSET $%d LIMIT $%d`, b.lastStackIndex+1, b.lastStackIndex+2)
@@ -197,9 +399,51 @@ func (b *findProductBuilder) Result() []*products.Product {
 		return make([]*products.Product, 0)
 	}
 	b.resetQuery()
+
+	if b.req.UseCursor {
+		hasMore := len(productsData) > b.req.Limit
+		if hasMore {
+			productsData = productsData[:b.req.Limit]
+		}
+		if b.isBackward() {
+			for i, j := 0, len(productsData)-1; i < j; i, j = i+1, j-1 {
+				productsData[i], productsData[j] = productsData[j], productsData[i]
+			}
+		}
+		if len(productsData) > 0 {
+			b.pageCursor = entities.NewPageCursor(
+				b.req.Position,
+				hasMore,
+				b.cursorOf(productsData[0]),
+				b.cursorOf(productsData[len(productsData)-1]),
+			)
+		}
+	}
 	return productsData
 }
 
+func (b *findProductBuilder) cursorOf(product *products.Product) *entities.Cursor {
+	var value string
+	switch b.req.OrderBy {
+	case "id":
+		value = product.Id
+	case "price":
+		value = strconv.FormatFloat(product.Price, 'g', -1, 64)
+	default:
+		value = product.Title
+	}
+	return &entities.Cursor{
+		Id:      product.Id,
+		Value:   value,
+		OrderBy: b.req.OrderBy,
+		Sort:    b.req.Sort,
+	}
+}
+
+func (b *findProductBuilder) PageCursor() *entities.PageCursor {
+	return b.pageCursor
+}
+
 func (b *findProductBuilder) Count() int {
 	_, cancel := context.WithTimeout(context.Background(), time.Second*15)
 	defer cancel()
@@ -213,6 +457,41 @@ func (b *findProductBuilder) Count() int {
 	return count
 }
 
+func (b *findProductBuilder) CategoryFacets() []*products.CategoryFacet {
+	b.query += `
+	GROUP BY "fc"."id", "fc"."title"
+	ORDER BY "fc"."id" ASC;`
+
+	facets := make([]*products.CategoryFacet, 0)
+	if err := b.db.Select(&facets, b.query, b.values...); err != nil {
+		log.Printf("find category facets failed: %v\n", err)
+		b.resetQuery()
+		return make([]*products.CategoryFacet, 0)
+	}
+	b.resetQuery()
+	return facets
+}
+
+func (b *findProductBuilder) PriceFacets() []*products.PriceFacet {
+	b.query += `
+	GROUP BY "bucket";`
+
+	type bucketCount struct {
+		Bucket int `db:"bucket"`
+		Count  int `db:"count"`
+	}
+	rows := make([]*bucketCount, 0)
+	counts := make(map[int]int)
+	if err := b.db.Select(&rows, b.query, b.values...); err != nil {
+		log.Printf("find price facets failed: %v\n", err)
+	}
+	for _, row := range rows {
+		counts[row.Bucket] = row.Count
+	}
+	b.resetQuery()
+	return products.NewPriceFacets(counts)
+}
+
 func (b *findProductBuilder) PrintQuery() {
 	utils.Debug(b.values)
 	fmt.Println(b.query)
@@ -232,6 +511,7 @@ func (en *findProductEngineer) FindProduct() IFindProductBuilder {
 	en.builder.openJsonQuery()
 	en.builder.initQuery()
 	en.builder.whereQuery()
+	en.builder.cursorQuery()
 	en.builder.sort()
 	en.builder.paginate()
 	en.builder.closeJsonQuery()
@@ -243,3 +523,15 @@ func (en *findProductEngineer) CountProduct() IFindProductBuilder {
 	en.builder.whereQuery()
 	return en.builder
 }
+
+func (en *findProductEngineer) FacetCategory() IFindProductBuilder {
+	en.builder.categoryFacetQuery()
+	en.builder.whereQuery()
+	return en.builder
+}
+
+func (en *findProductEngineer) FacetPrice() IFindProductBuilder {
+	en.builder.priceFacetQuery()
+	en.builder.whereQuery()
+	return en.builder
+}
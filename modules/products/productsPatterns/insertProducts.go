@@ -80,13 +80,27 @@ func (b *insertProductBuilder) insertCategory() error {
 		"product_id",
 		"category_id"
 	)
-	VALUES ($1, $2);`
+	VALUES`
+
+	valueStack := make([]any, 0)
+	for i, category := range b.req.Categories {
+		valueStack = append(valueStack, b.req.Id, category.Id)
+
+		if i != len(b.req.Categories)-1 {
+			query += fmt.Sprintf(`
+	($%d, $%d),`, i*2+1, i*2+2)
+		} else {
+			query += fmt.Sprintf(`
+	($%d, $%d)`, i*2+1, i*2+2)
+		}
+	}
+	query += `
+	ON CONFLICT ("product_id", "category_id") DO NOTHING;`
 
 	if _, err := b.tx.ExecContext(
 		ctx,
 		query,
-		b.req.Id,
-		b.req.Category.Id,
+		valueStack...,
 	); err != nil {
 		b.tx.Rollback()
 		return fmt.Errorf("insert products_categories failed: %v", err)
@@ -102,6 +116,7 @@ func (b *insertProductBuilder) insertAttachment() error {
 	INSERT INTO "images" (
 		"filename",
 		"url",
+		"renditions",
 		"product_id"
 	)
 	VALUES`
@@ -112,17 +127,18 @@ func (b *insertProductBuilder) insertAttachment() error {
 		valueStack = append(valueStack,
 			b.req.Images[i].FileName,
 			b.req.Images[i].Url,
+			b.req.Images[i].Renditions,
 			b.req.Id,
 		)
 
 		if i != len(b.req.Images)-1 {
 			query += fmt.Sprintf(`
-			($%d, $%d, $%d),`, index+1, index+2, index+3)
+			($%d, $%d, $%d, $%d),`, index+1, index+2, index+3, index+4)
 		} else {
 			query += fmt.Sprintf(`
-			($%d, $%d, $%d);`, index+1, index+2, index+3)
+			($%d, $%d, $%d, $%d);`, index+1, index+2, index+3, index+4)
 		}
-		index += 3
+		index += 4
 	}
 
 	if _, err := b.tx.ExecContext(
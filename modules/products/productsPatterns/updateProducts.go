@@ -102,22 +102,43 @@ func (b *updateProductBuilder) updatePriceQuery() {
 }
 
 func (b *updateProductBuilder) updateCategory() error {
-	if b.req.Category == nil {
-		return nil
-	}
-	if b.req.Category.Id == 0 {
+	if len(b.req.Categories) == 0 {
 		return nil
 	}
 
+	// แทนที่ category เดิมทั้งหมดด้วย category ที่ส่งมา
 	query := `
-	UPDATE "products_categories" SET
-		"category_id" = $1
-	WHERE "product_id" = $2;`
+	DELETE FROM "products_categories"
+	WHERE "product_id" = $1;`
 
-	if _, err := b.tx.ExecContext(context.Background(),
-		query,
-		b.req.Category.Id,
-		b.req.Id); err != nil {
+	if _, err := b.tx.ExecContext(context.Background(), query, b.req.Id); err != nil {
+		b.tx.Rollback()
+		return fmt.Errorf("delete products_categories failed: %v", err)
+	}
+
+	query = `
+	INSERT INTO "products_categories" (
+		"product_id",
+		"category_id"
+	)
+	VALUES`
+
+	valueStack := make([]any, 0)
+	for i, category := range b.req.Categories {
+		valueStack = append(valueStack, b.req.Id, category.Id)
+
+		if i != len(b.req.Categories)-1 {
+			query += fmt.Sprintf(`
+	($%d, $%d),`, i*2+1, i*2+2)
+		} else {
+			query += fmt.Sprintf(`
+	($%d, $%d)`, i*2+1, i*2+2)
+		}
+	}
+	query += `
+	ON CONFLICT ("product_id", "category_id") DO NOTHING;`
+
+	if _, err := b.tx.ExecContext(context.Background(), query, valueStack...); err != nil {
 		b.tx.Rollback()
 		return fmt.Errorf("update products_categories failed: %v", err)
 	}
@@ -129,6 +150,7 @@ func (b *updateProductBuilder) insertImages() error {
 	INSERT INTO "images" (
 		"filename",
 		"url",
+		"renditions",
 		"product_id"
 	)
 	VALUES`
@@ -139,17 +161,18 @@ func (b *updateProductBuilder) insertImages() error {
 		valueStack = append(valueStack,
 			b.req.Images[i].FileName,
 			b.req.Images[i].Url,
+			b.req.Images[i].Renditions,
 			b.req.Id,
 		)
 
 		if i != len(b.req.Images)-1 {
 			query += fmt.Sprintf(`
-			($%d, $%d, $%d),`, index+1, index+2, index+3)
+			($%d, $%d, $%d, $%d),`, index+1, index+2, index+3, index+4)
 		} else {
 			query += fmt.Sprintf(`
-			($%d, $%d, $%d);`, index+1, index+2, index+3)
+			($%d, $%d, $%d, $%d);`, index+1, index+2, index+3, index+4)
 		}
-		index += 3
+		index += 4
 	}
 
 	if _, err := b.tx.ExecContext(
@@ -168,9 +191,11 @@ func (b *updateProductBuilder) getOldImages() []*entities.Image {
 	SELECT 
 		"id",
 		"filename",
-		"url"
+		"url",
+		"renditions"
 	FROM "images"
-	WHERE "product_id" = $1;`
+	WHERE "product_id" = $1
+	AND "variant_id" IS NULL;`
 
 	images := make([]*entities.Image, 0)
 	if err := b.db.Select(
@@ -186,17 +211,16 @@ func (b *updateProductBuilder) getOldImages() []*entities.Image {
 func (b *updateProductBuilder) deleteOldImages() error {
 	query := `
 	DELETE FROM "images"
-	WHERE "product_id" = $1;`
+	WHERE "product_id" = $1
+	AND "variant_id" IS NULL;`
 
 	images := b.getOldImages()
 	if len(images) > 0 {
 		deleteFileReq := make([]*files.DeleteFileReq, 0)
 		for _, img := range images {
-			deleteFileReq = append(deleteFileReq, &files.DeleteFileReq{
-				Destination: fmt.Sprintf("images/products/%s", img.FileName),
-			})
+			deleteFileReq = append(deleteFileReq, files.ImageDeleteReq("images/products", img)...)
 		}
-		if err := b.filesUsecases.DeleteFileOnGCP(deleteFileReq); err != nil {
+		if err := b.filesUsecases.DeleteFiles(deleteFileReq); err != nil {
 			b.tx.Rollback()
 			return err
 		}
@@ -2,8 +2,12 @@ package productsRepositories
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
@@ -15,10 +19,17 @@ import (
 
 type IProductsRepository interface {
 	FindOneProduct(productId string) (*products.Product, error)
-	FindProduct(req *products.ProductFilter) ([]*products.Product, int)
+	FindProduct(req *products.ProductFilter) ([]*products.Product, int, *entities.PageCursor)
+	FindProductFacets(req *products.ProductFilter) *products.ProductFacets
 	InsertProduct(req *products.Product) (*products.Product, error)
 	UpdateProduct(req *products.Product) (*products.Product, error)
 	DeleteProduct(productId string) error
+	AdjustStock(req *products.StockAdjustReq) (*products.StockMovement, error)
+	FindStockMovement(req *products.StockMovementFilter) ([]*products.StockMovement, int)
+	FindOneVariant(productId, variantId string) (*products.Variant, error)
+	InsertVariant(req *products.Variant) (*products.Variant, error)
+	UpdateVariant(req *products.Variant) (*products.Variant, error)
+	DeleteVariant(productId, variantId string) error
 }
 
 type productsRepository struct {
@@ -45,19 +56,22 @@ func (r *productsRepository) FindOneProduct(productId string) (*products.Product
 			"p"."title",
 			"p"."description",
 			"p"."price",
+			"p"."stock",
 			(
 				SELECT
-					to_jsonb("ct") 
+					COALESCE(array_to_json(array_agg("ct")), '[]'::json)
 				FROM (
 					SELECT
 						"c"."id",
-						"c"."title"
+						"c"."title",
+						"c"."parent_id"
 					FROM "categories" "c"
-					LEFT JOIN "products_categories" "pc"
+					JOIN "products_categories" "pc"
 					ON "pc"."category_id" = "c"."id"
 					WHERE "pc"."product_id" = "p"."id"
+					ORDER BY "c"."id" ASC
 				) AS "ct"
-			) AS "category",
+			) AS "categories",
 			"p"."created_at",
 			"p"."updated_at",
 			(
@@ -67,10 +81,44 @@ func (r *productsRepository) FindOneProduct(productId string) (*products.Product
 					SELECT 
 						"i"."id",
 						"i"."filename",
-						"i"."url"
+						"i"."url",
+						"i"."renditions"
 					FROM "images" "i"
+					WHERE "i"."product_id" = "p"."id"
+					AND "i"."variant_id" IS NULL
 				) AS "it"
-			) AS "images"
+			) AS "images",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("vt")), '[]'::json)
+				FROM (
+					SELECT
+						"v"."id",
+						"v"."product_id",
+						"v"."sku",
+						"v"."options",
+						"v"."price",
+						"v"."stock",
+						(
+							SELECT
+								COALESCE(array_to_json(array_agg("vit")), '[]'::json)
+							FROM (
+								SELECT
+									"vi"."id",
+									"vi"."filename",
+									"vi"."url",
+									"vi"."renditions"
+								FROM "images" "vi"
+								WHERE "vi"."variant_id" = "v"."id"
+							) AS "vit"
+						) AS "images",
+						"v"."created_at",
+						"v"."updated_at"
+					FROM "product_variants" "v"
+					WHERE "v"."product_id" = "p"."id"
+					ORDER BY "v"."created_at" ASC
+				) AS "vt"
+			) AS "variants"
 		FROM "products" "p"
 		WHERE "p"."id" = $1
 		LIMIT 1
@@ -90,13 +138,27 @@ func (r *productsRepository) FindOneProduct(productId string) (*products.Product
 	return product, nil
 }
 
-func (r *productsRepository) FindProduct(req *products.ProductFilter) ([]*products.Product, int) {
+func (r *productsRepository) FindProduct(req *products.ProductFilter) ([]*products.Product, int, *entities.PageCursor) {
 	builder := productsPatterns.FindProductBuilder(r.db, req)
 	engineer := productsPatterns.FindProductEngineer(builder)
 
 	result := engineer.FindProduct().Result()
+	cursor := builder.PageCursor()
+	if req.SkipCount {
+		return result, 0, cursor
+	}
 	count := engineer.CountProduct().Count()
-	return result, count
+	return result, count, cursor
+}
+
+func (r *productsRepository) FindProductFacets(req *products.ProductFilter) *products.ProductFacets {
+	builder := productsPatterns.FindProductBuilder(r.db, req)
+	engineer := productsPatterns.FindProductEngineer(builder)
+
+	return &products.ProductFacets{
+		Categories: engineer.FacetCategory().CategoryFacets(),
+		Prices:     engineer.FacetPrice().PriceFacets(),
+	}
 }
 
 func (r *productsRepository) InsertProduct(req *products.Product) (*products.Product, error) {
@@ -136,3 +198,132 @@ func (r *productsRepository) DeleteProduct(productId string) error {
 	}
 	return nil
 }
+
+func (r *productsRepository) AdjustStock(req *products.StockAdjustReq) (*products.StockMovement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// update แบบ relative ทำให้ postgres lock row ให้เอง และ check constraint กัน stock ติดลบ
+	query := `
+	UPDATE "products" SET
+		"stock" = "stock" + $1
+	WHERE "id" = $2
+	RETURNING "stock";`
+	values := []any{req.Qty, req.ProductId}
+	notFoundErr := fmt.Errorf("product not found")
+	if req.VariantId != "" {
+		query = `
+	UPDATE "product_variants" SET
+		"stock" = "stock" + $1
+	WHERE "id" = $2
+	AND "product_id" = $3
+	RETURNING "stock";`
+		values = []any{req.Qty, req.VariantId, req.ProductId}
+		notFoundErr = fmt.Errorf("variant not found")
+	}
+
+	var balance int
+	if err := tx.QueryRowContext(ctx, query, values...).Scan(&balance); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, notFoundErr
+		}
+		if strings.Contains(err.Error(), "stock_check") {
+			return nil, fmt.Errorf("stock is insufficient")
+		}
+		return nil, fmt.Errorf("update stock failed: %v", err)
+	}
+
+	query = `
+	INSERT INTO "stock_movements" (
+		"product_id",
+		"variant_id",
+		"user_id",
+		"type",
+		"qty",
+		"balance",
+		"note"
+	)
+	VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), 'adjust', $4, $5, $6)
+	RETURNING "id";`
+
+	movement := &products.StockMovement{
+		ProductId: req.ProductId,
+		VariantId: req.VariantId,
+		UserId:    req.UserId,
+		Type:      "adjust",
+		Qty:       req.Qty,
+		Balance:   balance,
+		Note:      req.Note,
+	}
+	if err := tx.QueryRowContext(
+		ctx,
+		query,
+		req.ProductId,
+		req.VariantId,
+		req.UserId,
+		req.Qty,
+		balance,
+		req.Note,
+	).Scan(&movement.Id); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("insert stock movement failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (r *productsRepository) FindStockMovement(req *products.StockMovementFilter) ([]*products.StockMovement, int) {
+	query := `
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT
+			"sm"."id",
+			"sm"."product_id",
+			COALESCE("sm"."variant_id", '') AS "variant_id",
+			COALESCE("sm"."order_id", '') AS "order_id",
+			COALESCE("sm"."user_id", '') AS "user_id",
+			"sm"."type",
+			"sm"."qty",
+			"sm"."balance",
+			"sm"."note",
+			"sm"."created_at"
+		FROM "stock_movements" "sm"
+		WHERE "sm"."product_id" = $1
+		ORDER BY "sm"."created_at" DESC
+		OFFSET $2 LIMIT $3
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	movements := make([]*products.StockMovement, 0)
+	if err := r.db.Get(&raw, query, req.ProductId, (req.Page-1)*req.Limit, req.Limit); err != nil {
+		log.Printf("find stock movements failed: %v\n", err)
+		return movements, 0
+	}
+	if err := json.Unmarshal(raw, &movements); err != nil {
+		log.Printf("unmarshal stock movements failed: %v\n", err)
+		return make([]*products.StockMovement, 0), 0
+	}
+
+	query = `
+	SELECT
+		COUNT(*) AS "count"
+	FROM "stock_movements"
+	WHERE "product_id" = $1;`
+
+	var count int
+	if err := r.db.Get(&count, query, req.ProductId); err != nil {
+		log.Printf("count stock movements failed: %v\n", err)
+		return movements, 0
+	}
+	return movements, count
+}
@@ -0,0 +1,250 @@
+package productsRepositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products"
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *productsRepository) FindOneVariant(productId, variantId string) (*products.Variant, error) {
+	query := `
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT
+			"v"."id",
+			"v"."product_id",
+			"v"."sku",
+			"v"."options",
+			"v"."price",
+			"v"."stock",
+			(
+				SELECT
+					COALESCE(array_to_json(array_agg("it")), '[]'::json)
+				FROM (
+					SELECT
+						"i"."id",
+						"i"."filename",
+						"i"."url",
+						"i"."renditions"
+					FROM "images" "i"
+					WHERE "i"."variant_id" = "v"."id"
+				) AS "it"
+			) AS "images",
+			"v"."created_at",
+			"v"."updated_at"
+		FROM "product_variants" "v"
+		WHERE "v"."id" = $1
+		AND "v"."product_id" = $2
+	) AS "t";`
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, variantId, productId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("variant not found")
+		}
+		return nil, fmt.Errorf("get variant failed: %v", err)
+	}
+
+	variant := &products.Variant{
+		Images: make([]*entities.Image, 0),
+	}
+	if err := json.Unmarshal(raw, variant); err != nil {
+		return nil, fmt.Errorf("unmarshal variant failed: %v", err)
+	}
+	return variant, nil
+}
+
+func (r *productsRepository) InsertVariant(req *products.Variant) (*products.Variant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO "product_variants" (
+		"product_id",
+		"sku",
+		"options",
+		"price"
+	)
+	VALUES ($1, $2, $3, $4)
+	RETURNING "id";`
+
+	if err := tx.QueryRowContext(
+		ctx,
+		query,
+		req.ProductId,
+		req.Sku,
+		req.Options,
+		req.Price,
+	).Scan(&req.Id); err != nil {
+		tx.Rollback()
+		return nil, variantErr(err, "insert variant failed")
+	}
+
+	if err := insertVariantImages(ctx, tx, req); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.FindOneVariant(req.ProductId, req.Id)
+}
+
+func (r *productsRepository) UpdateVariant(req *products.Variant) (*products.Variant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	old, err := r.FindOneVariant(req.ProductId, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// field ที่ไม่ได้ส่งมาจะไม่ถูก update เหมือน product
+	queryFields := make([]string, 0)
+	values := make([]any, 0)
+	if req.Sku != "" {
+		values = append(values, req.Sku)
+		queryFields = append(queryFields, fmt.Sprintf(`"sku" = $%d`, len(values)))
+	}
+	if req.Options != nil {
+		values = append(values, req.Options)
+		queryFields = append(queryFields, fmt.Sprintf(`"options" = $%d`, len(values)))
+	}
+	if req.Price != nil {
+		values = append(values, req.Price)
+		queryFields = append(queryFields, fmt.Sprintf(`"price" = $%d`, len(values)))
+	}
+
+	if len(queryFields) > 0 {
+		values = append(values, req.Id)
+		query := fmt.Sprintf(`
+	UPDATE "product_variants" SET
+		%s
+	WHERE "id" = $%d;`, strings.Join(queryFields, `,
+		`), len(values))
+
+		if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+			tx.Rollback()
+			return nil, variantErr(err, "update variant failed")
+		}
+	}
+
+	if len(req.Images) > 0 {
+		if len(old.Images) > 0 {
+			deleteFileReq := make([]*files.DeleteFileReq, 0)
+			for _, img := range old.Images {
+				deleteFileReq = append(deleteFileReq, files.ImageDeleteReq("images/products", img)...)
+			}
+			if err := r.filesUsecase.DeleteFiles(deleteFileReq); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
+		query := `
+	DELETE FROM "images"
+	WHERE "variant_id" = $1;`
+
+		if _, err := tx.ExecContext(ctx, query, req.Id); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("delete images failed: %v", err)
+		}
+		if err := insertVariantImages(ctx, tx, req); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.FindOneVariant(req.ProductId, req.Id)
+}
+
+func (r *productsRepository) DeleteVariant(productId, variantId string) error {
+	query := `
+	DELETE FROM "product_variants"
+	WHERE "id" = $1
+	AND "product_id" = $2;`
+
+	result, err := r.db.ExecContext(context.Background(), query, variantId, productId)
+	if err != nil {
+		return fmt.Errorf("delete variant failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("variant not found")
+	}
+	return nil
+}
+
+func insertVariantImages(ctx context.Context, tx *sqlx.Tx, req *products.Variant) error {
+	if len(req.Images) == 0 {
+		return nil
+	}
+
+	query := `
+	INSERT INTO "images" (
+		"filename",
+		"url",
+		"renditions",
+		"product_id",
+		"variant_id"
+	)
+	VALUES`
+
+	valueStack := make([]any, 0)
+	var index int
+	for i := range req.Images {
+		valueStack = append(valueStack,
+			req.Images[i].FileName,
+			req.Images[i].Url,
+			req.Images[i].Renditions,
+			req.ProductId,
+			req.Id,
+		)
+
+		if i != len(req.Images)-1 {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d, $%d, $%d),`, index+1, index+2, index+3, index+4, index+5)
+		} else {
+			query += fmt.Sprintf(`
+			($%d, $%d, $%d, $%d, $%d);`, index+1, index+2, index+3, index+4, index+5)
+		}
+		index += 5
+	}
+
+	if _, err := tx.ExecContext(ctx, query, valueStack...); err != nil {
+		return fmt.Errorf("insert images failed: %v", err)
+	}
+	return nil
+}
+
+func variantErr(err error, message string) error {
+	switch {
+	case strings.Contains(err.Error(), "product_variants_sku_key"):
+		return fmt.Errorf("sku has been used")
+	case strings.Contains(err.Error(), "product_variants_product_id_fkey"):
+		return fmt.Errorf("product not found")
+	}
+	return fmt.Errorf("%s: %v", message, err)
+}
@@ -14,6 +14,12 @@ type IProductsUsecase interface {
 	AddProduct(req *products.Product) (*products.Product, error)
 	UpdateProduct(req *products.Product) (*products.Product, error)
 	DeleteProduct(productId string) error
+	AdjustStock(req *products.StockAdjustReq) (*products.StockMovement, error)
+	FindStockMovement(req *products.StockMovementFilter) *entities.PaginateRes
+	FindOneVariant(productId, variantId string) (*products.Variant, error)
+	AddVariant(req *products.Variant) (*products.Variant, error)
+	UpdateVariant(req *products.Variant) (*products.Variant, error)
+	DeleteVariant(productId, variantId string) error
 }
 
 type productsUsecase struct {
@@ -35,14 +41,20 @@ func (u *productsUsecase) FindOneProduct(productId string) (*products.Product, e
 }
 
 func (u *productsUsecase) FindProduct(req *products.ProductFilter) *entities.PaginateRes {
-	products, count := u.productsRepository.FindProduct(req)
-	return &entities.PaginateRes{
-		Data:      products,
-		Page:      req.Page,
-		Limit:     req.Limit,
-		TotalItem: count,
-		TotalPage: int(math.Ceil(float64(count) / float64(req.Limit))),
+	products, count, cursor := u.productsRepository.FindProduct(req)
+	res := &entities.PaginateRes{
+		Data:       products,
+		Page:       req.Page,
+		Limit:      req.Limit,
+		TotalItem:  count,
+		TotalPage:  int(math.Ceil(float64(count) / float64(req.Limit))),
+		NextCursor: cursor.Next,
+		PrevCursor: cursor.Prev,
 	}
+	if req.Facets {
+		res.Facets = u.productsRepository.FindProductFacets(req)
+	}
+	return res
 }
 
 func (u *productsUsecase) AddProduct(req *products.Product) (*products.Product, error) {
@@ -67,3 +79,53 @@ func (u *productsUsecase) DeleteProduct(productId string) error {
 	}
 	return nil
 }
+
+func (u *productsUsecase) AdjustStock(req *products.StockAdjustReq) (*products.StockMovement, error) {
+	movement, err := u.productsRepository.AdjustStock(req)
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (u *productsUsecase) FindStockMovement(req *products.StockMovementFilter) *entities.PaginateRes {
+	movements, count := u.productsRepository.FindStockMovement(req)
+	return &entities.PaginateRes{
+		Data:      movements,
+		Page:      req.Page,
+		Limit:     req.Limit,
+		TotalItem: count,
+		TotalPage: int(math.Ceil(float64(count) / float64(req.Limit))),
+	}
+}
+
+func (u *productsUsecase) FindOneVariant(productId, variantId string) (*products.Variant, error) {
+	variant, err := u.productsRepository.FindOneVariant(productId, variantId)
+	if err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+func (u *productsUsecase) AddVariant(req *products.Variant) (*products.Variant, error) {
+	variant, err := u.productsRepository.InsertVariant(req)
+	if err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+func (u *productsUsecase) UpdateVariant(req *products.Variant) (*products.Variant, error) {
+	variant, err := u.productsRepository.UpdateVariant(req)
+	if err != nil {
+		return nil, err
+	}
+	return variant, nil
+}
+
+func (u *productsUsecase) DeleteVariant(productId, variantId string) error {
+	if err := u.productsRepository.DeleteVariant(productId, variantId); err != nil {
+		return err
+	}
+	return nil
+}
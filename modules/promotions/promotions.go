@@ -0,0 +1,130 @@
+package promotions
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+)
+
+type Promotion struct {
+	Id                string   `json:"id"`
+	Code              string   `json:"code"`
+	Title             string   `json:"title"`
+	Type              string   `json:"type"` // percentage, fixed
+	Value             float64  `json:"value"`
+	MinSpend          float64  `json:"min_spend"`
+	MaxDiscount       float64  `json:"max_discount"`         // 0 = ไม่จำกัด
+	UsageLimit        int      `json:"usage_limit"`          // 0 = ไม่จำกัด
+	UsageLimitPerUser int      `json:"usage_limit_per_user"` // 0 = ไม่จำกัด
+	UsedCount         int      `json:"used_count"`
+	StartsAt          *string  `json:"starts_at"`
+	EndsAt            *string  `json:"ends_at"`
+	IsActive          bool     `json:"is_active"`
+	CategoryIds       []int    `json:"category_ids"` // รวม sub category ด้วย
+	ProductIds        []string `json:"product_ids"`
+	CreatedAt         string   `json:"created_at"`
+	UpdatedAt         string   `json:"updated_at"`
+}
+
+type PromotionFilter struct {
+	Search string `query:"search"`
+	*entities.PaginationReq
+}
+
+// PromotionUpdateReq : field ที่เป็น nil จะไม่ถูก update
+type PromotionUpdateReq struct {
+	Id                string    `json:"-"`
+	Title             *string   `json:"title"`
+	Value             *float64  `json:"value"`
+	MinSpend          *float64  `json:"min_spend"`
+	MaxDiscount       *float64  `json:"max_discount"`
+	UsageLimit        *int      `json:"usage_limit"`
+	UsageLimitPerUser *int      `json:"usage_limit_per_user"`
+	StartsAt          *string   `json:"starts_at"`
+	EndsAt            *string   `json:"ends_at"`
+	IsActive          *bool     `json:"is_active"`
+	CategoryIds       *[]int    `json:"category_ids"`
+	ProductIds        *[]string `json:"product_ids"`
+}
+
+// PromotionItem : product ใน order ที่ใช้คำนวณส่วนลด
+type PromotionItem struct {
+	ProductId   string
+	CategoryIds []int // category ของ product และ parent ทุกชั้น
+	Price       float64
+	Qty         int
+}
+
+func IsType(promotionType string) bool {
+	return promotionType == "percentage" || promotionType == "fixed"
+}
+
+// IsValidValue : ส่วนลดแบบ percentage ต้องไม่เกิน 100
+func IsValidValue(promotionType string, value float64) bool {
+	return value > 0 && (promotionType != "percentage" || value <= 100)
+}
+
+func (p *Promotion) isEligible(item *PromotionItem) bool {
+	if len(p.CategoryIds) == 0 && len(p.ProductIds) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIds {
+		if id == item.ProductId {
+			return true
+		}
+	}
+	for _, id := range p.CategoryIds {
+		for _, categoryId := range item.CategoryIds {
+			if id == categoryId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Discount คำนวณส่วนลดของ order
+// inPeriod และ userUsedCount ต้องอ่านจาก database ใน transaction เดียวกับการสร้าง order
+func (p *Promotion) Discount(items []*PromotionItem, inPeriod bool, userUsedCount int) (float64, error) {
+	if !p.IsActive || !inPeriod {
+		return 0, fmt.Errorf("coupon is expired or inactive")
+	}
+	if p.UsageLimit > 0 && p.UsedCount >= p.UsageLimit {
+		return 0, fmt.Errorf("coupon usage limit reached")
+	}
+	if p.UsageLimitPerUser > 0 && userUsedCount >= p.UsageLimitPerUser {
+		return 0, fmt.Errorf("coupon usage limit per user reached")
+	}
+
+	var subtotal, eligible float64
+	for _, item := range items {
+		subtotal += item.Price * float64(item.Qty)
+		if p.isEligible(item) {
+			eligible += item.Price * float64(item.Qty)
+		}
+	}
+	if subtotal < p.MinSpend {
+		return 0, fmt.Errorf("order does not reach coupon minimum spend")
+	}
+	if eligible == 0 {
+		return 0, fmt.Errorf("coupon is not applicable to products")
+	}
+
+	var discount float64
+	switch p.Type {
+	case "percentage":
+		discount = eligible * p.Value / 100
+		if p.MaxDiscount > 0 && discount > p.MaxDiscount {
+			discount = p.MaxDiscount
+		}
+	case "fixed":
+		discount = p.Value
+	default:
+		return 0, fmt.Errorf("promotion type is invalid")
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	return math.Round(discount*100) / 100, nil
+}
@@ -0,0 +1,235 @@
+package promotionsHandlers
+
+import (
+	"strings"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions/promotionsUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type promotionsHandlersErrCode string
+
+const (
+	findPromotionErr    promotionsHandlersErrCode = "promotions-001"
+	findOnePromotionErr promotionsHandlersErrCode = "promotions-002"
+	insertPromotionErr  promotionsHandlersErrCode = "promotions-003"
+	updatePromotionErr  promotionsHandlersErrCode = "promotions-004"
+	deletePromotionErr  promotionsHandlersErrCode = "promotions-005"
+)
+
+type IPromotionsHandler interface {
+	FindPromotion(c *fiber.Ctx) error
+	FindOnePromotion(c *fiber.Ctx) error
+	AddPromotion(c *fiber.Ctx) error
+	UpdatePromotion(c *fiber.Ctx) error
+	DeletePromotion(c *fiber.Ctx) error
+}
+
+type promotionsHandler struct {
+	cfg               config.IConfig
+	promotionsUsecase promotionsUsecases.IPromotionsUsecase
+}
+
+func PromotionsHandler(cfg config.IConfig, promotionsUsecase promotionsUsecases.IPromotionsUsecase) IPromotionsHandler {
+	return &promotionsHandler{
+		cfg:               cfg,
+		promotionsUsecase: promotionsUsecase,
+	}
+}
+
+func (h *promotionsHandler) FindPromotion(c *fiber.Ctx) error {
+	req := &promotions.PromotionFilter{
+		PaginationReq: &entities.PaginationReq{},
+	}
+	if err := c.QueryParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(findPromotionErr),
+			err.Error(),
+		).Res()
+	}
+
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.Limit < 5 {
+		req.Limit = 5
+	}
+
+	promotions := h.promotionsUsecase.FindPromotion(req)
+	return entities.NewResponse(c).Success(fiber.StatusOK, promotions).Res()
+}
+
+func (h *promotionsHandler) FindOnePromotion(c *fiber.Ctx) error {
+	promotionId := strings.Trim(c.Params("promotion_id"), " ")
+
+	promotion, err := h.promotionsUsecase.FindOnePromotion(promotionId)
+	if err != nil {
+		switch err.Error() {
+		case "promotion not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findOnePromotionErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(findOnePromotionErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, promotion).Res()
+}
+
+func (h *promotionsHandler) AddPromotion(c *fiber.Ctx) error {
+	req := &promotions.Promotion{
+		IsActive:    true,
+		CategoryIds: make([]int, 0),
+		ProductIds:  make([]string, 0),
+	}
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertPromotionErr),
+			err.Error(),
+		).Res()
+	}
+
+	// code เก็บเป็นตัวพิมพ์ใหญ่ เพื่อให้ user กรอก coupon แบบไม่สนตัวพิมพ์
+	req.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	req.Type = strings.ToLower(req.Type)
+	if req.StartsAt != nil && *req.StartsAt == "" {
+		req.StartsAt = nil
+	}
+	if req.EndsAt != nil && *req.EndsAt == "" {
+		req.EndsAt = nil
+	}
+
+	switch {
+	case req.Code == "":
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertPromotionErr),
+			"code is required",
+		).Res()
+	case !promotions.IsType(req.Type):
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertPromotionErr),
+			"promotion type is invalid",
+		).Res()
+	case !promotions.IsValidValue(req.Type, req.Value):
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertPromotionErr),
+			"value is invalid",
+		).Res()
+	case req.MinSpend < 0 || req.MaxDiscount < 0 || req.UsageLimit < 0 || req.UsageLimitPerUser < 0:
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(insertPromotionErr),
+			"min spend, max discount and usage limits must not be negative",
+		).Res()
+	}
+
+	promotion, err := h.promotionsUsecase.AddPromotion(req)
+	if err != nil {
+		switch err.Error() {
+		case "code has been used":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(insertPromotionErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(insertPromotionErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, promotion).Res()
+}
+
+func (h *promotionsHandler) UpdatePromotion(c *fiber.Ctx) error {
+	req := new(promotions.PromotionUpdateReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updatePromotionErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("promotion_id"), " ")
+
+	if req.Value != nil && *req.Value <= 0 {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updatePromotionErr),
+			"value is invalid",
+		).Res()
+	}
+	if (req.MinSpend != nil && *req.MinSpend < 0) ||
+		(req.MaxDiscount != nil && *req.MaxDiscount < 0) ||
+		(req.UsageLimit != nil && *req.UsageLimit < 0) ||
+		(req.UsageLimitPerUser != nil && *req.UsageLimitPerUser < 0) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updatePromotionErr),
+			"min spend, max discount and usage limits must not be negative",
+		).Res()
+	}
+
+	promotion, err := h.promotionsUsecase.UpdatePromotion(req)
+	if err != nil {
+		switch err.Error() {
+		case "promotion not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updatePromotionErr),
+				err.Error(),
+			).Res()
+		case "value is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updatePromotionErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updatePromotionErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, promotion).Res()
+}
+
+func (h *promotionsHandler) DeletePromotion(c *fiber.Ctx) error {
+	promotionId := strings.Trim(c.Params("promotion_id"), " ")
+
+	if err := h.promotionsUsecase.DeletePromotion(promotionId); err != nil {
+		switch err.Error() {
+		case "promotion not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(deletePromotionErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deletePromotionErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusNoContent, nil).Res()
+}
@@ -0,0 +1,336 @@
+package promotionsRepositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions"
+	"github.com/jmoiron/sqlx"
+)
+
+type IPromotionsRepository interface {
+	FindPromotion(req *promotions.PromotionFilter) ([]*promotions.Promotion, int)
+	FindOnePromotion(promotionId string) (*promotions.Promotion, error)
+	InsertPromotion(req *promotions.Promotion) (*promotions.Promotion, error)
+	UpdatePromotion(req *promotions.PromotionUpdateReq) (*promotions.Promotion, error)
+	DeletePromotion(promotionId string) error
+}
+
+type promotionsRepository struct {
+	db *sqlx.DB
+}
+
+func PromotionsRepository(db *sqlx.DB) IPromotionsRepository {
+	return &promotionsRepository{
+		db: db,
+	}
+}
+
+const promotionColumns = `
+			"pm"."id",
+			"pm"."code",
+			"pm"."title",
+			"pm"."type",
+			"pm"."value",
+			"pm"."min_spend",
+			"pm"."max_discount",
+			"pm"."usage_limit",
+			"pm"."usage_limit_per_user",
+			"pm"."used_count",
+			"pm"."starts_at",
+			"pm"."ends_at",
+			"pm"."is_active",
+			(
+				SELECT
+					COALESCE(array_agg("pmc"."category_id"), '{}')
+				FROM "promotions_categories" "pmc"
+				WHERE "pmc"."promotion_id" = "pm"."id"
+			) AS "category_ids",
+			(
+				SELECT
+					COALESCE(array_agg("pmp"."product_id"), '{}')
+				FROM "promotions_products" "pmp"
+				WHERE "pmp"."promotion_id" = "pm"."id"
+			) AS "product_ids",
+			"pm"."created_at",
+			"pm"."updated_at"`
+
+func (r *promotionsRepository) FindPromotion(req *promotions.PromotionFilter) ([]*promotions.Promotion, int) {
+	whereQuery := ""
+	values := make([]any, 0)
+	if req.Search != "" {
+		whereQuery = `
+		WHERE (LOWER("pm"."code") LIKE $1 OR LOWER("pm"."title") LIKE $1)`
+
+		values = append(values, "%"+strings.ToLower(req.Search)+"%")
+	}
+
+	query := fmt.Sprintf(`
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT%s
+		FROM "promotions" "pm"%s
+		ORDER BY "pm"."created_at" DESC
+		OFFSET $%d LIMIT $%d
+	) AS "t";`, promotionColumns, whereQuery, len(values)+1, len(values)+2)
+
+	raw := make([]byte, 0)
+	promotionsData := make([]*promotions.Promotion, 0)
+	if err := r.db.Get(&raw, query, append(values, (req.Page-1)*req.Limit, req.Limit)...); err != nil {
+		log.Printf("find promotions failed: %v\n", err)
+		return promotionsData, 0
+	}
+	if err := json.Unmarshal(raw, &promotionsData); err != nil {
+		log.Printf("unmarshal promotions failed: %v\n", err)
+		return make([]*promotions.Promotion, 0), 0
+	}
+
+	query = fmt.Sprintf(`
+	SELECT
+		COUNT(*) AS "count"
+	FROM "promotions" "pm"%s;`, whereQuery)
+
+	var count int
+	if err := r.db.Get(&count, query, values...); err != nil {
+		log.Printf("count promotions failed: %v\n", err)
+		return promotionsData, 0
+	}
+	return promotionsData, count
+}
+
+func (r *promotionsRepository) FindOnePromotion(promotionId string) (*promotions.Promotion, error) {
+	query := fmt.Sprintf(`
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT%s
+		FROM "promotions" "pm"
+		WHERE "pm"."id" = $1
+	) AS "t";`, promotionColumns)
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, promotionId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("promotion not found")
+		}
+		return nil, fmt.Errorf("get promotion failed: %v", err)
+	}
+
+	promotion := new(promotions.Promotion)
+	if err := json.Unmarshal(raw, promotion); err != nil {
+		return nil, fmt.Errorf("unmarshal promotion failed: %v", err)
+	}
+	return promotion, nil
+}
+
+func (r *promotionsRepository) InsertPromotion(req *promotions.Promotion) (*promotions.Promotion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO "promotions" (
+		"code",
+		"title",
+		"type",
+		"value",
+		"min_spend",
+		"max_discount",
+		"usage_limit",
+		"usage_limit_per_user",
+		"starts_at",
+		"ends_at",
+		"is_active"
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING "id";`
+
+	if err := tx.QueryRowxContext(
+		ctx,
+		query,
+		req.Code,
+		req.Title,
+		req.Type,
+		req.Value,
+		req.MinSpend,
+		req.MaxDiscount,
+		req.UsageLimit,
+		req.UsageLimitPerUser,
+		req.StartsAt,
+		req.EndsAt,
+		req.IsActive,
+	).Scan(&req.Id); err != nil {
+		tx.Rollback()
+		if strings.Contains(err.Error(), "promotions_code_key") {
+			return nil, fmt.Errorf("code has been used")
+		}
+		return nil, fmt.Errorf("insert promotion failed: %v", err)
+	}
+
+	if err := insertScopes(ctx, tx, req.Id, req.CategoryIds, req.ProductIds); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.FindOnePromotion(req.Id)
+}
+
+func (r *promotionsRepository) UpdatePromotion(req *promotions.PromotionUpdateReq) (*promotions.Promotion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]string, 0)
+	values := make([]any, 0)
+	set := func(column string, value any) {
+		values = append(values, value)
+		sets = append(sets, fmt.Sprintf(`"%s" = $%d`, column, len(values)))
+	}
+	if req.Title != nil {
+		set("title", *req.Title)
+	}
+	if req.Value != nil {
+		set("value", *req.Value)
+	}
+	if req.MinSpend != nil {
+		set("min_spend", *req.MinSpend)
+	}
+	if req.MaxDiscount != nil {
+		set("max_discount", *req.MaxDiscount)
+	}
+	if req.UsageLimit != nil {
+		set("usage_limit", *req.UsageLimit)
+	}
+	if req.UsageLimitPerUser != nil {
+		set("usage_limit_per_user", *req.UsageLimitPerUser)
+	}
+	// ส่ง "" เพื่อล้างวันเริ่ม/สิ้นสุด
+	if req.StartsAt != nil {
+		set("starts_at", nullIfEmpty(*req.StartsAt))
+	}
+	if req.EndsAt != nil {
+		set("ends_at", nullIfEmpty(*req.EndsAt))
+	}
+	if req.IsActive != nil {
+		set("is_active", *req.IsActive)
+	}
+
+	// แก้เฉพาะ scope ก็ยัง update "updated_at" และใช้ตรวจว่ามี promotion อยู่จริง
+	query := `
+	UPDATE "promotions" SET
+		` + strings.Join(append(sets, `"updated_at" = now()`), `,
+		`) + fmt.Sprintf(`
+	WHERE "id" = $%d
+	RETURNING "type", "value";`, len(values)+1)
+
+	var (
+		promotionType string
+		value         float64
+	)
+	if err := tx.QueryRowContext(ctx, query, append(values, req.Id)...).Scan(&promotionType, &value); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("promotion not found")
+		}
+		return nil, fmt.Errorf("update promotion failed: %v", err)
+	}
+	// ตรวจหลัง update เพราะ type อยู่ใน database ไม่ได้ส่งมากับ request
+	if !promotions.IsValidValue(promotionType, value) {
+		tx.Rollback()
+		return nil, fmt.Errorf("value is invalid")
+	}
+
+	if req.CategoryIds != nil {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM "promotions_categories" WHERE "promotion_id" = $1;`, req.Id); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("delete promotion categories failed: %v", err)
+		}
+		if err := insertScopes(ctx, tx, req.Id, *req.CategoryIds, nil); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if req.ProductIds != nil {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM "promotions_products" WHERE "promotion_id" = $1;`, req.Id); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("delete promotion products failed: %v", err)
+		}
+		if err := insertScopes(ctx, tx, req.Id, nil, *req.ProductIds); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.FindOnePromotion(req.Id)
+}
+
+func (r *promotionsRepository) DeletePromotion(promotionId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	query := `DELETE FROM "promotions" WHERE "id" = $1;`
+
+	result, err := r.db.ExecContext(ctx, query, promotionId)
+	if err != nil {
+		return fmt.Errorf("delete promotion failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("promotion not found")
+	}
+	return nil
+}
+
+func insertScopes(ctx context.Context, tx *sqlx.Tx, promotionId string, categoryIds []int, productIds []string) error {
+	for _, categoryId := range categoryIds {
+		query := `
+		INSERT INTO "promotions_categories" (
+			"promotion_id",
+			"category_id"
+		)
+		VALUES ($1, $2);`
+
+		if _, err := tx.ExecContext(ctx, query, promotionId, categoryId); err != nil {
+			return fmt.Errorf("insert promotion categories failed: %v", err)
+		}
+	}
+	for _, productId := range productIds {
+		query := `
+		INSERT INTO "promotions_products" (
+			"promotion_id",
+			"product_id"
+		)
+		VALUES ($1, $2);`
+
+		if _, err := tx.ExecContext(ctx, query, promotionId, productId); err != nil {
+			return fmt.Errorf("insert promotion products failed: %v", err)
+		}
+	}
+	return nil
+}
+
+func nullIfEmpty(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
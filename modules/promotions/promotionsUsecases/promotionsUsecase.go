@@ -0,0 +1,69 @@
+package promotionsUsecases
+
+import (
+	"math"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions/promotionsRepositories"
+)
+
+type IPromotionsUsecase interface {
+	FindPromotion(req *promotions.PromotionFilter) *entities.PaginateRes
+	FindOnePromotion(promotionId string) (*promotions.Promotion, error)
+	AddPromotion(req *promotions.Promotion) (*promotions.Promotion, error)
+	UpdatePromotion(req *promotions.PromotionUpdateReq) (*promotions.Promotion, error)
+	DeletePromotion(promotionId string) error
+}
+
+type promotionsUsecase struct {
+	promotionsRepository promotionsRepositories.IPromotionsRepository
+}
+
+func PromotionsUsecase(promotionsRepository promotionsRepositories.IPromotionsRepository) IPromotionsUsecase {
+	return &promotionsUsecase{
+		promotionsRepository: promotionsRepository,
+	}
+}
+
+func (u *promotionsUsecase) FindPromotion(req *promotions.PromotionFilter) *entities.PaginateRes {
+	promotions, count := u.promotionsRepository.FindPromotion(req)
+	return &entities.PaginateRes{
+		Data:      promotions,
+		Page:      req.Page,
+		Limit:     req.Limit,
+		TotalItem: count,
+		TotalPage: int(math.Ceil(float64(count) / float64(req.Limit))),
+	}
+}
+
+func (u *promotionsUsecase) FindOnePromotion(promotionId string) (*promotions.Promotion, error) {
+	promotion, err := u.promotionsRepository.FindOnePromotion(promotionId)
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (u *promotionsUsecase) AddPromotion(req *promotions.Promotion) (*promotions.Promotion, error) {
+	promotion, err := u.promotionsRepository.InsertPromotion(req)
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (u *promotionsUsecase) UpdatePromotion(req *promotions.PromotionUpdateReq) (*promotions.Promotion, error) {
+	promotion, err := u.promotionsRepository.UpdatePromotion(req)
+	if err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (u *promotionsUsecase) DeletePromotion(promotionId string) error {
+	if err := u.promotionsRepository.DeletePromotion(promotionId); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package roles
+
+import (
+	"fmt"
+	"strings"
+)
+
+// role ที่ถูกสร้างตอน migration signup ได้ customer admin มีทุก permission เสมอ
+const (
+	RoleCustomer = 1
+	RoleAdmin    = 2
+)
+
+type Role struct {
+	Id          int      `json:"id"`
+	Title       string   `json:"title"`
+	Permissions []string `json:"permissions"`
+}
+
+type Permission struct {
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+}
+
+type RoleReq struct {
+	Title       string   `json:"title"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleUpdateReq : field ที่เป็น nil จะไม่ถูก update permissions ที่ส่งมาจะแทนที่ของเดิมทั้งหมด
+type RoleUpdateReq struct {
+	Id          int       `json:"-"`
+	Title       *string   `json:"title"`
+	Permissions *[]string `json:"permissions"`
+}
+
+// CheckPermissions : ตัด permission ซ้ำและตรวจว่ามีอยู่ใน table permissions (role ที่ไม่มี permission เลยได้)
+func CheckPermissions(permissions []string, known []*Permission) ([]string, error) {
+	result := make([]string, 0, len(permissions))
+	seen := make(map[string]bool)
+	for _, permission := range permissions {
+		permission = strings.ToLower(strings.TrimSpace(permission))
+		if seen[permission] {
+			continue
+		}
+		valid := false
+		for _, p := range known {
+			if p.Name == permission {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("permission %s not found", permission)
+		}
+		seen[permission] = true
+		result = append(result, permission)
+	}
+	return result, nil
+}
@@ -0,0 +1,275 @@
+package rolesHandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles/rolesUsecases"
+	"github.com/gofiber/fiber/v2"
+)
+
+type rolesHandlersErrCode string
+
+const (
+	findRolesErr       rolesHandlersErrCode = "roles-001"
+	findPermissionsErr rolesHandlersErrCode = "roles-002"
+	addRoleErr         rolesHandlersErrCode = "roles-003"
+	updateRoleErr      rolesHandlersErrCode = "roles-004"
+	deleteRoleErr      rolesHandlersErrCode = "roles-005"
+	updateUserRoleErr  rolesHandlersErrCode = "roles-006"
+)
+
+type IRolesHandler interface {
+	FindRoles(c *fiber.Ctx) error
+	FindPermissions(c *fiber.Ctx) error
+	AddRole(c *fiber.Ctx) error
+	UpdateRole(c *fiber.Ctx) error
+	DeleteRole(c *fiber.Ctx) error
+	UpdateUserRole(c *fiber.Ctx) error
+}
+
+type rolesHandler struct {
+	cfg          config.IConfig
+	rolesUsecase rolesUsecases.IRolesUsecase
+}
+
+func RolesHandler(cfg config.IConfig, rolesUsecase rolesUsecases.IRolesUsecase) IRolesHandler {
+	return &rolesHandler{
+		cfg:          cfg,
+		rolesUsecase: rolesUsecase,
+	}
+}
+
+func roleIdParam(c *fiber.Ctx) (int, error) {
+	roleId, err := strconv.Atoi(strings.Trim(c.Params("role_id"), " "))
+	if err != nil || roleId <= 0 {
+		return 0, fmt.Errorf("role id is invalid")
+	}
+	return roleId, nil
+}
+
+// isPermissionErr : error จาก roles.CheckPermissions
+func isPermissionErr(err error) bool {
+	return strings.HasPrefix(err.Error(), "permission ") && strings.HasSuffix(err.Error(), "not found")
+}
+
+func (h *rolesHandler) FindRoles(c *fiber.Ctx) error {
+	result, err := h.rolesUsecase.FindRoles()
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findRolesErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *rolesHandler) FindPermissions(c *fiber.Ctx) error {
+	permissions, err := h.rolesUsecase.FindPermissions()
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findPermissionsErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, permissions).Res()
+}
+
+func (h *rolesHandler) AddRole(c *fiber.Ctx) error {
+	req := new(roles.RoleReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addRoleErr),
+			err.Error(),
+		).Res()
+	}
+	req.Title = strings.ToLower(strings.TrimSpace(req.Title))
+	if req.Title == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(addRoleErr),
+			"title is required",
+		).Res()
+	}
+
+	role, err := h.rolesUsecase.InsertRole(req)
+	if err != nil {
+		if err.Error() == "title has been used" || isPermissionErr(err) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(addRoleErr),
+				err.Error(),
+			).Res()
+		}
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(addRoleErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, role).Res()
+}
+
+// UpdateRole : middleware cache permission ของ role ไว้ การแก้จะมีผลภายใน 1 นาที
+func (h *rolesHandler) UpdateRole(c *fiber.Ctx) error {
+	roleId, err := roleIdParam(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateRoleErr),
+			err.Error(),
+		).Res()
+	}
+	req := new(roles.RoleUpdateReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateRoleErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = roleId
+
+	if req.Title != nil {
+		if *req.Title = strings.ToLower(strings.TrimSpace(*req.Title)); *req.Title == "" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateRoleErr),
+				"title is required",
+			).Res()
+		}
+	}
+
+	role, err := h.rolesUsecase.UpdateRole(req)
+	if err != nil {
+		switch {
+		case err.Error() == "role not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateRoleErr),
+				err.Error(),
+			).Res()
+		case err.Error() == "admin role cannot be changed":
+			return entities.NewResponse(c).Error(
+				fiber.ErrForbidden.Code,
+				string(updateRoleErr),
+				err.Error(),
+			).Res()
+		case err.Error() == "title has been used" || isPermissionErr(err):
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateRoleErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateRoleErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, role).Res()
+}
+
+func (h *rolesHandler) DeleteRole(c *fiber.Ctx) error {
+	roleId, err := roleIdParam(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(deleteRoleErr),
+			err.Error(),
+		).Res()
+	}
+
+	if err := h.rolesUsecase.DeleteRole(roleId); err != nil {
+		switch err.Error() {
+		case "role not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(deleteRoleErr),
+				err.Error(),
+			).Res()
+		case "default role cannot be deleted":
+			return entities.NewResponse(c).Error(
+				fiber.ErrForbidden.Code,
+				string(deleteRoleErr),
+				err.Error(),
+			).Res()
+		case "role is in use":
+			return entities.NewResponse(c).Error(
+				fiber.ErrConflict.Code,
+				string(deleteRoleErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deleteRoleErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		&struct {
+			RoleId int `json:"role_id"`
+		}{
+			RoleId: roleId,
+		},
+	).Res()
+}
+
+// UpdateUserRole : เปลี่ยน role ของตัวเองไม่ได้ กัน admin คนสุดท้ายถอดสิทธิ์ตัวเอง
+func (h *rolesHandler) UpdateUserRole(c *fiber.Ctx) error {
+	roleId, err := roleIdParam(c)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateUserRoleErr),
+			err.Error(),
+		).Res()
+	}
+	userId := strings.Trim(c.Params("user_id"), " ")
+	if userId == c.Locals("userId").(string) {
+		return entities.NewResponse(c).Error(
+			fiber.ErrForbidden.Code,
+			string(updateUserRoleErr),
+			"cannot change own role",
+		).Res()
+	}
+
+	if err := h.rolesUsecase.UpdateUserRole(userId, roleId); err != nil {
+		switch err.Error() {
+		case "user not found", "role not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateUserRoleErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateUserRoleErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(
+		fiber.StatusOK,
+		&struct {
+			UserId string `json:"user_id"`
+			RoleId int    `json:"role_id"`
+		}{
+			UserId: userId,
+			RoleId: roleId,
+		},
+	).Res()
+}
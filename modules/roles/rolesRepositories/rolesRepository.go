@@ -0,0 +1,272 @@
+package rolesRepositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles"
+	"github.com/jmoiron/sqlx"
+)
+
+type IRolesRepository interface {
+	FindRoles() ([]*roles.Role, error)
+	FindOneRole(roleId int) (*roles.Role, error)
+	FindPermissions() ([]*roles.Permission, error)
+	InsertRole(req *roles.RoleReq) (int, error)
+	UpdateRole(req *roles.RoleUpdateReq) error
+	DeleteRole(roleId int) error
+	UpdateUserRole(userId string, roleId int) error
+}
+
+type rolesRepository struct {
+	db *sqlx.DB
+}
+
+func RolesRepository(db *sqlx.DB) IRolesRepository {
+	return &rolesRepository{
+		db: db,
+	}
+}
+
+const roleColumns = `
+			"r"."id",
+			"r"."title",
+			COALESCE((
+				SELECT
+					array_agg("rp"."permission" ORDER BY "rp"."permission")
+				FROM "roles_permissions" "rp"
+				WHERE "rp"."role_id" = "r"."id"
+			), '{}') AS "permissions"`
+
+func (r *rolesRepository) FindRoles() ([]*roles.Role, error) {
+	query := fmt.Sprintf(`
+	SELECT
+		COALESCE(array_to_json(array_agg("t")), '[]'::json)
+	FROM (
+		SELECT%s
+		FROM "roles" "r"
+		ORDER BY "r"."id"
+	) AS "t";`, roleColumns)
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query); err != nil {
+		return nil, fmt.Errorf("get roles failed: %v", err)
+	}
+
+	result := make([]*roles.Role, 0)
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal roles failed: %v", err)
+	}
+	return result, nil
+}
+
+func (r *rolesRepository) FindOneRole(roleId int) (*roles.Role, error) {
+	query := fmt.Sprintf(`
+	SELECT
+		to_jsonb("t")
+	FROM (
+		SELECT%s
+		FROM "roles" "r"
+		WHERE "r"."id" = $1
+	) AS "t";`, roleColumns)
+
+	raw := make([]byte, 0)
+	if err := r.db.Get(&raw, query, roleId); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("role not found")
+		}
+		return nil, fmt.Errorf("get role failed: %v", err)
+	}
+
+	role := new(roles.Role)
+	if err := json.Unmarshal(raw, role); err != nil {
+		return nil, fmt.Errorf("unmarshal role failed: %v", err)
+	}
+	return role, nil
+}
+
+func (r *rolesRepository) FindPermissions() ([]*roles.Permission, error) {
+	query := `
+	SELECT
+		"name",
+		"description"
+	FROM "permissions"
+	ORDER BY "name";`
+
+	permissions := make([]*roles.Permission, 0)
+	if err := r.db.Select(&permissions, query); err != nil {
+		return nil, fmt.Errorf("get permissions failed: %v", err)
+	}
+	return permissions, nil
+}
+
+// insertRolePermissions : แทนที่ permission ทั้งหมดของ role
+func insertRolePermissions(ctx context.Context, tx *sqlx.Tx, roleId int, permissions []string) error {
+	query := `
+	DELETE FROM "roles_permissions"
+	WHERE "role_id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, roleId); err != nil {
+		return fmt.Errorf("delete role permissions failed: %v", err)
+	}
+
+	query = `
+	INSERT INTO "roles_permissions" (
+		"role_id",
+		"permission"
+	)
+	SELECT $1, UNNEST($2::VARCHAR[]);`
+
+	if _, err := tx.ExecContext(ctx, query, roleId, permissions); err != nil {
+		if strings.Contains(err.Error(), "roles_permissions_permission_fkey") {
+			return fmt.Errorf("permission not found")
+		}
+		return fmt.Errorf("insert role permissions failed: %v", err)
+	}
+	return nil
+}
+
+func (r *rolesRepository) InsertRole(req *roles.RoleReq) (int, error) {
+	ctx := context.Background()
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+	INSERT INTO "roles" (
+		"title"
+	)
+	VALUES ($1)
+	RETURNING "id";`
+
+	var roleId int
+	if err := tx.QueryRowxContext(ctx, query, req.Title).Scan(&roleId); err != nil {
+		tx.Rollback()
+		if strings.Contains(err.Error(), "roles_title_key") {
+			return 0, fmt.Errorf("title has been used")
+		}
+		return 0, fmt.Errorf("insert role failed: %v", err)
+	}
+
+	if err := insertRolePermissions(ctx, tx, roleId, req.Permissions); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return roleId, nil
+}
+
+func (r *rolesRepository) UpdateRole(req *roles.RoleUpdateReq) error {
+	ctx := context.Background()
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// update title ด้วยค่าเดิมเมื่อไม่ได้ส่งมา เพื่อใช้ตรวจว่ามี role อยู่จริง
+	query := `
+	UPDATE "roles" SET
+		"title" = COALESCE($1, "title")
+	WHERE "id" = $2;`
+
+	result, err := tx.ExecContext(ctx, query, req.Title, req.Id)
+	if err != nil {
+		tx.Rollback()
+		if strings.Contains(err.Error(), "roles_title_key") {
+			return fmt.Errorf("title has been used")
+		}
+		return fmt.Errorf("update role failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Rollback()
+		return fmt.Errorf("role not found")
+	}
+
+	if req.Permissions != nil {
+		if err := insertRolePermissions(ctx, tx, req.Id, *req.Permissions); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// DeleteRole : users.role_id เป็น ON DELETE CASCADE จึงลบได้เฉพาะ role ที่ไม่มี user ใช้อยู่
+func (r *rolesRepository) DeleteRole(roleId int) error {
+	query := `
+	DELETE FROM "roles"
+	WHERE "id" = $1
+	AND NOT EXISTS (
+		SELECT 1
+		FROM "users"
+		WHERE "role_id" = $1
+	);`
+
+	result, err := r.db.ExecContext(context.Background(), query, roleId)
+	if err != nil {
+		return fmt.Errorf("delete role failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		if _, err := r.FindOneRole(roleId); err != nil {
+			return err
+		}
+		return fmt.Errorf("role is in use")
+	}
+	return nil
+}
+
+// UpdateUserRole : ลบ session ทั้งหมดของ user ด้วย token เดิมมี role เก่าอยู่ จึงต้อง sign in ใหม่
+func (r *rolesRepository) UpdateUserRole(userId string, roleId int) error {
+	ctx := context.Background()
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "users" SET
+		"role_id" = $1,
+		"updated_at" = now()
+	WHERE "id" = $2;`
+
+	result, err := tx.ExecContext(ctx, query, roleId, userId)
+	if err != nil {
+		tx.Rollback()
+		if strings.Contains(err.Error(), "users_role_id_fkey") {
+			return fmt.Errorf("role not found")
+		}
+		return fmt.Errorf("update user role failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Rollback()
+		return fmt.Errorf("user not found")
+	}
+
+	query = `
+	DELETE FROM "oauth"
+	WHERE "user_id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete user oauth failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package rolesUsecases
+
+import (
+	"fmt"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles/rolesRepositories"
+)
+
+type IRolesUsecase interface {
+	FindRoles() ([]*roles.Role, error)
+	FindPermissions() ([]*roles.Permission, error)
+	InsertRole(req *roles.RoleReq) (*roles.Role, error)
+	UpdateRole(req *roles.RoleUpdateReq) (*roles.Role, error)
+	DeleteRole(roleId int) error
+	UpdateUserRole(userId string, roleId int) error
+}
+
+type rolesUsecase struct {
+	rolesRepository rolesRepositories.IRolesRepository
+}
+
+func RolesUsecase(rolesRepository rolesRepositories.IRolesRepository) IRolesUsecase {
+	return &rolesUsecase{
+		rolesRepository: rolesRepository,
+	}
+}
+
+func (u *rolesUsecase) FindRoles() ([]*roles.Role, error) {
+	return u.rolesRepository.FindRoles()
+}
+
+func (u *rolesUsecase) FindPermissions() ([]*roles.Permission, error) {
+	return u.rolesRepository.FindPermissions()
+}
+
+func (u *rolesUsecase) checkPermissions(permissions []string) ([]string, error) {
+	known, err := u.rolesRepository.FindPermissions()
+	if err != nil {
+		return nil, err
+	}
+	return roles.CheckPermissions(permissions, known)
+}
+
+func (u *rolesUsecase) InsertRole(req *roles.RoleReq) (*roles.Role, error) {
+	permissions, err := u.checkPermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	req.Permissions = permissions
+
+	roleId, err := u.rolesRepository.InsertRole(req)
+	if err != nil {
+		return nil, err
+	}
+	return u.rolesRepository.FindOneRole(roleId)
+}
+
+// UpdateRole : admin ต้องมีทุก permission เสมอ กันการล็อกตัวเองออกจากระบบ
+func (u *rolesUsecase) UpdateRole(req *roles.RoleUpdateReq) (*roles.Role, error) {
+	if req.Id == roles.RoleAdmin {
+		return nil, fmt.Errorf("admin role cannot be changed")
+	}
+	if req.Permissions != nil {
+		permissions, err := u.checkPermissions(*req.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		req.Permissions = &permissions
+	}
+
+	if err := u.rolesRepository.UpdateRole(req); err != nil {
+		return nil, err
+	}
+	return u.rolesRepository.FindOneRole(req.Id)
+}
+
+func (u *rolesUsecase) DeleteRole(roleId int) error {
+	if roleId == roles.RoleCustomer || roleId == roles.RoleAdmin {
+		return fmt.Errorf("default role cannot be deleted")
+	}
+	return u.rolesRepository.DeleteRole(roleId)
+}
+
+func (u *rolesUsecase) UpdateUserRole(userId string, roleId int) error {
+	return u.rolesRepository.UpdateUserRole(userId, roleId)
+}
@@ -1,8 +1,12 @@
 package servers
 
 import (
+	"path/filepath"
+
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesHandlers"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesStorages"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
 )
 
 type IFilesModule interface {
@@ -30,8 +34,17 @@ func (m *moduleFactory) FilesModule() IFilesModule {
 
 func (f *fileModule) Init() {
 	router := f.router.Group("files")
-	router.Post("/upload", f.middleware.JwtAuth(), f.middleware.Authorize(2), f.handler.UploadFiles)
-	router.Patch("/delete", f.middleware.JwtAuth(), f.middleware.Authorize(2), f.handler.DeleteFile)
+	router.Post("/upload", f.middleware.JwtAuth(), f.middleware.Authorize(middlewares.PermFilesWrite), f.handler.UploadFiles)
+	router.Patch("/delete", f.middleware.JwtAuth(), f.middleware.Authorize(middlewares.PermFilesWrite), f.handler.DeleteFile)
+
+	router.Post("/presign", f.middleware.JwtAuth(), f.handler.PresignUpload)
+
+	// local storage : ให้ server เป็นคนเสิร์ฟไฟล์เอง ไฟล์ private ต้องใช้ signed url
+	if f.server.cfg.Storage().Driver() == filesStorages.DriverLocal {
+		router.Static("/static", filepath.Join(f.server.cfg.Storage().LocalPath(), "public"))
+		router.Get("/signed/*", f.handler.FindSignedFile)
+		router.Post("/signed/*", f.handler.UploadSignedFile)
+	}
 }
 
 func (f *fileModule) Usecase() filesUsecases.IFilesUsecase { return f.usecase }
@@ -5,7 +5,11 @@ import (
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/appinfo/addinfoHandlers"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/appinfo/appinfoRepositories"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/appinfo/appinfoUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts/cartsHandlers"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts/cartsRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts/cartsUsecases"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares/middlewaresHandlers"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares/middlewaresRepositories"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares/middlewaresUsecases"
@@ -13,10 +17,23 @@ import (
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersHandlers"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersRepositories"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments/paymentsHandlers"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments/paymentsProviders"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments/paymentsRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments/paymentsUsecases"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions/promotionsHandlers"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions/promotionsRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions/promotionsUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles/rolesHandlers"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles/rolesRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles/rolesUsecases"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersHandlers"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersProviders"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersRepositories"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/lockout"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/mailer"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -27,6 +44,10 @@ type IModuleFactory interface {
 	FilesModule() IFilesModule
 	ProductsModule() IProductsModule
 	OrdersModule()
+	CartsModule()
+	PromotionsModule()
+	PaymentsModule()
+	RolesModule()
 }
 
 type moduleFactory struct {
@@ -57,30 +78,71 @@ func (m *moduleFactory) MonitorModule() {
 
 func (m *moduleFactory) UsersModule() {
 	repository := usersRepositories.UsersRepository(m.server.db)
-	usecase := usersUsecases.UsersUsecase(m.server.cfg, repository)
+	usecase := usersUsecases.UsersUsecase(
+		m.server.cfg,
+		repository,
+		mailer.NewMailer(m.server.cfg.Mail()),
+		lockout.NewStore(m.server.cfg.Auth(), m.server.db),
+		usersProviders.NewProviders(m.server.cfg),
+	)
 	handler := usersHandlers.UsersHandler(m.server.cfg, usecase)
 
 	router := m.router.Group("/users")
-	router.Post("/signup", m.middleware.ApiKeyAuth(), handler.SignUpCustomer)
-	router.Post("/signin", m.middleware.ApiKeyAuth(), handler.SignIn)
-	router.Post("/refresh", m.middleware.ApiKeyAuth(), handler.RefreshPassport)
-	router.Post("/signout", m.middleware.ApiKeyAuth(), handler.SignOut)
-	router.Post("/signup-admin", m.middleware.JwtAuth(), m.middleware.Authorize(2), handler.SignOut)
-	router.Get("/admin/secret", m.middleware.JwtAuth(), m.middleware.Authorize(2), handler.GenerateAdminToken)
+	router.Post("/signup", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.SignUpCustomer)
+	router.Post("/signin", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.SignIn)
+	router.Post("/signin/totp", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.SignInTotp)
+	router.Post("/signin/totp/setup", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.SetupTotpChallenge)
+	router.Get("/oauth/:provider", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.OauthAuthorization)
+	router.Post("/oauth/:provider/callback", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.OauthSignIn)
+	router.Post("/refresh", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.RefreshPassport)
+	router.Post("/signout", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.SignOut)
+	router.Post("/verify-email", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.VerifyEmail)
+	router.Post("/verify-email/resend", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.ResendVerifyEmail)
+	router.Post("/password/forgot", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.ForgotPassword)
+	router.Post("/password/reset", m.middleware.ApiKeyAuth(middlewares.ScopeUsers), handler.ResetPassword)
+	router.Post("/signup-admin", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermAdminsWrite), handler.SignOut)
+	router.Get("/admin/secret", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermAdminsWrite), handler.GenerateAdminToken)
 	// initial admin (sql migration) > generate admin key > ส่ง admin token ผ่าน middlewares ทุกครั้งที่ signup admin
-	router.Get("/:user_id", m.middleware.JwtAuth(), m.middleware.ParamsCheck(), handler.GetUserProfile)
+	router.Get("/:user_id", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersReadAny), handler.GetUserProfile)
+	router.Patch("/:user_id", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.UpdateUserProfile)
+	router.Delete("/:user_id", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.DeleteUser)
+	router.Post("/:user_id/password", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.ChangePassword)
+	router.Post("/:user_id/totp", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.SetupTotp)
+	router.Post("/:user_id/totp/enable", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.EnableTotp)
+	router.Delete("/:user_id/totp", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.DisableTotp)
+	router.Post("/:user_id/totp/recovery-codes", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.RegenerateRecoveryCodes)
+	router.Delete("/:user_id/lockout", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermUsersWriteAny), handler.UnlockUser)
+	router.Get("/:user_id/signin-failures", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermUsersReadAny), handler.FindSignInFailures)
+	router.Get("/:user_id/identities", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersReadAny), handler.FindUserIdentities)
+	router.Post("/:user_id/identities/:provider", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.LinkIdentityAuthorization)
+	router.Post("/:user_id/identities/:provider/callback", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.LinkUserIdentity)
+	router.Delete("/:user_id/identities/:provider", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.DeleteUserIdentity)
+	// jwks อยู่นอก /v1 ตามตำแหน่งมาตรฐาน
+	m.server.app.Get("/.well-known/jwks.json", handler.Jwks)
+
+	router.Get("/:user_id/sessions", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersReadAny), handler.FindUserSessions)
+	router.Delete("/:user_id/sessions", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.DeleteUserSessions)
+	router.Delete("/:user_id/sessions/:session_id", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermUsersWriteAny), handler.DeleteUserSession)
 }
 
 func (m *moduleFactory) AppinfoModule() {
 	repository := appinfoRepositories.AppinfoRepository(m.server.db)
 	usecase := appinfoUsecases.AppinfoUsecase(repository)
-	handler := appinfoHandlers.AppinfoHandler(m.server.cfg, usecase)
+	handler := appinfoHandlers.AppinfoHandler(m.server.cfg, usecase, m.middleware)
 
 	router := m.router.Group("/appinfo")
-	router.Post("/categories", m.middleware.JwtAuth(), m.middleware.Authorize(2), handler.AddCategory)
-	router.Get("/categories", m.middleware.ApiKeyAuth(), handler.FindCategory)
-	router.Get("/apikey", m.middleware.JwtAuth(), m.middleware.Authorize(2), handler.GenerateApiKey)
-	router.Delete("/:category_id/categories", m.middleware.JwtAuth(), m.middleware.Authorize(2), handler.RemoveCategory)
+	router.Post("/categories", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermCategoriesWrite), handler.AddCategory)
+	router.Get("/categories", m.middleware.ApiKeyAuth(middlewares.ScopeCatalog), handler.FindCategory)
+	router.Get("/apikeys", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermApiKeysRead), handler.FindApiKey)
+	router.Post("/apikeys", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermApiKeysWrite), handler.GenerateApiKey)
+	router.Get("/apikeys/:api_key_id", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermApiKeysRead), handler.FindOneApiKey)
+	router.Patch("/apikeys/:api_key_id", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermApiKeysWrite), handler.UpdateApiKey)
+	router.Delete("/apikeys/:api_key_id", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermApiKeysWrite), handler.RevokeApiKey)
+	// deprecated : api key แบบ jwt เดิม ปิดด้วย AUTH_LEGACY_API_KEY=false หลัง client ย้ายไป /apikeys แล้ว
+	if m.server.cfg.Auth().LegacyApiKey() {
+		router.Get("/apikey", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermApiKeysWrite), handler.GenerateLegacyApiKey)
+	}
+	router.Delete("/:category_id/categories", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermCategoriesWrite), handler.RemoveCategory)
 }
 
 func (m *moduleFactory) OrdersModule() {
@@ -88,12 +150,75 @@ func (m *moduleFactory) OrdersModule() {
 	productsRepository := productsRepositories.ProductsRepository(m.server.db, m.server.cfg, filesUsecase)
 
 	repository := ordersRepositories.OrdersRepository(m.server.db)
-	usecase := ordersUsecases.OrdersUsecase(repository, productsRepository)
+	usecase := ordersUsecases.OrdersUsecase(repository, productsRepository, filesUsecase)
 	handler := ordersHandlers.OrdersHandler(m.server.cfg, usecase)
 
 	router := m.router.Group("/orders")
-	router.Post("/", m.middleware.JwtAuth(), handler.InsertOrder)
-	router.Get("/", m.middleware.JwtAuth(), m.middleware.Authorize(2), handler.FindOrder)
-	router.Get("/:user_id/:order_id", m.middleware.JwtAuth(), m.middleware.ParamsCheck(), handler.FindOneOrder)
-	router.Patch("/:user_id/:order_id", m.middleware.JwtAuth(), m.middleware.ParamsCheck(), handler.UpdateOrder)
+	router.Post("/", m.middleware.JwtAuth(), m.middleware.VerifiedAuth(), handler.InsertOrder)
+	router.Get("/", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermOrdersReadAny), handler.FindOrder)
+	router.Get("/export", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermReportsExport), handler.ExportOrder)
+	router.Get("/:user_id/:order_id", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermOrdersReadAny), handler.FindOneOrder)
+	// warehouse (orders:fulfil) เปลี่ยนได้เฉพาะ status ตามขั้นตอนจัดส่ง ถูก check ตอน update
+	router.Patch("/:user_id/:order_id", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermOrdersWriteAny, middlewares.PermOrdersFulfil), handler.UpdateOrder)
+	router.Get("/:user_id/:order_id/history", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermOrdersReadAny), handler.FindOrderStatusHistory)
+	router.Get("/:user_id/:order_id/slip", m.middleware.JwtAuth(), m.middleware.ParamsCheck(middlewares.PermOrdersReadAny), handler.FindTransferSlip)
+}
+
+func (m *moduleFactory) CartsModule() {
+	filesUsecase := filesUsecases.FilesUsecase(m.server.cfg)
+	productsRepository := productsRepositories.ProductsRepository(m.server.db, m.server.cfg, filesUsecase)
+	ordersRepository := ordersRepositories.OrdersRepository(m.server.db)
+
+	repository := cartsRepositories.CartsRepository(m.server.db)
+	usecase := cartsUsecases.CartsUsecase(repository, ordersRepository, productsRepository)
+	handler := cartsHandlers.CartsHandler(m.server.cfg, usecase)
+
+	router := m.router.Group("/carts")
+	router.Get("/", m.middleware.JwtAuth(), handler.FindCart)
+	router.Post("/items", m.middleware.JwtAuth(), handler.AddCartItem)
+	router.Patch("/items/:product_id", m.middleware.JwtAuth(), handler.UpdateCartItem)
+	router.Delete("/items/:product_id", m.middleware.JwtAuth(), handler.RemoveCartItem)
+	router.Post("/checkout", m.middleware.JwtAuth(), m.middleware.VerifiedAuth(), handler.Checkout)
+}
+
+func (m *moduleFactory) PromotionsModule() {
+	repository := promotionsRepositories.PromotionsRepository(m.server.db)
+	usecase := promotionsUsecases.PromotionsUsecase(repository)
+	handler := promotionsHandlers.PromotionsHandler(m.server.cfg, usecase)
+
+	router := m.router.Group("/promotions")
+	router.Get("/", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermPromotionsRead), handler.FindPromotion)
+	router.Get("/:promotion_id", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermPromotionsRead), handler.FindOnePromotion)
+	router.Post("/", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermPromotionsWrite), handler.AddPromotion)
+	router.Patch("/:promotion_id", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermPromotionsWrite), handler.UpdatePromotion)
+	router.Delete("/:promotion_id", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermPromotionsWrite), handler.DeletePromotion)
+}
+
+func (m *moduleFactory) PaymentsModule() {
+	ordersRepository := ordersRepositories.OrdersRepository(m.server.db)
+	providers := paymentsProviders.NewProviders(m.server.cfg)
+
+	repository := paymentsRepositories.PaymentsRepository(m.server.db)
+	usecase := paymentsUsecases.PaymentsUsecase(repository, ordersRepository, providers)
+	handler := paymentsHandlers.PaymentsHandler(m.server.cfg, usecase)
+
+	router := m.router.Group("/payments")
+	router.Post("/", m.middleware.JwtAuth(), handler.InsertPayment)
+	router.Get("/:payment_id", m.middleware.JwtAuth(), handler.FindOnePayment)
+	// webhook ของ provider ยืนยันด้วย signature แทน jwt
+	router.Post("/webhooks/:provider", handler.Webhook)
+}
+
+func (m *moduleFactory) RolesModule() {
+	repository := rolesRepositories.RolesRepository(m.server.db)
+	usecase := rolesUsecases.RolesUsecase(repository)
+	handler := rolesHandlers.RolesHandler(m.server.cfg, usecase)
+
+	router := m.router.Group("/roles")
+	router.Get("/", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermRolesRead), handler.FindRoles)
+	router.Get("/permissions", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermRolesRead), handler.FindPermissions)
+	router.Post("/", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermRolesWrite), handler.AddRole)
+	router.Patch("/:role_id", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermRolesWrite), handler.UpdateRole)
+	router.Delete("/:role_id", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermRolesWrite), handler.DeleteRole)
+	router.Put("/:role_id/users/:user_id", m.middleware.JwtAuth(), m.middleware.Authorize(middlewares.PermRolesWrite), handler.UpdateUserRole)
 }
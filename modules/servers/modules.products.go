@@ -1,6 +1,7 @@
 package servers
 
 import (
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsHandlers"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsRepositories"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsUsecases"
@@ -35,11 +36,17 @@ func (m *moduleFactory) ProductsModule() IProductsModule {
 
 func (p *productsModule) Init() {
 	router := p.router.Group("/products")
-	router.Post("/", p.middleware.JwtAuth(), p.middleware.Authorize(2), p.handler.AddProduct)
-	router.Patch("/:product_id", p.middleware.JwtAuth(), p.middleware.Authorize(2), p.handler.UpdateProduct)
-	router.Get("/", p.middleware.ApiKeyAuth(), p.handler.FindProduct)
-	router.Get("/:product_id", p.middleware.ApiKeyAuth(), p.handler.FindOneProduct)
-	router.Delete("/:product_id", p.middleware.JwtAuth(), p.middleware.Authorize(2), p.handler.DeleteProduct)
+	router.Post("/", p.middleware.JwtAuth(), p.middleware.Authorize(middlewares.PermProductsWrite), p.handler.AddProduct)
+	router.Patch("/:product_id", p.middleware.JwtAuth(), p.middleware.Authorize(middlewares.PermProductsWrite), p.handler.UpdateProduct)
+	router.Get("/", p.middleware.ApiKeyAuth(middlewares.ScopeCatalog), p.handler.FindProduct)
+	router.Get("/:product_id", p.middleware.ApiKeyAuth(middlewares.ScopeCatalog), p.handler.FindOneProduct)
+	router.Delete("/:product_id", p.middleware.JwtAuth(), p.middleware.Authorize(middlewares.PermProductsWrite), p.handler.DeleteProduct)
+	router.Get("/:product_id/stock", p.middleware.JwtAuth(), p.middleware.Authorize(middlewares.PermStockRead), p.handler.FindStockMovement)
+	router.Patch("/:product_id/stock", p.middleware.JwtAuth(), p.middleware.Authorize(middlewares.PermStockWrite), p.handler.AdjustStock)
+	router.Post("/:product_id/variants", p.middleware.JwtAuth(), p.middleware.Authorize(middlewares.PermProductsWrite), p.handler.AddVariant)
+	router.Patch("/:product_id/variants/:variant_id", p.middleware.JwtAuth(), p.middleware.Authorize(middlewares.PermProductsWrite), p.handler.UpdateVariant)
+	router.Delete("/:product_id/variants/:variant_id", p.middleware.JwtAuth(), p.middleware.Authorize(middlewares.PermProductsWrite), p.handler.DeleteVariant)
+	router.Patch("/:product_id/variants/:variant_id/stock", p.middleware.JwtAuth(), p.middleware.Authorize(middlewares.PermStockWrite), p.handler.AdjustStock)
 }
 
 func (p *productsModule) Repository() productsRepositories.IProductsRepository { return p.repository }
@@ -7,6 +7,7 @@ import (
 	"os/signal"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 )
@@ -42,6 +43,11 @@ func (s *server) GetServer() *server {
 }
 
 func (s *server) Start() {
+	// jwt keys : ตรวจ PEM ใน config ตั้งแต่ start server ไม่ใช่ตอน sign token ครั้งแรก
+	if _, err := authentication.Keys(s.cfg.Jwt()); err != nil {
+		log.Fatalf("load jwt keys failed: %v", err)
+	}
+
 	// middlewares
 	middlewares := InitMiddlewares(s)
 	s.app.Use(middlewares.Logger())
@@ -56,6 +62,10 @@ func (s *server) Start() {
 	modules.FilesModule().Init()
 	modules.ProductsModule().Init()
 	modules.OrdersModule()
+	modules.CartsModule()
+	modules.PromotionsModule()
+	modules.PaymentsModule()
+	modules.RolesModule()
 
 	s.app.Use(middlewares.RouterCheck())
 
@@ -1,8 +1,13 @@
 package users
 
 import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
 	"fmt"
 	"regexp"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -12,6 +17,7 @@ type User struct {
 	Email    string `db:"email" json:"email"`
 	Username string `db:"username" json:"username"`
 	RoleId   int    `db:"role_id" json:"role_id"`
+	Verified bool   `db:"verified" json:"verified"` // ยืนยัน email แล้ว ยังไม่ยืนยันสั่งซื้อไม่ได้
 }
 
 type UserRegisterReq struct {
@@ -23,28 +29,43 @@ type UserRegisterReq struct {
 type UserCredential struct {
 	Email    string `db:"email" json:"email" form:"email"`
 	Password string `db:"password" json:"password" form:"password"`
+	UserClient
 }
 
 type UserCredentialCheck struct {
-	Id       string `db:"id"`
-	Email    string `db:"email"`
-	Password string `db:"password"`
-	Username string `db:"username"`
-	RoleId   int    `db:"role_id"`
+	Id          string `db:"id"`
+	Email       string `db:"email"`
+	Password    string `db:"password"`
+	Username    string `db:"username"`
+	RoleId      int    `db:"role_id"`
+	Verified    bool   `db:"verified"`
+	TotpEnabled bool   `db:"totp_enabled"`
 }
 
 func (obj *UserRegisterReq) BcryptHashing() error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(obj.Password), 10)
-	// Cost เยอะ secure มากขึ้นแต่ใช้ resource มากขึ้น
+	hashedPassword, err := HashPassword(obj.Password)
 	if err != nil {
-		return fmt.Errorf("hashed password failed: %v", err)
+		return err
 	}
-	obj.Password = string(hashedPassword)
+	obj.Password = hashedPassword
 	return nil
 }
 
+func HashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	// Cost เยอะ secure มากขึ้นแต่ใช้ resource มากขึ้น
+	if err != nil {
+		return "", fmt.Errorf("hashed password failed: %v", err)
+	}
+	return string(hashedPassword), nil
+}
+
 func (obj *UserRegisterReq) IsEmail() bool {
-	match, err := regexp.MatchString(`^[\w-\.]+@([\w-]+\.)+[\w-]{2,4}$`, obj.Email)
+	return IsEmail(obj.Email)
+}
+
+func IsEmail(email string) bool {
+	match, err := regexp.MatchString(`^[\w-\.]+@([\w-]+\.)+[\w-]{2,4}$`, email)
 	if err != nil {
 		return false
 	}
@@ -52,8 +73,9 @@ func (obj *UserRegisterReq) IsEmail() bool {
 }
 
 type UserPassport struct {
-	User  *User      `json:"user"`
-	Token *UserToken `json:"token"`
+	User          *User      `json:"user"`
+	Token         *UserToken `json:"token"`
+	RecoveryCodes []string   `json:"recovery_codes,omitempty"` // มีเฉพาะตอนเปิดใช้ TOTP ระหว่าง sign in
 }
 
 type UserToken struct {
@@ -64,19 +86,240 @@ type UserToken struct {
 
 type UserClaims struct {
 	// ไม่ควรมีข้อมูล credential แค่ระบุตัวตนได้ก็พอ เพราะเป็น base64 สามารถถอดได้
-	Id     string `db:"id" json:"id"`
-	RoleId int    `db:"role" json:"role"`
+	Id        string `db:"id" json:"id"`
+	RoleId    int    `db:"role" json:"role"`
+	SessionId string `db:"session_id" json:"session_id,omitempty"` // id ของ oauth ที่ออก token นี้
+	// permission ของ role ตอนออก token (เฉพาะ access token) ให้ client และ service อื่นใช้แสดงผล
+	// api ตรวจสิทธิ์จาก database เสมอ เพราะ role อาจถูกแก้ก่อน token หมดอายุ
+	Permissions []string `db:"-" json:"permissions,omitempty"`
 }
 
 type UserRefreshCredential struct {
 	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	UserClient
+}
+
+// UserClient : ข้อมูลของ client ที่ใช้ระบุ session ให้ user ดู ไม่ได้รับมาจาก body
+type UserClient struct {
+	Device string `json:"-" form:"-"`
+	Ip     string `json:"-" form:"-"`
 }
 
 type Oauth struct {
-	Id     string `db:"id" json:"id"`
-	UserId string `db:"user_id" json:"user_id"`
+	Id           string `db:"id" json:"id"`
+	UserId       string `db:"user_id" json:"user_id"`
+	RefreshToken string `db:"refresh_token" json:"-"` // sha-256 ของ refresh token
+}
+
+// UserSession : session ที่ยัง sign in อยู่ของ user (1 oauth = 1 session)
+type UserSession struct {
+	Id         string `db:"id" json:"id"`
+	Device     string `db:"device" json:"device"`
+	Ip         string `db:"ip" json:"ip"`
+	CreatedAt  string `db:"created_at" json:"created_at"`
+	LastUsedAt string `db:"last_used_at" json:"last_used_at"`
+	ExpiresAt  string `db:"expires_at" json:"expires_at"`
+	Current    bool   `db:"-" json:"current"`
+}
+
+// UserUpdateReq : field ที่เป็น nil จะไม่ถูก update
+type UserUpdateReq struct {
+	Id       string  `json:"-"`
+	Username *string `json:"username" form:"username"`
+	Email    *string `json:"email" form:"email"`
+	Password string  `json:"password" form:"password"` // password ปัจจุบัน ต้องใช้เมื่อเปลี่ยน email ของตัวเอง
+}
+
+type UserChangePasswordReq struct {
+	UserId      string `json:"-"`
+	SessionId   string `json:"-"` // session ที่เปลี่ยน password ยัง sign in อยู่ต่อ
+	OldPassword string `json:"old_password" form:"old_password"`
+	NewPassword string `json:"new_password" form:"new_password"`
+}
+
+type UserDeleteReq struct {
+	Password string `json:"password" form:"password"`
 }
 
 type UserRemoveCredential struct {
 	OauthId string `json:"oauth_id" form:"oauth_id"`
 }
+
+// token ที่ส่งทาง email
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+
+	VerifyEmailExpires   = 24 * time.Hour
+	ResetPasswordExpires = time.Hour
+)
+
+type UserTokenReq struct {
+	Token string `json:"token" form:"token"`
+}
+
+type UserEmailReq struct {
+	Email string `json:"email" form:"email"`
+}
+
+type UserResetPasswordReq struct {
+	Token    string `json:"token" form:"token"`
+	Password string `json:"password" form:"password"`
+}
+
+// NewUserToken : random 32 bytes เก็บใน database เป็น sha-256 เหมือน oauth token
+func NewUserToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token failed: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type UserTotp struct {
+	Secret   string `db:"totp_secret"`
+	Enabled  bool   `db:"totp_enabled"`
+	LastStep int64  `db:"totp_last_step"`
+}
+
+// UserTotpSetup : secret ที่ยังไม่ได้ยืนยัน uri ใช้สร้าง QR code
+type UserTotpSetup struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+// UserTotpChallenge : ผลของ sign in เมื่อต้องยืนยัน TOTP ก่อนได้ passport
+// EnrollmentRequired = admin ที่ยังไม่ได้ตั้งค่า TOTP ต้องตั้งค่าด้วย challenge token ก่อน
+type UserTotpChallenge struct {
+	ChallengeToken     string `json:"challenge_token"`
+	ExpiresIn          int    `json:"expires_in"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+}
+
+type UserChallengeReq struct {
+	ChallengeToken string `json:"challenge_token" form:"challenge_token"`
+	Code           string `json:"code" form:"code"` // TOTP หรือ recovery code
+	UserClient
+}
+
+type UserTotpCodeReq struct {
+	Code string `json:"code" form:"code"`
+}
+
+type UserTotpDisableReq struct {
+	Password string `json:"password" form:"password"`
+	Code     string `json:"code" form:"code"` // TOTP หรือ recovery code
+}
+
+type UserRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+const recoveryCodeCount = 10
+
+// NewRecoveryCodes : code รูปแบบ xxxxx-xxxxx (base32 ตัวเล็ก) อ่านและพิมพ์ง่าย
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generate recovery code failed: %v", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode : ไม่สนตัวพิมพ์ใหญ่เล็ก ขีดและช่องว่าง
+func NormalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+// UserLockedError : sign in ถูก lock ชั่วคราว RetryAfter ส่งกลับเป็น header Retry-After
+type UserLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *UserLockedError) Error() string {
+	return "account is temporarily locked"
+}
+
+// UserSignInFailure : audit ของ sign in ที่ไม่สำเร็จ
+type UserSignInFailure struct {
+	Id        string `db:"id" json:"id"`
+	Email     string `db:"email" json:"email"`
+	Ip        string `db:"ip" json:"ip"`
+	Device    string `db:"device" json:"device"`
+	Reason    string `db:"reason" json:"reason"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
+
+const (
+	ProviderGoogle   = "google"
+	ProviderLine     = "line"
+	ProviderFacebook = "facebook"
+	ProviderOidc     = "oidc"
+
+	OauthStateExpires = 10 * time.Minute
+)
+
+// UserIdentityClaims : ข้อมูลผู้ใช้จาก provider ที่ตรวจ id token/access token แล้ว
+// EmailVerified = provider ยืนยันว่าเป็นเจ้าของ email ใช้ผูกกับบัญชีที่มีอยู่แล้วได้
+type UserIdentityClaims struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type UserIdentity struct {
+	Id         string `db:"id" json:"id"`
+	Provider   string `db:"provider" json:"provider"`
+	Email      string `db:"email" json:"email"`
+	CreatedAt  string `db:"created_at" json:"created_at"`
+	LastUsedAt string `db:"last_used_at" json:"last_used_at"`
+}
+
+// UserOauthState : เก็บฝั่ง server ระหว่าง redirect ไป provider ใช้ได้ครั้งเดียว
+// UserId = ผูก provider กับบัญชีที่ sign in อยู่ (ว่าง = sign in)
+type UserOauthState struct {
+	Provider     string `db:"provider"`
+	CodeVerifier string `db:"code_verifier"`
+	Nonce        string `db:"nonce"`
+	UserId       string `db:"user_id"`
+}
+
+type UserOauthAuthorization struct {
+	AuthorizationUrl string `json:"authorization_url"`
+	State            string `json:"state"`
+	ExpiresIn        int    `json:"expires_in"`
+}
+
+type UserOauthCallbackReq struct {
+	Provider string `json:"-" form:"-"`
+	Code     string `json:"code" form:"code"`
+	State    string `json:"state" form:"state"`
+	UserClient
+}
+
+// OauthUsername : username ของบัญชีที่สร้างจาก provider ส่วนหน้า @ ของ email ต่อด้วยเลขสุ่ม (แก้ทีหลังได้)
+func OauthUsername(email string) (string, error) {
+	name := strings.ToLower(email)
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	name = regexp.MustCompile(`[^a-z0-9_.-]`).ReplaceAllString(name, "")
+	if len(name) > 20 {
+		name = name[:20]
+	}
+	if name == "" {
+		name = "user"
+	}
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate username failed: %v", err)
+	}
+	return fmt.Sprintf("%s_%x", name, b), nil
+}
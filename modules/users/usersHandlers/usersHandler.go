@@ -1,6 +1,9 @@
 package usersHandlers
 
 import (
+	"errors"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
@@ -21,6 +24,29 @@ const (
 	signUpAdminErr        usersHandlersErrCode = "users-005"
 	generateAdminTokenErr usersHandlersErrCode = "users-006"
 	getUserProfileErr     usersHandlersErrCode = "users-007"
+	findUserSessionsErr   usersHandlersErrCode = "users-008"
+	deleteUserSessionErr  usersHandlersErrCode = "users-009"
+	jwksErr               usersHandlersErrCode = "users-010"
+	verifyEmailErr        usersHandlersErrCode = "users-011"
+	resendVerifyEmailErr  usersHandlersErrCode = "users-012"
+	forgotPasswordErr     usersHandlersErrCode = "users-013"
+	resetPasswordErr      usersHandlersErrCode = "users-014"
+	updateUserProfileErr  usersHandlersErrCode = "users-015"
+	changePasswordErr     usersHandlersErrCode = "users-016"
+	deleteUserErr         usersHandlersErrCode = "users-017"
+	signInTotpErr         usersHandlersErrCode = "users-018"
+	setupTotpErr          usersHandlersErrCode = "users-019"
+	enableTotpErr         usersHandlersErrCode = "users-020"
+	disableTotpErr        usersHandlersErrCode = "users-021"
+	recoveryCodesErr      usersHandlersErrCode = "users-022"
+	accountLockedErr      usersHandlersErrCode = "users-023"
+	unlockUserErr         usersHandlersErrCode = "users-024"
+	findSignInFailuresErr usersHandlersErrCode = "users-025"
+	oauthAuthorizationErr usersHandlersErrCode = "users-026"
+	oauthSignInErr        usersHandlersErrCode = "users-027"
+	linkUserIdentityErr   usersHandlersErrCode = "users-028"
+	findUserIdentitiesErr usersHandlersErrCode = "users-029"
+	deleteUserIdentityErr usersHandlersErrCode = "users-030"
 )
 
 type IUsersHandler interface {
@@ -31,6 +57,31 @@ type IUsersHandler interface {
 	SignUpAdmin(c *fiber.Ctx) error
 	GenerateAdminToken(c *fiber.Ctx) error
 	GetUserProfile(c *fiber.Ctx) error
+	FindUserSessions(c *fiber.Ctx) error
+	DeleteUserSession(c *fiber.Ctx) error
+	DeleteUserSessions(c *fiber.Ctx) error
+	Jwks(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
+	ResendVerifyEmail(c *fiber.Ctx) error
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+	UpdateUserProfile(c *fiber.Ctx) error
+	ChangePassword(c *fiber.Ctx) error
+	DeleteUser(c *fiber.Ctx) error
+	SignInTotp(c *fiber.Ctx) error
+	SetupTotpChallenge(c *fiber.Ctx) error
+	SetupTotp(c *fiber.Ctx) error
+	EnableTotp(c *fiber.Ctx) error
+	DisableTotp(c *fiber.Ctx) error
+	RegenerateRecoveryCodes(c *fiber.Ctx) error
+	UnlockUser(c *fiber.Ctx) error
+	FindSignInFailures(c *fiber.Ctx) error
+	OauthAuthorization(c *fiber.Ctx) error
+	OauthSignIn(c *fiber.Ctx) error
+	LinkIdentityAuthorization(c *fiber.Ctx) error
+	LinkUserIdentity(c *fiber.Ctx) error
+	FindUserIdentities(c *fiber.Ctx) error
+	DeleteUserIdentity(c *fiber.Ctx) error
 }
 
 type usersHandler struct {
@@ -168,35 +219,128 @@ func (h *usersHandler) SignIn(c *fiber.Ctx) error {
 		).Res()
 	}
 
-	passport, err := h.usersUsecase.GetPassport(req)
+	req.UserClient = userClient(c)
+
+	passport, challenge, err := h.usersUsecase.GetPassport(req)
 	if err != nil {
+		switch err.Error() {
+		case "account is temporarily locked":
+			return accountLocked(c, err)
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(signInErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	// ต้องยืนยัน TOTP ที่ /signin/totp ก่อน
+	if challenge != nil {
+		return entities.NewResponse(c).Success(fiber.StatusOK, challenge).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, passport).Res()
+}
+
+func (h *usersHandler) SignInTotp(c *fiber.Ctx) error {
+	req := new(users.UserChallengeReq)
+	if err := c.BodyParser(req); err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrBadRequest.Code,
-			string(signInErr),
+			string(signInTotpErr),
 			err.Error(),
 		).Res()
 	}
+
+	req.UserClient = userClient(c)
+
+	passport, err := h.usersUsecase.SignInTotp(req)
+	if err != nil {
+		switch err.Error() {
+		case "account is temporarily locked":
+			return accountLocked(c, err)
+		case "challenge token is invalid", "totp code is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrUnauthorized.Code,
+				string(signInTotpErr),
+				err.Error(),
+			).Res()
+		case "totp setup is required", "totp is already enabled", "user not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(signInTotpErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(signInTotpErr),
+				err.Error(),
+			).Res()
+		}
+	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, passport).Res()
 }
 
-func (h *usersHandler) RefreshPassport(c *fiber.Ctx) error {
-	req := new(users.UserRefreshCredential)
+// accountLocked : 429 พร้อม Retry-After (วินาที)
+func accountLocked(c *fiber.Ctx, err error) error {
+	var lockedErr *users.UserLockedError
+	if errors.As(err, &lockedErr) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+	}
+	return entities.NewResponse(c).Error(
+		fiber.StatusTooManyRequests,
+		string(accountLockedErr),
+		err.Error(),
+	).Res()
+}
+
+// SetupTotpChallenge : admin ที่ถูกบังคับใช้ TOTP ตั้งค่าระหว่าง sign in แล้วยืนยัน code แรกที่ /signin/totp
+func (h *usersHandler) SetupTotpChallenge(c *fiber.Ctx) error {
+	req := new(users.UserChallengeReq)
 	if err := c.BodyParser(req); err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrBadRequest.Code,
-			string(refreshPassportErr),
+			string(setupTotpErr),
 			err.Error(),
 		).Res()
 	}
 
-	passport, err := h.usersUsecase.RefreshPassport(req)
+	setup, err := h.usersUsecase.SetupTotpChallenge(req.ChallengeToken)
 	if err != nil {
+		return totpSetupError(c, err)
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, setup).Res()
+}
+
+func (h *usersHandler) RefreshPassport(c *fiber.Ctx) error {
+	req := new(users.UserRefreshCredential)
+	if err := c.BodyParser(req); err != nil {
 		return entities.NewResponse(c).Error(
 			fiber.ErrBadRequest.Code,
 			string(refreshPassportErr),
 			err.Error(),
 		).Res()
 	}
+
+	req.UserClient = userClient(c)
+
+	passport, err := h.usersUsecase.RefreshPassport(req)
+	if err != nil {
+		switch err.Error() {
+		case "refresh token has been reused":
+			return entities.NewResponse(c).Error(
+				fiber.ErrUnauthorized.Code,
+				string(refreshPassportErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(refreshPassportErr),
+				err.Error(),
+			).Res()
+		}
+	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, passport).Res()
 }
 
@@ -244,3 +388,729 @@ func (h *usersHandler) GetUserProfile(c *fiber.Ctx) error {
 	}
 	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
 }
+
+func (h *usersHandler) FindUserSessions(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	sessionId, _ := c.Locals("sessionId").(string)
+
+	sessions, err := h.usersUsecase.FindUserSessions(userId, sessionId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findUserSessionsErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, sessions).Res()
+}
+
+func (h *usersHandler) DeleteUserSession(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	sessionId := strings.Trim(c.Params("session_id"), " ")
+
+	if err := h.usersUsecase.DeleteUserSession(userId, sessionId); err != nil {
+		switch err.Error() {
+		case "session not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(deleteUserSessionErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deleteUserSessionErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func (h *usersHandler) DeleteUserSessions(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	if err := h.usersUsecase.DeleteUserSessions(userId); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(deleteUserSessionErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+// Jwks : public key สำหรับ verify token ที่ sign ด้วย RS256/EdDSA (HS256 ไม่มี public key)
+func (h *usersHandler) Jwks(c *fiber.Ctx) error {
+	keys, err := authentication.Keys(h.cfg.Jwt())
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(jwksErr),
+			err.Error(),
+		).Res()
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return entities.NewResponse(c).Success(fiber.StatusOK, keys.Jwks()).Res()
+}
+
+func (h *usersHandler) VerifyEmail(c *fiber.Ctx) error {
+	req := new(users.UserTokenReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(verifyEmailErr),
+			err.Error(),
+		).Res()
+	}
+
+	if err := h.usersUsecase.VerifyEmail(strings.TrimSpace(req.Token)); err != nil {
+		switch err.Error() {
+		case "token is invalid or expired":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(verifyEmailErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(verifyEmailErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+// ResendVerifyEmail : ตอบเหมือนกันทุกกรณี ใช้เดาว่ามี email ไหนในระบบไม่ได้
+func (h *usersHandler) ResendVerifyEmail(c *fiber.Ctx) error {
+	req := new(users.UserEmailReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(resendVerifyEmailErr),
+			err.Error(),
+		).Res()
+	}
+
+	if err := h.usersUsecase.ResendVerifyEmail(strings.TrimSpace(req.Email)); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(resendVerifyEmailErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusAccepted, nil).Res()
+}
+
+// ForgotPassword : ตอบเหมือนกันทุกกรณี ใช้เดาว่ามี email ไหนในระบบไม่ได้
+func (h *usersHandler) ForgotPassword(c *fiber.Ctx) error {
+	req := new(users.UserEmailReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(forgotPasswordErr),
+			err.Error(),
+		).Res()
+	}
+
+	if err := h.usersUsecase.ForgotPassword(strings.TrimSpace(req.Email)); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(forgotPasswordErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusAccepted, nil).Res()
+}
+
+func (h *usersHandler) ResetPassword(c *fiber.Ctx) error {
+	req := new(users.UserResetPasswordReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(resetPasswordErr),
+			err.Error(),
+		).Res()
+	}
+	req.Token = strings.TrimSpace(req.Token)
+
+	if req.Password == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(resetPasswordErr),
+			"password is required",
+		).Res()
+	}
+
+	if err := h.usersUsecase.ResetPassword(req); err != nil {
+		switch err.Error() {
+		case "token is invalid or expired":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(resetPasswordErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(resetPasswordErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func (h *usersHandler) UpdateUserProfile(c *fiber.Ctx) error {
+	req := new(users.UserUpdateReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(updateUserProfileErr),
+			err.Error(),
+		).Res()
+	}
+	req.Id = strings.Trim(c.Params("user_id"), " ")
+
+	if req.Username != nil {
+		if *req.Username = strings.TrimSpace(*req.Username); *req.Username == "" {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateUserProfileErr),
+				"username is required",
+			).Res()
+		}
+	}
+	if req.Email != nil {
+		if *req.Email = strings.TrimSpace(*req.Email); !users.IsEmail(*req.Email) {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateUserProfileErr),
+				"email pattern is invalid",
+			).Res()
+		}
+	}
+
+	// admin แก้ข้อมูลของ user อื่นได้โดยไม่ต้องรู้ password
+	self := c.Locals("userId").(string) == req.Id
+	result, err := h.usersUsecase.UpdateUserProfile(req, self)
+	if err != nil {
+		switch err.Error() {
+		case "username has been used", "email has been used", "password is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(updateUserProfileErr),
+				err.Error(),
+			).Res()
+		case "user not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(updateUserProfileErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(updateUserProfileErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, result).Res()
+}
+
+func (h *usersHandler) ChangePassword(c *fiber.Ctx) error {
+	req := new(users.UserChangePasswordReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(changePasswordErr),
+			err.Error(),
+		).Res()
+	}
+	req.UserId = strings.Trim(c.Params("user_id"), " ")
+	req.SessionId, _ = c.Locals("sessionId").(string)
+
+	if req.NewPassword == "" {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(changePasswordErr),
+			"new password is required",
+		).Res()
+	}
+
+	if err := h.usersUsecase.ChangePassword(req); err != nil {
+		switch err.Error() {
+		case "password is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(changePasswordErr),
+				err.Error(),
+			).Res()
+		case "user not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(changePasswordErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(changePasswordErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+// DeleteUser : ล้างข้อมูลส่วนตัวและ sign out ทุก session ประวัติการสั่งซื้อยังอยู่
+func (h *usersHandler) DeleteUser(c *fiber.Ctx) error {
+	// admin ลบให้ไม่ต้องส่ง body
+	req := new(users.UserDeleteReq)
+	if len(c.Body()) != 0 {
+		if err := c.BodyParser(req); err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(deleteUserErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	self := c.Locals("userId").(string) == userId
+	if err := h.usersUsecase.DeleteUser(userId, req.Password, self); err != nil {
+		switch err.Error() {
+		case "password is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(deleteUserErr),
+				err.Error(),
+			).Res()
+		case "user not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(deleteUserErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deleteUserErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func totpSetupError(c *fiber.Ctx, err error) error {
+	switch err.Error() {
+	case "challenge token is invalid":
+		return entities.NewResponse(c).Error(
+			fiber.ErrUnauthorized.Code,
+			string(setupTotpErr),
+			err.Error(),
+		).Res()
+	case "totp is already enabled":
+		return entities.NewResponse(c).Error(
+			fiber.ErrConflict.Code,
+			string(setupTotpErr),
+			err.Error(),
+		).Res()
+	case "user not found":
+		return entities.NewResponse(c).Error(
+			fiber.ErrNotFound.Code,
+			string(setupTotpErr),
+			err.Error(),
+		).Res()
+	default:
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(setupTotpErr),
+			err.Error(),
+		).Res()
+	}
+}
+
+// SetupTotp : secret ของ TOTP เห็นได้เฉพาะเจ้าของบัญชี admin ตั้งค่าแทนไม่ได้
+func (h *usersHandler) SetupTotp(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	if c.Locals("userId").(string) != userId {
+		return entities.NewResponse(c).Error(
+			fiber.ErrForbidden.Code,
+			string(setupTotpErr),
+			"no permission to access",
+		).Res()
+	}
+
+	setup, err := h.usersUsecase.SetupTotp(userId)
+	if err != nil {
+		return totpSetupError(c, err)
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, setup).Res()
+}
+
+func (h *usersHandler) EnableTotp(c *fiber.Ctx) error {
+	req := new(users.UserTotpCodeReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(enableTotpErr),
+			err.Error(),
+		).Res()
+	}
+	userId := strings.Trim(c.Params("user_id"), " ")
+	if c.Locals("userId").(string) != userId {
+		return entities.NewResponse(c).Error(
+			fiber.ErrForbidden.Code,
+			string(enableTotpErr),
+			"no permission to access",
+		).Res()
+	}
+
+	recoveryCodes, err := h.usersUsecase.EnableTotp(userId, req.Code)
+	if err != nil {
+		switch err.Error() {
+		case "totp code is invalid", "totp setup is required":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(enableTotpErr),
+				err.Error(),
+			).Res()
+		case "totp is already enabled":
+			return entities.NewResponse(c).Error(
+				fiber.ErrConflict.Code,
+				string(enableTotpErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(enableTotpErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, recoveryCodes).Res()
+}
+
+func (h *usersHandler) DisableTotp(c *fiber.Ctx) error {
+	// admin ปิดให้ไม่ต้องส่ง body
+	req := new(users.UserTotpDisableReq)
+	if len(c.Body()) != 0 {
+		if err := c.BodyParser(req); err != nil {
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(disableTotpErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	self := c.Locals("userId").(string) == userId
+	if err := h.usersUsecase.DisableTotp(userId, req, self); err != nil {
+		switch err.Error() {
+		case "password is invalid", "totp code is invalid":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(disableTotpErr),
+				err.Error(),
+			).Res()
+		case "totp is required for admin":
+			return entities.NewResponse(c).Error(
+				fiber.ErrForbidden.Code,
+				string(disableTotpErr),
+				err.Error(),
+			).Res()
+		case "user not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(disableTotpErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(disableTotpErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func (h *usersHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	req := new(users.UserTotpCodeReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(recoveryCodesErr),
+			err.Error(),
+		).Res()
+	}
+	userId := strings.Trim(c.Params("user_id"), " ")
+	if c.Locals("userId").(string) != userId {
+		return entities.NewResponse(c).Error(
+			fiber.ErrForbidden.Code,
+			string(recoveryCodesErr),
+			"no permission to access",
+		).Res()
+	}
+
+	recoveryCodes, err := h.usersUsecase.RegenerateRecoveryCodes(userId, req.Code)
+	if err != nil {
+		switch err.Error() {
+		case "totp code is invalid", "totp is not enabled":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(recoveryCodesErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(recoveryCodesErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, recoveryCodes).Res()
+}
+
+func (h *usersHandler) UnlockUser(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	if err := h.usersUsecase.UnlockUser(userId); err != nil {
+		switch err.Error() {
+		case "user not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(unlockUserErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(unlockUserErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+func (h *usersHandler) FindSignInFailures(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	failures, err := h.usersUsecase.FindSignInFailures(userId)
+	if err != nil {
+		switch err.Error() {
+		case "user not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(findSignInFailuresErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(findSignInFailuresErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, failures).Res()
+}
+
+func oauthAuthorizationError(c *fiber.Ctx, err error) error {
+	switch err.Error() {
+	case "provider is not supported":
+		return entities.NewResponse(c).Error(
+			fiber.ErrNotFound.Code,
+			string(oauthAuthorizationErr),
+			err.Error(),
+		).Res()
+	default:
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(oauthAuthorizationErr),
+			err.Error(),
+		).Res()
+	}
+}
+
+// oauthCallbackError : error ของการแลก code กับ provider ใช้ร่วมกันระหว่าง sign in และผูกบัญชี
+func oauthCallbackError(c *fiber.Ctx, errCode usersHandlersErrCode, err error) error {
+	switch err.Error() {
+	case "provider is not supported":
+		return entities.NewResponse(c).Error(
+			fiber.ErrNotFound.Code,
+			string(errCode),
+			err.Error(),
+		).Res()
+	case "state is invalid or expired", "authorization code is invalid", "id token is invalid":
+		return entities.NewResponse(c).Error(
+			fiber.ErrUnauthorized.Code,
+			string(errCode),
+			err.Error(),
+		).Res()
+	case "email is required":
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(errCode),
+			err.Error(),
+		).Res()
+	case "email has been used", "username has been used", "identity is linked to another user", "provider has been linked":
+		return entities.NewResponse(c).Error(
+			fiber.ErrConflict.Code,
+			string(errCode),
+			err.Error(),
+		).Res()
+	default:
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(errCode),
+			err.Error(),
+		).Res()
+	}
+}
+
+// OauthAuthorization : frontend redirect ผู้ใช้ไปที่ authorization_url แล้วส่ง code และ state ที่ได้กลับมาที่ callback
+func (h *usersHandler) OauthAuthorization(c *fiber.Ctx) error {
+	provider := strings.ToLower(strings.Trim(c.Params("provider"), " "))
+
+	authorization, err := h.usersUsecase.OauthAuthorization(provider, "")
+	if err != nil {
+		return oauthAuthorizationError(c, err)
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, authorization).Res()
+}
+
+func (h *usersHandler) OauthSignIn(c *fiber.Ctx) error {
+	req := new(users.UserOauthCallbackReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(oauthSignInErr),
+			err.Error(),
+		).Res()
+	}
+
+	req.Provider = strings.ToLower(strings.Trim(c.Params("provider"), " "))
+	req.UserClient = userClient(c)
+
+	passport, challenge, err := h.usersUsecase.OauthSignIn(req)
+	if err != nil {
+		return oauthCallbackError(c, oauthSignInErr, err)
+	}
+	// ต้องยืนยัน TOTP ที่ /signin/totp ก่อน
+	if challenge != nil {
+		return entities.NewResponse(c).Success(fiber.StatusOK, challenge).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, passport).Res()
+}
+
+// LinkIdentityAuthorization : ผูก provider ได้เฉพาะบัญชีตัวเอง
+func (h *usersHandler) LinkIdentityAuthorization(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	if c.Locals("userId").(string) != userId {
+		return entities.NewResponse(c).Error(
+			fiber.ErrForbidden.Code,
+			string(oauthAuthorizationErr),
+			"no permission to access",
+		).Res()
+	}
+	provider := strings.ToLower(strings.Trim(c.Params("provider"), " "))
+
+	authorization, err := h.usersUsecase.OauthAuthorization(provider, userId)
+	if err != nil {
+		return oauthAuthorizationError(c, err)
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, authorization).Res()
+}
+
+func (h *usersHandler) LinkUserIdentity(c *fiber.Ctx) error {
+	req := new(users.UserOauthCallbackReq)
+	if err := c.BodyParser(req); err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrBadRequest.Code,
+			string(linkUserIdentityErr),
+			err.Error(),
+		).Res()
+	}
+	userId := strings.Trim(c.Params("user_id"), " ")
+	if c.Locals("userId").(string) != userId {
+		return entities.NewResponse(c).Error(
+			fiber.ErrForbidden.Code,
+			string(linkUserIdentityErr),
+			"no permission to access",
+		).Res()
+	}
+	req.Provider = strings.ToLower(strings.Trim(c.Params("provider"), " "))
+
+	identities, err := h.usersUsecase.LinkUserIdentity(userId, req)
+	if err != nil {
+		return oauthCallbackError(c, linkUserIdentityErr, err)
+	}
+	return entities.NewResponse(c).Success(fiber.StatusCreated, identities).Res()
+}
+
+func (h *usersHandler) FindUserIdentities(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+
+	identities, err := h.usersUsecase.FindUserIdentities(userId)
+	if err != nil {
+		return entities.NewResponse(c).Error(
+			fiber.ErrInternalServerError.Code,
+			string(findUserIdentitiesErr),
+			err.Error(),
+		).Res()
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, identities).Res()
+}
+
+func (h *usersHandler) DeleteUserIdentity(c *fiber.Ctx) error {
+	userId := strings.Trim(c.Params("user_id"), " ")
+	provider := strings.ToLower(strings.Trim(c.Params("provider"), " "))
+
+	if err := h.usersUsecase.DeleteUserIdentity(userId, provider); err != nil {
+		switch err.Error() {
+		case "password is required":
+			return entities.NewResponse(c).Error(
+				fiber.ErrBadRequest.Code,
+				string(deleteUserIdentityErr),
+				err.Error(),
+			).Res()
+		case "user not found", "identity not found":
+			return entities.NewResponse(c).Error(
+				fiber.ErrNotFound.Code,
+				string(deleteUserIdentityErr),
+				err.Error(),
+			).Res()
+		default:
+			return entities.NewResponse(c).Error(
+				fiber.ErrInternalServerError.Code,
+				string(deleteUserIdentityErr),
+				err.Error(),
+			).Res()
+		}
+	}
+	return entities.NewResponse(c).Success(fiber.StatusOK, nil).Res()
+}
+
+// userClient : device และ ip ที่แสดงในรายการ session
+func userClient(c *fiber.Ctx) users.UserClient {
+	device := c.Get("User-Agent")
+	if len(device) > 255 {
+		device = device[:255]
+	}
+	return users.UserClient{
+		Device: device,
+		Ip:     c.IP(),
+	}
+}
@@ -91,6 +91,7 @@ func (f *userReq) Customer() (IInsertUser, error) {
 	return f, nil
 }
 
+// Admin : admin ถูกสร้างโดย admin ด้วยกัน ถือว่ายืนยัน email แล้ว
 func (f *userReq) Admin() (IInsertUser, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
@@ -100,10 +101,11 @@ func (f *userReq) Admin() (IInsertUser, error) {
 		"email",
 		"password",
 		"username",
-		"role_id"
+		"role_id",
+		"email_verified_at"
 	)
 	VALUES
-		($1, $2, $3, 2)
+		($1, $2, $3, 2, now())
 	RETURNING "id"`
 
 	if err := f.db.QueryRowContext(
@@ -137,7 +139,8 @@ func (f *userReq) Result() (*users.UserPassport, error) {
 			"u"."id",
 			"u"."email",
 			"u"."username",
-			"u"."role_id"
+			"u"."role_id",
+			("u"."email_verified_at" IS NOT NULL) AS "verified"
 		FROM "users" "u"
 		WHERE "u"."id" = $1
 	) AS "t"`
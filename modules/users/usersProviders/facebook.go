@@ -0,0 +1,108 @@
+package usersProviders
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users"
+)
+
+type FacebookOptions struct {
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	DialogUrl    string // default https://www.facebook.com/v18.0/dialog/oauth
+	GraphUrl     string // default https://graph.facebook.com/v18.0
+}
+
+type facebookProvider struct {
+	opts   *FacebookOptions
+	client *http.Client
+}
+
+// FacebookProvider : Facebook Login เป็น OAuth2 (ไม่มี id token) ข้อมูลผู้ใช้มาจาก Graph API
+// email จาก Facebook ไม่มีสถานะการยืนยันจึงไม่ใช้ผูกกับบัญชีเดิมอัตโนมัติ
+func FacebookProvider(opts *FacebookOptions) IProvider {
+	if opts.DialogUrl == "" {
+		opts.DialogUrl = "https://www.facebook.com/v18.0/dialog/oauth"
+	}
+	if opts.GraphUrl == "" {
+		opts.GraphUrl = "https://graph.facebook.com/v18.0"
+	}
+	opts.GraphUrl = strings.TrimSuffix(opts.GraphUrl, "/")
+
+	return &facebookProvider{
+		opts: opts,
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+	}
+}
+
+func (p *facebookProvider) Name() string { return users.ProviderFacebook }
+
+func (p *facebookProvider) AuthCodeUrl(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.opts.ClientId)
+	values.Set("redirect_uri", p.opts.RedirectUrl)
+	values.Set("scope", "email,public_profile")
+	values.Set("state", state)
+	values.Set("code_challenge", codeChallenge)
+	values.Set("code_challenge_method", "S256")
+	return p.opts.DialogUrl + "?" + values.Encode(), nil
+}
+
+type facebookUser struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (p *facebookProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*users.UserIdentityClaims, error) {
+	values := url.Values{}
+	values.Set("client_id", p.opts.ClientId)
+	values.Set("client_secret", p.opts.ClientSecret)
+	values.Set("redirect_uri", p.opts.RedirectUrl)
+	values.Set("code", code)
+	values.Set("code_verifier", codeVerifier)
+
+	token := new(oidcToken)
+	if err := getJson(ctx, p.client, p.opts.GraphUrl+"/oauth/access_token?"+values.Encode(), token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("authorization code is invalid")
+	}
+
+	// appsecret_proof : Graph API ตรวจว่า access token ถูกใช้โดย app ของเรา
+	mac := hmac.New(sha256.New, []byte(p.opts.ClientSecret))
+	mac.Write([]byte(token.AccessToken))
+
+	values = url.Values{}
+	values.Set("fields", "id,name,email")
+	values.Set("access_token", token.AccessToken)
+	values.Set("appsecret_proof", hex.EncodeToString(mac.Sum(nil)))
+
+	user := new(facebookUser)
+	if err := getJson(ctx, p.client, p.opts.GraphUrl+"/me?"+values.Encode(), user); err != nil {
+		return nil, err
+	}
+	if user.Id == "" {
+		return nil, fmt.Errorf("get facebook user failed: response is invalid")
+	}
+
+	return &users.UserIdentityClaims{
+		Provider: users.ProviderFacebook,
+		Subject:  user.Id,
+		Email:    user.Email,
+		Name:     user.Name,
+	}, nil
+}
@@ -0,0 +1,208 @@
+package usersProviders
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcDiscoveryTtl = time.Hour
+	// key id ที่ไม่รู้จัก fetch jwks ใหม่ได้อย่างมากนาทีละครั้ง (provider หมุน key)
+	oidcKeysRefetch = time.Minute
+)
+
+type OidcOptions struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	Scopes       []string
+}
+
+type oidcProvider struct {
+	opts   *OidcOptions
+	client *http.Client
+
+	mu           sync.Mutex
+	discovery    *oidcDiscovery
+	discoveredAt time.Time
+	keys         map[string]crypto.PublicKey
+	keysAt       time.Time
+}
+
+// OidcProvider : OpenID Connect provider ที่มี discovery document (Google, LINE, mock server)
+func OidcProvider(opts *OidcOptions) IProvider {
+	opts.Issuer = strings.TrimSuffix(opts.Issuer, "/")
+
+	return &oidcProvider{
+		opts: opts,
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+		keys: make(map[string]crypto.PublicKey),
+	}
+}
+
+func (p *oidcProvider) Name() string { return p.opts.Name }
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil && time.Since(p.discoveredAt) < oidcDiscoveryTtl {
+		return p.discovery, nil
+	}
+
+	discovery := new(oidcDiscovery)
+	if err := getJson(ctx, p.client, p.opts.Issuer+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.opts.Issuer {
+		return nil, fmt.Errorf("%s issuer does not match", p.opts.Name)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksUri == "" {
+		return nil, fmt.Errorf("%s discovery is invalid", p.opts.Name)
+	}
+	p.discovery = discovery
+	p.discoveredAt = time.Now()
+	return discovery, nil
+}
+
+func (p *oidcProvider) publicKey(ctx context.Context, jwksUri, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < oidcKeysRefetch {
+		return nil, fmt.Errorf("key id is invalid")
+	}
+
+	jwks := new(authentication.Jwks)
+	if err := getJson(ctx, p.client, jwksUri, jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		// key ที่ไม่รองรับข้ามไป provider อาจมี key หลายแบบ
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("key id is invalid")
+	}
+	return key, nil
+}
+
+func (p *oidcProvider) AuthCodeUrl(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%s authorization endpoint is invalid", p.opts.Name)
+	}
+
+	values := u.Query()
+	values.Set("response_type", "code")
+	values.Set("client_id", p.opts.ClientId)
+	values.Set("redirect_uri", p.opts.RedirectUrl)
+	values.Set("scope", strings.Join(p.opts.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", codeChallenge)
+	values.Set("code_challenge_method", "S256")
+	u.RawQuery = values.Encode()
+	return u.String(), nil
+}
+
+type oidcToken struct {
+	AccessToken string `json:"access_token"`
+	IdToken     string `json:"id_token"`
+}
+
+// oidcClaims : email_verified บาง provider ส่งเป็น string
+type oidcClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*users.UserIdentityClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.opts.RedirectUrl)
+	form.Set("client_id", p.opts.ClientId)
+	form.Set("client_secret", p.opts.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("new token request failed: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	token := new(oidcToken)
+	if err := doJson(p.client, req, token); err != nil {
+		return nil, err
+	}
+
+	claims := new(oidcClaims)
+	if _, err := jwt.ParseWithClaims(token.IdToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.publicKey(ctx, discovery.JwksUri, kid)
+	},
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodRS256.Alg(),
+			jwt.SigningMethodES256.Alg(),
+			jwt.SigningMethodEdDSA.Alg(),
+		}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.opts.ClientId),
+		jwt.WithExpirationRequired(),
+	); err != nil {
+		return nil, fmt.Errorf("id token is invalid")
+	}
+	if claims.Nonce != nonce || claims.Subject == "" {
+		return nil, fmt.Errorf("id token is invalid")
+	}
+
+	return &users.UserIdentityClaims{
+		Provider:      p.opts.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified == true || claims.EmailVerified == "true",
+		Name:          claims.Name,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package usersProviders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users"
+)
+
+// IProvider : identity provider ภายนอก (authorization code + PKCE)
+type IProvider interface {
+	Name() string
+	// AuthCodeUrl : หน้า login ของ provider ที่ redirect ผู้ใช้ไป
+	AuthCodeUrl(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange : แลก code เป็นข้อมูลผู้ใช้ nonce ต้องตรงกับที่ส่งไปตอน AuthCodeUrl
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*users.UserIdentityClaims, error)
+}
+
+// NewProviders : เปิดเฉพาะ provider ที่กำหนด client id ไว้ใน config
+func NewProviders(cfg config.IConfig) map[string]IProvider {
+	redirectUrl := func(name string) string {
+		return strings.TrimSuffix(cfg.Oauth().RedirectUrl(), "/") + "/" + name
+	}
+
+	providers := make(map[string]IProvider)
+	if cfg.Oauth().GoogleClientId() != "" {
+		providers[users.ProviderGoogle] = OidcProvider(&OidcOptions{
+			Name:         users.ProviderGoogle,
+			Issuer:       "https://accounts.google.com",
+			ClientId:     cfg.Oauth().GoogleClientId(),
+			ClientSecret: cfg.Oauth().GoogleClientSecret(),
+			RedirectUrl:  redirectUrl(users.ProviderGoogle),
+			Scopes:       []string{"openid", "email", "profile"},
+		})
+	}
+	if cfg.Oauth().LineClientId() != "" {
+		// LINE ไม่มี email_verified ใน id token จึงไม่ผูกกับบัญชีเดิมด้วย email อัตโนมัติ
+		providers[users.ProviderLine] = OidcProvider(&OidcOptions{
+			Name:         users.ProviderLine,
+			Issuer:       "https://access.line.me",
+			ClientId:     cfg.Oauth().LineClientId(),
+			ClientSecret: cfg.Oauth().LineClientSecret(),
+			RedirectUrl:  redirectUrl(users.ProviderLine),
+			Scopes:       []string{"openid", "profile", "email"},
+		})
+	}
+	if cfg.Oauth().FacebookClientId() != "" {
+		providers[users.ProviderFacebook] = FacebookProvider(&FacebookOptions{
+			ClientId:     cfg.Oauth().FacebookClientId(),
+			ClientSecret: cfg.Oauth().FacebookClientSecret(),
+			RedirectUrl:  redirectUrl(users.ProviderFacebook),
+		})
+	}
+	if cfg.Oauth().OidcClientId() != "" {
+		providers[users.ProviderOidc] = OidcProvider(&OidcOptions{
+			Name:         users.ProviderOidc,
+			Issuer:       cfg.Oauth().OidcIssuer(),
+			ClientId:     cfg.Oauth().OidcClientId(),
+			ClientSecret: cfg.Oauth().OidcClientSecret(),
+			RedirectUrl:  redirectUrl(users.ProviderOidc),
+			Scopes:       []string{"openid", "email", "profile"},
+		})
+	}
+	return providers
+}
+
+// doJson : ส่ง request แล้ว decode response ที่เป็น json
+// status 400/401 = code หรือ token ไม่ถูกต้อง (eg. invalid_grant)
+func doJson(client *http.Client, req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %s failed: %v", req.URL.Host, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("authorization code is invalid")
+	}
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("request %s failed: %s %s", req.URL.Host, res.Status, string(msg))
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("decode %s response failed: %v", req.URL.Host, err)
+	}
+	return nil
+}
+
+func getJson(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("new request failed: %v", err)
+	}
+	return doJson(client, req, v)
+}
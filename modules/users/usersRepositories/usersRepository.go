@@ -2,22 +2,57 @@ package usersRepositories
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersPatterns"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
 	"github.com/jmoiron/sqlx"
 )
 
 type IUsersRepository interface {
 	InsertUser(req *users.UserRegisterReq, isAdmin bool) (*users.UserPassport, error)
 	FindOneUserByEmail(email string) (*users.UserCredentialCheck, error)
-	InsertOauth(req *users.UserPassport) error
+	InsertOauth(req *users.UserPassport, client *users.UserClient, expiresIn int) error
 	FindOneOauth(refreshToken string) (*users.Oauth, error)
-	UpdateOauth(req *users.UserToken) error
+	FindOauthById(oauthId string) (*users.Oauth, error)
+	RotateOauth(req *users.UserToken, oldRefreshToken string, client *users.UserClient) (bool, error)
 	GetProfile(userId string) (*users.User, error)
 	DeleteOauth(oauthId string) error
+	FindUserSessions(userId string) ([]*users.UserSession, error)
+	DeleteUserOauth(userId, oauthId string) error
+	DeleteUserOauths(userId string) error
+	InsertUserToken(userId, tokenType, token string, expiresIn time.Duration) error
+	VerifyEmail(token string) error
+	ResetPassword(token, hashedPassword string) error
+	FindOneUserById(userId string) (*users.UserCredentialCheck, error)
+	UpdateUser(req *users.UserUpdateReq) error
+	UpdatePassword(userId, hashedPassword, sessionId string) error
+	DeleteUser(userId string) error
+	FindUserTotp(userId string) (*users.UserTotp, error)
+	UpdateTotpSecret(userId, secret string) error
+	EnableTotp(userId string, step int64, recoveryCodes []string) error
+	UseTotpStep(userId string, step int64) (bool, error)
+	UseRecoveryCode(userId, code string) (bool, error)
+	UpdateRecoveryCodes(userId string, recoveryCodes []string) error
+	DisableTotp(userId string) error
+	InsertSignInFailure(req *users.UserSignInFailure) error
+	FindSignInFailures(userId string) ([]*users.UserSignInFailure, error)
+	InsertOauthState(state string, req *users.UserOauthState, expiresIn time.Duration) error
+	UseOauthState(state, provider string) (*users.UserOauthState, error)
+	FindUserByIdentity(provider, subject string) (*users.UserCredentialCheck, error)
+	InsertUserIdentity(userId string, identity *users.UserIdentityClaims) error
+	InsertIdentityUser(username string, identity *users.UserIdentityClaims) (*users.UserCredentialCheck, error)
+	FindUserIdentities(userId string) ([]*users.UserIdentity, error)
+	DeleteUserIdentity(userId, provider string) error
+	FindRolePermissions(roleId int) ([]string, error)
+	InsertTotpChallenge(userId, challengeToken string, expiresIn time.Duration) error
+	FindTotpChallenge(challengeToken string) (string, error)
+	UseTotpChallenge(challengeToken string) error
+	FailTotpChallenge(challengeToken string, maxAttempts int) error
 }
 
 type usersRepository struct {
@@ -61,7 +96,9 @@ func (r *usersRepository) FindOneUserByEmail(email string) (*users.UserCredentia
 		"email",
 		"password",
 		"username",
-		"role_id"
+		"role_id",
+		("email_verified_at" IS NOT NULL) AS "verified",
+		("totp_enabled_at" IS NOT NULL) AS "totp_enabled"
 	FROM "users"
 	WHERE "email" = $1;`
 
@@ -72,25 +109,36 @@ func (r *usersRepository) FindOneUserByEmail(email string) (*users.UserCredentia
 	return user, nil
 }
 
-func (r *usersRepository) InsertOauth(req *users.UserPassport) error {
+// InsertOauth : เก็บ token เป็น sha-256 ส่วน req.Token ยังเป็น token จริงที่ส่งให้ client
+// req.Token.Id ต้องสร้างไว้ก่อน เพราะ token ที่ sign แล้วมี session id อยู่ด้วย
+// expiresIn : อายุของ session (วินาที) เท่ากับอายุของ refresh token ตัวแรก
+func (r *usersRepository) InsertOauth(req *users.UserPassport, client *users.UserClient, expiresIn int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	query := `
 	INSERT INTO "oauth" (
+		"id",
 		"user_id",
 		"refresh_token",
-		"access_token"
+		"access_token",
+		"device",
+		"ip",
+		"expires_at"
 	)
-	VALUES ($1, $2, $3)
+	VALUES ($1, $2, $3, $4, $5, $6, now() + ($7::INT * INTERVAL '1 second'))
 	RETURNING "id";`
 
 	if err := r.db.QueryRowContext(
 		ctx,
 		query,
+		req.Token.Id,
 		req.User.Id,
-		req.Token.RefreshToken,
-		req.Token.AccessToken,
+		authentication.HashToken(req.Token.RefreshToken),
+		authentication.HashToken(req.Token.AccessToken),
+		client.Device,
+		client.Ip,
+		expiresIn,
 	).Scan(&req.Token.Id); err != nil {
 		return fmt.Errorf("insert oauth failed: %v", err)
 	}
@@ -106,24 +154,60 @@ func (r *usersRepository) FindOneOauth(refreshToken string) (*users.Oauth, error
 	WHERE "refresh_token" = $1;`
 
 	oauth := new(users.Oauth)
-	if err := r.db.Get(oauth, query, refreshToken); err != nil {
+	if err := r.db.Get(oauth, query, authentication.HashToken(refreshToken)); err != nil {
+		return nil, fmt.Errorf("oauth not found")
+	}
+	return oauth, nil
+}
+
+func (r *usersRepository) FindOauthById(oauthId string) (*users.Oauth, error) {
+	query := `
+	SELECT
+		"id",
+		"user_id",
+		"refresh_token"
+	FROM "oauth"
+	WHERE "id" = $1;`
+
+	oauth := new(users.Oauth)
+	if err := r.db.Get(oauth, query, oauthId); err != nil {
 		return nil, fmt.Errorf("oauth not found")
 	}
 	return oauth, nil
 }
 
-func (r *usersRepository) UpdateOauth(req *users.UserToken) error {
+// RotateOauth : เปลี่ยน token เฉพาะเมื่อ refresh token ใน database ยังเป็นตัวเดิมอยู่
+// false = refresh token ถูกใช้ไปแล้ว (eg. ถูกขโมยไปใช้ก่อน หรือส่งซ้ำพร้อมกัน)
+func (r *usersRepository) RotateOauth(req *users.UserToken, oldRefreshToken string, client *users.UserClient) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	query := `
 	UPDATE "oauth" SET
-		"access_token" = :access_token, 
-		"refresh_token" = :refresh_token
-	WHERE "id" = :id;`
-	// ใช้ json key ที่กำหนดใน struct
+		"access_token" = $1,
+		"refresh_token" = $2,
+		"ip" = $3,
+		"last_used_at" = now()
+	WHERE "id" = $4
+	AND "refresh_token" = $5;`
 
-	if _, err := r.db.NamedExecContext(context.Background(), query, req); err != nil {
-		return fmt.Errorf("update oauth failed: %v", err)
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		authentication.HashToken(req.AccessToken),
+		authentication.HashToken(req.RefreshToken),
+		client.Ip,
+		req.Id,
+		authentication.HashToken(oldRefreshToken),
+	)
+	if err != nil {
+		return false, fmt.Errorf("update oauth failed: %v", err)
 	}
-	return nil
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("update oauth failed: %v", err)
+	}
+	return rows == 1, nil
 }
 
 func (r *usersRepository) GetProfile(userId string) (*users.User, error) {
@@ -132,7 +216,8 @@ func (r *usersRepository) GetProfile(userId string) (*users.User, error) {
 		"id",
 		"email",
 		"username",
-		"role_id"
+		"role_id",
+		("email_verified_at" IS NOT NULL) AS "verified"
 	FROM "users"
 	WHERE "id" = $1;`
 
@@ -152,3 +237,965 @@ func (r *usersRepository) DeleteOauth(oauthId string) error {
 	}
 	return nil
 }
+
+func (r *usersRepository) FindUserSessions(userId string) ([]*users.UserSession, error) {
+	query := `
+	SELECT
+		"id",
+		"device",
+		"ip",
+		to_char("created_at", 'YYYY-MM-DD"T"HH24:MI:SS') AS "created_at",
+		to_char("last_used_at", 'YYYY-MM-DD"T"HH24:MI:SS') AS "last_used_at",
+		COALESCE(to_char("expires_at", 'YYYY-MM-DD"T"HH24:MI:SS'), '') AS "expires_at"
+	FROM "oauth"
+	WHERE "user_id" = $1
+	AND ("expires_at" IS NULL OR "expires_at" > now())
+	ORDER BY "last_used_at" DESC;`
+
+	sessions := make([]*users.UserSession, 0)
+	if err := r.db.Select(&sessions, query, userId); err != nil {
+		return nil, fmt.Errorf("get sessions failed: %v", err)
+	}
+	return sessions, nil
+}
+
+func (r *usersRepository) DeleteUserOauth(userId, oauthId string) error {
+	query := `DELETE FROM "oauth" WHERE "user_id" = $1 AND "id" = $2;`
+
+	result, err := r.db.ExecContext(context.Background(), query, userId, oauthId)
+	if err != nil {
+		return fmt.Errorf("delete session failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+// DeleteUserOauths : sign out ทุก device
+func (r *usersRepository) DeleteUserOauths(userId string) error {
+	query := `DELETE FROM "oauth" WHERE "user_id" = $1;`
+
+	if _, err := r.db.ExecContext(context.Background(), query, userId); err != nil {
+		return fmt.Errorf("delete sessions failed: %v", err)
+	}
+	return nil
+}
+
+// InsertUserToken : token ใหม่ทำให้ token ประเภทเดียวกันที่ยังไม่ได้ใช้ของ user ใช้ไม่ได้ (link ล่าสุดใช้ได้อันเดียว)
+func (r *usersRepository) InsertUserToken(userId, tokenType, token string, expiresIn time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	DELETE FROM "user_tokens"
+	WHERE "user_id" = $1
+	AND "type" = $2
+	AND "used_at" IS NULL;`
+
+	if _, err := tx.ExecContext(ctx, query, userId, tokenType); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete user tokens failed: %v", err)
+	}
+
+	query = `
+	INSERT INTO "user_tokens" (
+		"user_id",
+		"type",
+		"token_hash",
+		"expires_at"
+	)
+	VALUES ($1, $2, $3, now() + ($4::INT * INTERVAL '1 second'));`
+
+	if _, err := tx.ExecContext(
+		ctx,
+		query,
+		userId,
+		tokenType,
+		authentication.HashToken(token),
+		int(expiresIn.Seconds()),
+	); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert user token failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// useUserToken : mark token ว่าใช้แล้วใน transaction เดียวกับการเปลี่ยนข้อมูล token ใช้ซ้ำไม่ได้แม้ส่งมาพร้อมกัน
+func useUserToken(ctx context.Context, tx *sqlx.Tx, tokenType, token string) (string, error) {
+	query := `
+	UPDATE "user_tokens" SET
+		"used_at" = now()
+	WHERE "token_hash" = $1
+	AND "type" = $2
+	AND "used_at" IS NULL
+	AND "expires_at" > now()
+	RETURNING "user_id";`
+
+	var userId string
+	if err := tx.QueryRowxContext(ctx, query, authentication.HashToken(token), tokenType).Scan(&userId); err != nil {
+		// ไม่บอกเหตุผล (ไม่มี, ใช้ไปแล้ว, หมดอายุ)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("token is invalid or expired")
+		}
+		return "", fmt.Errorf("update user token failed: %v", err)
+	}
+	return userId, nil
+}
+
+func (r *usersRepository) VerifyEmail(token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	userId, err := useUserToken(ctx, tx, users.TokenVerifyEmail, token)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := `
+	UPDATE "users" SET
+		"email_verified_at" = COALESCE("email_verified_at", now())
+	WHERE "id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update user failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// ResetPassword : เปลี่ยน password แล้ว sign out ทุก session (คนที่รู้ password เดิมต้อง sign in ใหม่)
+// reset token อื่นที่ยังไม่ได้ใช้ก็ใช้ไม่ได้อีก
+// user ที่ reset password ได้แสดงว่าเป็นเจ้าของ email จึงถือว่ายืนยัน email แล้วด้วย
+func (r *usersRepository) ResetPassword(token, hashedPassword string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	userId, err := useUserToken(ctx, tx, users.TokenResetPassword, token)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := `
+	UPDATE "users" SET
+		"password" = $1,
+		"email_verified_at" = COALESCE("email_verified_at", now())
+	WHERE "id" = $2;`
+
+	if _, err := tx.ExecContext(ctx, query, hashedPassword, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update password failed: %v", err)
+	}
+
+	query = `DELETE FROM "oauth" WHERE "user_id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete sessions failed: %v", err)
+	}
+
+	query = `
+	DELETE FROM "user_tokens"
+	WHERE "user_id" = $1
+	AND "type" = $2
+	AND "used_at" IS NULL;`
+
+	if _, err := tx.ExecContext(ctx, query, userId, users.TokenResetPassword); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete user tokens failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (r *usersRepository) FindOneUserById(userId string) (*users.UserCredentialCheck, error) {
+	query := `
+	SELECT
+		"id",
+		"email",
+		"password",
+		"username",
+		"role_id",
+		("email_verified_at" IS NOT NULL) AS "verified",
+		("totp_enabled_at" IS NOT NULL) AS "totp_enabled"
+	FROM "users"
+	WHERE "id" = $1
+	AND "deleted_at" IS NULL;`
+
+	user := new(users.UserCredentialCheck)
+	if err := r.db.Get(user, query, userId); err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	return user, nil
+}
+
+// UpdateUser : เปลี่ยน email แล้วต้องยืนยัน email ใหม่ (ค่าใน SET อ้างถึง email เดิมก่อน update)
+func (r *usersRepository) UpdateUser(req *users.UserUpdateReq) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sets := make([]string, 0)
+	values := make([]any, 0)
+	set := func(column string, value any) {
+		values = append(values, value)
+		sets = append(sets, fmt.Sprintf(`"%s" = $%d`, column, len(values)))
+	}
+	if req.Username != nil {
+		set("username", *req.Username)
+	}
+	if req.Email != nil {
+		set("email", *req.Email)
+		sets = append(sets, fmt.Sprintf(`"email_verified_at" = CASE WHEN "email" = $%d THEN "email_verified_at" ELSE NULL END`, len(values)))
+	}
+
+	query := `
+	UPDATE "users" SET
+		` + strings.Join(append(sets, `"updated_at" = now()`), `,
+		`) + fmt.Sprintf(`
+	WHERE "id" = $%d
+	AND "deleted_at" IS NULL;`, len(values)+1)
+
+	result, err := r.db.ExecContext(ctx, query, append(values, req.Id)...)
+	if err != nil {
+		switch err.Error() {
+		case "ERROR: duplicate key value violates unique constraint \"users_username_key\" (SQLSTATE 23505)":
+			return fmt.Errorf("username has been used")
+		case "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)":
+			return fmt.Errorf("email has been used")
+		default:
+			return fmt.Errorf("update user failed: %v", err)
+		}
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// UpdatePassword : session อื่นทั้งหมดถูก sign out เหลือเฉพาะ session ที่เปลี่ยน password
+func (r *usersRepository) UpdatePassword(userId, hashedPassword, sessionId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "users" SET
+		"password" = $1
+	WHERE "id" = $2;`
+
+	if _, err := tx.ExecContext(ctx, query, hashedPassword, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update password failed: %v", err)
+	}
+
+	query = `DELETE FROM "oauth" WHERE "user_id" = $1 AND "id" <> $2;`
+
+	if _, err := tx.ExecContext(ctx, query, userId, sessionId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete sessions failed: %v", err)
+	}
+
+	// link reset password ที่ส่งไปก่อนหน้าใช้ไม่ได้แล้ว
+	query = `
+	DELETE FROM "user_tokens"
+	WHERE "user_id" = $1
+	AND "type" = $2
+	AND "used_at" IS NULL;`
+
+	if _, err := tx.ExecContext(ctx, query, userId, users.TokenResetPassword); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete user tokens failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// DeleteUser : ไม่ลบ row ของ user เพราะ orders, payments อ้างถึงอยู่ (FK เป็น CASCADE)
+// ล้างข้อมูลส่วนตัวแทน : email, username, password ของ user และ contact, address ของ orders
+// transfer slip ยังเก็บไว้เป็นหลักฐานการชำระเงิน
+func (r *usersRepository) DeleteUser(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "users" SET
+		"username" = CONCAT('deleted-', "id"),
+		"email" = CONCAT("id", '@deleted.invalid'),
+		"password" = '',
+		"email_verified_at" = NULL,
+		"totp_secret" = NULL,
+		"totp_enabled_at" = NULL,
+		"deleted_at" = now()
+	WHERE "id" = $1
+	AND "deleted_at" IS NULL;`
+
+	result, err := tx.ExecContext(ctx, query, userId)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete user failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Rollback()
+		return fmt.Errorf("user not found")
+	}
+
+	queries := []string{
+		`UPDATE "orders" SET "contact" = '', "address" = '' WHERE "user_id" = $1;`,
+		`DELETE FROM "oauth" WHERE "user_id" = $1;`,
+		`DELETE FROM "user_tokens" WHERE "user_id" = $1;`,
+		`DELETE FROM "users_recovery_codes" WHERE "user_id" = $1;`,
+		`DELETE FROM "users_signin_failures" WHERE "user_id" = $1;`,
+		`DELETE FROM "user_identities" WHERE "user_id" = $1;`,
+		`DELETE FROM "user_oauth_states" WHERE "user_id" = $1;`,
+		`DELETE FROM "carts_items" WHERE "user_id" = $1;`,
+		`UPDATE "api_keys" SET "revoked_at" = COALESCE("revoked_at", now()) WHERE "owner_id" = $1;`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("delete user data failed: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (r *usersRepository) FindUserTotp(userId string) (*users.UserTotp, error) {
+	query := `
+	SELECT
+		COALESCE("totp_secret", '') AS "totp_secret",
+		("totp_enabled_at" IS NOT NULL) AS "totp_enabled",
+		"totp_last_step"
+	FROM "users"
+	WHERE "id" = $1
+	AND "deleted_at" IS NULL;`
+
+	totp := new(users.UserTotp)
+	if err := r.db.Get(totp, query, userId); err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	return totp, nil
+}
+
+// UpdateTotpSecret : เริ่มตั้งค่าใหม่ได้เรื่อยๆ จนกว่าจะยืนยัน code แรก
+func (r *usersRepository) UpdateTotpSecret(userId, secret string) error {
+	query := `
+	UPDATE "users" SET
+		"totp_secret" = $1
+	WHERE "id" = $2
+	AND "totp_enabled_at" IS NULL;`
+
+	result, err := r.db.ExecContext(context.Background(), query, secret, userId)
+	if err != nil {
+		return fmt.Errorf("update totp failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("totp is already enabled")
+	}
+	return nil
+}
+
+func insertRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userId string, recoveryCodes []string) error {
+	query := `DELETE FROM "users_recovery_codes" WHERE "user_id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("delete recovery codes failed: %v", err)
+	}
+
+	query = `
+	INSERT INTO "users_recovery_codes" (
+		"user_id",
+		"code_hash"
+	)
+	SELECT $1, UNNEST($2::VARCHAR[]);`
+
+	hashes := make([]string, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		hashes = append(hashes, authentication.HashToken(users.NormalizeRecoveryCode(code)))
+	}
+	if _, err := tx.ExecContext(ctx, query, userId, hashes); err != nil {
+		return fmt.Errorf("insert recovery codes failed: %v", err)
+	}
+	return nil
+}
+
+// EnableTotp : code แรกที่ยืนยันแล้วนับเป็น code ที่ใช้ไปแล้วด้วย
+func (r *usersRepository) EnableTotp(userId string, step int64, recoveryCodes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "users" SET
+		"totp_enabled_at" = now(),
+		"totp_last_step" = $1
+	WHERE "id" = $2
+	AND "totp_enabled_at" IS NULL
+	AND "totp_last_step" < $1;`
+
+	result, err := tx.ExecContext(ctx, query, step, userId)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update totp failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Rollback()
+		return fmt.Errorf("totp is already enabled")
+	}
+
+	if err := insertRecoveryCodes(ctx, tx, userId, recoveryCodes); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// UseTotpStep : false = code นี้ (หรือ code ที่ใหม่กว่า) ถูกใช้ไปแล้ว
+func (r *usersRepository) UseTotpStep(userId string, step int64) (bool, error) {
+	query := `
+	UPDATE "users" SET
+		"totp_last_step" = $1
+	WHERE "id" = $2
+	AND "totp_last_step" < $1;`
+
+	result, err := r.db.ExecContext(context.Background(), query, step, userId)
+	if err != nil {
+		return false, fmt.Errorf("update totp failed: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("update totp failed: %v", err)
+	}
+	return rows == 1, nil
+}
+
+func (r *usersRepository) UseRecoveryCode(userId, code string) (bool, error) {
+	query := `
+	UPDATE "users_recovery_codes" SET
+		"used_at" = now()
+	WHERE "id" = (
+		SELECT "id"
+		FROM "users_recovery_codes"
+		WHERE "user_id" = $1
+		AND "code_hash" = $2
+		AND "used_at" IS NULL
+		LIMIT 1
+	)
+	AND "used_at" IS NULL;`
+
+	result, err := r.db.ExecContext(
+		context.Background(),
+		query,
+		userId,
+		authentication.HashToken(users.NormalizeRecoveryCode(code)),
+	)
+	if err != nil {
+		return false, fmt.Errorf("update recovery code failed: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("update recovery code failed: %v", err)
+	}
+	return rows == 1, nil
+}
+
+// UpdateRecoveryCodes : code ชุดเดิมทั้งหมดใช้ไม่ได้อีก
+func (r *usersRepository) UpdateRecoveryCodes(userId string, recoveryCodes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := insertRecoveryCodes(ctx, tx, userId, recoveryCodes); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+func (r *usersRepository) DisableTotp(userId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE "users" SET
+		"totp_secret" = NULL,
+		"totp_enabled_at" = NULL
+	WHERE "id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("update totp failed: %v", err)
+	}
+
+	query = `DELETE FROM "users_recovery_codes" WHERE "user_id" = $1;`
+
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete recovery codes failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// InsertSignInFailure : user_id หาจาก email ถ้าไม่ตรงกับ user ใดจะเป็น NULL
+func (r *usersRepository) InsertSignInFailure(req *users.UserSignInFailure) error {
+	query := `
+	INSERT INTO "users_signin_failures" (
+		"user_id",
+		"email",
+		"ip",
+		"device",
+		"reason"
+	)
+	VALUES ((SELECT "id" FROM "users" WHERE "email" = $1), $1, $2, $3, $4);`
+
+	if _, err := r.db.ExecContext(
+		context.Background(),
+		query,
+		req.Email,
+		req.Ip,
+		req.Device,
+		req.Reason,
+	); err != nil {
+		return fmt.Errorf("insert signin failure failed: %v", err)
+	}
+	return nil
+}
+
+func (r *usersRepository) FindSignInFailures(userId string) ([]*users.UserSignInFailure, error) {
+	query := `
+	SELECT
+		"id",
+		"email",
+		"ip",
+		"device",
+		"reason",
+		to_char("created_at", 'YYYY-MM-DD"T"HH24:MI:SS') AS "created_at"
+	FROM "users_signin_failures"
+	WHERE "user_id" = $1
+	ORDER BY "created_at" DESC
+	LIMIT 100;`
+
+	failures := make([]*users.UserSignInFailure, 0)
+	if err := r.db.Select(&failures, query, userId); err != nil {
+		return nil, fmt.Errorf("get signin failures failed: %v", err)
+	}
+	return failures, nil
+}
+
+// InsertOauthState : ลบ state ที่หมดอายุไปพร้อมกัน
+func (r *usersRepository) InsertOauthState(state string, req *users.UserOauthState, expiresIn time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM "user_oauth_states" WHERE "expires_at" <= now();`
+
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("delete oauth states failed: %v", err)
+	}
+
+	query = `
+	INSERT INTO "user_oauth_states" (
+		"state_hash",
+		"provider",
+		"code_verifier",
+		"nonce",
+		"user_id",
+		"expires_at"
+	)
+	VALUES ($1, $2, $3, $4, NULLIF($5, ''), now() + ($6::INT * INTERVAL '1 second'));`
+
+	if _, err := r.db.ExecContext(
+		ctx,
+		query,
+		authentication.HashToken(state),
+		req.Provider,
+		req.CodeVerifier,
+		req.Nonce,
+		req.UserId,
+		int(expiresIn.Seconds()),
+	); err != nil {
+		return fmt.Errorf("insert oauth state failed: %v", err)
+	}
+	return nil
+}
+
+// UseOauthState : state ใช้ได้ครั้งเดียวและต้องเป็นของ provider เดียวกัน
+func (r *usersRepository) UseOauthState(state, provider string) (*users.UserOauthState, error) {
+	query := `
+	DELETE FROM "user_oauth_states"
+	WHERE "state_hash" = $1
+	AND "provider" = $2
+	AND "expires_at" > now()
+	RETURNING
+		"provider",
+		"code_verifier",
+		"nonce",
+		COALESCE("user_id", '') AS "user_id";`
+
+	result := new(users.UserOauthState)
+	if err := r.db.Get(result, query, authentication.HashToken(state), provider); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("state is invalid or expired")
+		}
+		return nil, fmt.Errorf("delete oauth state failed: %v", err)
+	}
+	return result, nil
+}
+
+func (r *usersRepository) FindUserByIdentity(provider, subject string) (*users.UserCredentialCheck, error) {
+	query := `
+	WITH "identity" AS (
+		UPDATE "user_identities" SET
+			"last_used_at" = now()
+		WHERE "provider" = $1
+		AND "subject" = $2
+		RETURNING "user_id"
+	)
+	SELECT
+		"u"."id",
+		"u"."email",
+		"u"."password",
+		"u"."username",
+		"u"."role_id",
+		("u"."email_verified_at" IS NOT NULL) AS "verified",
+		("u"."totp_enabled_at" IS NOT NULL) AS "totp_enabled"
+	FROM "users" "u"
+	JOIN "identity" "i" ON "i"."user_id" = "u"."id"
+	WHERE "u"."deleted_at" IS NULL;`
+
+	user := new(users.UserCredentialCheck)
+	if err := r.db.Get(user, query, provider, subject); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("identity not found")
+		}
+		return nil, fmt.Errorf("get identity failed: %v", err)
+	}
+	return user, nil
+}
+
+func insertUserIdentity(ctx context.Context, tx *sqlx.Tx, userId string, identity *users.UserIdentityClaims) error {
+	query := `
+	INSERT INTO "user_identities" (
+		"user_id",
+		"provider",
+		"subject",
+		"email"
+	)
+	VALUES ($1, $2, $3, $4);`
+
+	if _, err := tx.ExecContext(
+		ctx,
+		query,
+		userId,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	); err != nil {
+		switch err.Error() {
+		case "ERROR: duplicate key value violates unique constraint \"user_identities_provider_subject_key\" (SQLSTATE 23505)":
+			return fmt.Errorf("identity is linked to another user")
+		case "ERROR: duplicate key value violates unique constraint \"user_identities_user_id_provider_key\" (SQLSTATE 23505)":
+			return fmt.Errorf("provider has been linked")
+		default:
+			return fmt.Errorf("insert identity failed: %v", err)
+		}
+	}
+	return nil
+}
+
+func (r *usersRepository) InsertUserIdentity(userId string, identity *users.UserIdentityClaims) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := insertUserIdentity(ctx, tx, userId, identity); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// InsertIdentityUser : customer ใหม่จาก provider ไม่มี password (ตั้งได้ผ่าน forgot password)
+// email ที่ provider ยืนยันแล้วถือว่ายืนยันแล้ว
+func (r *usersRepository) InsertIdentityUser(username string, identity *users.UserIdentityClaims) (*users.UserCredentialCheck, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO "users" (
+		"email",
+		"password",
+		"username",
+		"role_id",
+		"email_verified_at"
+	)
+	VALUES ($1, '', $2, 1, CASE WHEN $3::BOOLEAN THEN now() END)
+	RETURNING
+		"id",
+		"email",
+		"password",
+		"username",
+		"role_id",
+		("email_verified_at" IS NOT NULL) AS "verified",
+		FALSE AS "totp_enabled";`
+
+	user := new(users.UserCredentialCheck)
+	if err := tx.GetContext(ctx, user, query, identity.Email, username, identity.EmailVerified); err != nil {
+		tx.Rollback()
+		switch err.Error() {
+		case "ERROR: duplicate key value violates unique constraint \"users_username_key\" (SQLSTATE 23505)":
+			return nil, fmt.Errorf("username has been used")
+		case "ERROR: duplicate key value violates unique constraint \"users_email_key\" (SQLSTATE 23505)":
+			return nil, fmt.Errorf("email has been used")
+		default:
+			return nil, fmt.Errorf("insert user failed: %v", err)
+		}
+	}
+
+	if err := insertUserIdentity(ctx, tx, user.Id, identity); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *usersRepository) FindUserIdentities(userId string) ([]*users.UserIdentity, error) {
+	query := `
+	SELECT
+		"id",
+		"provider",
+		"email",
+		to_char("created_at", 'YYYY-MM-DD"T"HH24:MI:SS') AS "created_at",
+		to_char("last_used_at", 'YYYY-MM-DD"T"HH24:MI:SS') AS "last_used_at"
+	FROM "user_identities"
+	WHERE "user_id" = $1
+	ORDER BY "created_at";`
+
+	identities := make([]*users.UserIdentity, 0)
+	if err := r.db.Select(&identities, query, userId); err != nil {
+		return nil, fmt.Errorf("get identities failed: %v", err)
+	}
+	return identities, nil
+}
+
+func (r *usersRepository) DeleteUserIdentity(userId, provider string) error {
+	query := `DELETE FROM "user_identities" WHERE "user_id" = $1 AND "provider" = $2;`
+
+	result, err := r.db.ExecContext(context.Background(), query, userId, provider)
+	if err != nil {
+		return fmt.Errorf("delete identity failed: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("identity not found")
+	}
+	return nil
+}
+
+func (r *usersRepository) FindRolePermissions(roleId int) ([]string, error) {
+	query := `
+	SELECT
+		"permission"
+	FROM "roles_permissions"
+	WHERE "role_id" = $1
+	ORDER BY "permission";`
+
+	permissions := make([]string, 0)
+	if err := r.db.Select(&permissions, query, roleId); err != nil {
+		return nil, fmt.Errorf("get permissions failed: %v", err)
+	}
+	return permissions, nil
+}
+
+// InsertTotpChallenge : challenge ใหม่ทำให้ challenge ที่ยังไม่ได้ใช้ของ user ใช้ไม่ได้ (sign in ล่าสุดใช้ได้อันเดียว)
+func (r *usersRepository) InsertTotpChallenge(userId, challengeToken string, expiresIn time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	DELETE FROM "totp_challenges"
+	WHERE "user_id" = $1
+	AND "used_at" IS NULL;`
+
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete totp challenges failed: %v", err)
+	}
+
+	query = `
+	INSERT INTO "totp_challenges" (
+		"user_id",
+		"token_hash",
+		"expires_at"
+	)
+	VALUES ($1, $2, now() + ($3::INT * INTERVAL '1 second'));`
+
+	if _, err := tx.ExecContext(
+		ctx,
+		query,
+		userId,
+		authentication.HashToken(challengeToken),
+		int(expiresIn.Seconds()),
+	); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("insert totp challenge failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// FindTotpChallenge : user ของ challenge ที่ยังไม่ได้ใช้และยังไม่หมดอายุ
+func (r *usersRepository) FindTotpChallenge(challengeToken string) (string, error) {
+	query := `
+	SELECT
+		"user_id"
+	FROM "totp_challenges"
+	WHERE "token_hash" = $1
+	AND "used_at" IS NULL
+	AND "expires_at" > now();`
+
+	var userId string
+	if err := r.db.Get(&userId, query, authentication.HashToken(challengeToken)); err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("challenge token is invalid")
+		}
+		return "", fmt.Errorf("get totp challenge failed: %v", err)
+	}
+	return userId, nil
+}
+
+// UseTotpChallenge : mark ว่าใช้แล้ว request ที่ส่งมาพร้อมกันได้ passport แค่อันเดียว
+func (r *usersRepository) UseTotpChallenge(challengeToken string) error {
+	query := `
+	UPDATE "totp_challenges" SET
+		"used_at" = now()
+	WHERE "token_hash" = $1
+	AND "used_at" IS NULL
+	AND "expires_at" > now();`
+
+	result, err := r.db.ExecContext(context.Background(), query, authentication.HashToken(challengeToken))
+	if err != nil {
+		return fmt.Errorf("update totp challenge failed: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update totp challenge failed: %v", err)
+	}
+	if rows != 1 {
+		return fmt.Errorf("challenge token is invalid")
+	}
+	return nil
+}
+
+// FailTotpChallenge : นับ code ที่ผิด ครบ maxAttempts แล้ว challenge ใช้ไม่ได้อีก
+func (r *usersRepository) FailTotpChallenge(challengeToken string, maxAttempts int) error {
+	query := `
+	UPDATE "totp_challenges" SET
+		"attempts" = "attempts" + 1,
+		"used_at" = CASE WHEN "attempts" + 1 >= $2 THEN now() ELSE "used_at" END
+	WHERE "token_hash" = $1
+	AND "used_at" IS NULL;`
+
+	if _, err := r.db.ExecContext(context.Background(), query, authentication.HashToken(challengeToken), maxAttempts); err != nil {
+		return fmt.Errorf("update totp challenge failed: %v", err)
+	}
+	return nil
+}
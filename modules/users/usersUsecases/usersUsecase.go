@@ -1,33 +1,145 @@
 package usersUsecases
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersProviders"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersRepositories"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/lockout"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/mailer"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type IUsersUsecase interface {
 	InsertCustomer(req *users.UserRegisterReq) (*users.UserPassport, error)
-	GetPassport(req *users.UserCredential) (*users.UserPassport, error)
+	GetPassport(req *users.UserCredential) (*users.UserPassport, *users.UserTotpChallenge, error)
+	SignInTotp(req *users.UserChallengeReq) (*users.UserPassport, error)
+	SetupTotpChallenge(challengeToken string) (*users.UserTotpSetup, error)
 	RefreshPassport(req *users.UserRefreshCredential) (*users.UserPassport, error)
 	DeleteOauth(oauthId string) error
+	FindUserSessions(userId, currentSessionId string) ([]*users.UserSession, error)
+	DeleteUserSession(userId, sessionId string) error
+	DeleteUserSessions(userId string) error
 	InsertAdmin(req *users.UserRegisterReq) (*users.UserPassport, error)
 	GetUserProfile(userId string) (*users.User, error)
+	VerifyEmail(token string) error
+	ResendVerifyEmail(email string) error
+	ForgotPassword(email string) error
+	ResetPassword(req *users.UserResetPasswordReq) error
+	UpdateUserProfile(req *users.UserUpdateReq, checkPassword bool) (*users.User, error)
+	ChangePassword(req *users.UserChangePasswordReq) error
+	DeleteUser(userId, password string, checkPassword bool) error
+	SetupTotp(userId string) (*users.UserTotpSetup, error)
+	EnableTotp(userId, code string) (*users.UserRecoveryCodes, error)
+	DisableTotp(userId string, req *users.UserTotpDisableReq, checkPassword bool) error
+	RegenerateRecoveryCodes(userId, code string) (*users.UserRecoveryCodes, error)
+	UnlockUser(userId string) error
+	FindSignInFailures(userId string) ([]*users.UserSignInFailure, error)
+	OauthAuthorization(provider, userId string) (*users.UserOauthAuthorization, error)
+	OauthSignIn(req *users.UserOauthCallbackReq) (*users.UserPassport, *users.UserTotpChallenge, error)
+	LinkUserIdentity(userId string, req *users.UserOauthCallbackReq) ([]*users.UserIdentity, error)
+	FindUserIdentities(userId string) ([]*users.UserIdentity, error)
+	DeleteUserIdentity(userId, provider string) error
 }
 
 type usersUsecase struct {
 	cfg             config.IConfig
 	usersRepository usersRepositories.IUsersRepository
+	mailer          mailer.IMailer
+	accountLockout  lockout.ILockout
+	ipLockout       lockout.ILockout
+	providers       map[string]usersProviders.IProvider
 }
 
-func UsersUsecase(cfg config.IConfig, usersRepository usersRepositories.IUsersRepository) IUsersUsecase {
+// UsersUsecase : store ใช้นับ sign in ที่ผิดทั้งต่อบัญชี (account:<email>) และต่อ ip (ip:<ip>)
+func UsersUsecase(cfg config.IConfig, usersRepository usersRepositories.IUsersRepository, mailer mailer.IMailer, store lockout.IStore, providers map[string]usersProviders.IProvider) IUsersUsecase {
+	maxDuration := time.Duration(cfg.Auth().LockoutMaxDuration()) * time.Second
 	return &usersUsecase{
 		cfg:             cfg,
 		usersRepository: usersRepository,
+		mailer:          mailer,
+		providers:       providers,
+		accountLockout: lockout.NewLockout(store, &lockout.Policy{
+			Threshold:   cfg.Auth().LockoutThreshold(),
+			Duration:    time.Duration(cfg.Auth().LockoutDuration()) * time.Second,
+			MaxDuration: maxDuration,
+		}),
+		ipLockout: lockout.NewLockout(store, &lockout.Policy{
+			Threshold:   cfg.Auth().LockoutIpThreshold(),
+			Duration:    time.Duration(cfg.Auth().LockoutDuration()) * time.Second,
+			MaxDuration: maxDuration,
+		}),
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLockout : ตรวจก่อน compare password เพื่อไม่ให้ลองต่อได้ระหว่างถูก lock
+func (u *usersUsecase) checkLockout(email string, client *users.UserClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	retryAfter, err := u.accountLockout.RetryAfter(ctx, accountKey(email), now)
+	if err != nil {
+		return err
+	}
+	ipRetryAfter, err := u.ipLockout.RetryAfter(ctx, ipKey(client.Ip), now)
+	if err != nil {
+		return err
+	}
+	if ipRetryAfter > retryAfter {
+		retryAfter = ipRetryAfter
+	}
+	if retryAfter > 0 {
+		lockedErr := &users.UserLockedError{RetryAfter: retryAfter}
+		u.auditSignIn(email, client, lockedErr)
+		return lockedErr
+	}
+	return nil
+}
+
+// failSignIn : นับครั้งที่ผิดและเก็บ audit คืน err เดิม (ครั้งที่ทำให้ถูก lock ยังได้ err เดิม)
+func (u *usersUsecase) failSignIn(email string, client *users.UserClient, err error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	if _, lockErr := u.accountLockout.Fail(ctx, accountKey(email), now); lockErr != nil {
+		log.Printf("count signin failure of %s failed: %v\n", email, lockErr)
+	}
+	if _, lockErr := u.ipLockout.Fail(ctx, ipKey(client.Ip), now); lockErr != nil {
+		log.Printf("count signin failure of %s failed: %v\n", client.Ip, lockErr)
+	}
+	u.auditSignIn(email, client, err)
+	return err
+}
+
+func (u *usersUsecase) auditSignIn(email string, client *users.UserClient, reason error) {
+	if err := u.usersRepository.InsertSignInFailure(&users.UserSignInFailure{
+		Email:  email,
+		Ip:     client.Ip,
+		Device: client.Device,
+		Reason: reason.Error(),
+	}); err != nil {
+		log.Println(err)
 	}
 }
 
@@ -42,6 +154,11 @@ func (u *usersUsecase) InsertCustomer(req *users.UserRegisterReq) (*users.UserPa
 	if err != nil {
 		return nil, err
 	}
+
+	// ส่ง email ไม่สำเร็จไม่ทำให้ sign up fail ขอส่งใหม่ได้
+	if err := u.sendVerifyEmail(result.User); err != nil {
+		log.Printf("send verify email to %s failed: %v\n", result.User.Id, err)
+	}
 	return result, nil
 }
 
@@ -59,27 +176,90 @@ func (u *usersUsecase) InsertAdmin(req *users.UserRegisterReq) (*users.UserPassp
 	return result, nil
 }
 
-func (u *usersUsecase) GetPassport(req *users.UserCredential) (*users.UserPassport, error) {
+// GetPassport : user ที่เปิดใช้ TOTP (หรือ admin เมื่อ config บังคับ) ได้ challenge แทน passport
+// ต้องยืนยัน code ที่ SignInTotp ก่อนจึงได้ passport
+func (u *usersUsecase) GetPassport(req *users.UserCredential) (*users.UserPassport, *users.UserTotpChallenge, error) {
+	if err := u.checkLockout(req.Email, &req.UserClient); err != nil {
+		return nil, nil, err
+	}
+
 	// find user
 	user, err := u.usersRepository.FindOneUserByEmail(req.Email)
 	if err != nil {
-		return nil, err
+		return nil, nil, u.failSignIn(req.Email, &req.UserClient, err)
 	}
 
 	// compare password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("password is invalid")
+		return nil, nil, u.failSignIn(req.Email, &req.UserClient, fmt.Errorf("password is invalid"))
+	}
+	return u.signIn(user, &req.UserClient)
+}
+
+// totpRequired : config บังคับ TOTP กับ role ที่มี permission :any (admin และ staff role)
+func (u *usersUsecase) totpRequired(roleId int) (bool, error) {
+	if !u.cfg.Auth().AdminTotpRequired() {
+		return false, nil
+	}
+	permissions, err := u.usersRepository.FindRolePermissions(roleId)
+	if err != nil {
+		return false, err
+	}
+	return middlewares.Permissions(permissions).IsStaff(), nil
+}
+
+// signIn : user ที่ผ่านขั้นแรกแล้ว (password หรือ provider) ได้ passport
+// user ที่เปิดใช้ TOTP (หรือ staff เมื่อ config บังคับ) ได้ challenge แทน
+func (u *usersUsecase) signIn(user *users.UserCredentialCheck, client *users.UserClient) (*users.UserPassport, *users.UserTotpChallenge, error) {
+	required, err := u.totpRequired(user.RoleId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.TotpEnabled || required {
+		challenge, err := authentication.NewAuthentication(authentication.Challenge, u.cfg.Jwt(), &users.UserClaims{
+			Id:     user.Id,
+			RoleId: user.RoleId,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		challengeToken := challenge.SignToken()
+		if err := u.usersRepository.InsertTotpChallenge(user.Id, challengeToken, authentication.ChallengeExpires*time.Second); err != nil {
+			return nil, nil, err
+		}
+		return nil, &users.UserTotpChallenge{
+			ChallengeToken:     challengeToken,
+			ExpiresIn:          authentication.ChallengeExpires,
+			EnrollmentRequired: !user.TotpEnabled,
+		}, nil
+	}
+
+	passport, err := u.issuePassport(user, client)
+	if err != nil {
+		return nil, nil, err
 	}
+	return passport, nil, nil
+}
 
-	// sign token
+// issuePassport : สร้าง session ใหม่ของ user ที่ยืนยันตัวตนครบแล้ว
+func (u *usersUsecase) issuePassport(user *users.UserCredentialCheck, client *users.UserClient) (*users.UserPassport, error) {
+	// sign token : session id อยู่ใน token ด้วย ใช้หา session ตอน refresh
+	sessionId := uuid.NewString()
+	permissions, err := u.usersRepository.FindRolePermissions(user.RoleId)
+	if err != nil {
+		return nil, err
+	}
 	accessToken, _ := authentication.NewAuthentication(authentication.Access, u.cfg.Jwt(), &users.UserClaims{
-		Id:     user.Id,
-		RoleId: user.RoleId,
+		Id:          user.Id,
+		RoleId:      user.RoleId,
+		SessionId:   sessionId,
+		Permissions: permissions,
 	})
 
 	refreshToken, _ := authentication.NewAuthentication(authentication.Refresh, u.cfg.Jwt(), &users.UserClaims{
-		Id:     user.Id,
-		RoleId: user.RoleId,
+		Id:        user.Id,
+		RoleId:    user.RoleId,
+		SessionId: sessionId,
 	})
 
 	// set passport
@@ -89,31 +269,60 @@ func (u *usersUsecase) GetPassport(req *users.UserCredential) (*users.UserPasspo
 			Email:    user.Email,
 			Username: user.Username,
 			RoleId:   user.RoleId,
+			Verified: user.Verified,
 		},
 		Token: &users.UserToken{
+			Id:           sessionId,
 			AccessToken:  accessToken.SignToken(),
 			RefreshToken: refreshToken.SignToken(),
 		},
 	}
 
-	if err := u.usersRepository.InsertOauth(passport); err != nil {
+	if err := u.usersRepository.InsertOauth(passport, client, u.cfg.Jwt().RefreshExpireAt()); err != nil {
 		return nil, err
 	}
+
+	// sign in สำเร็จนับใหม่เฉพาะของบัญชี ของ ip นับต่อ (ไม่ให้ใช้บัญชีตัวเองล้างจำนวนครั้งของ ip)
+	if err := u.accountLockout.Reset(context.Background(), accountKey(user.Email)); err != nil {
+		log.Println(err)
+	}
 	return passport, nil
 }
 
+// RefreshPassport : refresh token ใช้ได้ครั้งเดียว ทุกครั้งที่ refresh จะได้ token คู่ใหม่ (อายุ session เท่าเดิม)
+// ถ้า refresh token ที่ถูกใช้ไปแล้วถูกส่งมาอีก ถือว่า token หลุด จะยกเลิกทั้ง session
 func (u *usersUsecase) RefreshPassport(req *users.UserRefreshCredential) (*users.UserPassport, error) {
 	// parse token
 	claims, err := authentication.ParseToken(u.cfg.Jwt(), req.RefreshToken)
 	if err != nil {
 		return nil, err
 	}
+	if claims.Subject != "refresh-token" || claims.Claims == nil {
+		return nil, fmt.Errorf("refresh token is invalid")
+	}
 
-	// check oauth
-	oauth, err := u.usersRepository.FindOneOauth(req.RefreshToken)
+	// check oauth : token ที่ออกก่อนมี session id ต้องหาจาก refresh token
+	var oauth *users.Oauth
+	if claims.Claims.SessionId != "" {
+		oauth, err = u.usersRepository.FindOauthById(claims.Claims.SessionId)
+	} else {
+		oauth, err = u.usersRepository.FindOneOauth(req.RefreshToken)
+		if err == nil {
+			oauth.RefreshToken = authentication.HashToken(req.RefreshToken)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if oauth.UserId != claims.Claims.Id {
+		return nil, fmt.Errorf("refresh token is invalid")
+	}
+	if oauth.RefreshToken != authentication.HashToken(req.RefreshToken) {
+		if err := u.usersRepository.DeleteOauth(oauth.Id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("refresh token has been reused")
+	}
 
 	// check profile
 	profile, err := u.usersRepository.GetProfile(oauth.UserId)
@@ -123,14 +332,24 @@ func (u *usersUsecase) RefreshPassport(req *users.UserRefreshCredential) (*users
 
 	// sign new payload, access token and refresh token
 	newClaims := &users.UserClaims{
-		Id:     profile.Id,
-		RoleId: profile.RoleId,
+		Id:        profile.Id,
+		RoleId:    profile.RoleId,
+		SessionId: oauth.Id,
 	}
 
+	permissions, err := u.usersRepository.FindRolePermissions(profile.RoleId)
+	if err != nil {
+		return nil, err
+	}
 	accessToken, err := authentication.NewAuthentication(
 		authentication.Access,
 		u.cfg.Jwt(),
-		newClaims,
+		&users.UserClaims{
+			Id:          newClaims.Id,
+			RoleId:      newClaims.RoleId,
+			SessionId:   newClaims.SessionId,
+			Permissions: permissions,
+		},
 	)
 	if err != nil {
 		return nil, err
@@ -151,9 +370,17 @@ func (u *usersUsecase) RefreshPassport(req *users.UserRefreshCredential) (*users
 			RefreshToken: refreshToken,
 		},
 	}
-	if err := u.usersRepository.UpdateOauth(passport.Token); err != nil {
+	rotated, err := u.usersRepository.RotateOauth(passport.Token, req.RefreshToken, &req.UserClient)
+	if err != nil {
 		return nil, err
 	}
+	if !rotated {
+		// มี request อื่นใช้ refresh token นี้ไปก่อนแล้ว
+		if err := u.usersRepository.DeleteOauth(oauth.Id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("refresh token has been reused")
+	}
 	return passport, nil
 }
 
@@ -171,3 +398,566 @@ func (u *usersUsecase) GetUserProfile(userId string) (*users.User, error) {
 	}
 	return profile, nil
 }
+
+func (u *usersUsecase) FindUserSessions(userId, currentSessionId string) ([]*users.UserSession, error) {
+	sessions, err := u.usersRepository.FindUserSessions(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.Id == currentSessionId
+	}
+	return sessions, nil
+}
+
+func (u *usersUsecase) DeleteUserSession(userId, sessionId string) error {
+	if err := u.usersRepository.DeleteUserOauth(userId, sessionId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *usersUsecase) DeleteUserSessions(userId string) error {
+	if err := u.usersRepository.DeleteUserOauths(userId); err != nil {
+		return err
+	}
+	return nil
+}
+
+// mailLink : link ไปหน้าเว็บที่รับ token ไม่ได้กำหนด url ไว้จะส่งเฉพาะ token
+func (u *usersUsecase) mailLink(path, token string) string {
+	linkUrl := strings.TrimSuffix(u.cfg.Mail().LinkUrl(), "/")
+	if linkUrl == "" {
+		return token
+	}
+	return fmt.Sprintf("%s%s?token=%s", linkUrl, path, url.QueryEscape(token))
+}
+
+func (u *usersUsecase) sendMail(msg *mailer.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return u.mailer.Send(ctx, msg)
+}
+
+func (u *usersUsecase) sendVerifyEmail(user *users.User) error {
+	token, err := users.NewUserToken()
+	if err != nil {
+		return err
+	}
+	if err := u.usersRepository.InsertUserToken(user.Id, users.TokenVerifyEmail, token, users.VerifyEmailExpires); err != nil {
+		return err
+	}
+	return u.sendMail(&mailer.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Verify your email for %s", u.cfg.App().Name()),
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease verify your email address to start ordering:\n\n%s\n\nThis link expires in 24 hours.\n",
+			user.Username,
+			u.mailLink("/verify-email", token),
+		),
+	})
+}
+
+func (u *usersUsecase) VerifyEmail(token string) error {
+	if err := u.usersRepository.VerifyEmail(token); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ResendVerifyEmail : ไม่บอกว่ามี email นี้ในระบบหรือไม่
+func (u *usersUsecase) ResendVerifyEmail(email string) error {
+	user, err := u.usersRepository.FindOneUserByEmail(email)
+	if err != nil || user.Verified {
+		return nil
+	}
+	return u.sendVerifyEmail(&users.User{
+		Id:       user.Id,
+		Email:    user.Email,
+		Username: user.Username,
+	})
+}
+
+// ForgotPassword : ไม่บอกว่ามี email นี้ในระบบหรือไม่ ส่ง email ไม่สำเร็จก็ตอบเหมือนเดิม
+func (u *usersUsecase) ForgotPassword(email string) error {
+	user, err := u.usersRepository.FindOneUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := users.NewUserToken()
+	if err != nil {
+		return err
+	}
+	if err := u.usersRepository.InsertUserToken(user.Id, users.TokenResetPassword, token, users.ResetPasswordExpires); err != nil {
+		return err
+	}
+	if err := u.sendMail(&mailer.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Reset your %s password", u.cfg.App().Name()),
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nWe received a request to reset your password. Use the link below to choose a new one:\n\n%s\n\nThis link expires in 1 hour and can be used once. If you did not request this, you can ignore this email.\n",
+			user.Username,
+			u.mailLink("/reset-password", token),
+		),
+	}); err != nil {
+		log.Printf("send reset password email to %s failed: %v\n", user.Id, err)
+	}
+	return nil
+}
+
+func (u *usersUsecase) ResetPassword(req *users.UserResetPasswordReq) error {
+	hashedPassword, err := users.HashPassword(req.Password)
+	if err != nil {
+		return err
+	}
+	if err := u.usersRepository.ResetPassword(req.Token, hashedPassword); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkPassword : password ปัจจุบันของ user
+func (u *usersUsecase) checkPassword(userId, password string) error {
+	user, err := u.usersRepository.FindOneUserById(userId)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return fmt.Errorf("password is invalid")
+	}
+	return nil
+}
+
+// UpdateUserProfile : checkPassword = user แก้ของตัวเอง ต้องยืนยัน password ก่อนเปลี่ยน email (admin แก้ให้ไม่ต้อง)
+func (u *usersUsecase) UpdateUserProfile(req *users.UserUpdateReq, checkPassword bool) (*users.User, error) {
+	if req.Email != nil && checkPassword {
+		if err := u.checkPassword(req.Id, req.Password); err != nil {
+			return nil, err
+		}
+	}
+	if err := u.usersRepository.UpdateUser(req); err != nil {
+		return nil, err
+	}
+
+	profile, err := u.usersRepository.GetProfile(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Email != nil && !profile.Verified {
+		if err := u.sendVerifyEmail(profile); err != nil {
+			log.Printf("send verify email to %s failed: %v\n", profile.Id, err)
+		}
+	}
+	return profile, nil
+}
+
+func (u *usersUsecase) ChangePassword(req *users.UserChangePasswordReq) error {
+	if err := u.checkPassword(req.UserId, req.OldPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := users.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := u.usersRepository.UpdatePassword(req.UserId, hashedPassword, req.SessionId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *usersUsecase) DeleteUser(userId, password string, checkPassword bool) error {
+	if checkPassword {
+		if err := u.checkPassword(userId, password); err != nil {
+			return err
+		}
+	}
+	if err := u.usersRepository.DeleteUser(userId); err != nil {
+		return err
+	}
+	return nil
+}
+
+// verifyTotp : TOTP ที่ยังไม่เคยใช้ หรือ recovery code (allowRecovery) ที่ยังไม่เคยใช้
+func (u *usersUsecase) verifyTotp(userId string, totp *users.UserTotp, code string, allowRecovery bool) error {
+	if step, ok := authentication.VerifyTotp(totp.Secret, code, time.Now(), totp.LastStep); ok {
+		used, err := u.usersRepository.UseTotpStep(userId, step)
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	}
+	if allowRecovery {
+		used, err := u.usersRepository.UseRecoveryCode(userId, code)
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	}
+	return fmt.Errorf("totp code is invalid")
+}
+
+func (u *usersUsecase) challengeUser(challengeToken string) (*users.UserCredentialCheck, error) {
+	claims, err := authentication.ParseChallengeToken(u.cfg.Jwt(), challengeToken)
+	if err != nil {
+		return nil, fmt.Errorf("challenge token is invalid")
+	}
+	// challenge ที่ใช้แล้วหรือใส่ code ผิดครบแล้วใช้ไม่ได้แม้ยังไม่หมดอายุ
+	userId, err := u.usersRepository.FindTotpChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if userId != claims.Claims.Id {
+		return nil, fmt.Errorf("challenge token is invalid")
+	}
+	return u.usersRepository.FindOneUserById(userId)
+}
+
+// SignInTotp : user ที่ยังไม่ได้เปิดใช้ (admin ที่ถูกบังคับ) code แรกคือการยืนยันการตั้งค่า ได้ recovery codes พร้อม passport
+func (u *usersUsecase) SignInTotp(req *users.UserChallengeReq) (*users.UserPassport, error) {
+	user, err := u.challengeUser(req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+	totp, err := u.usersRepository.FindUserTotp(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.checkLockout(user.Email, &req.UserClient); err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if totp.Enabled {
+		err = u.verifyTotp(user.Id, totp, req.Code, true)
+	} else {
+		var codes *users.UserRecoveryCodes
+		if codes, err = u.EnableTotp(user.Id, req.Code); err == nil {
+			recoveryCodes = codes.RecoveryCodes
+		}
+	}
+	if err != nil {
+		if err.Error() == "totp code is invalid" {
+			if err := u.usersRepository.FailTotpChallenge(req.ChallengeToken, authentication.ChallengeMaxAttempts); err != nil {
+				return nil, err
+			}
+			return nil, u.failSignIn(user.Email, &req.UserClient, err)
+		}
+		return nil, err
+	}
+	if err := u.usersRepository.UseTotpChallenge(req.ChallengeToken); err != nil {
+		return nil, err
+	}
+
+	passport, err := u.issuePassport(user, &req.UserClient)
+	if err != nil {
+		return nil, err
+	}
+	passport.RecoveryCodes = recoveryCodes
+	return passport, nil
+}
+
+// SetupTotpChallenge : ตั้งค่า TOTP ระหว่าง sign in ด้วย challenge token (ยังไม่มี access token)
+func (u *usersUsecase) SetupTotpChallenge(challengeToken string) (*users.UserTotpSetup, error) {
+	user, err := u.challengeUser(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	return u.SetupTotp(user.Id)
+}
+
+func (u *usersUsecase) SetupTotp(userId string) (*users.UserTotpSetup, error) {
+	user, err := u.usersRepository.FindOneUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+	if user.TotpEnabled {
+		return nil, fmt.Errorf("totp is already enabled")
+	}
+
+	secret, err := authentication.NewTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.usersRepository.UpdateTotpSecret(user.Id, secret); err != nil {
+		return nil, err
+	}
+	return &users.UserTotpSetup{
+		Secret: secret,
+		Uri:    authentication.TotpUri(u.cfg.App().Name(), user.Email, secret),
+	}, nil
+}
+
+// EnableTotp : ยืนยัน code แรกจาก secret ที่ตั้งค่าไว้ recovery codes แสดงครั้งเดียว
+func (u *usersUsecase) EnableTotp(userId, code string) (*users.UserRecoveryCodes, error) {
+	totp, err := u.usersRepository.FindUserTotp(userId)
+	if err != nil {
+		return nil, err
+	}
+	if totp.Enabled {
+		return nil, fmt.Errorf("totp is already enabled")
+	}
+	if totp.Secret == "" {
+		return nil, fmt.Errorf("totp setup is required")
+	}
+
+	step, ok := authentication.VerifyTotp(totp.Secret, code, time.Now(), totp.LastStep)
+	if !ok {
+		return nil, fmt.Errorf("totp code is invalid")
+	}
+	recoveryCodes, err := users.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.usersRepository.EnableTotp(userId, step, recoveryCodes); err != nil {
+		return nil, err
+	}
+	return &users.UserRecoveryCodes{RecoveryCodes: recoveryCodes}, nil
+}
+
+// DisableTotp : checkPassword = user ปิดเอง ต้องใช้ทั้ง password และ code (admin ปิดให้ได้เมื่อ user ทำ authenticator หาย)
+func (u *usersUsecase) DisableTotp(userId string, req *users.UserTotpDisableReq, checkPassword bool) error {
+	user, err := u.usersRepository.FindOneUserById(userId)
+	if err != nil {
+		return err
+	}
+	required, err := u.totpRequired(user.RoleId)
+	if err != nil {
+		return err
+	}
+	if required {
+		return fmt.Errorf("totp is required for admin")
+	}
+	if checkPassword {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			return fmt.Errorf("password is invalid")
+		}
+		totp, err := u.usersRepository.FindUserTotp(userId)
+		if err != nil {
+			return err
+		}
+		if totp.Enabled {
+			if err := u.verifyTotp(userId, totp, req.Code, true); err != nil {
+				return err
+			}
+		}
+	}
+	if err := u.usersRepository.DisableTotp(userId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *usersUsecase) RegenerateRecoveryCodes(userId, code string) (*users.UserRecoveryCodes, error) {
+	totp, err := u.usersRepository.FindUserTotp(userId)
+	if err != nil {
+		return nil, err
+	}
+	if !totp.Enabled {
+		return nil, fmt.Errorf("totp is not enabled")
+	}
+	if err := u.verifyTotp(userId, totp, code, false); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := users.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.usersRepository.UpdateRecoveryCodes(userId, recoveryCodes); err != nil {
+		return nil, err
+	}
+	return &users.UserRecoveryCodes{RecoveryCodes: recoveryCodes}, nil
+}
+
+// UnlockUser : admin ปลด lock ของบัญชี (lock ของ ip หมดอายุเอง)
+func (u *usersUsecase) UnlockUser(userId string) error {
+	user, err := u.usersRepository.FindOneUserById(userId)
+	if err != nil {
+		return err
+	}
+	if err := u.accountLockout.Reset(context.Background(), accountKey(user.Email)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *usersUsecase) FindSignInFailures(userId string) ([]*users.UserSignInFailure, error) {
+	if _, err := u.usersRepository.FindOneUserById(userId); err != nil {
+		return nil, err
+	}
+	return u.usersRepository.FindSignInFailures(userId)
+}
+
+func (u *usersUsecase) provider(name string) (usersProviders.IProvider, error) {
+	provider, ok := u.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("provider is not supported")
+	}
+	return provider, nil
+}
+
+// OauthAuthorization : userId ว่าง = sign in, มีค่า = ผูก provider กับบัญชีที่ sign in อยู่
+func (u *usersUsecase) OauthAuthorization(providerName, userId string) (*users.UserOauthAuthorization, error) {
+	provider, err := u.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := users.NewUserToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := users.NewUserToken()
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := authentication.NewCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	authorizationUrl, err := provider.AuthCodeUrl(ctx, state, nonce, authentication.CodeChallenge(codeVerifier))
+	if err != nil {
+		return nil, err
+	}
+	if err := u.usersRepository.InsertOauthState(state, &users.UserOauthState{
+		Provider:     provider.Name(),
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		UserId:       userId,
+	}, users.OauthStateExpires); err != nil {
+		return nil, err
+	}
+	return &users.UserOauthAuthorization{
+		AuthorizationUrl: authorizationUrl,
+		State:            state,
+		ExpiresIn:        int(users.OauthStateExpires.Seconds()),
+	}, nil
+}
+
+// oauthIdentity : state ต้องออกให้ userId เดียวกัน (state ของ sign in ใช้ผูกบัญชีไม่ได้ และกลับกัน)
+func (u *usersUsecase) oauthIdentity(req *users.UserOauthCallbackReq, userId string) (*users.UserIdentityClaims, error) {
+	provider, err := u.provider(req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	state, err := u.usersRepository.UseOauthState(req.State, provider.Name())
+	if err != nil {
+		return nil, err
+	}
+	if state.UserId != userId {
+		return nil, fmt.Errorf("state is invalid or expired")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	return provider.Exchange(ctx, req.Code, state.CodeVerifier, state.Nonce)
+}
+
+// OauthSignIn : หา user จาก identity ที่ผูกไว้ ถ้ายังไม่เคยผูก
+// email ที่ provider ยืนยันแล้วตรงกับ customer ที่มีอยู่ = ผูกกับบัญชีนั้น, ไม่มีบัญชี = สร้าง customer ใหม่
+// admin และ email ที่ยังไม่ยืนยัน (ทั้งฝั่ง provider และบัญชีเดิม) ต้อง sign in ด้วย password แล้วผูกเอง
+func (u *usersUsecase) OauthSignIn(req *users.UserOauthCallbackReq) (*users.UserPassport, *users.UserTotpChallenge, error) {
+	identity, err := u.oauthIdentity(req, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := u.usersRepository.FindUserByIdentity(identity.Provider, identity.Subject)
+	if err != nil {
+		if err.Error() != "identity not found" {
+			return nil, nil, err
+		}
+		if user, err = u.oauthUser(identity); err != nil {
+			return nil, nil, err
+		}
+	}
+	return u.signIn(user, &req.UserClient)
+}
+
+func (u *usersUsecase) oauthUser(identity *users.UserIdentityClaims) (*users.UserCredentialCheck, error) {
+	if !users.IsEmail(identity.Email) {
+		return nil, fmt.Errorf("email is required")
+	}
+
+	user, err := u.usersRepository.FindOneUserByEmail(identity.Email)
+	if err == nil {
+		// บัญชีที่ยังไม่ยืนยัน email อาจถูกคนอื่นสมัครดัก (รู้ password) ไว้ก่อน ผูกให้ไม่ได้
+		if !identity.EmailVerified || !user.Verified || user.RoleId != roles.RoleCustomer {
+			return nil, fmt.Errorf("email has been used")
+		}
+		if err := u.usersRepository.InsertUserIdentity(user.Id, identity); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if err.Error() != "user not found" {
+		return nil, err
+	}
+
+	username, err := users.OauthUsername(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+	user, err = u.usersRepository.InsertIdentityUser(username, identity)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Verified {
+		if err := u.sendVerifyEmail(&users.User{
+			Id:       user.Id,
+			Email:    user.Email,
+			Username: user.Username,
+		}); err != nil {
+			log.Printf("send verify email to %s failed: %v\n", user.Id, err)
+		}
+	}
+	return user, nil
+}
+
+func (u *usersUsecase) LinkUserIdentity(userId string, req *users.UserOauthCallbackReq) ([]*users.UserIdentity, error) {
+	identity, err := u.oauthIdentity(req, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.usersRepository.InsertUserIdentity(userId, identity); err != nil {
+		return nil, err
+	}
+	return u.usersRepository.FindUserIdentities(userId)
+}
+
+func (u *usersUsecase) FindUserIdentities(userId string) ([]*users.UserIdentity, error) {
+	return u.usersRepository.FindUserIdentities(userId)
+}
+
+// DeleteUserIdentity : user ที่ไม่มี password ต้องเหลือ provider ไว้อย่างน้อยหนึ่งอันเพื่อ sign in
+func (u *usersUsecase) DeleteUserIdentity(userId, provider string) error {
+	user, err := u.usersRepository.FindOneUserById(userId)
+	if err != nil {
+		return err
+	}
+	if user.Password == "" {
+		identities, err := u.usersRepository.FindUserIdentities(userId)
+		if err != nil {
+			return err
+		}
+		if len(identities) <= 1 {
+			return fmt.Errorf("password is required")
+		}
+	}
+	if err := u.usersRepository.DeleteUserIdentity(userId, provider); err != nil {
+		return err
+	}
+	return nil
+}
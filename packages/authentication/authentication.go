@@ -2,6 +2,8 @@
 package authentication
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
@@ -10,6 +12,7 @@ import (
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type TokenType string
@@ -20,8 +23,16 @@ const (
 	Refresh TokenType = "refresh"
 	Admin   TokenType = "admin"
 	ApiKey  TokenType = "apikey"
+	// Challenge : ออกหลัง password ถูกต้อง ใช้แลก passport เมื่อยืนยัน TOTP แล้ว
+	Challenge TokenType = "challenge"
 )
 
+// ChallengeExpires : อายุของ challenge token (วินาที)
+const ChallengeExpires = 300
+
+// ChallengeMaxAttempts : ใส่ code ผิดได้กี่ครั้งต่อ challenge token
+const ChallengeMaxAttempts = 5
+
 type authentication struct {
 	mapClaims *authMapClaims // payload
 	cfg       config.IJwtConfig
@@ -62,32 +73,63 @@ func jwtTimeRepeatAdapter(t int64) *jwt.NumericDate {
 }
 
 func (a *authentication) SignToken() string {
-	// sign token พร้อมกับ payload แบบ simmetric key
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, a.mapClaims)
-	ss, _ := token.SignedString(a.cfg.SecretKey()) // sign token
-	return ss
+	return a.sign(a.cfg.SecretKey())
 }
 
 func (a *authenticationAdmin) SignToken() string {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, a.mapClaims)
-	ss, _ := token.SignedString(a.cfg.AdminKey())
-	return ss
+	return a.sign(a.cfg.AdminKey())
 }
 
 func (a *authenticationApiKey) SignToken() string {
+	return a.sign(a.cfg.ApiKey())
+}
+
+// sign : มี private key ใน config = sign ด้วย key นั้น (RS256/EdDSA) พร้อม kid ใน header
+// ไม่มี = HS256 ด้วย secret ของ token แต่ละประเภทแบบเดิม
+func (a *authentication) sign(secret []byte) string {
+	if keys, err := Keys(a.cfg); err == nil && keys.Signing != nil {
+		token := jwt.NewWithClaims(keys.Signing.Method, a.mapClaims)
+		token.Header["kid"] = keys.Signing.Id
+		ss, _ := token.SignedString(keys.Signing.Private)
+		return ss
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, a.mapClaims)
-	ss, _ := token.SignedString(a.cfg.ApiKey())
+	ss, _ := token.SignedString(secret) // sign token
 	return ss
 }
 
-func ParseToken(cfg config.IJwtConfig, tokenString string) (*authMapClaims, error) {
+// parseToken : token ที่ sign ด้วย key ต่างกันแยกประเภทด้วย subject เพราะ asymmetric key ใช้ key เดียวกันทุกประเภท
+// HS256 ยังใช้ได้ถ้ามี secret (ช่วงย้ายไปใช้ asymmetric key) ลบ secret ออกจาก config เมื่อ token เก่าหมดอายุแล้ว
+func parseToken(cfg config.IJwtConfig, tokenString string, secret []byte, subjects ...string) (*authMapClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &authMapClaims{}, func(t *jwt.Token) (interface{}, error) {
 		// check sign token method
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("signing method is invalid")
+		switch t.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if len(secret) == 0 {
+				return nil, fmt.Errorf("signing method is invalid")
+			}
+			return secret, nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+			keys, err := Keys(cfg)
+			if err != nil {
+				return nil, err
+			}
+			kid, _ := t.Header["kid"].(string)
+			key, ok := keys.Verify[kid]
+			if !ok {
+				return nil, fmt.Errorf("key id is invalid")
+			}
+			if key.Method.Alg() != t.Method.Alg() {
+				return nil, fmt.Errorf("signing method is invalid")
+			}
+			return key.Public, nil
 		}
-		return cfg.SecretKey(), nil
-	})
+		return nil, fmt.Errorf("signing method is invalid")
+	}, jwt.WithValidMethods([]string{
+		jwt.SigningMethodHS256.Alg(),
+		jwt.SigningMethodRS256.Alg(),
+		jwt.SigningMethodEdDSA.Alg(),
+	}))
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenMalformed) {
 			return nil, fmt.Errorf("token format is invalid")
@@ -97,57 +139,54 @@ func ParseToken(cfg config.IJwtConfig, tokenString string) (*authMapClaims, erro
 			return nil, fmt.Errorf("parse token failed: %v", err)
 		}
 	}
-	if claims, ok := token.Claims.(*authMapClaims); ok {
-		return claims, nil
-	} else {
+
+	claims, ok := token.Claims.(*authMapClaims)
+	if !ok {
 		return nil, fmt.Errorf("claims type is invalid")
 	}
+	for _, subject := range subjects {
+		if claims.Subject == subject {
+			return claims, nil
+		}
+	}
+	return nil, fmt.Errorf("token type is invalid")
 }
 
-func ParseAdminToken(cfg config.IJwtConfig, tokenString string) (*authMapClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &authMapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("signing method is invalid")
-		}
-		return cfg.AdminKey(), nil
-	})
+func ParseToken(cfg config.IJwtConfig, tokenString string) (*authMapClaims, error) {
+	claims, err := parseToken(cfg, tokenString, cfg.SecretKey(), "access-token", "refresh-token")
 	if err != nil {
-		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return nil, fmt.Errorf("token format is invalid")
-		} else if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, fmt.Errorf("token had expired")
-		} else {
-			return nil, fmt.Errorf("parse token failed: %v", err)
-		}
+		return nil, err
 	}
-	if claims, ok := token.Claims.(*authMapClaims); ok {
-		return claims, nil
-	} else {
+	if claims.Claims == nil {
 		return nil, fmt.Errorf("claims type is invalid")
 	}
+	return claims, nil
 }
 
-func ParseApiKey(cfg config.IJwtConfig, tokenString string) (*authMapClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &authMapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("signing method is invalid")
-		}
-		return cfg.ApiKey(), nil
-	})
+// ParseChallengeToken : challenge token ใช้แทน access token ไม่ได้ (ParseToken ไม่รับ subject นี้)
+func ParseChallengeToken(cfg config.IJwtConfig, tokenString string) (*authMapClaims, error) {
+	claims, err := parseToken(cfg, tokenString, cfg.SecretKey(), "challenge-token")
 	if err != nil {
-		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return nil, fmt.Errorf("token format is invalid")
-		} else if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, fmt.Errorf("token had expired")
-		} else {
-			return nil, fmt.Errorf("parse token failed: %v", err)
-		}
+		return nil, err
 	}
-	if claims, ok := token.Claims.(*authMapClaims); ok {
-		return claims, nil
-	} else {
+	if claims.Claims == nil {
 		return nil, fmt.Errorf("claims type is invalid")
 	}
+	return claims, nil
+}
+
+func ParseAdminToken(cfg config.IJwtConfig, tokenString string) (*authMapClaims, error) {
+	return parseToken(cfg, tokenString, cfg.AdminKey(), "admin-token")
+}
+
+func ParseApiKey(cfg config.IJwtConfig, tokenString string) (*authMapClaims, error) {
+	return parseToken(cfg, tokenString, cfg.ApiKey(), "api-token")
+}
+
+// HashToken : token ที่เก็บใน database (sha-256 hex) jwt มี entropy สูงอยู่แล้วจึงไม่ต้องใช้ salt
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func RepeatToken(cfg config.IJwtConfig, claims *users.UserClaims, exp int64) string {
@@ -157,6 +196,7 @@ func RepeatToken(cfg config.IJwtConfig, claims *users.UserClaims, exp int64) str
 			Claims: claims,
 			RegisteredClaims: jwt.RegisteredClaims{
 				Issuer:    "application-api",
+				ID:        uuid.NewString(), // refresh token ใช้ได้ครั้งเดียว token ที่ rotate ต้องไม่ซ้ำกัน
 				Subject:   "refresh-token",
 				Audience:  []string{"customer", "admin"},
 				ExpiresAt: jwtTimeRepeatAdapter(exp),
@@ -178,6 +218,8 @@ func NewAuthentication(tokenType TokenType, cfg config.IJwtConfig, claims *users
 		return newAdminToken(cfg), nil
 	case ApiKey:
 		return newApiKey(cfg), nil
+	case Challenge:
+		return newChallengeToken(cfg, claims), nil
 	default:
 		return nil, fmt.Errorf("unknow token type")
 	}
@@ -200,6 +242,24 @@ func newAccessToken(cfg config.IJwtConfig, claims *users.UserClaims) IAuthentica
 	}
 }
 
+func newChallengeToken(cfg config.IJwtConfig, claims *users.UserClaims) IAuthentication {
+	return &authentication{
+		cfg: cfg,
+		mapClaims: &authMapClaims{
+			Claims: claims,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "application-api",
+				ID:        uuid.NewString(), // challenge ใช้ได้ครั้งเดียว token ของแต่ละ sign in ต้องไม่ซ้ำกัน
+				Subject:   "challenge-token",
+				Audience:  []string{"customer", "admin"},
+				ExpiresAt: jwtTimeDurationCal(ChallengeExpires),
+				NotBefore: jwt.NewNumericDate(time.Now()),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+			},
+		},
+	}
+}
+
 func newRefreshToken(cfg config.IJwtConfig, claims *users.UserClaims) IAuthentication {
 	return &authentication{
 		cfg: cfg,
@@ -207,6 +267,7 @@ func newRefreshToken(cfg config.IJwtConfig, claims *users.UserClaims) IAuthentic
 			Claims: claims,
 			RegisteredClaims: jwt.RegisteredClaims{
 				Issuer:    "application-api",
+				ID:        uuid.NewString(), // refresh token ใช้ได้ครั้งเดียว token ที่ rotate ต้องไม่ซ้ำกัน
 				Subject:   "refresh-token",
 				Audience:  []string{"customer", "admin"},
 				ExpiresAt: jwtTimeDurationCal(cfg.RefreshExpireAt()),
@@ -0,0 +1,296 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key : key แบบ asymmetric kid คือ JWK thumbprint (RFC 7638) ของ public key
+type Key struct {
+	Id      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey // nil = ใช้ verify อย่างเดียว
+	Public  crypto.PublicKey
+}
+
+type KeySet struct {
+	Signing *Key // nil = sign ด้วย HS256
+	Verify  map[string]*Key
+}
+
+type Jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type Jwks struct {
+	Keys []*Jwk `json:"keys"`
+}
+
+// keySets : parse PEM ครั้งเดียวต่อ config
+var keySets sync.Map
+
+// Keys : key set จาก config ที่ parse ไว้แล้ว
+func Keys(cfg config.IJwtConfig) (*KeySet, error) {
+	if keys, ok := keySets.Load(cfg); ok {
+		return keys.(*KeySet), nil
+	}
+	keys, err := ParseKeySet(cfg.PrivateKey(), cfg.PublicKeys())
+	if err != nil {
+		return nil, err
+	}
+	keySets.Store(cfg, keys)
+	return keys, nil
+}
+
+func ParseKeySet(privateKey []byte, publicKeys [][]byte) (*KeySet, error) {
+	keys := &KeySet{
+		Verify: make(map[string]*Key),
+	}
+
+	if len(privateKey) != 0 {
+		key, err := parsePrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		keys.Signing = key
+		keys.Verify[key.Id] = key
+	}
+	for _, publicKey := range publicKeys {
+		key, err := parsePublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		keys.Verify[key.Id] = key
+	}
+	return keys, nil
+}
+
+// parsePrivateKey : รองรับ PKCS#8 (RSA, Ed25519) และ PKCS#1 (RSA)
+func parsePrivateKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key is not pem")
+	}
+
+	var private any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key failed: %v", err)
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		key, err := newKey(&private.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		key.Private = private
+		return key, nil
+	case ed25519.PrivateKey:
+		key, err := newKey(private.Public())
+		if err != nil {
+			return nil, err
+		}
+		key.Private = private
+		return key, nil
+	}
+	return nil, fmt.Errorf("private key type is not supported")
+}
+
+func parsePublicKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key is not pem")
+	}
+
+	var public any
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse public key failed: %v", err)
+	}
+	return newKey(public)
+}
+
+func newKey(public crypto.PublicKey) (*Key, error) {
+	key := &Key{Public: public}
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("rsa key must be at least 2048 bits")
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("public key type is not supported")
+	}
+	key.Id = Thumbprint(key.Jwk())
+	return key, nil
+}
+
+func (k *Key) Jwk() *Jwk {
+	jwk := &Jwk{
+		Use: "sig",
+		Alg: k.Method.Alg(),
+		Kid: k.Id,
+	}
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// Jwks : public key ทั้งหมดที่ใช้ verify ให้ service อื่น verify token ได้โดยไม่ต้องรู้ secret
+func (s *KeySet) Jwks() *Jwks {
+	jwks := &Jwks{
+		Keys: make([]*Jwk, 0),
+	}
+	// key ที่ใช้ sign อยู่ตอนนี้ขึ้นก่อน
+	if s.Signing != nil {
+		jwks.Keys = append(jwks.Keys, s.Signing.Jwk())
+	}
+	ids := make([]string, 0, len(s.Verify))
+	for id := range s.Verify {
+		if s.Signing != nil && id == s.Signing.Id {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		jwks.Keys = append(jwks.Keys, s.Verify[id].Jwk())
+	}
+	return jwks
+}
+
+// Thumbprint : RFC 7638 sha-256 ของ member ที่จำเป็นของ jwk เรียงตามตัวอักษร
+func Thumbprint(jwk *Jwk) string {
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = &struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = &struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	case "EC":
+		members = &struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	}
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PublicKey : public key จาก jwk ของ provider ภายนอก (RSA, EC P-256, Ed25519)
+func (j *Jwk) PublicKey() (crypto.PublicKey, error) {
+	decode := func(s string) ([]byte, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("jwk is invalid")
+		}
+		return b, nil
+	}
+
+	switch j.Kty {
+	case "RSA":
+		n, err := decode(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(e) > 4 {
+			return nil, fmt.Errorf("jwk is invalid")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("jwk curve is not supported")
+		}
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		public := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !public.Curve.IsOnCurve(public.X, public.Y) {
+			return nil, fmt.Errorf("jwk is invalid")
+		}
+		return public, nil
+	case "OKP":
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwk curve is not supported")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("jwk type is not supported")
+}
@@ -0,0 +1,8 @@
+BEGIN;
+-- Drop table
+DROP TABLE IF EXISTS "stock_movements" CASCADE;
+-- Drop type
+DROP TYPE IF EXISTS "stock_movement_type";
+-- Drop column
+ALTER TABLE "products" DROP COLUMN IF EXISTS "stock";
+COMMIT;
@@ -0,0 +1,30 @@
+BEGIN;
+-- Stock on hand : ห้ามติดลบ เพื่อกัน oversell ระดับ database
+ALTER TABLE "products"
+ADD COLUMN "stock" INT NOT NULL DEFAULT 0 CHECK ("stock" >= 0);
+-- Create enum
+CREATE TYPE "stock_movement_type" AS ENUM (
+    'order',
+    'cancel',
+    'adjust'
+);
+-- Create table
+CREATE TABLE "stock_movements" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "product_id" VARCHAR NOT NULL,
+    "order_id" VARCHAR,
+    "user_id" VARCHAR,
+    "type" stock_movement_type NOT NULL,
+    "qty" INT NOT NULL,
+    "balance" INT NOT NULL,
+    "note" VARCHAR NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE "stock_movements"
+ADD FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON DELETE CASCADE;
+ALTER TABLE "stock_movements"
+ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE SET NULL;
+ALTER TABLE "stock_movements"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE SET NULL;
+CREATE INDEX "stock_movements_product_id_idx" ON "stock_movements" ("product_id", "created_at");
+COMMIT;
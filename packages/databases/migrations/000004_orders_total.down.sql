@@ -0,0 +1,8 @@
+BEGIN;
+-- Drop column
+ALTER TABLE "orders"
+DROP COLUMN IF EXISTS "subtotal",
+DROP COLUMN IF EXISTS "discount",
+DROP COLUMN IF EXISTS "shipping_fee",
+DROP COLUMN IF EXISTS "total_paid";
+COMMIT;
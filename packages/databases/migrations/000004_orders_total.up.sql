@@ -0,0 +1,21 @@
+BEGIN;
+-- เก็บยอดเงินไว้ใน order ตอนสั่งซื้อ ราคา product เปลี่ยนทีหลังยอดจะไม่เปลี่ยนตาม
+ALTER TABLE "orders"
+ADD COLUMN "subtotal" FLOAT NOT NULL DEFAULT 0.0,
+ADD COLUMN "discount" FLOAT NOT NULL DEFAULT 0.0,
+ADD COLUMN "shipping_fee" FLOAT NOT NULL DEFAULT 0.0,
+ADD COLUMN "total_paid" FLOAT NOT NULL DEFAULT 0.0;
+-- Backfill existing orders from product snapshot
+UPDATE "orders" "o"
+SET "subtotal" = "t"."subtotal",
+    "total_paid" = "t"."subtotal"
+FROM (
+        SELECT "po"."order_id",
+            SUM(
+                COALESCE(("po"."product"->>'price')::FLOAT, 0) * "po"."qty"
+            ) AS "subtotal"
+        FROM "products_orders" "po"
+        GROUP BY "po"."order_id"
+    ) AS "t"
+WHERE "t"."order_id" = "o"."id";
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+-- Drop table
+DROP TABLE IF EXISTS "order_status_history" CASCADE;
+COMMIT;
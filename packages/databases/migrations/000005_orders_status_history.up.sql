@@ -0,0 +1,26 @@
+BEGIN;
+-- Create table
+CREATE TABLE "order_status_history" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "order_id" VARCHAR NOT NULL,
+    "from_status" order_status,
+    "to_status" order_status NOT NULL,
+    "changed_by" VARCHAR,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE "order_status_history"
+ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "order_status_history"
+ADD FOREIGN KEY ("changed_by") REFERENCES "users" ("id") ON DELETE SET NULL;
+CREATE INDEX "order_status_history_order_id_idx" ON "order_status_history" ("order_id", "created_at");
+-- Backfill : status ปัจจุบันของ order ที่มีอยู่แล้ว ไม่รู้ว่าใครเปลี่ยน changed_by จึงเป็น NULL
+INSERT INTO "order_status_history" (
+        "order_id",
+        "to_status",
+        "created_at"
+    )
+SELECT "o"."id",
+    "o"."status",
+    "o"."created_at"
+FROM "orders" "o";
+COMMIT;
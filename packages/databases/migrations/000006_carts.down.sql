@@ -0,0 +1,6 @@
+BEGIN;
+-- Drop trigger
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_carts_items_table ON "carts_items";
+-- Drop table
+DROP TABLE IF EXISTS "carts_items" CASCADE;
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+-- Create table
+CREATE TABLE "carts_items" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "user_id" VARCHAR NOT NULL,
+    "product_id" VARCHAR NOT NULL,
+    "qty" INT NOT NULL DEFAULT 1 CHECK ("qty" > 0),
+    "price" FLOAT NOT NULL DEFAULT 0.0,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE ("user_id", "product_id")
+);
+ALTER TABLE "carts_items"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "carts_items"
+ADD FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON DELETE CASCADE;
+-- Create trigger
+CREATE TRIGGER set_updated_at_timestamp_carts_items_table BEFORE
+UPDATE ON "carts_items" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+COMMIT;
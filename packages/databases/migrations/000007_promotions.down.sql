@@ -0,0 +1,15 @@
+BEGIN;
+-- Drop trigger
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_promotions_table ON "promotions";
+-- Drop column
+ALTER TABLE "orders"
+DROP COLUMN IF EXISTS "promotion_id",
+DROP COLUMN IF EXISTS "coupon_code";
+-- Drop table
+DROP TABLE IF EXISTS "promotions_usages" CASCADE;
+DROP TABLE IF EXISTS "promotions_products" CASCADE;
+DROP TABLE IF EXISTS "promotions_categories" CASCADE;
+DROP TABLE IF EXISTS "promotions" CASCADE;
+-- Drop type
+DROP TYPE IF EXISTS "promotion_type";
+COMMIT;
@@ -0,0 +1,67 @@
+BEGIN;
+-- Create enum
+CREATE TYPE "promotion_type" AS ENUM (
+    'percentage',
+    'fixed'
+);
+-- Create table
+CREATE TABLE "promotions" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "code" VARCHAR NOT NULL UNIQUE,
+    "title" VARCHAR NOT NULL DEFAULT '',
+    "type" promotion_type NOT NULL,
+    "value" FLOAT NOT NULL CHECK ("value" > 0),
+    "min_spend" FLOAT NOT NULL DEFAULT 0.0,
+    "max_discount" FLOAT NOT NULL DEFAULT 0.0,
+    "usage_limit" INT NOT NULL DEFAULT 0,
+    "usage_limit_per_user" INT NOT NULL DEFAULT 0,
+    "used_count" INT NOT NULL DEFAULT 0,
+    "starts_at" TIMESTAMP,
+    "ends_at" TIMESTAMP,
+    "is_active" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+-- max_discount, usage_limit, usage_limit_per_user : 0 = ไม่จำกัด
+CREATE TABLE "promotions_categories" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "promotion_id" VARCHAR NOT NULL,
+    "category_id" INT NOT NULL
+);
+CREATE TABLE "promotions_products" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "promotion_id" VARCHAR NOT NULL,
+    "product_id" VARCHAR NOT NULL
+);
+CREATE TABLE "promotions_usages" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "promotion_id" VARCHAR NOT NULL,
+    "user_id" VARCHAR,
+    "order_id" VARCHAR NOT NULL,
+    "discount" FLOAT NOT NULL DEFAULT 0.0,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE "orders"
+ADD COLUMN "promotion_id" VARCHAR,
+ADD COLUMN "coupon_code" VARCHAR;
+ALTER TABLE "promotions_categories"
+ADD FOREIGN KEY ("promotion_id") REFERENCES "promotions" ("id") ON DELETE CASCADE;
+ALTER TABLE "promotions_categories"
+ADD FOREIGN KEY ("category_id") REFERENCES "categories" ("id") ON DELETE CASCADE;
+ALTER TABLE "promotions_products"
+ADD FOREIGN KEY ("promotion_id") REFERENCES "promotions" ("id") ON DELETE CASCADE;
+ALTER TABLE "promotions_products"
+ADD FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON DELETE CASCADE;
+ALTER TABLE "promotions_usages"
+ADD FOREIGN KEY ("promotion_id") REFERENCES "promotions" ("id") ON DELETE CASCADE;
+ALTER TABLE "promotions_usages"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE SET NULL;
+ALTER TABLE "promotions_usages"
+ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "orders"
+ADD FOREIGN KEY ("promotion_id") REFERENCES "promotions" ("id") ON DELETE SET NULL;
+CREATE INDEX "promotions_usages_promotion_id_user_id_idx" ON "promotions_usages" ("promotion_id", "user_id");
+-- Create trigger
+CREATE TRIGGER set_updated_at_timestamp_promotions_table BEFORE
+UPDATE ON "promotions" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+COMMIT;
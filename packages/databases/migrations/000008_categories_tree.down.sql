@@ -0,0 +1,6 @@
+BEGIN;
+-- Alter table
+ALTER TABLE "products_categories" DROP CONSTRAINT IF EXISTS "products_categories_product_id_category_id_key";
+DROP INDEX IF EXISTS "categories_parent_id_idx";
+ALTER TABLE "categories" DROP COLUMN IF EXISTS "parent_id";
+COMMIT;
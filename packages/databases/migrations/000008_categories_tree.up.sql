@@ -0,0 +1,15 @@
+BEGIN;
+-- Alter table
+ALTER TABLE "categories"
+ADD COLUMN "parent_id" INT;
+ALTER TABLE "categories"
+ADD FOREIGN KEY ("parent_id") REFERENCES "categories" ("id") ON DELETE CASCADE;
+CREATE INDEX "categories_parent_id_idx" ON "categories" ("parent_id");
+-- product หนึ่งตัวอยู่ได้หลาย category แต่ไม่ซ้ำ category เดิม
+DELETE FROM "products_categories" "a" USING "products_categories" "b"
+WHERE "a"."product_id" = "b"."product_id"
+AND "a"."category_id" = "b"."category_id"
+AND "a"."id" > "b"."id";
+ALTER TABLE "products_categories"
+ADD CONSTRAINT "products_categories_product_id_category_id_key" UNIQUE ("product_id", "category_id");
+COMMIT;
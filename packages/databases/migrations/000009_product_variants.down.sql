@@ -0,0 +1,16 @@
+BEGIN;
+-- Drop trigger
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_product_variants_table ON "product_variants";
+-- Alter table
+DROP INDEX IF EXISTS "carts_items_user_id_product_id_variant_id_key";
+DELETE FROM "carts_items" WHERE "variant_id" IS NOT NULL;
+ALTER TABLE "carts_items" DROP COLUMN IF EXISTS "variant_id";
+ALTER TABLE "carts_items"
+ADD CONSTRAINT "carts_items_user_id_product_id_key" UNIQUE ("user_id", "product_id");
+ALTER TABLE "stock_movements" DROP COLUMN IF EXISTS "variant_id";
+ALTER TABLE "products_orders" DROP COLUMN IF EXISTS "variant_id";
+DELETE FROM "images" WHERE "variant_id" IS NOT NULL;
+ALTER TABLE "images" DROP COLUMN IF EXISTS "variant_id";
+-- Drop table
+DROP TABLE IF EXISTS "product_variants" CASCADE;
+COMMIT;
@@ -0,0 +1,40 @@
+BEGIN;
+-- Create table
+CREATE TABLE "product_variants" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "product_id" VARCHAR NOT NULL,
+    "sku" VARCHAR NOT NULL UNIQUE,
+    "options" jsonb NOT NULL DEFAULT '{}'::jsonb,
+    "price" FLOAT,
+    "stock" INT NOT NULL DEFAULT 0 CHECK ("stock" >= 0),
+    "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+-- price : NULL = ใช้ราคาของ product
+ALTER TABLE "product_variants"
+ADD FOREIGN KEY ("product_id") REFERENCES "products" ("id") ON DELETE CASCADE;
+CREATE INDEX "product_variants_product_id_idx" ON "product_variants" ("product_id");
+-- Alter table
+ALTER TABLE "images"
+ADD COLUMN "variant_id" VARCHAR;
+ALTER TABLE "images"
+ADD FOREIGN KEY ("variant_id") REFERENCES "product_variants" ("id") ON DELETE CASCADE;
+ALTER TABLE "products_orders"
+ADD COLUMN "variant_id" VARCHAR;
+ALTER TABLE "products_orders"
+ADD FOREIGN KEY ("variant_id") REFERENCES "product_variants" ("id") ON DELETE SET NULL;
+ALTER TABLE "stock_movements"
+ADD COLUMN "variant_id" VARCHAR;
+ALTER TABLE "stock_movements"
+ADD FOREIGN KEY ("variant_id") REFERENCES "product_variants" ("id") ON DELETE CASCADE;
+-- cart แยก item ตาม variant
+ALTER TABLE "carts_items"
+ADD COLUMN "variant_id" VARCHAR;
+ALTER TABLE "carts_items"
+ADD FOREIGN KEY ("variant_id") REFERENCES "product_variants" ("id") ON DELETE CASCADE;
+ALTER TABLE "carts_items" DROP CONSTRAINT IF EXISTS "carts_items_user_id_product_id_key";
+CREATE UNIQUE INDEX "carts_items_user_id_product_id_variant_id_key" ON "carts_items" ("user_id", "product_id", COALESCE("variant_id", ''));
+-- Create trigger
+CREATE TRIGGER set_updated_at_timestamp_product_variants_table BEFORE
+UPDATE ON "product_variants" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+COMMIT;
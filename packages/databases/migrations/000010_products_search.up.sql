@@ -0,0 +1,9 @@
+BEGIN;
+-- Full-text search : ใช้ config 'simple' เพราะมีทั้งภาษาไทยและอังกฤษ (ไม่ตัด stem)
+ALTER TABLE "products"
+ADD COLUMN "search_vector" tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', COALESCE("title", '')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE("description", '')), 'B')
+) STORED;
+CREATE INDEX "products_search_vector_idx" ON "products" USING GIN ("search_vector");
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+-- url ของรูปแต่ละขนาด eg. {"thumbnail": "...", "medium": "...", "large": "..."}
+ALTER TABLE "images" ADD COLUMN "renditions" jsonb NOT NULL DEFAULT '{}';
+COMMIT;
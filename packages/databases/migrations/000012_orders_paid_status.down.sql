@@ -0,0 +1,19 @@
+BEGIN;
+-- postgres ลบค่าออกจาก enum ไม่ได้ ต้องสร้าง type ใหม่แล้วย้าย column ไปใช้
+UPDATE "orders" SET "status" = 'waiting' WHERE "status" = 'paid';
+DELETE FROM "order_status_history" WHERE "to_status" = 'paid';
+UPDATE "order_status_history" SET "from_status" = 'waiting' WHERE "from_status" = 'paid';
+ALTER TYPE "order_status" RENAME TO "order_status_old";
+CREATE TYPE "order_status" AS ENUM (
+    'waiting',
+    'shipping',
+    'completed',
+    'canceled'
+);
+ALTER TABLE "orders"
+ALTER COLUMN "status" TYPE order_status USING "status"::TEXT::order_status;
+ALTER TABLE "order_status_history"
+ALTER COLUMN "from_status" TYPE order_status USING "from_status"::TEXT::order_status,
+ALTER COLUMN "to_status" TYPE order_status USING "to_status"::TEXT::order_status;
+DROP TYPE "order_status_old";
+COMMIT;
@@ -0,0 +1,3 @@
+-- ALTER TYPE ... ADD VALUE ใช้ใน transaction block ไม่ได้ (postgres < 12) จึงไม่มี BEGIN/COMMIT
+-- paid : จ่ายเงินผ่าน payment gateway แล้ว รอ admin จัดส่ง
+ALTER TYPE "order_status" ADD VALUE IF NOT EXISTS 'paid' AFTER 'waiting';
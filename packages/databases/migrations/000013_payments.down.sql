@@ -0,0 +1,9 @@
+BEGIN;
+-- Drop trigger
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_payments_table ON "payments";
+-- Drop table
+DROP TABLE IF EXISTS "payment_events" CASCADE;
+DROP TABLE IF EXISTS "payments" CASCADE;
+-- Drop type
+DROP TYPE IF EXISTS "payment_status";
+COMMIT;
@@ -0,0 +1,47 @@
+BEGIN;
+-- Create enum
+CREATE TYPE "payment_status" AS ENUM (
+    'pending',
+    'succeeded',
+    'failed',
+    'canceled'
+);
+-- Create table
+CREATE TABLE "payments" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "order_id" VARCHAR NOT NULL,
+    "user_id" VARCHAR NOT NULL,
+    "provider" VARCHAR NOT NULL,
+    "status" payment_status NOT NULL DEFAULT 'pending',
+    "amount" FLOAT NOT NULL CHECK ("amount" > 0),
+    "currency" VARCHAR NOT NULL DEFAULT 'THB',
+    "provider_ref" VARCHAR,
+    "qr_payload" VARCHAR,
+    "redirect_url" VARCHAR,
+    "paid_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+-- event ที่ได้รับจาก webhook เก็บ id ไว้กันการประมวลผลซ้ำ (provider ส่ง event เดิมซ้ำได้)
+CREATE TABLE "payment_events" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "provider" VARCHAR NOT NULL,
+    "event_id" VARCHAR NOT NULL,
+    "type" VARCHAR NOT NULL,
+    "payment_id" VARCHAR,
+    "payload" jsonb NOT NULL DEFAULT '{}',
+    "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+    UNIQUE ("provider", "event_id")
+);
+ALTER TABLE "payments"
+ADD FOREIGN KEY ("order_id") REFERENCES "orders" ("id") ON DELETE CASCADE;
+ALTER TABLE "payments"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "payment_events"
+ADD FOREIGN KEY ("payment_id") REFERENCES "payments" ("id") ON DELETE SET NULL;
+CREATE INDEX "payments_order_id_idx" ON "payments" ("order_id", "created_at");
+CREATE UNIQUE INDEX "payments_provider_ref_idx" ON "payments" ("provider", "provider_ref");
+-- Create trigger
+CREATE TRIGGER set_updated_at_timestamp_payments_table BEFORE
+UPDATE ON "payments" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+DROP INDEX IF EXISTS "oauth_user_id_idx";
+ALTER TABLE "oauth"
+DROP COLUMN IF EXISTS "device",
+DROP COLUMN IF EXISTS "ip",
+DROP COLUMN IF EXISTS "last_used_at",
+DROP COLUMN IF EXISTS "expires_at";
+COMMIT;
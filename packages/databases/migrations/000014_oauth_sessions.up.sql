@@ -0,0 +1,9 @@
+BEGIN;
+-- oauth 1 row = 1 session (family ของ refresh token ที่ rotate ต่อกันมาจากการ sign in ครั้งเดียว)
+ALTER TABLE "oauth"
+ADD COLUMN "device" VARCHAR NOT NULL DEFAULT '',
+ADD COLUMN "ip" VARCHAR NOT NULL DEFAULT '',
+ADD COLUMN "last_used_at" TIMESTAMP NOT NULL DEFAULT now(),
+ADD COLUMN "expires_at" TIMESTAMP;
+CREATE INDEX "oauth_user_id_idx" ON "oauth" ("user_id");
+COMMIT;
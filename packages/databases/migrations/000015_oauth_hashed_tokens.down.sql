@@ -0,0 +1,6 @@
+BEGIN;
+DROP INDEX IF EXISTS "oauth_access_token_idx";
+DROP INDEX IF EXISTS "oauth_refresh_token_idx";
+-- แปลง hash กลับเป็น token ไม่ได้ ทุก session ต้อง sign in ใหม่
+DELETE FROM "oauth";
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+-- เก็บ token เป็น sha-256 (hex) แทน jwt ตัวจริง database หลุดก็เอาไปใช้ต่อไม่ได้
+UPDATE "oauth" SET
+    "access_token" = encode(sha256(convert_to("access_token", 'UTF8')), 'hex'),
+    "refresh_token" = encode(sha256(convert_to("refresh_token", 'UTF8')), 'hex');
+CREATE INDEX "oauth_access_token_idx" ON "oauth" ("access_token");
+CREATE INDEX "oauth_refresh_token_idx" ON "oauth" ("refresh_token");
+COMMIT;
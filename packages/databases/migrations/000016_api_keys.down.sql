@@ -0,0 +1,6 @@
+BEGIN;
+-- Drop trigger
+DROP TRIGGER IF EXISTS set_updated_at_timestamp_api_keys_table ON "api_keys";
+-- Drop table
+DROP TABLE IF EXISTS "api_keys" CASCADE;
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+-- Create table
+-- เก็บเฉพาะ sha-256 ของ key ตัว key จริงแสดงครั้งเดียวตอนสร้าง prefix ใช้ให้ admin ดูว่าเป็น key ไหน
+CREATE TABLE "api_keys" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "name" VARCHAR NOT NULL,
+    "owner_id" VARCHAR,
+    "prefix" VARCHAR NOT NULL,
+    "key_hash" VARCHAR NOT NULL UNIQUE,
+    "scopes" VARCHAR[] NOT NULL DEFAULT '{}',
+    "last_used_at" TIMESTAMP,
+    "revoked_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE "api_keys"
+ADD FOREIGN KEY ("owner_id") REFERENCES "users" ("id") ON DELETE SET NULL;
+CREATE INDEX "api_keys_owner_id_idx" ON "api_keys" ("owner_id");
+-- Create trigger
+CREATE TRIGGER set_updated_at_timestamp_api_keys_table BEFORE
+UPDATE ON "api_keys" FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+COMMIT;
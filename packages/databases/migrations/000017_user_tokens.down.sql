@@ -0,0 +1,8 @@
+BEGIN;
+-- Drop table
+DROP TABLE IF EXISTS "user_tokens" CASCADE;
+-- Drop type
+DROP TYPE IF EXISTS "user_token_type";
+ALTER TABLE "users"
+DROP COLUMN IF EXISTS "email_verified_at";
+COMMIT;
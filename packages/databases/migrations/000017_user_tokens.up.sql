@@ -0,0 +1,25 @@
+BEGIN;
+-- user ที่มีอยู่แล้วถือว่ายืนยัน email แล้ว
+ALTER TABLE "users"
+ADD COLUMN "email_verified_at" TIMESTAMP;
+UPDATE "users" SET "email_verified_at" = "created_at";
+-- Create enum
+CREATE TYPE "user_token_type" AS ENUM (
+    'verify_email',
+    'reset_password'
+);
+-- Create table
+-- token ที่ส่งทาง email ใช้ได้ครั้งเดียว เก็บเฉพาะ sha-256
+CREATE TABLE "user_tokens" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "user_id" VARCHAR NOT NULL,
+    "type" user_token_type NOT NULL,
+    "token_hash" VARCHAR NOT NULL UNIQUE,
+    "expires_at" TIMESTAMP NOT NULL,
+    "used_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE "user_tokens"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+CREATE INDEX "user_tokens_user_id_idx" ON "user_tokens" ("user_id", "type");
+COMMIT;
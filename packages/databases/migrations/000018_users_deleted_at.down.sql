@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE "users"
+DROP COLUMN IF EXISTS "deleted_at";
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+-- user ที่ลบบัญชีแล้วไม่ลบ row เพราะ orders อ้างถึงอยู่ ข้อมูลส่วนตัวถูกล้างตอนลบ
+ALTER TABLE "users"
+ADD COLUMN "deleted_at" TIMESTAMP;
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+-- Drop table
+DROP TABLE IF EXISTS "users_recovery_codes" CASCADE;
+ALTER TABLE "users"
+DROP COLUMN IF EXISTS "totp_secret",
+DROP COLUMN IF EXISTS "totp_enabled_at",
+DROP COLUMN IF EXISTS "totp_last_step";
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+-- totp_secret ที่ totp_enabled_at ยังเป็น NULL = อยู่ระหว่างตั้งค่า (ยังไม่ได้ยืนยัน code แรก)
+-- totp_last_step : step ของ code ล่าสุดที่ใช้ไปแล้ว กันการใช้ code เดิมซ้ำ
+ALTER TABLE "users"
+ADD COLUMN "totp_secret" VARCHAR,
+ADD COLUMN "totp_enabled_at" TIMESTAMP,
+ADD COLUMN "totp_last_step" BIGINT NOT NULL DEFAULT 0;
+-- Create table
+-- recovery code ใช้แทน TOTP ได้ครั้งเดียว เก็บเฉพาะ sha-256
+CREATE TABLE "users_recovery_codes" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "user_id" VARCHAR NOT NULL,
+    "code_hash" VARCHAR NOT NULL,
+    "used_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE "users_recovery_codes"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+CREATE INDEX "users_recovery_codes_user_id_idx" ON "users_recovery_codes" ("user_id");
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+-- Drop table
+DROP TABLE IF EXISTS "users_signin_failures" CASCADE;
+DROP TABLE IF EXISTS "signin_attempts" CASCADE;
+COMMIT;
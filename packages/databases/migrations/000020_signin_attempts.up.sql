@@ -0,0 +1,24 @@
+BEGIN;
+-- Create table
+-- จำนวนครั้งที่ sign in ผิดต่อ key (account:<email>, ip:<ip>) ของ lockout driver postgres
+CREATE TABLE "signin_attempts" (
+    "key" VARCHAR NOT NULL UNIQUE PRIMARY KEY,
+    "failures" INT NOT NULL DEFAULT 0,
+    "last_failed_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+    "locked_until" TIMESTAMPTZ
+);
+CREATE INDEX "signin_attempts_last_failed_at_idx" ON "signin_attempts" ("last_failed_at");
+-- audit ของ sign in ที่ไม่สำเร็จ user_id เป็น NULL เมื่อ email ไม่ตรงกับ user
+CREATE TABLE "users_signin_failures" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "user_id" VARCHAR,
+    "email" VARCHAR NOT NULL,
+    "ip" VARCHAR NOT NULL DEFAULT '',
+    "device" VARCHAR NOT NULL DEFAULT '',
+    "reason" VARCHAR NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE "users_signin_failures"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+CREATE INDEX "users_signin_failures_user_id_idx" ON "users_signin_failures" ("user_id", "created_at");
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+-- Drop table
+DROP TABLE IF EXISTS "user_oauth_states" CASCADE;
+DROP TABLE IF EXISTS "user_identities" CASCADE;
+COMMIT;
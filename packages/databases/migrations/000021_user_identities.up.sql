@@ -0,0 +1,31 @@
+BEGIN;
+-- Create table
+-- บัญชีของ provider ภายนอก (subject = user id ฝั่ง provider) หนึ่ง user ผูกได้ provider ละบัญชี
+CREATE TABLE "user_identities" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "user_id" VARCHAR NOT NULL,
+    "provider" VARCHAR NOT NULL,
+    "subject" VARCHAR NOT NULL,
+    "email" VARCHAR NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP NOT NULL DEFAULT now(),
+    "last_used_at" TIMESTAMP NOT NULL DEFAULT now(),
+    CONSTRAINT "user_identities_provider_subject_key" UNIQUE ("provider", "subject"),
+    CONSTRAINT "user_identities_user_id_provider_key" UNIQUE ("user_id", "provider")
+);
+ALTER TABLE "user_identities"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+-- state ระหว่าง redirect ไป provider เก็บเฉพาะ sha-256 ของ state
+CREATE TABLE "user_oauth_states" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "state_hash" VARCHAR NOT NULL UNIQUE,
+    "provider" VARCHAR NOT NULL,
+    "code_verifier" VARCHAR NOT NULL,
+    "nonce" VARCHAR NOT NULL,
+    "user_id" VARCHAR,
+    "expires_at" TIMESTAMP NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE "user_oauth_states"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+CREATE INDEX "user_oauth_states_expires_at_idx" ON "user_oauth_states" ("expires_at");
+COMMIT;
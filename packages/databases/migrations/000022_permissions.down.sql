@@ -0,0 +1,5 @@
+BEGIN;
+-- Drop table
+DROP TABLE IF EXISTS "roles_permissions" CASCADE;
+DROP TABLE IF EXISTS "permissions" CASCADE;
+COMMIT;
@@ -0,0 +1,41 @@
+BEGIN;
+-- Create table
+-- permission ที่ route ต้องการ (ชื่อตรงกับ const ใน middlewares) role มีได้หลาย permission
+CREATE TABLE "permissions" (
+    "name" VARCHAR NOT NULL UNIQUE PRIMARY KEY,
+    "description" VARCHAR NOT NULL DEFAULT ''
+);
+CREATE TABLE "roles_permissions" (
+    "role_id" INT NOT NULL,
+    "permission" VARCHAR NOT NULL,
+    PRIMARY KEY ("role_id", "permission")
+);
+ALTER TABLE "roles_permissions"
+ADD FOREIGN KEY ("role_id") REFERENCES "roles" ("id") ON DELETE CASCADE;
+ALTER TABLE "roles_permissions"
+ADD FOREIGN KEY ("permission") REFERENCES "permissions" ("name") ON DELETE CASCADE;
+-- Insert data
+INSERT INTO "permissions" ("name", "description")
+VALUES ('users:read:any', 'View any user profile, sessions and sign-in failures'),
+    ('users:write:any', 'Update, delete and unlock any user'),
+    ('admins:write', 'Create admin accounts'),
+    ('roles:read', 'View roles and permissions'),
+    ('roles:write', 'Manage roles and assign them to users'),
+    ('apikeys:read', 'View api keys'),
+    ('apikeys:write', 'Create, update and revoke api keys'),
+    ('categories:write', 'Create and delete categories'),
+    ('products:write', 'Create, update and delete products and variants'),
+    ('stock:read', 'View stock movements'),
+    ('stock:write', 'Adjust stock'),
+    ('files:write', 'Upload and delete files in any directory'),
+    ('orders:read:any', 'View any order'),
+    ('orders:write:any', 'Create orders for and update any order'),
+    ('payments:read:any', 'View any payment'),
+    ('promotions:read', 'View promotions'),
+    ('promotions:write', 'Create, update and delete promotions');
+-- admin (id 2) มีทุก permission permission ที่เพิ่มทีหลังต้อง insert ให้ admin ด้วย
+INSERT INTO "roles_permissions" ("role_id", "permission")
+SELECT 2,
+    "name"
+FROM "permissions";
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+-- users.role_id เป็น ON DELETE CASCADE ต้องย้าย user กลับเป็น customer ก่อนลบ role
+UPDATE "users"
+SET "role_id" = 1
+WHERE "role_id" IN (
+        SELECT "id"
+        FROM "roles"
+        WHERE "title" IN ('warehouse', 'support', 'finance')
+    );
+DELETE FROM "roles"
+WHERE "title" IN ('warehouse', 'support', 'finance');
+DELETE FROM "permissions"
+WHERE "name" IN ('orders:fulfil', 'reports:export');
+COMMIT;
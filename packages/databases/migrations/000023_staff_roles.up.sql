@@ -0,0 +1,30 @@
+BEGIN;
+-- Insert data
+INSERT INTO "permissions" ("name", "description")
+VALUES ('orders:fulfil', 'Move paid orders to shipping and completed'),
+    ('reports:export', 'Export order reports');
+-- admin มีทุก permission
+INSERT INTO "roles_permissions" ("role_id", "permission")
+VALUES (2, 'orders:fulfil'),
+    (2, 'reports:export');
+-- role ของพนักงาน id เป็น SERIAL อ้างด้วย title
+INSERT INTO "roles" ("title")
+VALUES ('warehouse'),
+    ('support'),
+    ('finance') ON CONFLICT ("title") DO NOTHING;
+INSERT INTO "roles_permissions" ("role_id", "permission")
+SELECT "r"."id",
+    "p"."permission"
+FROM "roles" "r"
+    JOIN (
+        VALUES ('warehouse', 'orders:read:any'),
+            ('warehouse', 'orders:fulfil'),
+            ('warehouse', 'stock:read'),
+            ('warehouse', 'stock:write'),
+            ('support', 'orders:read:any'),
+            ('support', 'users:read:any'),
+            ('finance', 'orders:read:any'),
+            ('finance', 'payments:read:any'),
+            ('finance', 'reports:export')
+    ) AS "p" ("title", "permission") ON "p"."title" = "r"."title" ON CONFLICT DO NOTHING;
+COMMIT;
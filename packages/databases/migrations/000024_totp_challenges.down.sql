@@ -0,0 +1,4 @@
+BEGIN;
+-- Drop table
+DROP TABLE IF EXISTS "totp_challenges" CASCADE;
+COMMIT;
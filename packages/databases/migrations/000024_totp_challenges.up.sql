@@ -0,0 +1,17 @@
+BEGIN;
+-- Create table
+-- challenge token ที่ออกหลัง password ถูกต้อง ใช้แลก passport ได้ครั้งเดียว เก็บเฉพาะ sha-256
+-- ใส่ code ผิดครบจำนวนครั้ง challenge ใช้ไม่ได้อีก ต้อง sign in ใหม่
+CREATE TABLE "totp_challenges" (
+    "id" VARCHAR NOT NULL UNIQUE PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "user_id" VARCHAR NOT NULL,
+    "token_hash" VARCHAR NOT NULL UNIQUE,
+    "attempts" INT NOT NULL DEFAULT 0,
+    "expires_at" TIMESTAMP NOT NULL,
+    "used_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT now()
+);
+ALTER TABLE "totp_challenges"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+CREATE INDEX "totp_challenges_user_id_idx" ON "totp_challenges" ("user_id");
+COMMIT;
@@ -0,0 +1,205 @@
+// imaging : ตรวจสอบ แปลง และย่อขนาดรูปที่ upload (ใช้ standard library อย่างเดียว)
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+)
+
+const (
+	FormatJpeg = "jpeg"
+	FormatPng  = "png"
+
+	// กันรูปที่ decode แล้วใช้ memory มากเกินไป (decompression bomb)
+	maxPixels   = 40_000_000
+	jpegQuality = 85
+)
+
+type Rendition struct {
+	Name    string
+	MaxSize int // ความยาวด้านที่ยาวที่สุด (px)
+}
+
+// Renditions : ขนาดของรูปที่สร้างเพิ่มจากรูปต้นฉบับ
+var Renditions = []*Rendition{
+	{Name: "thumbnail", MaxSize: 200},
+	{Name: "medium", MaxSize: 600},
+	{Name: "large", MaxSize: 1200},
+}
+
+type Result struct {
+	Format      string
+	ContentType string
+	Original    []byte            // รูปต้นฉบับที่ encode ใหม่ (ไม่มี EXIF)
+	Renditions  map[string][]byte // key : ชื่อ rendition
+}
+
+// DetectFormat : ดู format จาก magic bytes ไม่เชื่อนามสกุลไฟล์
+func DetectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return FormatJpeg
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPng
+	}
+	return ""
+}
+
+// FormatOfExtension : jpg กับ jpeg เป็น format เดียวกัน
+func FormatOfExtension(ext string) string {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "jpg", "jpeg":
+		return FormatJpeg
+	case "png":
+		return FormatPng
+	}
+	return ""
+}
+
+// RenditionFileName : eg. abc.png -> abc_thumbnail.png
+func RenditionFileName(fileName, rendition string) string {
+	if i := strings.LastIndex(fileName, "."); i >= 0 {
+		return fileName[:i] + "_" + rendition + fileName[i:]
+	}
+	return fileName + "_" + rendition
+}
+
+// Process : decode รูปตาม format จริง หมุนตาม EXIF orientation แล้ว encode ใหม่พร้อม renditions
+// การ encode ใหม่จะตัด metadata ทั้งหมด (EXIF, GPS) ออกไปด้วย
+func Process(data []byte, ext string) (*Result, error) {
+	format := DetectFormat(data)
+	if format == "" {
+		return nil, fmt.Errorf("file is not a valid image")
+	}
+	if ext != "" && FormatOfExtension(ext) != format {
+		return nil, fmt.Errorf("file content does not match extension")
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("file is not a valid image")
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image is too large")
+	}
+
+	var img image.Image
+	switch format {
+	case FormatJpeg:
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case FormatPng:
+		img, err = png.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file is not a valid image")
+	}
+	if format == FormatJpeg {
+		img = applyOrientation(img, readOrientation(data))
+	}
+
+	res := &Result{
+		Format:      format,
+		ContentType: "image/" + format,
+		Renditions:  make(map[string][]byte),
+	}
+	if res.Original, err = encode(img, format); err != nil {
+		return nil, err
+	}
+	for _, r := range Renditions {
+		encoded, err := encode(Fit(img, r.MaxSize), format)
+		if err != nil {
+			return nil, err
+		}
+		res.Renditions[r.Name] = encoded
+	}
+	return res, nil
+}
+
+func encode(img image.Image, format string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	var err error
+	switch format {
+	case FormatJpeg:
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: jpegQuality})
+	case FormatPng:
+		err = png.Encode(buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode image failed: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Fit : ย่อรูปให้ด้านที่ยาวที่สุดไม่เกิน maxSize โดยคงสัดส่วน ไม่ขยายรูปที่เล็กกว่า
+func Fit(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxSize && h <= maxSize {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*maxSize/w)
+		w = maxSize
+	} else {
+		w = max(1, w*maxSize/h)
+		h = maxSize
+	}
+	return Resize(img, w, h)
+}
+
+// Resize : ย่อรูปด้วยการเฉลี่ยพื้นที่ (box filter) เหมาะกับการย่อ
+func Resize(img image.Image, width, height int) *image.RGBA {
+	src := toRGBA(img)
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcH / height
+		y1 := max(y0+1, (y+1)*srcH/height)
+		for x := 0; x < width; x++ {
+			x0 := x * srcW / width
+			x1 := max(x0+1, (x+1)*srcW/width)
+
+			// premultiplied alpha จึงเฉลี่ยทุก channel ได้ตรงๆ
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return dst
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
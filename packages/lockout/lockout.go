@@ -0,0 +1,136 @@
+// lockout : นับจำนวนครั้งที่ sign in ผิดต่อ key และ lock ชั่วคราวแบบ exponential backoff
+package lockout
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/jmoiron/sqlx"
+)
+
+// IStore : ที่เก็บจำนวนครั้งที่ผิด ใช้ร่วมกันได้หลาย ILockout (key ต่างกัน)
+type IStore interface {
+	Find(ctx context.Context, key string) (*Attempt, error)
+	// Fail : เพิ่มจำนวนครั้งที่ผิด นับใหม่เมื่อครั้งที่ผิดล่าสุดเก่ากว่า window คืนจำนวนครั้งที่ผิดหลังเพิ่ม
+	Fail(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	// Prune : ลบ key ที่ผิดครั้งล่าสุดก่อน before
+	Prune(ctx context.Context, before time.Time) error
+}
+
+type Attempt struct {
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  time.Time
+}
+
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+func NewStore(cfg config.IAuthConfig, db *sqlx.DB) IStore {
+	switch cfg.LockoutDriver() {
+	case DriverPostgres:
+		return PostgresStore(db)
+	case DriverMemory:
+		return MemoryStore()
+	}
+	log.Fatalf("lockout driver %q is not supported", cfg.LockoutDriver())
+	return nil
+}
+
+type Policy struct {
+	Threshold   int           // ผิดได้กี่ครั้งก่อนถูก lock (0 = ไม่ lock)
+	Duration    time.Duration // lock ครั้งแรก
+	MaxDuration time.Duration // lock นานสุด และเป็น window ที่นับจำนวนครั้งที่ผิด
+}
+
+// LockDuration : ผิดครบ Threshold lock เท่ากับ Duration ผิดต่อแต่ละครั้งเพิ่มเป็น 2 เท่าจนถึง MaxDuration
+func (p *Policy) LockDuration(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+	d := p.Duration
+	for i := p.Threshold; i < failures && d < p.MaxDuration; i++ {
+		d *= 2
+	}
+	if d > p.MaxDuration {
+		return p.MaxDuration
+	}
+	return d
+}
+
+type ILockout interface {
+	// RetryAfter : เวลาที่เหลือของ lock (0 = ไม่ถูก lock)
+	RetryAfter(ctx context.Context, key string, now time.Time) (time.Duration, error)
+	// Fail : นับครั้งที่ผิด คืนระยะเวลา lock เมื่อครั้งนี้ทำให้ถูก lock
+	Fail(ctx context.Context, key string, now time.Time) (time.Duration, error)
+	Reset(ctx context.Context, key string) error
+}
+
+type lockout struct {
+	store  IStore
+	policy *Policy
+
+	mu      sync.Mutex
+	pruneAt time.Time
+}
+
+func NewLockout(store IStore, policy *Policy) ILockout {
+	return &lockout{
+		store:  store,
+		policy: policy,
+	}
+}
+
+func (l *lockout) RetryAfter(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	attempt, err := l.store.Find(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if !attempt.LockedUntil.After(now) {
+		return 0, nil
+	}
+	return attempt.LockedUntil.Sub(now), nil
+}
+
+func (l *lockout) Fail(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	l.prune(ctx, now)
+
+	failures, err := l.store.Fail(ctx, key, now, l.policy.MaxDuration)
+	if err != nil {
+		return 0, err
+	}
+	d := l.policy.LockDuration(failures)
+	if d == 0 {
+		return 0, nil
+	}
+	if err := l.store.Lock(ctx, key, now.Add(d)); err != nil {
+		return 0, err
+	}
+	return d, nil
+}
+
+func (l *lockout) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
+
+// prune : ลบ key ที่หมด window แล้วอย่างมาก window ละครั้ง (lock ไม่เกิน MaxDuration จึงหมดไปด้วย)
+func (l *lockout) prune(ctx context.Context, now time.Time) {
+	l.mu.Lock()
+	if now.Before(l.pruneAt) {
+		l.mu.Unlock()
+		return
+	}
+	l.pruneAt = now.Add(l.policy.MaxDuration)
+	l.mu.Unlock()
+
+	if err := l.store.Prune(ctx, now.Add(-l.policy.MaxDuration)); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,69 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]*Attempt
+}
+
+// MemoryStore : ใช้ได้เฉพาะ instance เดียว (restart แล้วนับใหม่)
+func MemoryStore() IStore {
+	return &memoryStore{
+		attempts: make(map[string]*Attempt),
+	}
+}
+
+func (s *memoryStore) Find(ctx context.Context, key string) (*Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if attempt, ok := s.attempts[key]; ok {
+		copied := *attempt
+		return &copied, nil
+	}
+	return new(Attempt), nil
+}
+
+func (s *memoryStore) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.attempts[key]
+	if !ok || attempt.LastFailedAt.Before(now.Add(-window)) {
+		attempt = new(Attempt)
+		s.attempts[key] = attempt
+	}
+	attempt.Failures++
+	attempt.LastFailedAt = now
+	return attempt.Failures, nil
+}
+
+func (s *memoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if attempt, ok := s.attempts[key]; ok {
+		attempt.LockedUntil = until
+	}
+	return nil
+}
+
+func (s *memoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+func (s *memoryStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, attempt := range s.attempts {
+		if attempt.LastFailedAt.Before(before) {
+			delete(s.attempts, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresStore struct {
+	db *sqlx.DB
+}
+
+// PostgresStore : ใช้ร่วมกันได้หลาย instance
+func PostgresStore(db *sqlx.DB) IStore {
+	return &postgresStore{
+		db: db,
+	}
+}
+
+func (s *postgresStore) Find(ctx context.Context, key string) (*Attempt, error) {
+	query := `
+	SELECT
+		"failures",
+		"last_failed_at",
+		"locked_until"
+	FROM "signin_attempts"
+	WHERE "key" = $1;`
+
+	var lockedUntil sql.NullTime
+	attempt := new(Attempt)
+	if err := s.db.QueryRowxContext(ctx, query, key).Scan(
+		&attempt.Failures,
+		&attempt.LastFailedAt,
+		&lockedUntil,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return attempt, nil
+		}
+		return nil, fmt.Errorf("get signin attempt failed: %v", err)
+	}
+	attempt.LockedUntil = lockedUntil.Time
+	return attempt, nil
+}
+
+func (s *postgresStore) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	query := `
+	INSERT INTO "signin_attempts" (
+		"key",
+		"failures",
+		"last_failed_at"
+	)
+	VALUES ($1, 1, $2)
+	ON CONFLICT ("key") DO UPDATE SET
+		"failures" = CASE
+			WHEN "signin_attempts"."last_failed_at" < $3 THEN 1
+			ELSE "signin_attempts"."failures" + 1
+		END,
+		"last_failed_at" = $2
+	RETURNING "failures";`
+
+	var failures int
+	if err := s.db.QueryRowxContext(ctx, query, key, now, now.Add(-window)).Scan(&failures); err != nil {
+		return 0, fmt.Errorf("insert signin attempt failed: %v", err)
+	}
+	return failures, nil
+}
+
+func (s *postgresStore) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `UPDATE "signin_attempts" SET "locked_until" = $2 WHERE "key" = $1;`
+
+	if _, err := s.db.ExecContext(ctx, query, key, until); err != nil {
+		return fmt.Errorf("lock signin attempt failed: %v", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) Reset(ctx context.Context, key string) error {
+	query := `DELETE FROM "signin_attempts" WHERE "key" = $1;`
+
+	if _, err := s.db.ExecContext(ctx, query, key); err != nil {
+		return fmt.Errorf("reset signin attempt failed: %v", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) Prune(ctx context.Context, before time.Time) error {
+	query := `DELETE FROM "signin_attempts" WHERE "last_failed_at" < $1;`
+
+	if _, err := s.db.ExecContext(ctx, query, before); err != nil {
+		return fmt.Errorf("prune signin attempts failed: %v", err)
+	}
+	return nil
+}
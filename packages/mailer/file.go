@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fileMailer struct {
+	root string
+	from string
+}
+
+// FileMailer : เขียน email เป็นไฟล์ .eml ใน root ใช้ตอน dev เปิดดูด้วยโปรแกรม email ได้
+func FileMailer(root, from string) IMailer {
+	return &fileMailer{
+		root: root,
+		from: from,
+	}
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg *Message) error {
+	now := time.Now()
+	data, err := Build(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.root, 0o755); err != nil {
+		return fmt.Errorf("create mail dir failed: %v", err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405"), uuid.NewString())
+	if err := os.WriteFile(filepath.Join(m.root, name), data, 0o600); err != nil {
+		return fmt.Errorf("write mail failed: %v", err)
+	}
+	return nil
+}
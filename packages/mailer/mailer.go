@@ -0,0 +1,86 @@
+// mailer : ส่ง email ผ่าน driver ตาม config
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/google/uuid"
+)
+
+type IMailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // text/plain
+}
+
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverMemory = "memory"
+)
+
+func NewMailer(cfg config.IMailConfig) IMailer {
+	switch cfg.Driver() {
+	case DriverSMTP:
+		return SmtpMailer(&SmtpOptions{
+			Host:     cfg.SmtpHost(),
+			Port:     cfg.SmtpPort(),
+			Username: cfg.SmtpUsername(),
+			Password: cfg.SmtpPassword(),
+			From:     cfg.From(),
+		})
+	case DriverFile:
+		return FileMailer(cfg.FilePath(), cfg.From())
+	case DriverMemory:
+		return MemoryMailer()
+	}
+	log.Fatalf("mail driver %q is not supported", cfg.Driver())
+	return nil
+}
+
+// Build : RFC 5322 message ที่ smtp และ file driver ใช้ร่วมกัน
+// header ที่มาจาก input ถูกตรวจ/encode กัน header injection
+func Build(from string, msg *Message, now time.Time) ([]byte, error) {
+	fromAddress, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("from address is invalid")
+	}
+	toAddress, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("to address is invalid")
+	}
+	domain := fromAddress.Address[strings.LastIndex(fromAddress.Address, "@")+1:]
+
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "From: %s\r\n", fromAddress.String())
+	fmt.Fprintf(b, "To: %s\r\n", toAddress.String())
+	fmt.Fprintf(b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(b, "Message-ID: <%s@%s>\r\n", uuid.NewString(), domain)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n")
+	b.WriteString("\r\n")
+
+	// base64 บรรทัดละ 76 ตัวอักษร
+	body := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(body) > 76 {
+		b.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	b.WriteString(body + "\r\n")
+	return b.Bytes(), nil
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"sync"
+)
+
+// IMemoryMailer : เก็บ email ไว้ใน memory ให้ test อ่าน token ที่ส่งไป
+type IMemoryMailer interface {
+	IMailer
+	Messages() []*Message
+}
+
+type memoryMailer struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func MemoryMailer() IMemoryMailer {
+	return &memoryMailer{
+		messages: make([]*Message, 0),
+	}
+}
+
+func (m *memoryMailer) Send(ctx context.Context, msg *Message) error {
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return fmt.Errorf("to address is invalid")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *memoryMailer) Messages() []*Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Message(nil), m.messages...)
+}
@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+type SmtpOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type smtpMailer struct {
+	*SmtpOptions
+}
+
+// SmtpMailer : ใช้ STARTTLS เมื่อ server รองรับ (net/smtp ไม่ยอมส่ง password ผ่าน connection ที่ไม่เข้ารหัส ยกเว้น localhost)
+func SmtpMailer(opts *SmtpOptions) IMailer {
+	return &smtpMailer{
+		SmtpOptions: opts,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg *Message) error {
+	data, err := Build(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(m.From)
+	to, _ := mail.ParseAddress(msg.To)
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	// smtp.SendMail ไม่รับ context จึงส่งใน goroutine แล้วรอ ctx
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(net.JoinHostPort(m.Host, strconv.Itoa(m.Port)), auth, from.Address, []string{to.Address}, data)
+	}()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("send mail failed: %v", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("send mail failed: %v", ctx.Err())
+	}
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/appinfo"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares/middlewaresHandlers"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares/middlewaresUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBuildCategoryTree(t *testing.T) {
+	parentId := func(id int) *int { return &id }
+
+	categories := []*appinfo.Category{
+		{Id: 1, Title: "food & beverage"},
+		{Id: 2, Title: "coffee", ParentId: parentId(1)},
+		{Id: 3, Title: "arabica", ParentId: parentId(2)},
+		{Id: 4, Title: "gadget"},
+		{Id: 5, Title: "phone", ParentId: parentId(9)},
+	}
+
+	expect := `[{"id":1,"title":"food \u0026 beverage","parent_id":null,"children":[{"id":2,"title":"coffee","parent_id":1,"children":[{"id":3,"title":"arabica","parent_id":2}]}]},{"id":4,"title":"gadget","parent_id":null},{"id":5,"title":"phone","parent_id":9}]`
+
+	tree := appinfo.BuildCategoryTree(categories)
+	if result := CompressToJSON(&tree); result != expect {
+		t.Errorf("expect: %v, got: %v", expect, result)
+	}
+}
+
+func TestNewApiKey(t *testing.T) {
+	key, prefix, err := appinfo.NewApiKey()
+	if err != nil {
+		t.Fatalf("new api key failed: %v", err)
+	}
+	if !strings.HasPrefix(key, "kc_") || len(key) != 46 || prefix != key[:11] {
+		t.Errorf("expect: kc_ key with 11 chars prefix, got: %s %s", key, prefix)
+	}
+	if other, _, _ := appinfo.NewApiKey(); other == key {
+		t.Errorf("expect: random key")
+	}
+
+	scopes, err := appinfo.CheckScopes([]string{" Users", "catalog", "users"})
+	if err != nil || CompressToJSON(&scopes) != `["users","catalog"]` {
+		t.Errorf("expect: [users catalog], got: %v %v", scopes, err)
+	}
+	for _, scopes := range [][]string{nil, {"admin"}} {
+		if _, err := appinfo.CheckScopes(scopes); err == nil {
+			t.Errorf("%v expect: error", scopes)
+		}
+	}
+}
+
+type testMiddlewaresRepository struct {
+	calls           int
+	permissionCalls int
+}
+
+func (r *testMiddlewaresRepository) FindAccessToken(string, string) bool { return false }
+func (r *testMiddlewaresRepository) FindRolePermissions(roleId int) ([]string, error) {
+	r.permissionCalls++
+	if roleId == 2 {
+		return []string{middlewares.PermOrdersReadAny, middlewares.PermProductsWrite}, nil
+	}
+	return []string{}, nil
+}
+func (r *testMiddlewaresRepository) FindEmailVerified(string) bool { return false }
+func (r *testMiddlewaresRepository) FindApiKey(key string) (*middlewares.ApiKey, error) {
+	r.calls++
+	if key != "kc_valid" {
+		return nil, fmt.Errorf("api key not found")
+	}
+	return &middlewares.ApiKey{Id: "K1", Scopes: []string{middlewares.ScopeCatalog}}, nil
+}
+
+func TestFindApiKeyCache(t *testing.T) {
+	repository := new(testMiddlewaresRepository)
+	usecase := middlewaresUsecases.MiddlewaresUsecase(repository)
+
+	for i := 0; i < 3; i++ {
+		apiKey, err := usecase.FindApiKey("kc_valid")
+		if err != nil || apiKey.Id != "K1" {
+			t.Fatalf("expect: K1, got: %v %v", apiKey, err)
+		}
+		if !apiKey.HasScopes(middlewares.ScopeCatalog) || apiKey.HasScopes(middlewares.ScopeUsers) {
+			t.Errorf("expect: catalog scope only, got: %v", apiKey.Scopes)
+		}
+	}
+	if repository.calls != 1 {
+		t.Errorf("valid key expect: 1 repository call, got: %d", repository.calls)
+	}
+
+	// key ที่ไม่ถูกต้องไม่ถูก cache
+	for i := 0; i < 2; i++ {
+		if _, err := usecase.FindApiKey("kc_invalid"); err == nil {
+			t.Errorf("expect: error")
+		}
+	}
+	if repository.calls != 3 {
+		t.Errorf("invalid key expect: 3 repository calls, got: %d", repository.calls)
+	}
+
+	// key ที่ถูก revoke ต้องอ่านจาก database ใหม่ทันที
+	usecase.ForgetApiKey("K1")
+	if _, err := usecase.FindApiKey("kc_valid"); err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	if repository.calls != 4 {
+		t.Errorf("forgotten key expect: 4 repository calls, got: %d", repository.calls)
+	}
+}
+
+type testApiKeyConfig struct {
+	config.IConfig
+	legacyApiKey bool
+}
+
+func (c *testApiKeyConfig) Jwt() config.IJwtConfig { return &testJwtConfig{} }
+func (c *testApiKeyConfig) Auth() config.IAuthConfig {
+	return &testAuthConfig{legacyApiKey: c.legacyApiKey}
+}
+
+type testAuthConfig struct {
+	config.IAuthConfig
+	legacyApiKey bool
+}
+
+func (c *testAuthConfig) LegacyApiKey() bool { return c.legacyApiKey }
+
+func TestApiKeyAuthLegacy(t *testing.T) {
+	legacy, _ := authentication.NewAuthentication(authentication.ApiKey, &testJwtConfig{}, nil)
+	legacyKey := legacy.SignToken()
+
+	for _, test := range []struct {
+		key          string
+		legacyApiKey bool
+		expect       int
+	}{
+		{key: "kc_valid", expect: fiber.StatusOK},
+		{key: "kc_invalid", legacyApiKey: true, expect: fiber.StatusUnauthorized},
+		{key: legacyKey, legacyApiKey: true, expect: fiber.StatusOK},
+		{key: legacyKey, legacyApiKey: false, expect: fiber.StatusUnauthorized},
+	} {
+		handler := middlewaresHandlers.MiddlewaresHandler(
+			&testApiKeyConfig{legacyApiKey: test.legacyApiKey},
+			middlewaresUsecases.MiddlewaresUsecase(new(testMiddlewaresRepository)),
+		)
+		app := fiber.New()
+		app.Get("/", handler.ApiKeyAuth(middlewares.ScopeCatalog), func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Api-Key", test.key)
+		res, err := app.Test(req)
+		if err != nil || res.StatusCode != test.expect {
+			t.Errorf("%.12s (legacy: %v) expect: %d, got: %v %v", test.key, test.legacyApiKey, test.expect, res.StatusCode, err)
+		}
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	repository := new(testMiddlewaresRepository)
+	usecase := middlewaresUsecases.MiddlewaresUsecase(repository)
+
+	for i := 0; i < 3; i++ {
+		permissions, err := usecase.FindRolePermissions(2)
+		if err != nil {
+			t.Fatalf("expect: nil, got: %v", err)
+		}
+		if !permissions.Has(middlewares.PermOrdersReadAny, middlewares.PermProductsWrite) {
+			t.Errorf("expect: orders:read:any and products:write, got: %v", permissions)
+		}
+		if permissions.Has(middlewares.PermProductsWrite, middlewares.PermRolesWrite) {
+			t.Errorf("expect: all permissions required")
+		}
+	}
+	if _, err := usecase.FindRolePermissions(1); err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	if repository.permissionCalls != 2 {
+		t.Errorf("expect: 1 repository call per role, got: %d", repository.permissionCalls)
+	}
+}
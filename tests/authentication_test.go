@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testKeyPem(t *testing.T, private any) ([]byte, []byte) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("marshal private key failed: %v", err)
+	}
+	var public any
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		public = &private.PublicKey
+	case ed25519.PrivateKey:
+		public = private.Public()
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("marshal public key failed: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})
+}
+
+func tokenHeader(t *testing.T, token string) map[string]any {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("parse token failed: %v", err)
+	}
+	return parsed.Header
+}
+
+func TestThumbprint(t *testing.T) {
+	// ตัวอย่างจาก RFC 7638 section 3.1
+	jwk := &authentication.Jwk{
+		Kty: "RSA",
+		E:   "AQAB",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}
+	if kid := authentication.Thumbprint(jwk); kid != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("expect: NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs, got: %s", kid)
+	}
+}
+
+func TestAsymmetricToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key failed: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key failed: %v", err)
+	}
+	rsaPrivate, rsaPublic := testKeyPem(t, rsaKey)
+	edPrivate, _ := testKeyPem(t, edKey)
+	claims := &users.UserClaims{Id: "U000001", RoleId: 1, SessionId: "S1"}
+
+	// token เดิมที่ sign ด้วย HS256
+	legacyCfg := &testJwtConfig{}
+	legacyAccess, _ := authentication.NewAuthentication(authentication.Access, legacyCfg, claims)
+	legacyToken := legacyAccess.SignToken()
+
+	// key เก่า (rsa) ยังอยู่ใน public keys ระหว่าง rotate ไปใช้ ed25519
+	oldCfg := &testJwtConfig{privateKey: rsaPrivate}
+	oldAccess, _ := authentication.NewAuthentication(authentication.Access, oldCfg, claims)
+	oldToken := oldAccess.SignToken()
+	if header := tokenHeader(t, oldToken); header["alg"] != "RS256" || header["kid"] == "" {
+		t.Errorf("expect: RS256 with kid, got: %v", header)
+	}
+
+	cfg := &testJwtConfig{privateKey: edPrivate, publicKeys: [][]byte{rsaPublic}}
+	access, _ := authentication.NewAuthentication(authentication.Access, cfg, claims)
+	token := access.SignToken()
+	if header := tokenHeader(t, token); header["alg"] != "EdDSA" {
+		t.Errorf("expect: EdDSA, got: %v", header)
+	}
+
+	for name, tokenString := range map[string]string{"eddsa": token, "rsa": oldToken, "hs256": legacyToken} {
+		result, err := authentication.ParseToken(cfg, tokenString)
+		if err != nil {
+			t.Errorf("%s expect: valid token, got: %v", name, err)
+			continue
+		}
+		if result.Claims.Id != "U000001" || result.Claims.SessionId != "S1" {
+			t.Errorf("%s expect: claims of U000001, got: %+v", name, result.Claims)
+		}
+	}
+
+	// key ที่ไม่ได้อยู่ใน config แล้ว verify ไม่ผ่าน
+	if _, err := authentication.ParseToken(&testJwtConfig{privateKey: edPrivate}, oldToken); err == nil {
+		t.Errorf("removed key expect: error")
+	}
+
+	// ทุกประเภทใช้ key เดียวกัน access token ต้องใช้แทน api key หรือ admin token ไม่ได้
+	if _, err := authentication.ParseApiKey(cfg, token); err == nil {
+		t.Errorf("access token as api key expect: error")
+	}
+	if _, err := authentication.ParseAdminToken(cfg, token); err == nil {
+		t.Errorf("access token as admin token expect: error")
+	}
+	apiKey, _ := authentication.NewAuthentication(authentication.ApiKey, cfg, nil)
+	if _, err := authentication.ParseApiKey(cfg, apiKey.SignToken()); err != nil {
+		t.Errorf("api key expect: valid, got: %v", err)
+	}
+
+	keys, err := authentication.Keys(cfg)
+	if err != nil {
+		t.Fatalf("keys failed: %v", err)
+	}
+	jwks := keys.Jwks()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kty != "OKP" || jwks.Keys[1].Kty != "RSA" || jwks.Keys[0].Kid != tokenHeader(t, token)["kid"] {
+		t.Errorf("expect: signing key then rsa key, got: %+v", jwks.Keys)
+	}
+}
+
+func TestTotp(t *testing.T) {
+	// ตัวอย่าง SHA-1 จาก RFC 6238 appendix B (secret "12345678901234567890")
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(59, 0)
+	if code, _ := authentication.TotpCode(secret, authentication.TotpStep(now)); code != "287082" {
+		t.Fatalf("expect: 287082, got: %s", code)
+	}
+
+	step, ok := authentication.VerifyTotp(secret, "287 082", now, 0)
+	if !ok || step != 1 {
+		t.Errorf("expect: valid code at step 1, got: %d %v", step, ok)
+	}
+	if _, ok := authentication.VerifyTotp(secret, "287082", now, step); ok {
+		t.Errorf("expect: used code to be rejected")
+	}
+	if _, ok := authentication.VerifyTotp(secret, "287082", now.Add(5*time.Minute), 0); ok {
+		t.Errorf("expect: expired code to be rejected")
+	}
+
+	cfg := &testJwtConfig{}
+	challenge, _ := authentication.NewAuthentication(authentication.Challenge, cfg, &users.UserClaims{Id: "U000001", RoleId: 2})
+	token := challenge.SignToken()
+	if _, err := authentication.ParseToken(cfg, token); err == nil {
+		t.Errorf("expect: challenge token to be rejected as access token")
+	}
+	if claims, err := authentication.ParseChallengeToken(cfg, token); err != nil || claims.Claims.Id != "U000001" {
+		t.Errorf("expect: challenge token of U000001, got: %v", err)
+	}
+
+	if code := users.NormalizeRecoveryCode(" AbCdE-12345 "); code != "abcde12345" {
+		t.Errorf("expect: abcde12345, got: %s", code)
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts/cartsRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/carts/cartsUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsRepositories"
+	"github.com/jmoiron/sqlx"
+)
+
+// newCartsUsecase : cart ของ customer001 (seed) ถูกล้างก่อน test เริ่ม
+func newCartsUsecase(t *testing.T, cfg config.IConfig, db *sqlx.DB) cartsUsecases.ICartsUsecase {
+	t.Helper()
+	if _, err := db.Exec(`DELETE FROM "carts_items" WHERE "user_id" = 'U000001';`); err != nil {
+		t.Fatalf("clear cart failed: %v", err)
+	}
+	return cartsUsecases.CartsUsecase(
+		cartsRepositories.CartsRepository(db),
+		ordersRepositories.OrdersRepository(db),
+		productsRepositories.ProductsRepository(db, cfg, filesUsecases.FilesUsecase(cfg)),
+	)
+}
+
+func newCheckoutReq() *carts.CheckoutReq {
+	return &carts.CheckoutReq{
+		UserId:      "U000001",
+		ShippingFee: 50,
+		Address:     "test address",
+		Contact:     "test contact",
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	cfg, db := SetupDb(t)
+	productId := InsertTestProduct(t, db, 100, 5)
+	usecase := newCartsUsecase(t, cfg, db)
+
+	if _, err := usecase.AddCartItem(&carts.CartItemReq{UserId: "U000001", ProductId: productId, Qty: 2}); err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	order, err := usecase.Checkout(newCheckoutReq())
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	DeleteTestOrder(t, db, order.Id)
+	if order.Subtotal != 200 || order.TotalPaid != 250 || len(order.Products) != 1 {
+		t.Errorf("expect: subtotal 200 total 250 with 1 product, got: %v", CompressToJSON(order))
+	}
+	if stock := ProductStock(t, db, productId); stock != 3 {
+		t.Errorf("expect: stock 3, got: %d", stock)
+	}
+
+	// cart ถูกล้างพร้อม order จึง checkout ซ้ำไม่ได้
+	cart, err := usecase.FindCart("U000001")
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	if len(cart.Items) != 0 {
+		t.Errorf("expect: empty cart, got: %v", CompressToJSON(cart))
+	}
+	if _, err := usecase.Checkout(newCheckoutReq()); err == nil || err.Error() != "cart is empty" {
+		t.Errorf("expect: cart is empty, got: %v", err)
+	}
+}
+
+func TestCheckoutRollback(t *testing.T) {
+	cfg, db := SetupDb(t)
+	productId := InsertTestProduct(t, db, 100, 1)
+	usecase := newCartsUsecase(t, cfg, db)
+
+	if _, err := usecase.AddCartItem(&carts.CartItemReq{UserId: "U000001", ProductId: productId, Qty: 2}); err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+
+	// order ไม่สำเร็จ cart ต้องยังอยู่ครบ
+	if _, err := usecase.Checkout(newCheckoutReq()); err == nil || err.Error() != "product "+productId+" is out of stock" {
+		t.Errorf("expect: product %s is out of stock, got: %v", productId, err)
+	}
+	cart, err := usecase.FindCart("U000001")
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	if len(cart.Items) != 1 || cart.Items[0].Qty != 2 {
+		t.Errorf("expect: cart with 1 item (qty 2), got: %v", CompressToJSON(cart))
+	}
+	if stock := ProductStock(t, db, productId); stock != 1 {
+		t.Errorf("expect: stock 1, got: %d", stock)
+	}
+}
+
+func TestInsertOrderCartChanged(t *testing.T) {
+	cfg, db := SetupDb(t)
+	productId := InsertTestProduct(t, db, 100, 5)
+
+	// item ที่ไม่อยู่ใน cart แล้ว (เช่น checkout ซ้อนกัน) ต้อง rollback ทั้ง order และ stock
+	req := newTestOrder(productId, 1)
+	req.CartItemIds = []string{"not-in-cart"}
+	if _, err := newOrdersUsecase(cfg, db).InsertOrder(req); err == nil || err.Error() != "cart has changed" {
+		t.Errorf("expect: cart has changed, got: %v", err)
+	}
+	if stock := ProductStock(t, db, productId); stock != 5 {
+		t.Errorf("expect: stock 5, got: %d", stock)
+	}
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+)
+
+func TestCursor(t *testing.T) {
+	cursor := &entities.Cursor{Id: "P000010", Value: "Coffee", OrderBy: "title", Sort: "ASC"}
+	decoded, err := entities.DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("decode cursor failed: %v", err)
+	}
+	if *decoded != *cursor {
+		t.Errorf("expect: %+v, got: %+v", cursor, decoded)
+	}
+
+	if _, err := entities.DecodeCursor("not-a-cursor"); err == nil {
+		t.Errorf("expect: error, got: nil")
+	}
+}
+
+type testPageCursor struct {
+	label      string
+	req        *entities.Cursor
+	hasMore    bool
+	expectNext bool
+	expectPrev bool
+}
+
+func TestNewPageCursor(t *testing.T) {
+	tests := []testPageCursor{
+		{label: "first page", req: nil, hasMore: true, expectNext: true, expectPrev: false},
+		{label: "only page", req: nil, hasMore: false, expectNext: false, expectPrev: false},
+		{label: "middle page", req: &entities.Cursor{Id: "P1"}, hasMore: true, expectNext: true, expectPrev: true},
+		{label: "last page", req: &entities.Cursor{Id: "P1"}, hasMore: false, expectNext: false, expectPrev: true},
+		{label: "back to first page", req: &entities.Cursor{Id: "P1", Backward: true}, hasMore: false, expectNext: true, expectPrev: false},
+	}
+
+	for _, test := range tests {
+		page := entities.NewPageCursor(test.req, test.hasMore, &entities.Cursor{Id: "first"}, &entities.Cursor{Id: "last"})
+		if (page.Next != "") != test.expectNext || (page.Prev != "") != test.expectPrev {
+			t.Errorf("%s: expect next: %v prev: %v, got: %+v", test.label, test.expectNext, test.expectPrev, page)
+		}
+		if page.Prev != "" {
+			prev, _ := entities.DecodeCursor(page.Prev)
+			if prev.Id != "first" || !prev.Backward {
+				t.Errorf("%s: prev cursor is invalid: %+v", test.label, prev)
+			}
+		}
+	}
+}
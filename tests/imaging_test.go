@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/imaging"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestProcessImage(t *testing.T) {
+	buf := new(bytes.Buffer)
+	png.Encode(buf, testImage(1600, 800))
+
+	res, err := imaging.Process(buf.Bytes(), "png")
+	if err != nil {
+		t.Fatalf("process image failed: %v", err)
+	}
+	if res.ContentType != "image/png" {
+		t.Errorf("expect: image/png, got: %s", res.ContentType)
+	}
+
+	expect := map[string][2]int{
+		"thumbnail": {200, 100},
+		"medium":    {600, 300},
+		"large":     {1200, 600},
+	}
+	for name, size := range expect {
+		config, err := png.DecodeConfig(bytes.NewReader(res.Renditions[name]))
+		if err != nil {
+			t.Fatalf("%s: decode failed: %v", name, err)
+		}
+		if config.Width != size[0] || config.Height != size[1] {
+			t.Errorf("%s: expect: %dx%d, got: %dx%d", name, size[0], size[1], config.Width, config.Height)
+		}
+	}
+}
+
+func TestProcessImageInvalid(t *testing.T) {
+	if _, err := imaging.Process([]byte("<?php echo 'x'; ?>"), "png"); err == nil || err.Error() != "file is not a valid image" {
+		t.Errorf("expect: file is not a valid image, got: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	jpeg.Encode(buf, testImage(10, 10), nil)
+	if _, err := imaging.Process(buf.Bytes(), "png"); err == nil || err.Error() != "file content does not match extension" {
+		t.Errorf("expect: file content does not match extension, got: %v", err)
+	}
+	if _, err := imaging.Process(buf.Bytes(), "jpg"); err != nil {
+		t.Errorf("expect: nil, got: %v", err)
+	}
+}
+
+func TestRenditionFileName(t *testing.T) {
+	if name := imaging.RenditionFileName("images/products/abc.png", "thumbnail"); name != "images/products/abc_thumbnail.png" {
+		t.Errorf("expect: images/products/abc_thumbnail.png, got: %s", name)
+	}
+}
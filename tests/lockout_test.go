@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/lockout"
+)
+
+func TestLockDuration(t *testing.T) {
+	policy := &lockout.Policy{Threshold: 3, Duration: 30 * time.Second, MaxDuration: 5 * time.Minute}
+	for failures, expect := range map[int]time.Duration{
+		2:  0,
+		3:  30 * time.Second,
+		4:  time.Minute,
+		6:  4 * time.Minute,
+		7:  5 * time.Minute,
+		64: 5 * time.Minute,
+	} {
+		if d := policy.LockDuration(failures); d != expect {
+			t.Errorf("failures %d expect: %v, got: %v", failures, expect, d)
+		}
+	}
+}
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+	l := lockout.NewLockout(lockout.MemoryStore(), &lockout.Policy{Threshold: 2, Duration: time.Minute, MaxDuration: time.Hour})
+	now := time.Now()
+
+	if d, _ := l.Fail(ctx, "account:a@b.com", now); d != 0 {
+		t.Fatalf("expect: not locked, got: %v", d)
+	}
+	if d, _ := l.Fail(ctx, "account:a@b.com", now); d != time.Minute {
+		t.Fatalf("expect: locked 1m, got: %v", d)
+	}
+	if d, _ := l.RetryAfter(ctx, "account:a@b.com", now.Add(20*time.Second)); d != 40*time.Second {
+		t.Errorf("expect: retry after 40s, got: %v", d)
+	}
+	if d, _ := l.RetryAfter(ctx, "account:other@b.com", now); d != 0 {
+		t.Errorf("expect: other key not locked, got: %v", d)
+	}
+
+	// ผิดต่อหลังหมด lock ได้ lock นานขึ้น
+	if d, _ := l.Fail(ctx, "account:a@b.com", now.Add(2*time.Minute)); d != 2*time.Minute {
+		t.Errorf("expect: locked 2m, got: %v", d)
+	}
+
+	// เกิน window นับใหม่
+	if d, _ := l.Fail(ctx, "account:a@b.com", now.Add(3*time.Hour)); d != 0 {
+		t.Errorf("expect: not locked after window, got: %v", d)
+	}
+
+	l.Fail(ctx, "account:a@b.com", now.Add(3*time.Hour))
+	l.Reset(ctx, "account:a@b.com")
+	if d, _ := l.RetryAfter(ctx, "account:a@b.com", now.Add(3*time.Hour)); d != 0 {
+		t.Errorf("expect: unlocked after reset, got: %v", d)
+	}
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/mailer"
+)
+
+func TestBuildMail(t *testing.T) {
+	msg := &mailer.Message{
+		To:      "customer@example.com",
+		Subject: "ยืนยัน email",
+		Body:    "token: abc",
+	}
+	data, err := mailer.Build("K-Commerce <no-reply@example.com>", msg, time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+
+	header, body, _ := strings.Cut(string(data), "\r\n\r\n")
+	for _, expect := range []string{
+		"From: \"K-Commerce\" <no-reply@example.com>",
+		"To: <customer@example.com>",
+		"Subject: =?utf-8?q?",
+		"Content-Type: text/plain; charset=utf-8",
+	} {
+		if !strings.Contains(header, expect) {
+			t.Errorf("expect header: %s, got: %s", expect, header)
+		}
+	}
+	if decoded, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(body)); string(decoded) != msg.Body {
+		t.Errorf("expect body: %s, got: %s", msg.Body, decoded)
+	}
+
+	// ใส่ header เพิ่มผ่าน address ไม่ได้
+	msg.To = "customer@example.com\r\nBcc: other@example.com"
+	if _, err := mailer.Build("no-reply@example.com", msg, time.Now()); err == nil {
+		t.Errorf("header injection expect: error")
+	}
+}
+
+func TestFileAndMemoryMailer(t *testing.T) {
+	msg := &mailer.Message{To: "customer@example.com", Subject: "reset", Body: "token"}
+
+	dir := t.TempDir()
+	if err := mailer.FileMailer(dir, "no-reply@example.com").Send(context.Background(), msg); err != nil {
+		t.Fatalf("file mailer failed: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), ".eml") {
+		t.Errorf("expect: 1 eml file, got: %v", entries)
+	}
+
+	memory := mailer.MemoryMailer()
+	if err := memory.Send(context.Background(), msg); err != nil {
+		t.Fatalf("memory mailer failed: %v", err)
+	}
+	if messages := memory.Messages(); len(messages) != 1 || messages[0].Subject != "reset" {
+		t.Errorf("expect: 1 message, got: %+v", messages)
+	}
+}
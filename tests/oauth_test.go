@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersProviders"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/lockout"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestCodeChallenge(t *testing.T) {
+	// ตัวอย่างจาก RFC 7636 appendix B
+	if challenge := authentication.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); challenge != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("expect: E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM, got: %s", challenge)
+	}
+}
+
+// mockOidcServer : OpenID provider ที่ออก id token ให้ code "valid-code" เมื่อ code verifier ตรงกับ challenge
+func mockOidcServer(t *testing.T, nonce func() string, challenge func() string) *httptest.Server {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key failed: %v", err)
+	}
+	privatePem, _ := testKeyPem(t, rsaKey)
+	keys, err := authentication.ParseKeySet(privatePem, nil)
+	if err != nil {
+		t.Fatalf("parse key set failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(keys.Jwks())
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "valid-code" ||
+			r.PostForm.Get("client_secret") != "secret" ||
+			authentication.CodeChallenge(r.PostForm.Get("code_verifier")) != challenge() {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            server.URL,
+			"aud":            "client",
+			"sub":            "mock-user-1",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          nonce(),
+			"email":          "customer@example.com",
+			"email_verified": true,
+		})
+		token.Header["kid"] = keys.Signing.Id
+		idToken, _ := token.SignedString(keys.Signing.Private)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "id_token": idToken})
+	})
+	return server
+}
+
+func TestOidcProvider(t *testing.T) {
+	var authUrl *url.URL
+	server := mockOidcServer(t,
+		func() string { return authUrl.Query().Get("nonce") },
+		func() string { return authUrl.Query().Get("code_challenge") },
+	)
+	defer server.Close()
+
+	provider := usersProviders.OidcProvider(&usersProviders.OidcOptions{
+		Name:         users.ProviderOidc,
+		Issuer:       server.URL,
+		ClientId:     "client",
+		ClientSecret: "secret",
+		RedirectUrl:  "http://localhost:3000/oauth/oidc",
+		Scopes:       []string{"openid", "email"},
+	})
+	ctx := context.Background()
+	verifier, _ := authentication.NewCodeVerifier()
+
+	raw, err := provider.AuthCodeUrl(ctx, "state-1", "nonce-1", authentication.CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("auth code url failed: %v", err)
+	}
+	authUrl, _ = url.Parse(raw)
+	if authUrl.Path != "/authorize" || authUrl.Query().Get("state") != "state-1" || authUrl.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("expect: authorization url with state and S256 challenge, got: %s", raw)
+	}
+
+	identity, err := provider.Exchange(ctx, "valid-code", verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	if identity.Subject != "mock-user-1" || identity.Email != "customer@example.com" || !identity.EmailVerified {
+		t.Errorf("expect: verified identity of mock-user-1, got: %+v", identity)
+	}
+
+	if _, err := provider.Exchange(ctx, "valid-code", "wrong-verifier", "nonce-1"); err == nil || err.Error() != "authorization code is invalid" {
+		t.Errorf("expect: authorization code is invalid, got: %v", err)
+	}
+	if _, err := provider.Exchange(ctx, "valid-code", verifier, "other-nonce"); err == nil || err.Error() != "id token is invalid" {
+		t.Errorf("expect: id token is invalid, got: %v", err)
+	}
+}
+
+// testProvider : provider ที่ยืนยัน email victim@test.com แล้ว
+type testProvider struct{ usersProviders.IProvider }
+
+func (p *testProvider) Name() string { return "test" }
+func (p *testProvider) Exchange(context.Context, string, string, string) (*users.UserIdentityClaims, error) {
+	return &users.UserIdentityClaims{Provider: "test", Subject: "S1", Email: "victim@test.com", EmailVerified: true}, nil
+}
+
+// testOauthRepository : identity ยังไม่เคยผูก แต่มีบัญชีที่ใช้ email เดียวกันอยู่แล้ว
+type testOauthRepository struct {
+	usersRepositories.IUsersRepository
+	user   *users.UserCredentialCheck
+	linked bool
+}
+
+func (r *testOauthRepository) UseOauthState(string, string) (*users.UserOauthState, error) {
+	return &users.UserOauthState{Provider: "test"}, nil
+}
+func (r *testOauthRepository) FindUserByIdentity(string, string) (*users.UserCredentialCheck, error) {
+	return nil, fmt.Errorf("identity not found")
+}
+func (r *testOauthRepository) FindOneUserByEmail(string) (*users.UserCredentialCheck, error) {
+	return r.user, nil
+}
+func (r *testOauthRepository) InsertUserIdentity(string, *users.UserIdentityClaims) error {
+	r.linked = true
+	return nil
+}
+func (r *testOauthRepository) FindRolePermissions(int) ([]string, error) { return nil, nil }
+func (r *testOauthRepository) InsertOauth(*users.UserPassport, *users.UserClient, int) error {
+	return nil
+}
+
+func TestOauthSignInLinkAccount(t *testing.T) {
+	for _, test := range []struct {
+		user   *users.UserCredentialCheck
+		expect bool
+	}{
+		{user: &users.UserCredentialCheck{Id: "U000001", Email: "victim@test.com", RoleId: roles.RoleCustomer, Verified: true}, expect: true},
+		// บัญชีที่สมัครดักไว้ก่อนโดยไม่ได้ยืนยัน email
+		{user: &users.UserCredentialCheck{Id: "U000001", Email: "victim@test.com", RoleId: roles.RoleCustomer, Verified: false}, expect: false},
+		{user: &users.UserCredentialCheck{Id: "U000002", Email: "victim@test.com", RoleId: roles.RoleAdmin, Verified: true}, expect: false},
+	} {
+		repository := &testOauthRepository{user: test.user}
+		usecase := usersUsecases.UsersUsecase(new(testUsersConfig), repository, nil, lockout.MemoryStore(), map[string]usersProviders.IProvider{
+			"test": new(testProvider),
+		})
+
+		passport, _, err := usecase.OauthSignIn(&users.UserOauthCallbackReq{Provider: "test", Code: "code", State: "state"})
+		if test.expect && (err != nil || passport == nil || !repository.linked) {
+			t.Errorf("%v expect: linked passport, got: %v %v", CompressToJSON(test.user), passport, err)
+		}
+		if !test.expect && (err == nil || err.Error() != "email has been used" || repository.linked) {
+			t.Errorf("%v expect: email has been used, got: %v (linked: %v)", CompressToJSON(test.user), err, repository.linked)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package tests
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersHandlers"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders/ordersUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsUsecases"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type testOrderTransition struct {
+	from    string
+	to      string
+	isAdmin bool
+	expect  bool
+}
+
+func TestOrderTransition(t *testing.T) {
+	tests := []testOrderTransition{
+		{from: "waiting", to: "shipping", isAdmin: true, expect: true},
+		{from: "waiting", to: "canceled", isAdmin: true, expect: true},
+		{from: "waiting", to: "paid", isAdmin: true, expect: false},
+		{from: "paid", to: "shipping", isAdmin: true, expect: true},
+		{from: "paid", to: "waiting", isAdmin: true, expect: false},
+		{from: "shipping", to: "completed", isAdmin: true, expect: true},
+		{from: "shipping", to: "canceled", isAdmin: true, expect: false},
+		{from: "completed", to: "canceled", isAdmin: true, expect: false},
+		{from: "canceled", to: "waiting", isAdmin: true, expect: false},
+		{from: "waiting", to: "waiting", isAdmin: true, expect: false},
+		{from: "waiting", to: "canceled", isAdmin: false, expect: true},
+		{from: "waiting", to: "shipping", isAdmin: false, expect: false},
+		{from: "waiting", to: "paid", isAdmin: false, expect: false},
+		{from: "paid", to: "canceled", isAdmin: false, expect: false},
+		{from: "shipping", to: "canceled", isAdmin: false, expect: false},
+		{from: "completed", to: "canceled", isAdmin: false, expect: false},
+	}
+
+	for _, test := range tests {
+		if result := orders.CanTransition(test.from, test.to, test.isAdmin); result != test.expect {
+			t.Errorf("%s -> %s (admin: %v) expect: %v, got: %v", test.from, test.to, test.isAdmin, test.expect, result)
+		}
+	}
+}
+
+func TestOrderActorTransition(t *testing.T) {
+	warehouse := &orders.OrderActor{UserId: "U000003", IsFulfilment: true}
+	for _, test := range []struct {
+		from    string
+		to      string
+		isOwner bool
+		expect  bool
+	}{
+		{from: "paid", to: "shipping", expect: true},
+		{from: "shipping", to: "completed", expect: true},
+		{from: "waiting", to: "shipping", expect: false},
+		{from: "waiting", to: "canceled", expect: false},
+		{from: "paid", to: "canceled", expect: false},
+		{from: "waiting", to: "canceled", isOwner: true, expect: true},
+	} {
+		if result := warehouse.CanTransition(test.from, test.to, test.isOwner); result != test.expect {
+			t.Errorf("warehouse %s -> %s (owner: %v) expect: %v, got: %v", test.from, test.to, test.isOwner, test.expect, result)
+		}
+	}
+
+	// customer ยกเลิกได้เฉพาะ order ของตัวเอง
+	customer := &orders.OrderActor{UserId: "U000001"}
+	if customer.CanTransition("waiting", "canceled", false) || !customer.CanTransition("waiting", "canceled", true) {
+		t.Errorf("customer expect: cancel own order only")
+	}
+}
+
+func TestWriteOrderReport(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := orders.WriteOrderReport(buf, []*orders.OrderReport{
+		{Id: "O000001", UserId: "U000001", Status: "paid", CouponCode: "SALE, 10", Subtotal: 100, Discount: 10.5, ShippingFee: 50, TotalPaid: 139.5, CreatedAt: "2026-10-01 10:00:00"},
+	}); err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	expect := "id,user_id,status,coupon_code,subtotal,discount,shipping_fee,total_paid,created_at\n" +
+		"O000001,U000001,paid,\"SALE, 10\",100.00,10.50,50.00,139.50,2026-10-01 10:00:00\n"
+	if buf.String() != expect {
+		t.Errorf("expect: %q, got: %q", expect, buf.String())
+	}
+}
+
+func newOrdersUsecase(cfg config.IConfig, db *sqlx.DB) ordersUsecases.IOrdersUsecase {
+	filesUsecase := filesUsecases.FilesUsecase(cfg)
+	return ordersUsecases.OrdersUsecase(
+		ordersRepositories.OrdersRepository(db),
+		productsRepositories.ProductsRepository(db, cfg, filesUsecase),
+		filesUsecase,
+	)
+}
+
+// newTestOrder : order ของ customer001 (seed) ที่สั่ง product เดียว
+func newTestOrder(productId string, qty int) *orders.Order {
+	return &orders.Order{
+		UserId:      "U000001",
+		Contact:     "test contact",
+		Address:     "test address",
+		Status:      "waiting",
+		ShippingFee: 50,
+		Products: []*orders.ProductsOrder{
+			{Qty: qty, Product: &products.Product{Id: productId}},
+		},
+	}
+}
+
+func TestInsertOrderReserveStock(t *testing.T) {
+	cfg, db := SetupDb(t)
+	productId := InsertTestProduct(t, db, 100, 3)
+	usecase := newOrdersUsecase(cfg, db)
+
+	order, err := usecase.InsertOrder(newTestOrder(productId, 2))
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	DeleteTestOrder(t, db, order.Id)
+	if stock := ProductStock(t, db, productId); stock != 1 {
+		t.Errorf("expect: stock 1, got: %d", stock)
+	}
+
+	productsUsecase := productsUsecases.ProductsUsecase(productsRepositories.ProductsRepository(db, cfg, filesUsecases.FilesUsecase(cfg)))
+	res := productsUsecase.FindStockMovement(&products.StockMovementFilter{
+		ProductId:     productId,
+		PaginationReq: &entities.PaginationReq{Page: 1, Limit: 10},
+	})
+	movements := res.Data.([]*products.StockMovement)
+	if len(movements) != 1 || movements[0].Type != "order" || movements[0].Qty != -2 || movements[0].Balance != 1 || movements[0].OrderId != order.Id {
+		t.Errorf("expect: order movement -2 (balance 1), got: %v", CompressToJSON(&movements))
+	}
+
+	// stock ไม่พอต้องไม่ถูกตัดและไม่มี order เกิดขึ้น
+	if _, err := usecase.InsertOrder(newTestOrder(productId, 2)); err == nil || err.Error() != "product "+productId+" is out of stock" {
+		t.Errorf("expect: product %s is out of stock, got: %v", productId, err)
+	}
+	if stock := ProductStock(t, db, productId); stock != 1 {
+		t.Errorf("expect: stock 1, got: %d", stock)
+	}
+}
+
+func TestCalculateTotal(t *testing.T) {
+	order := &orders.Order{
+		Products: []*orders.ProductsOrder{
+			{Qty: 3, Product: &products.Product{Price: 19.99}},
+			{Qty: 1, Product: &products.Product{Price: 0.1}},
+		},
+		Discount:    10,
+		ShippingFee: 50,
+	}
+	order.CalculateTotal()
+	if order.Subtotal != 60.07 || order.TotalPaid != 100.07 {
+		t.Errorf("expect: subtotal 60.07 total 100.07, got: %v %v", order.Subtotal, order.TotalPaid)
+	}
+
+	// ส่วนลดเกินยอดสินค้าถูกตัดเหลือเท่ายอดสินค้า ค่าส่งยังต้องจ่าย
+	order.Discount = 100
+	order.CalculateTotal()
+	if order.Discount != 60.07 || order.TotalPaid != 50 {
+		t.Errorf("expect: discount 60.07 total 50, got: %v %v", order.Discount, order.TotalPaid)
+	}
+}
+
+func TestInsertOrderTotal(t *testing.T) {
+	cfg, db := SetupDb(t)
+	productId := InsertTestProduct(t, db, 120.5, 5)
+
+	// ยอดที่ client ส่งมาต้องถูกคำนวณใหม่จากราคาใน database
+	req := newTestOrder(productId, 2)
+	req.Subtotal = 1
+	req.TotalPaid = 1
+	order, err := newOrdersUsecase(cfg, db).InsertOrder(req)
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	DeleteTestOrder(t, db, order.Id)
+	if order.Subtotal != 241 || order.ShippingFee != 50 || order.TotalPaid != 291 {
+		t.Errorf("expect: subtotal 241 shipping 50 total 291, got: %v %v %v", order.Subtotal, order.ShippingFee, order.TotalPaid)
+	}
+	if len(order.Products) != 1 || order.Products[0].Product.Price != 120.5 {
+		t.Errorf("expect: product snapshot price 120.5, got: %v", CompressToJSON(&order.Products))
+	}
+}
+
+func TestCancelOrderRestoreStock(t *testing.T) {
+	cfg, db := SetupDb(t)
+	productId := InsertTestProduct(t, db, 100, 5)
+	usecase := newOrdersUsecase(cfg, db)
+
+	order, err := usecase.InsertOrder(newTestOrder(productId, 2))
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	DeleteTestOrder(t, db, order.Id)
+
+	customer := &orders.OrderActor{UserId: "U000001"}
+	if _, err := usecase.UpdateOrder(&orders.Order{Id: order.Id, Status: "canceled"}, customer); err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	if stock := ProductStock(t, db, productId); stock != 5 {
+		t.Errorf("expect: stock 5, got: %d", stock)
+	}
+
+	productsUsecase := productsUsecases.ProductsUsecase(productsRepositories.ProductsRepository(db, cfg, filesUsecases.FilesUsecase(cfg)))
+	res := productsUsecase.FindStockMovement(&products.StockMovementFilter{
+		ProductId:     productId,
+		PaginationReq: &entities.PaginationReq{Page: 1, Limit: 10},
+	})
+	movements := res.Data.([]*products.StockMovement)
+	var canceled *products.StockMovement
+	for _, m := range movements {
+		if m.Type == "cancel" {
+			canceled = m
+		}
+	}
+	if canceled == nil || canceled.Qty != 2 || canceled.Balance != 5 || canceled.OrderId != order.Id {
+		t.Errorf("expect: cancel movement +2 (balance 5), got: %v", CompressToJSON(&movements))
+	}
+
+	// order ที่ถูก cancel แล้วเปลี่ยน status ไม่ได้ stock จึงไม่ถูกคืนซ้ำ
+	if _, err := usecase.UpdateOrder(&orders.Order{Id: order.Id, Status: "canceled"}, customer); err == nil || err.Error() != "order status transition is not allowed" {
+		t.Errorf("expect: order status transition is not allowed, got: %v", err)
+	}
+	if stock := ProductStock(t, db, productId); stock != 5 {
+		t.Errorf("expect: stock 5, got: %d", stock)
+	}
+}
+
+func TestFindOrderReport(t *testing.T) {
+	cfg, db := SetupDb(t)
+	productId := InsertTestProduct(t, db, 100, 5)
+	usecase := newOrdersUsecase(cfg, db)
+
+	order, err := usecase.InsertOrder(newTestOrder(productId, 1))
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	DeleteTestOrder(t, db, order.Id)
+	// เที่ยงคืนพอดีต้องอยู่ในวันที่ 2 เท่านั้น
+	if _, err := db.Exec(`UPDATE "orders" SET "created_at" = '2001-01-02 00:00:00' WHERE "id" = $1;`, order.Id); err != nil {
+		t.Fatalf("update created_at failed: %v", err)
+	}
+
+	tests := []struct {
+		filter *orders.OrderReportFilter
+		expect bool
+	}{
+		{filter: &orders.OrderReportFilter{StartDate: "2001-01-02", EndDate: "2001-01-02"}, expect: true},
+		{filter: &orders.OrderReportFilter{StartDate: "2001-01-01", EndDate: "2001-01-02", Status: "waiting"}, expect: true},
+		{filter: &orders.OrderReportFilter{StartDate: "2001-01-02", EndDate: "2001-01-02", Status: "paid"}, expect: false},
+		{filter: &orders.OrderReportFilter{StartDate: "2001-01-01", EndDate: "2001-01-01"}, expect: false},
+	}
+	for _, test := range tests {
+		reports, err := usecase.FindOrderReport(test.filter)
+		if err != nil {
+			t.Fatalf("expect: nil, got: %v", err)
+		}
+		found := false
+		for _, report := range reports {
+			if report.Id == order.Id {
+				found = true
+			}
+		}
+		if found != test.expect {
+			t.Errorf("%v expect: %v, got: %v", CompressToJSON(test.filter), test.expect, CompressToJSON(&reports))
+		}
+	}
+}
+
+func TestIsSlipOf(t *testing.T) {
+	for _, test := range []struct {
+		destination string
+		expect      bool
+	}{
+		{destination: "slips/U000001/slip.png", expect: true},
+		{destination: "/slips/U000001/slip.png", expect: true},
+		{destination: "slips/U000002/slip.png", expect: false},
+		{destination: "slips/U000001/../U000002/slip.png", expect: false},
+		{destination: "slips/U0000011/slip.png", expect: false},
+		{destination: "slips/U000001", expect: false},
+	} {
+		if files.IsSlipOf("U000001", test.destination) != test.expect {
+			t.Errorf("%s expect: %v", test.destination, test.expect)
+		}
+	}
+}
+
+// stubOrdersUsecase : order ทุกอันเป็นของ U000002 และเก็บ req ที่ handler ส่งต่อมา
+type stubOrdersUsecase struct {
+	ordersUsecases.IOrdersUsecase
+	req *orders.Order
+}
+
+func (u *stubOrdersUsecase) FindOneOrder(orderId string) (*orders.Order, error) {
+	return &orders.Order{Id: orderId, UserId: "U000002"}, nil
+}
+
+func (u *stubOrdersUsecase) InsertOrder(req *orders.Order) (*orders.Order, error) {
+	u.req = req
+	return req, nil
+}
+
+type testOrdersConfig struct{ config.IConfig }
+
+func (c *testOrdersConfig) App() config.IAppConfig { return new(testOrdersAppConfig) }
+
+type testOrdersAppConfig struct{ config.IAppConfig }
+
+func (c *testOrdersAppConfig) ShippingFee() float64 { return 50 }
+
+// newOrdersApp : user U000001 ที่มี permission ตามที่ระบุ
+func newOrdersApp(usecase ordersUsecases.IOrdersUsecase, permissions ...string) *fiber.App {
+	handler := ordersHandlers.OrdersHandler(new(testOrdersConfig), usecase)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userId", "U000001")
+		c.Locals("userPermissions", middlewares.Permissions(permissions))
+		return c.Next()
+	})
+	app.Post("/orders", handler.InsertOrder)
+	app.Get("/orders/:user_id/:order_id", handler.FindOneOrder)
+	return app
+}
+
+func TestFindOneOrderOwner(t *testing.T) {
+	for _, test := range []struct {
+		userId      string
+		permissions []string
+		expect      int
+	}{
+		{userId: "U000001", expect: fiber.StatusNotFound},
+		{userId: "U000002", expect: fiber.StatusOK},
+		{userId: "U000001", permissions: []string{middlewares.PermOrdersReadAny}, expect: fiber.StatusOK},
+	} {
+		app := newOrdersApp(new(stubOrdersUsecase), test.permissions...)
+		res, err := app.Test(httptest.NewRequest("GET", "/orders/"+test.userId+"/O000001", nil))
+		if err != nil || res.StatusCode != test.expect {
+			t.Errorf("%s %v expect: %d, got: %v %v", test.userId, test.permissions, test.expect, res.StatusCode, err)
+		}
+	}
+}
+
+func TestInsertOrderIgnoreTransferSlip(t *testing.T) {
+	usecase := new(stubOrdersUsecase)
+	body := `{"products":[{"qty":1,"product":{"id":"P000001"}}],"transfer_slip":{"destination":"slips/U000002/slip.png"}}`
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := newOrdersApp(usecase).Test(req)
+	if err != nil || res.StatusCode != fiber.StatusCreated {
+		t.Fatalf("expect: %d, got: %v %v", fiber.StatusCreated, res.StatusCode, err)
+	}
+	if usecase.req.TransferSlip != nil || usecase.req.UserId != "U000001" {
+		t.Errorf("expect: no transfer slip for U000001, got: %v", CompressToJSON(usecase.req))
+	}
+}
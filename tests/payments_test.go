@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/payments/paymentsProviders"
+)
+
+func TestCRC16(t *testing.T) {
+	// check value ของ CRC-16/CCITT-FALSE
+	if crc := paymentsProviders.CRC16("123456789"); crc != 0x29B1 {
+		t.Errorf("expect: 29B1, got: %04X", crc)
+	}
+}
+
+func TestPromptPayPayload(t *testing.T) {
+	payload, err := paymentsProviders.PromptPayPayload("081-234-5678", 0)
+	if err != nil {
+		t.Fatalf("payload failed: %v", err)
+	}
+	expect := "000201010211" + "29370016A00000067701011101130066812345678" + "5303764" + "5802TH" + "6304"
+	if !strings.HasPrefix(payload, expect) {
+		t.Errorf("expect prefix: %s, got: %s", expect, payload)
+	}
+	if crc := fmt.Sprintf("%04X", paymentsProviders.CRC16(payload[:len(payload)-4])); payload[len(payload)-4:] != crc {
+		t.Errorf("expect crc: %s, got: %s", crc, payload[len(payload)-4:])
+	}
+
+	payload, err = paymentsProviders.PromptPayPayload("1234567890123", 100.5)
+	if err != nil {
+		t.Fatalf("payload failed: %v", err)
+	}
+	if !strings.Contains(payload, "010212") || !strings.Contains(payload, "02131234567890123") ||
+		!strings.Contains(payload, "5406100.50") {
+		t.Errorf("expect: dynamic qr with national id and amount, got: %s", payload)
+	}
+
+	for _, target := range []string{"", "08123", "08x2345678"} {
+		if _, err := paymentsProviders.PromptPayPayload(target, 10); err == nil {
+			t.Errorf("%q expect: error", target)
+		}
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("whsec")
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1700000000, 0)
+	header := paymentsProviders.SignWebhook(secret, now, body)
+
+	if err := paymentsProviders.VerifyWebhook(secret, header, body, now.Add(time.Minute)); err != nil {
+		t.Errorf("expect: valid, got: %v", err)
+	}
+	if err := paymentsProviders.VerifyWebhook(secret, header, []byte(`{"id":"evt_2"}`), now); err == nil || err.Error() != "signature is invalid" {
+		t.Errorf("tampered body expect: signature is invalid, got: %v", err)
+	}
+	if err := paymentsProviders.VerifyWebhook([]byte("other"), header, body, now); err == nil {
+		t.Errorf("wrong secret expect: error")
+	}
+	if err := paymentsProviders.VerifyWebhook(nil, header, body, now); err == nil {
+		t.Errorf("empty secret expect: error")
+	}
+	if err := paymentsProviders.VerifyWebhook(secret, header, body, now.Add(time.Hour)); err == nil || err.Error() != "signature has expired" {
+		t.Errorf("expect: signature has expired, got: %v", err)
+	}
+}
+
+func TestCardProvider(t *testing.T) {
+	// fake gateway
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/charges" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer sk_test" || r.Header.Get("Idempotency-Key") != "P1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		req := make(map[string]any)
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["amount"] != float64(10050) || req["currency"] != "thb" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":            "chrg_1",
+			"status":        "pending",
+			"authorize_uri": "https://gateway.test/pay/chrg_1",
+		})
+	}))
+	defer server.Close()
+
+	secret := []byte("whsec")
+	provider := paymentsProviders.CardProvider(&paymentsProviders.CardOptions{
+		BaseUrl:       server.URL + "/",
+		SecretKey:     "sk_test",
+		WebhookSecret: secret,
+	})
+
+	intent, err := provider.CreateIntent(context.Background(), &payments.Payment{
+		Id:       "P1",
+		OrderId:  "O1",
+		Amount:   100.5,
+		Currency: payments.Currency,
+	})
+	if err != nil {
+		t.Fatalf("create intent failed: %v", err)
+	}
+	if intent.ProviderRef != "chrg_1" || intent.RedirectUrl != "https://gateway.test/pay/chrg_1" {
+		t.Errorf("expect: charge chrg_1, got: %+v", intent)
+	}
+
+	body := []byte(`{"id":"evnt_1","type":"charge.succeeded","data":{"id":"chrg_1","amount":10050,"metadata":{"payment_id":"P1"}}}`)
+	event, err := provider.ParseWebhook(paymentsProviders.SignWebhook(secret, time.Now(), body), body)
+	if err != nil {
+		t.Fatalf("parse webhook failed: %v", err)
+	}
+	if event.PaymentId != "P1" || event.ProviderRef != "chrg_1" || event.Status != payments.StatusSucceeded || event.Amount != 100.5 {
+		t.Errorf("expect: succeeded event of P1, got: %+v", event)
+	}
+
+	// event ที่ไม่เกี่ยวกับผลการจ่ายเงินไม่ต้องประมวลผล
+	body = []byte(`{"id":"evnt_2","type":"charge.created","data":{"id":"chrg_1","metadata":{"payment_id":"P1"}}}`)
+	if event, err := provider.ParseWebhook(paymentsProviders.SignWebhook(secret, time.Now(), body), body); err != nil || event != nil {
+		t.Errorf("expect: ignored event, got: %+v %v", event, err)
+	}
+}
@@ -1,7 +1,16 @@
 package tests
 
 import (
+	"net/http/httptest"
 	"testing"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/entities"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/files/filesUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsHandlers"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/products/productsUsecases"
+	"github.com/gofiber/fiber/v2"
 )
 
 type testFindOneProduct struct {
@@ -20,7 +29,7 @@ func TestFindOneProduct(t *testing.T) {
 		{
 			productId: "P000001",
 			isErr:     false,
-			expect:    `{"id":"P000001","title":"Coffee","description":"Just a food \u0026 beverage product","category":{"id":1,"title":"food \u0026 beverage"},"created_at":"2023-05-03T17:22:47.649985","updated_at":"2023-05-03T17:22:47.649985","price":150,"images":[{"id":"c580fe73-afb3-47d1-a9df-eed24fdaea9b","filename":"fb1_1.jpg","url":"https://i.pinimg.com/564x/4a/1c/4a/4a1c4a9755e4d3bdfcb45a1c3a58712f.jpg"},{"id":"43bcd3fa-6f7f-4251-b196-f30ad4ea625e","filename":"fb1_2.jpg","url":"https://i.pinimg.com/564x/4a/1c/4a/4a1c4a9755e4d3bdfcb45a1c3a58712f.jpg"},{"id":"77d9e690-b722-4039-b0fe-5f7d9af0e6b4","filename":"fb1_3.jpg","url":"https://i.pinimg.com/564x/4a/1c/4a/4a1c4a9755e4d3bdfcb45a1c3a58712f.jpg"}]}`,
+			expect:    `{"id":"P000001","title":"Coffee","description":"Just a food \u0026 beverage product","categories":[{"id":1,"title":"food \u0026 beverage","parent_id":null}],"created_at":"2023-05-03T17:22:47.649985","updated_at":"2023-05-03T17:22:47.649985","price":150,"images":[{"id":"c580fe73-afb3-47d1-a9df-eed24fdaea9b","filename":"fb1_1.jpg","url":"https://i.pinimg.com/564x/4a/1c/4a/4a1c4a9755e4d3bdfcb45a1c3a58712f.jpg"},{"id":"43bcd3fa-6f7f-4251-b196-f30ad4ea625e","filename":"fb1_2.jpg","url":"https://i.pinimg.com/564x/4a/1c/4a/4a1c4a9755e4d3bdfcb45a1c3a58712f.jpg"},{"id":"77d9e690-b722-4039-b0fe-5f7d9af0e6b4","filename":"fb1_3.jpg","url":"https://i.pinimg.com/564x/4a/1c/4a/4a1c4a9755e4d3bdfcb45a1c3a58712f.jpg"}]}`,
 		},
 	}
 
@@ -41,3 +50,104 @@ func TestFindOneProduct(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildTsQuery(t *testing.T) {
+	tests := map[string]string{
+		"coffee":            "coffee:*",
+		"Blue  shi":         "blue:* & shi:*",
+		"tea & (milk) | !x": "tea:* & milk:* & x:*",
+		"กาแฟ สด":           "กาแฟ:* & สด:*",
+		"'':*&|":            "",
+	}
+
+	for search, expect := range tests {
+		if result := products.BuildTsQuery(search); result != expect {
+			t.Errorf("search: %q expect: %q, got: %q", search, expect, result)
+		}
+	}
+}
+
+func TestNewPriceFacets(t *testing.T) {
+	facets := products.NewPriceFacets(map[int]int{0: 3, 4: 1})
+	if len(facets) != len(products.PriceBuckets) {
+		t.Fatalf("expect: %d facets, got: %d", len(products.PriceBuckets), len(facets))
+	}
+	if facets[0].Count != 3 || facets[0].Max == nil || *facets[0].Max != 100 {
+		t.Errorf("first bucket is invalid: %+v", facets[0])
+	}
+	if facets[1].Count != 0 {
+		t.Errorf("expect: empty bucket, got: %d", facets[1].Count)
+	}
+	if last := facets[len(facets)-1]; last.Count != 1 || last.Max != nil {
+		t.Errorf("last bucket is invalid: %+v", last)
+	}
+}
+
+func TestAdjustStock(t *testing.T) {
+	cfg, db := SetupDb(t)
+	productId := InsertTestProduct(t, db, 100, 0)
+	usecase := productsUsecases.ProductsUsecase(productsRepositories.ProductsRepository(db, cfg, filesUsecases.FilesUsecase(cfg)))
+
+	movement, err := usecase.AdjustStock(&products.StockAdjustReq{ProductId: productId, UserId: "U000002", Qty: 5, Note: "restock"})
+	if err != nil || movement.Balance != 5 || movement.Type != "adjust" {
+		t.Fatalf("expect: balance 5, got: %v %v", CompressToJSON(&movement), err)
+	}
+
+	// stock ติดลบไม่ได้
+	if _, err := usecase.AdjustStock(&products.StockAdjustReq{ProductId: productId, UserId: "U000002", Qty: -6}); err == nil || err.Error() != "stock is insufficient" {
+		t.Errorf("expect: stock is insufficient, got: %v", err)
+	}
+	if _, err := usecase.AdjustStock(&products.StockAdjustReq{ProductId: "P999999", Qty: 1}); err == nil || err.Error() != "product not found" {
+		t.Errorf("expect: product not found, got: %v", err)
+	}
+	if stock := ProductStock(t, db, productId); stock != 5 {
+		t.Errorf("expect: stock 5, got: %d", stock)
+	}
+
+	res := usecase.FindStockMovement(&products.StockMovementFilter{
+		ProductId:     productId,
+		PaginationReq: &entities.PaginationReq{Page: 1, Limit: 10},
+	})
+	movements := res.Data.([]*products.StockMovement)
+	if res.TotalItem != 1 || len(movements) != 1 || movements[0].UserId != "U000002" || movements[0].Note != "restock" || movements[0].Qty != 5 {
+		t.Errorf("expect: 1 adjust movement by U000002, got: %d %v", res.TotalItem, CompressToJSON(&movements))
+	}
+}
+
+// stubProductsUsecase : เก็บ filter ที่ handler ส่งต่อมา
+type stubProductsUsecase struct {
+	productsUsecases.IProductsUsecase
+	req *products.ProductFilter
+}
+
+func (u *stubProductsUsecase) FindProduct(req *products.ProductFilter) *entities.PaginateRes {
+	u.req = req
+	return &entities.PaginateRes{Data: make([]*products.Product, 0)}
+}
+
+func TestFindProductFacets(t *testing.T) {
+	usecase := new(stubProductsUsecase)
+	app := fiber.New()
+	app.Get("/products", productsHandlers.ProductsHandler(nil, usecase, nil).FindProduct)
+
+	for _, test := range []struct {
+		query  string
+		expect bool
+	}{
+		{query: "", expect: true},
+		{query: "?cursor=", expect: false},
+		{query: "?skip_count=true", expect: false},
+		{query: "?cursor=&facets=true", expect: true},
+		{query: "?facets=false", expect: false},
+	} {
+		usecase.req = nil
+		res, err := app.Test(httptest.NewRequest("GET", "/products"+test.query, nil))
+		if err != nil || res.StatusCode != fiber.StatusOK {
+			t.Errorf("%q expect: 200, got: %v %v", test.query, res, err)
+			continue
+		}
+		if usecase.req.Facets != test.expect {
+			t.Errorf("%q expect facets: %v, got: %v", test.query, test.expect, usecase.req.Facets)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/orders"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/promotions/promotionsRepositories"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type testPromotionDiscount struct {
+	label     string
+	promotion *promotions.Promotion
+	inPeriod  bool
+	userUsed  int
+	expect    float64
+	expectErr string
+}
+
+func TestPromotionDiscount(t *testing.T) {
+	items := []*promotions.PromotionItem{
+		{ProductId: "P000001", CategoryIds: []int{1}, Price: 100, Qty: 2},
+		{ProductId: "P000002", CategoryIds: []int{2}, Price: 50, Qty: 1},
+	}
+
+	tests := []testPromotionDiscount{
+		{
+			label:     "percentage",
+			promotion: &promotions.Promotion{Type: "percentage", Value: 10, IsActive: true},
+			inPeriod:  true,
+			expect:    25,
+		},
+		{
+			label:     "percentage with max discount",
+			promotion: &promotions.Promotion{Type: "percentage", Value: 50, MaxDiscount: 30, IsActive: true},
+			inPeriod:  true,
+			expect:    30,
+		},
+		{
+			label:     "fixed by category",
+			promotion: &promotions.Promotion{Type: "fixed", Value: 80, CategoryIds: []int{2}, IsActive: true},
+			inPeriod:  true,
+			expect:    50,
+		},
+		{
+			label:     "percentage by product",
+			promotion: &promotions.Promotion{Type: "percentage", Value: 10, ProductIds: []string{"P000001"}, IsActive: true},
+			inPeriod:  true,
+			expect:    20,
+		},
+		{
+			label:     "not applicable",
+			promotion: &promotions.Promotion{Type: "fixed", Value: 10, CategoryIds: []int{3}, IsActive: true},
+			inPeriod:  true,
+			expectErr: "coupon is not applicable to products",
+		},
+		{
+			label:     "expired",
+			promotion: &promotions.Promotion{Type: "fixed", Value: 10, IsActive: true},
+			inPeriod:  false,
+			expectErr: "coupon is expired or inactive",
+		},
+		{
+			label:     "min spend",
+			promotion: &promotions.Promotion{Type: "fixed", Value: 10, MinSpend: 500, IsActive: true},
+			inPeriod:  true,
+			expectErr: "order does not reach coupon minimum spend",
+		},
+		{
+			label:     "usage limit",
+			promotion: &promotions.Promotion{Type: "fixed", Value: 10, UsageLimit: 5, UsedCount: 5, IsActive: true},
+			inPeriod:  true,
+			expectErr: "coupon usage limit reached",
+		},
+		{
+			label:     "usage limit per user",
+			promotion: &promotions.Promotion{Type: "fixed", Value: 10, UsageLimitPerUser: 1, IsActive: true},
+			inPeriod:  true,
+			userUsed:  1,
+			expectErr: "coupon usage limit per user reached",
+		},
+	}
+
+	for _, test := range tests {
+		discount, err := test.promotion.Discount(items, test.inPeriod, test.userUsed)
+		if test.expectErr != "" {
+			if err == nil || err.Error() != test.expectErr {
+				t.Errorf("%s expect error: %s, got: %v", test.label, test.expectErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s expect no error, got: %v", test.label, err)
+			continue
+		}
+		if discount != test.expect {
+			t.Errorf("%s expect: %v, got: %v", test.label, test.expect, discount)
+		}
+	}
+}
+
+func TestPromotionIsValidValue(t *testing.T) {
+	for _, test := range []struct {
+		promotionType string
+		value         float64
+		expect        bool
+	}{
+		{promotionType: "percentage", value: 100, expect: true},
+		{promotionType: "percentage", value: 150, expect: false},
+		{promotionType: "percentage", value: 0, expect: false},
+		{promotionType: "fixed", value: 150, expect: true},
+		{promotionType: "fixed", value: -1, expect: false},
+	} {
+		if result := promotions.IsValidValue(test.promotionType, test.value); result != test.expect {
+			t.Errorf("%s %v expect: %v, got: %v", test.promotionType, test.value, test.expect, result)
+		}
+	}
+}
+
+// insertTestCategory : category ของ test ถูกลบตอน test จบ (sub category ถูกลบตาม)
+func insertTestCategory(t *testing.T, db *sqlx.DB, parentId *int) int {
+	t.Helper()
+	var categoryId int
+	if err := db.Get(&categoryId, `INSERT INTO "categories" ("title", "parent_id") VALUES ($1, $2) RETURNING "id";`, "test "+uuid.NewString(), parentId); err != nil {
+		t.Fatalf("insert test category failed: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM "categories" WHERE "id" = $1;`, categoryId)
+	})
+	return categoryId
+}
+
+func TestPromotionUsage(t *testing.T) {
+	cfg, db := SetupDb(t)
+	productId := InsertTestProduct(t, db, 100, 5)
+
+	// promotion ผูกกับ category แม่ ส่วน product อยู่ใน sub category
+	parentId := insertTestCategory(t, db, nil)
+	childId := insertTestCategory(t, db, &parentId)
+	if _, err := db.Exec(`INSERT INTO "products_categories" ("product_id", "category_id") VALUES ($1, $2);`, productId, childId); err != nil {
+		t.Fatalf("insert products_categories failed: %v", err)
+	}
+
+	repository := promotionsRepositories.PromotionsRepository(db)
+	promotion, err := repository.InsertPromotion(&promotions.Promotion{
+		Code:        "TEST" + uuid.NewString()[:8],
+		Type:        "percentage",
+		Value:       10,
+		UsageLimit:  1,
+		IsActive:    true,
+		CategoryIds: []int{parentId},
+	})
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM "promotions" WHERE "id" = $1;`, promotion.Id)
+	})
+
+	usecase := newOrdersUsecase(cfg, db)
+	req := newTestOrder(productId, 2)
+	req.CouponCode = promotion.Code
+	order, err := usecase.InsertOrder(req)
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	DeleteTestOrder(t, db, order.Id)
+	if order.Discount != 20 || order.TotalPaid != 230 {
+		t.Errorf("expect: discount 20 total 230, got: %v %v", order.Discount, order.TotalPaid)
+	}
+
+	// cancel order แล้ว coupon ต้องกลับมาใช้ได้อีกครั้ง
+	if _, err := usecase.UpdateOrder(&orders.Order{Id: order.Id, Status: "canceled"}, &orders.OrderActor{UserId: "U000001"}); err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	result, err := repository.FindOnePromotion(promotion.Id)
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	if result.UsedCount != 0 {
+		t.Errorf("expect: used count 0, got: %d", result.UsedCount)
+	}
+	req = newTestOrder(productId, 1)
+	req.CouponCode = promotion.Code
+	order, err = usecase.InsertOrder(req)
+	if err != nil {
+		t.Fatalf("expect: nil, got: %v", err)
+	}
+	DeleteTestOrder(t, db, order.Id)
+
+	// percentage ที่แก้เกิน 100 ต้องไม่ถูกบันทึก
+	value := 150.0
+	if _, err := repository.UpdatePromotion(&promotions.PromotionUpdateReq{Id: promotion.Id, Value: &value}); err == nil || err.Error() != "value is invalid" {
+		t.Errorf("expect: value is invalid, got: %v", err)
+	}
+	if result, err := repository.FindOnePromotion(promotion.Id); err != nil || result.Value != 10 {
+		t.Errorf("expect: value 10, got: %v %v", CompressToJSON(result), err)
+	}
+}
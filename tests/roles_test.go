@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/middlewares"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/roles"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	known := []*roles.Permission{
+		{Name: middlewares.PermProductsWrite},
+		{Name: middlewares.PermStockRead},
+	}
+
+	permissions, err := roles.CheckPermissions([]string{" Products:Write", "stock:read", "products:write"}, known)
+	if err != nil || CompressToJSON(&permissions) != `["products:write","stock:read"]` {
+		t.Errorf("expect: [products:write stock:read], got: %v %v", permissions, err)
+	}
+	if permissions, err := roles.CheckPermissions(nil, known); err != nil || len(permissions) != 0 {
+		t.Errorf("expect: empty, got: %v %v", permissions, err)
+	}
+	if _, err := roles.CheckPermissions([]string{middlewares.PermRolesWrite}, known); err == nil || err.Error() != "permission roles:write not found" {
+		t.Errorf("expect: permission roles:write not found, got: %v", err)
+	}
+}
+
+func TestPermissionsHasAny(t *testing.T) {
+	permissions := middlewares.Permissions{middlewares.PermOrdersReadAny, middlewares.PermOrdersFulfil}
+	if !permissions.HasAny(middlewares.PermOrdersWriteAny, middlewares.PermOrdersFulfil) {
+		t.Errorf("expect: orders:fulfil matched")
+	}
+	if permissions.HasAny(middlewares.PermOrdersWriteAny) || permissions.HasAny() {
+		t.Errorf("expect: no permission matched")
+	}
+}
+
+func TestPermissionsIsStaff(t *testing.T) {
+	if !(middlewares.Permissions{middlewares.PermProductsWrite, middlewares.PermOrdersReadAny}).IsStaff() {
+		t.Errorf("expect: orders:read:any is staff")
+	}
+	if (middlewares.Permissions{middlewares.PermProductsWrite, middlewares.PermOrdersFulfil}).IsStaff() || (middlewares.Permissions{}).IsStaff() {
+		t.Errorf("expect: not staff")
+	}
+}
@@ -2,10 +2,14 @@ package tests
 
 import (
 	"encoding/json"
+	"os"
+	"testing"
 
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/servers"
 	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/databases"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 func SetupTest() servers.IModuleFactory {
@@ -18,6 +22,59 @@ func SetupTest() servers.IModuleFactory {
 	return servers.InitModule(nil, s.GetServer(), nil)
 }
 
+// SetupDb : test ที่ใช้ database จริง (migrate ล่าสุดพร้อม seed) จะถูก skip ถ้าไม่มี .env.test
+func SetupDb(t *testing.T) (config.IConfig, *sqlx.DB) {
+	t.Helper()
+	if _, err := os.Stat("../.env.test"); err != nil {
+		t.Skip("../.env.test not found")
+	}
+	cfg := config.LoadConfig("../.env.test")
+
+	db := databases.DbConnect(cfg.Db())
+	t.Cleanup(func() { db.Close() })
+	return cfg, db
+}
+
+// InsertTestProduct : product ของ test ถูกลบตอน test จบ (stock movement และ variant ถูกลบตาม)
+func InsertTestProduct(t *testing.T, db *sqlx.DB, price float64, stock int) string {
+	t.Helper()
+	query := `
+	INSERT INTO "products" (
+		"title",
+		"description",
+		"price",
+		"stock"
+	)
+	VALUES ($1, 'test product', $2, $3)
+	RETURNING "id";`
+
+	var productId string
+	if err := db.Get(&productId, query, "test "+uuid.NewString(), price, stock); err != nil {
+		t.Fatalf("insert test product failed: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM "products" WHERE "id" = $1;`, productId)
+	})
+	return productId
+}
+
+// DeleteTestOrder : ลบ order ที่ test สร้างตอน test จบ (ก่อน product ที่สร้างไว้ก่อนหน้า)
+func DeleteTestOrder(t *testing.T, db *sqlx.DB, orderId string) {
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM "orders" WHERE "id" = $1;`, orderId)
+	})
+}
+
+// ProductStock : stock ปัจจุบันของ product
+func ProductStock(t *testing.T, db *sqlx.DB, productId string) int {
+	t.Helper()
+	var stock int
+	if err := db.Get(&stock, `SELECT "stock" FROM "products" WHERE "id" = $1;`, productId); err != nil {
+		t.Fatalf("get product stock failed: %v", err)
+	}
+	return stock
+}
+
 func CompressToJSON(obj any) string {
 	result, _ := json.Marshal(&obj)
 	return string(result)
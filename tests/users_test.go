@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/config"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersRepositories"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/modules/users/usersUsecases"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/authentication"
+	"github.com/Montheankul-K/E-Commerce-Application-Backend/packages/lockout"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testJwtConfig struct {
+	privateKey []byte
+	publicKeys [][]byte
+}
+
+func (c *testJwtConfig) SecretKey() []byte       { return []byte("secret") }
+func (c *testJwtConfig) AdminKey() []byte        { return []byte("admin") }
+func (c *testJwtConfig) ApiKey() []byte          { return []byte("apikey") }
+func (c *testJwtConfig) PrivateKey() []byte      { return c.privateKey }
+func (c *testJwtConfig) PublicKeys() [][]byte    { return c.publicKeys }
+func (c *testJwtConfig) AccessExpireAt() int     { return 60 }
+func (c *testJwtConfig) RefreshExpireAt() int    { return 3600 }
+func (c *testJwtConfig) SetJwtAccessExpire(int)  {}
+func (c *testJwtConfig) SetJwtRefreshExpire(int) {}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	cfg := &testJwtConfig{}
+	userClaims := &users.UserClaims{Id: "U000001", RoleId: 1, SessionId: "S1"}
+
+	refresh, _ := authentication.NewAuthentication(authentication.Refresh, cfg, userClaims)
+	token := refresh.SignToken()
+	claims, err := authentication.ParseToken(cfg, token)
+	if err != nil {
+		t.Fatalf("parse token failed: %v", err)
+	}
+	if claims.Claims.SessionId != "S1" || claims.Subject != "refresh-token" || claims.ID == "" {
+		t.Errorf("expect: refresh token of session S1 with jti, got: %+v", claims)
+	}
+
+	// token ที่ rotate ต้องไม่ซ้ำกับตัวเดิมแม้ออกในวินาทีเดียวกัน แต่หมดอายุเท่าเดิม
+	rotated := authentication.RepeatToken(cfg, userClaims, claims.ExpiresAt.Unix())
+	if rotated == token {
+		t.Fatalf("expect: new refresh token")
+	}
+	rotatedClaims, err := authentication.ParseToken(cfg, rotated)
+	if err != nil {
+		t.Fatalf("parse token failed: %v", err)
+	}
+	if rotatedClaims.ID == claims.ID || !rotatedClaims.ExpiresAt.Equal(claims.ExpiresAt.Time) {
+		t.Errorf("expect: new jti with same expiry, got: %+v", rotatedClaims)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	// sha-256 ของ "abc"
+	expect := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if hash := authentication.HashToken("abc"); hash != expect {
+		t.Errorf("expect: %s, got: %s", expect, hash)
+	}
+}
+
+func TestIsEmail(t *testing.T) {
+	for email, expect := range map[string]bool{
+		"customer@example.com":   true,
+		"first.last@mail.co.th":  true,
+		"customer":               false,
+		"customer@example":       false,
+		"a@b.com\r\nBcc: x@y.io": false,
+	} {
+		if users.IsEmail(email) != expect {
+			t.Errorf("%q expect: %v", email, expect)
+		}
+	}
+}
+
+type testUsersConfig struct{ config.IConfig }
+
+func (c *testUsersConfig) Jwt() config.IJwtConfig   { return &testJwtConfig{} }
+func (c *testUsersConfig) Auth() config.IAuthConfig { return new(testLockoutConfig) }
+
+type testLockoutConfig struct{ config.IAuthConfig }
+
+func (c *testLockoutConfig) AdminTotpRequired() bool { return false }
+func (c *testLockoutConfig) LockoutThreshold() int   { return 100 }
+func (c *testLockoutConfig) LockoutIpThreshold() int { return 100 }
+func (c *testLockoutConfig) LockoutDuration() int    { return 60 }
+func (c *testLockoutConfig) LockoutMaxDuration() int { return 600 }
+
+type testTotpChallenge struct {
+	userId   string
+	attempts int
+	used     bool
+}
+
+// testTotpRepository : user คนเดียวที่เปิดใช้ TOTP แล้ว challenge เก็บใน map แทน table totp_challenges
+type testTotpRepository struct {
+	usersRepositories.IUsersRepository
+	user       *users.UserCredentialCheck
+	totp       *users.UserTotp
+	challenges map[string]*testTotpChallenge
+}
+
+func (r *testTotpRepository) FindOneUserByEmail(string) (*users.UserCredentialCheck, error) {
+	return r.user, nil
+}
+func (r *testTotpRepository) FindOneUserById(string) (*users.UserCredentialCheck, error) {
+	return r.user, nil
+}
+func (r *testTotpRepository) FindUserTotp(string) (*users.UserTotp, error) { return r.totp, nil }
+func (r *testTotpRepository) UseTotpStep(userId string, step int64) (bool, error) {
+	if step <= r.totp.LastStep {
+		return false, nil
+	}
+	r.totp.LastStep = step
+	return true, nil
+}
+func (r *testTotpRepository) UseRecoveryCode(string, string) (bool, error)       { return false, nil }
+func (r *testTotpRepository) InsertSignInFailure(*users.UserSignInFailure) error { return nil }
+func (r *testTotpRepository) FindRolePermissions(int) ([]string, error)          { return nil, nil }
+func (r *testTotpRepository) InsertOauth(*users.UserPassport, *users.UserClient, int) error {
+	return nil
+}
+
+func (r *testTotpRepository) InsertTotpChallenge(userId, challengeToken string, _ time.Duration) error {
+	for token, c := range r.challenges {
+		if c.userId == userId && !c.used {
+			delete(r.challenges, token)
+		}
+	}
+	r.challenges[challengeToken] = &testTotpChallenge{userId: userId}
+	return nil
+}
+func (r *testTotpRepository) FindTotpChallenge(challengeToken string) (string, error) {
+	if c, ok := r.challenges[challengeToken]; ok && !c.used {
+		return c.userId, nil
+	}
+	return "", fmt.Errorf("challenge token is invalid")
+}
+func (r *testTotpRepository) UseTotpChallenge(challengeToken string) error {
+	if _, err := r.FindTotpChallenge(challengeToken); err != nil {
+		return err
+	}
+	r.challenges[challengeToken].used = true
+	return nil
+}
+func (r *testTotpRepository) FailTotpChallenge(challengeToken string, maxAttempts int) error {
+	if c, ok := r.challenges[challengeToken]; ok && !c.used {
+		c.attempts++
+		c.used = c.attempts >= maxAttempts
+	}
+	return nil
+}
+
+func TestSignInTotpChallenge(t *testing.T) {
+	secret, _ := authentication.NewTotpSecret()
+	password, _ := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	repository := &testTotpRepository{
+		user:       &users.UserCredentialCheck{Id: "U000001", Email: "customer001@test.com", Password: string(password), RoleId: 1, TotpEnabled: true},
+		totp:       &users.UserTotp{Secret: secret, Enabled: true},
+		challenges: make(map[string]*testTotpChallenge),
+	}
+	usecase := usersUsecases.UsersUsecase(new(testUsersConfig), repository, nil, lockout.MemoryStore(), nil)
+	challenge := func() string {
+		_, c, err := usecase.GetPassport(&users.UserCredential{Email: "customer001@test.com", Password: "123456"})
+		if err != nil || c == nil {
+			t.Fatalf("expect: challenge, got: %v %v", c, err)
+		}
+		return c.ChallengeToken
+	}
+	code := func(offset int64) string {
+		c, _ := authentication.TotpCode(secret, authentication.TotpStep(time.Now())+offset)
+		return c
+	}
+
+	// challenge ใช้แลก passport ได้ครั้งเดียว
+	token := challenge()
+	if _, err := usecase.SignInTotp(&users.UserChallengeReq{ChallengeToken: token, Code: code(0)}); err != nil {
+		t.Fatalf("expect: passport, got: %v", err)
+	}
+	if _, err := usecase.SignInTotp(&users.UserChallengeReq{ChallengeToken: token, Code: code(1)}); err == nil || err.Error() != "challenge token is invalid" {
+		t.Errorf("replay expect: challenge token is invalid, got: %v", err)
+	}
+
+	// ใส่ code ผิดครบ challenge ใช้ไม่ได้แม้ code ถูก
+	token = challenge()
+	for i := 0; i < authentication.ChallengeMaxAttempts; i++ {
+		if _, err := usecase.SignInTotp(&users.UserChallengeReq{ChallengeToken: token, Code: "invalid"}); err == nil || err.Error() != "totp code is invalid" {
+			t.Fatalf("attempt %d expect: totp code is invalid, got: %v", i+1, err)
+		}
+	}
+	if _, err := usecase.SignInTotp(&users.UserChallengeReq{ChallengeToken: token, Code: code(1)}); err == nil || err.Error() != "challenge token is invalid" {
+		t.Errorf("after %d failures expect: challenge token is invalid, got: %v", authentication.ChallengeMaxAttempts, err)
+	}
+}